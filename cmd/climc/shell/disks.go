@@ -204,6 +204,20 @@ func init() {
 		printObject(disk)
 		return nil
 	})
+	type DiskMigrateStorageOptions struct {
+		DISK          string `help:"ID or name of disk"`
+		TargetStorage string `help:"ID or name of target storage" required:"true"`
+	}
+	R(&DiskMigrateStorageOptions{}, "disk-migrate-storage", "Live migrate disk of a running guest to another storage", func(s *mcclient.ClientSession, args *DiskMigrateStorageOptions) error {
+		params := jsonutils.NewDict()
+		params.Add(jsonutils.NewString(args.TargetStorage), "target_storage")
+		disk, err := modules.Disks.PerformAction(s, args.DISK, "migrate-storage", params)
+		if err != nil {
+			return err
+		}
+		printObject(disk)
+		return nil
+	})
 	type DiskResetOptions struct {
 		DISK      string `help:"ID or name of disk"`
 		SNAPSHOT  string `help:"snapshots ID of disk"`
@@ -0,0 +1,53 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+)
+
+func init() {
+	type ETagShowOptions struct {
+		MODULE string `help:"Resource type, e.g. servers, networks or loadbalancerlisteners"`
+		ID     string `help:"ID or Name of the resource"`
+	}
+	R(&ETagShowOptions{}, "etag-show", "Show the ETag of a resource for use with --if-match", func(s *mcclient.ClientSession, args *ETagShowOptions) error {
+		mod, err := modules.GetModule(s, args.MODULE)
+		if err != nil {
+			return err
+		}
+		emod, ok := mod.(interface {
+			GetWithETag(session *mcclient.ClientSession, id string, params jsonutils.JSONObject) (jsonutils.JSONObject, string, error)
+		})
+		if !ok {
+			return fmt.Errorf("%s does not support etags", args.MODULE)
+		}
+		id, err := mod.GetId(s, args.ID, nil)
+		if err != nil {
+			return err
+		}
+		_, etag, err := emod.GetWithETag(s, id, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Println(etag)
+		return nil
+	})
+}
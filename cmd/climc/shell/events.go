@@ -15,6 +15,8 @@
 package shell
 
 import (
+	"fmt"
+
 	"yunion.io/x/jsonutils"
 
 	"yunion.io/x/onecloud/pkg/mcclient"
@@ -95,9 +97,61 @@ func doEventList(man modules.ResourceManager, s *mcclient.ClientSession, args *E
 	return nil
 }
 
+type ResourceWatchOptions struct {
+	RESOURCE string   `help:"Resource to watch, e.g. servers"`
+	SinceSeq int64    `help:"Resume from the event of this sequence number, new events only if not set" default:"-1"`
+	Id       []string `help:"Watch these resources only"`
+	Project  string   `help:"Watch resources of this project, admin only"`
+	Admin    bool     `help:"Watch resources of all projects"`
+}
+
+type iWatchManager interface {
+	Watch(session *mcclient.ClientSession, params jsonutils.JSONObject, since int64, callback func(ev jsonutils.JSONObject) error) (int64, error)
+}
+
+func doResourceWatch(s *mcclient.ClientSession, args *ResourceWatchOptions) error {
+	mod, err := modules.GetModule(s, args.RESOURCE)
+	if err != nil {
+		return err
+	}
+	man, ok := mod.(iWatchManager)
+	if !ok {
+		return fmt.Errorf("%s cannot be watched", args.RESOURCE)
+	}
+	params := jsonutils.NewDict()
+	if len(args.Id) > 0 {
+		params.Add(jsonutils.NewStringArray(args.Id), "id")
+	}
+	if len(args.Project) > 0 {
+		params.Add(jsonutils.NewString(args.Project), "project")
+	}
+	if args.Admin || len(args.Project) > 0 {
+		params.Add(jsonutils.JSONTrue, "admin")
+	}
+	since := args.SinceSeq
+	for {
+		// the server ends a stream after a while, resume from the last event
+		since, err = man.Watch(s, params, since, func(ev jsonutils.JSONObject) error {
+			seq, _ := ev.Int("seq")
+			event, _ := ev.GetString("event")
+			action, _ := ev.GetString("action")
+			objName, _ := ev.GetString("obj_name")
+			objId, _ := ev.GetString("obj_id")
+			opsTime, _ := ev.GetString("ops_time")
+			fmt.Printf("%d\t%s\t%s\t%s\t%s(%s)\n", seq, opsTime, event, action, objName, objId)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
 func init() {
 	R(&EventListOptions{}, "event-show", "Show operation event logs", doComputeEventList)
 
+	R(&ResourceWatchOptions{}, "resource-watch", "Watch create, update and delete events of resources", doResourceWatch)
+
 	R(&TypeEventListOptions{}, "server-event", "Show operation event logs of server", func(s *mcclient.ClientSession, args *TypeEventListOptions) error {
 		nargs := EventListOptions{BaseEventListOptions: args.BaseEventListOptions, Id: args.ID, Type: []string{"server"}}
 		return doComputeEventList(s, &nargs)
@@ -0,0 +1,100 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"os"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/mcclient/options"
+)
+
+func init() {
+	type GuestImageListOptions struct {
+		options.BaseListOptions
+	}
+	R(&GuestImageListOptions{}, "guest-image-list", "List guest images imported from OVA", func(s *mcclient.ClientSession, args *GuestImageListOptions) error {
+		params, err := args.BaseListOptions.Params()
+		if err != nil {
+			return err
+		}
+		result, err := modules.GuestImages.List(s, params)
+		if err != nil {
+			return err
+		}
+		printList(result, modules.GuestImages.GetColumns(s))
+		return nil
+	})
+
+	type GuestImageOptions struct {
+		ID string `help:"Guest image id or name" metavar:"GUEST_IMAGE"`
+	}
+	R(&GuestImageOptions{}, "guest-image-show", "Show details of a guest image", func(s *mcclient.ClientSession, args *GuestImageOptions) error {
+		result, err := modules.GuestImages.Get(s, args.ID, nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&GuestImageOptions{}, "guest-image-delete", "Delete a guest image and images of its disks", func(s *mcclient.ClientSession, args *GuestImageOptions) error {
+		result, err := modules.GuestImages.Delete(s, args.ID, nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type GuestImageUploadOptions struct {
+		NAME   string `help:"Guest image name"`
+		FILE   string `help:"The local OVA package to upload"`
+		OsType string `help:"Type of OS, detected from OVF descriptor if omitted" choices:"Windows|Linux|Freebsd|Android|macOS|VMWare"`
+		Public bool   `help:"Make guest image public"`
+		Desc   string `help:"Description of guest image"`
+	}
+	R(&GuestImageUploadOptions{}, "guest-image-upload", "Import a local OVA package as guest image", func(s *mcclient.ClientSession, args *GuestImageUploadOptions) error {
+		params := jsonutils.NewDict()
+		params.Add(jsonutils.NewString(args.NAME), "name")
+		if len(args.OsType) > 0 {
+			params.Add(jsonutils.NewString(args.OsType), "os_type")
+		}
+		if args.Public {
+			params.Add(jsonutils.NewString("true"), "is-public")
+		}
+		if len(args.Desc) > 0 {
+			params.Add(jsonutils.NewString(args.Desc), "description")
+		}
+		f, err := os.Open(args.FILE)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		finfo, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		result, err := modules.GuestImages.Upload(s, params, f, finfo.Size())
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+}
@@ -184,6 +184,7 @@ func init() {
 		CpuReserved       int64   `help:"CPU reserved"`
 		HostType          string  `help:"Change host type, CAUTION!!!!" choices:"hypervisor|kubelet|esxi|baremetal"`
 		AccessIp          string  `help:"Change access ip, CAUTION!!!!"`
+		IpmiBmcType       string  `help:"Protocol talking to BMC of baremetal" choices:"ipmi|redfish"`
 	}
 	R(&HostUpdateOptions{}, "host-update", "Update information of a host", func(s *mcclient.ClientSession, args *HostUpdateOptions) error {
 		params := jsonutils.NewDict()
@@ -211,6 +212,9 @@ func init() {
 		if len(args.AccessIp) > 0 {
 			params.Add(jsonutils.NewString(args.AccessIp), "access_ip")
 		}
+		if len(args.IpmiBmcType) > 0 {
+			params.Add(jsonutils.NewString(args.IpmiBmcType), "ipmi_bmc_type")
+		}
 		if params.Size() == 0 {
 			return fmt.Errorf("Not data to update")
 		}
@@ -287,6 +291,40 @@ func init() {
 		return nil
 	})
 
+	type HostEvacuateStorageOptions struct {
+		ID            string `help:"ID or name of host"`
+		TargetStorage string `help:"ID or name of shared storage to move disks to"`
+	}
+	R(&HostEvacuateStorageOptions{}, "host-evacuate-storage", "Disable local storages of host and live migrate disks of running guests to shared storage", func(s *mcclient.ClientSession, args *HostEvacuateStorageOptions) error {
+		params := jsonutils.NewDict()
+		if len(args.TargetStorage) > 0 {
+			params.Add(jsonutils.NewString(args.TargetStorage), "target_storage")
+		}
+		result, err := modules.Hosts.PerformAction(s, args.ID, "evacuate-storage", params)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type HostDiskWipeOptions struct {
+		ID   string `help:"ID or name of host"`
+		Mode string `help:"Wipe mode, default is mode configured on baremetal agent" choices:"auto|overwrite"`
+	}
+	R(&HostDiskWipeOptions{}, "host-disk-wipe", "Reboot baremetal into PXE offline OS and wipe all disks", func(s *mcclient.ClientSession, args *HostDiskWipeOptions) error {
+		params := jsonutils.NewDict()
+		if len(args.Mode) > 0 {
+			params.Add(jsonutils.NewString(args.Mode), "mode")
+		}
+		result, err := modules.Hosts.PerformAction(s, args.ID, "disk-wipe", params)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
 	R(&HostDetailOptions{}, "host-unmaintenance", "Reboot host back into disk installed OS", func(s *mcclient.ClientSession, args *HostDetailOptions) error {
 		result, err := modules.Hosts.PerformAction(s, args.ID, "unmaintenance", nil)
 		if err != nil {
@@ -468,6 +506,7 @@ func init() {
 		IpmiUser   string `help:"IPMI user name"`
 		IpmiPasswd string `help:"IPMI user password"`
 		IpmiAddr   string `help:"IPMI IP address"`
+		BmcType    string `help:"Protocol talking to BMC, default is ipmi" choices:"ipmi|redfish"`
 	}
 	R(&HostCreateOptions{}, "host-create", "Create a baremetal host", func(s *mcclient.ClientSession, args *HostCreateOptions) error {
 		params := jsonutils.NewDict()
@@ -489,6 +528,9 @@ func init() {
 		if len(args.IpmiAddr) > 0 {
 			params.Add(jsonutils.NewString(args.IpmiAddr), "ipmi_ip_addr")
 		}
+		if len(args.BmcType) > 0 {
+			params.Add(jsonutils.NewString(args.BmcType), "ipmi_bmc_type")
+		}
 		result, err := modules.Hosts.Create(s, params)
 		if err != nil {
 			return err
@@ -70,6 +70,18 @@ func init() {
 		printObject(lbbackend)
 		return nil
 	})
+	R(&options.LoadbalancerBackendActionDrainOptions{}, "lbbackend-drain", "Drain lbbackend then remove it", func(s *mcclient.ClientSession, opts *options.LoadbalancerBackendActionDrainOptions) error {
+		params, err := options.StructToParams(opts)
+		if err != nil {
+			return err
+		}
+		lbbackend, err := modules.LoadbalancerBackends.PerformAction(s, opts.ID, "drain", params)
+		if err != nil {
+			return err
+		}
+		printObject(lbbackend)
+		return nil
+	})
 	R(&options.LoadbalancerBackendDeleteOptions{}, "lbbackend-purge", "Purge lbbackend", func(s *mcclient.ClientSession, opts *options.LoadbalancerBackendDeleteOptions) error {
 		lbbackend, err := modules.LoadbalancerBackends.PerformAction(s, opts.ID, "purge", nil)
 		if err != nil {
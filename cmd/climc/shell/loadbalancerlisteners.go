@@ -15,6 +15,8 @@
 package shell
 
 import (
+	"fmt"
+
 	"yunion.io/x/jsonutils"
 
 	"yunion.io/x/onecloud/pkg/mcclient"
@@ -56,6 +58,15 @@ func init() {
 	})
 	R(&options.LoadbalancerListenerUpdateOptions{}, "lblistener-update", "Update lblistener", func(s *mcclient.ClientSession, opts *options.LoadbalancerListenerUpdateOptions) error {
 		params, err := options.StructToParams(opts)
+		if len(opts.IfMatch) > 0 {
+			lblistener, etag, err := modules.LoadbalancerListeners.UpdateIfMatch(s, opts.ID, opts.IfMatch, params)
+			if err != nil {
+				return err
+			}
+			printObject(lblistener)
+			fmt.Println("ETag:", etag)
+			return nil
+		}
 		lblistener, err := modules.LoadbalancerListeners.Update(s, opts.ID, params)
 		if err != nil {
 			return err
@@ -91,6 +102,18 @@ func init() {
 		printObject(lblistener)
 		return nil
 	})
+	R(&options.LoadbalancerListenerActionShiftWeightOptions{}, "lblistener-shift-weight", "Shift lblistener traffic to another backend group in steps", func(s *mcclient.ClientSession, opts *options.LoadbalancerListenerActionShiftWeightOptions) error {
+		params, err := options.StructToParams(opts)
+		if err != nil {
+			return err
+		}
+		lblistener, err := modules.LoadbalancerListeners.PerformAction(s, opts.ID, "shift-weight", params)
+		if err != nil {
+			return err
+		}
+		printObject(lblistener)
+		return nil
+	})
 	R(&options.LoadbalancerListenerActionSyncStatusOptions{}, "lblistener-syncstatus", "Sync lblistener status", func(s *mcclient.ClientSession, opts *options.LoadbalancerListenerActionSyncStatusOptions) error {
 		lblistener, err := modules.LoadbalancerListeners.PerformAction(s, opts.ID, "syncstatus", nil)
 		if err != nil {
@@ -70,6 +70,7 @@ func init() {
 		VlanId      int64  `help:"Vlan ID" default:"1"`
 		ExternalId  string `help:"External ID"`
 		AllocPolicy string `help:"Address allocation policy" choices:"none|stepdown|stepup|random"`
+		IfMatch     string `help:"Only update if the network still has this ETag, see etag-show"`
 	}
 	R(&NetworkUpdateOptions{}, "network-update", "Update network", func(s *mcclient.ClientSession, args *NetworkUpdateOptions) error {
 		params := jsonutils.NewDict()
@@ -127,6 +128,15 @@ func init() {
 		if params.Size() == 0 {
 			return InvalidUpdateError()
 		}
+		if len(args.IfMatch) > 0 {
+			result, etag, err := modules.Networks.UpdateIfMatch(s, args.ID, args.IfMatch, params)
+			if err != nil {
+				return err
+			}
+			printObject(result)
+			fmt.Println("ETag:", etag)
+			return nil
+		}
 		result, err := modules.Networks.Update(s, args.ID, params)
 		if err != nil {
 			return err
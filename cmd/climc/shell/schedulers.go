@@ -57,6 +57,25 @@ func init() {
 			return nil
 		})
 
+	R(&options.SchedulerCapacityOptions{}, "scheduler-capacity", "Report how many servers of a spec still fit, by host and limiting resource",
+		func(s *mcclient.ClientSession, args *options.SchedulerCapacityOptions) error {
+			params, err := args.Params(s)
+			if err != nil {
+				return err
+			}
+			result, err := modules.SchedManager.Capacity(s, params)
+			if err != nil {
+				return err
+			}
+			hosts, _ := result.GetArray("hosts")
+			printList(&modules.ListResult{Data: hosts, Total: len(hosts)}, []string{"id", "name", "zone_id", "capacity", "bottleneck", "reason"})
+			bottlenecks, _ := result.GetArray("bottlenecks")
+			printList(&modules.ListResult{Data: bottlenecks, Total: len(bottlenecks)}, []string{"filter", "host_count", "capacity"})
+			total, _ := result.Int("total")
+			fmt.Printf("Total: %d\n", total)
+			return nil
+		})
+
 	type SchedulerCandidateListOptions struct {
 		Type   string `help:"Sched type filter" choices:"baremetal|host"`
 		Region string `help:"Cloud region ID"`
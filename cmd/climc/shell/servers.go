@@ -320,6 +320,19 @@ func init() {
 		return nil
 	})
 
+	R(&options.ServerConvertToKvmOptions{}, "server-convert-to-kvm", "Convert esxi server to kvm server", func(s *mcclient.ClientSession, opts *options.ServerConvertToKvmOptions) error {
+		params, err := options.StructToParams(opts)
+		if err != nil {
+			return err
+		}
+		ret, err := modules.Servers.PerformAction(s, opts.ID, "convert-to-kvm", params)
+		if err != nil {
+			return err
+		}
+		printObject(ret)
+		return nil
+	})
+
 	R(&options.ServerResetOptions{}, "server-reset", "Reset servers", func(s *mcclient.ClientSession, opts *options.ServerResetOptions) error {
 		params, err := options.StructToParams(opts)
 		if err != nil {
@@ -397,6 +410,18 @@ func init() {
 		if params.Size() == 0 {
 			return InvalidUpdateError()
 		}
+		if len(opts.IfMatch) > 0 {
+			if len(opts.ID) != 1 {
+				return fmt.Errorf("--if-match applies to a single server")
+			}
+			srv, etag, err := modules.Servers.UpdateIfMatch(s, opts.ID[0], opts.IfMatch, params)
+			if err != nil {
+				return err
+			}
+			printObject(srv)
+			fmt.Println("ETag:", etag)
+			return nil
+		}
 		result := modules.Servers.BatchPut(s, opts.ID, params)
 		printBatchResults(result, modules.Servers.GetColumns(s))
 		return nil
@@ -94,12 +94,14 @@ func init() {
 		ZONE         string `help:"Zone id of storage"`
 		Capacity     int64  `help:"Capacity of the Storage"`
 		MediumType   string `help:"Medium type, either ssd or rotate" choices:"ssd|rotate"`
-		StorageType  string `help:"Storage type" choices:"local|nas|vsan|rbd|nfs|baremetal"`
+		StorageType  string `help:"Storage type" choices:"local|nas|vsan|rbd|nfs|clvm|baremetal"`
 		MonHost      string `help:"Ceph mon_host config"`
 		Key          string `help:"Ceph key config"`
 		Pool         string `help:"Ceph Poll Name"`
 		NfsHost      string `help:"NFS host"`
 		NfsSharedDir string `help:"NFS shared dir"`
+		ClvmVgName   string `help:"Shared volume group of clvm storage"`
+		ClvmThinPool string `help:"Thin pool in volume group for clvm snapshots"`
 	}
 	R(&StorageCreateOptions{}, "storage-create", "Create a Storage", func(s *mcclient.ClientSession, args *StorageCreateOptions) error {
 		params := jsonutils.NewDict()
@@ -121,6 +123,14 @@ func init() {
 			}
 			params.Add(jsonutils.NewString(args.NfsHost), "nfs_host")
 			params.Add(jsonutils.NewString(args.NfsSharedDir), "nfs_shared_dir")
+		} else if args.StorageType == "clvm" {
+			if len(args.ClvmVgName) == 0 {
+				return fmt.Errorf("Storage type clvm missing volume group")
+			}
+			params.Add(jsonutils.NewString(args.ClvmVgName), "clvm_vg_name")
+			if len(args.ClvmThinPool) > 0 {
+				params.Add(jsonutils.NewString(args.ClvmThinPool), "clvm_thin_pool")
+			}
 		}
 		storage, err := modules.Storages.Create(s, params)
 		if err != nil {
@@ -169,6 +179,23 @@ func init() {
 		return nil
 	})
 
+	type StorageDecommissionOptions struct {
+		ID            string `help:"ID or name of storage"`
+		TargetStorage string `help:"ID or name of storage to move disks to, default least used storage on guest host"`
+	}
+	R(&StorageDecommissionOptions{}, "storage-decommission", "Disable a storage and live migrate disks of running guests off it", func(s *mcclient.ClientSession, args *StorageDecommissionOptions) error {
+		params := jsonutils.NewDict()
+		if len(args.TargetStorage) > 0 {
+			params.Add(jsonutils.NewString(args.TargetStorage), "target_storage")
+		}
+		result, err := modules.Storages.PerformAction(s, args.ID, "decommission", params)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
 	R(&StorageShowOptions{}, "storage-online", "Online a storage", func(s *mcclient.ClientSession, args *StorageShowOptions) error {
 		result, err := modules.Storages.PerformAction(s, args.ID, "online", nil)
 		if err != nil {
@@ -73,4 +73,13 @@ func init() {
 		printObject(result)
 		return nil
 	})
+
+	R(&TaskShowOptions{}, "region-task-cancel", "Cancel a region task, its object is rolled back by the failure handler of current stage", func(s *mcclient.ClientSession, args *TaskShowOptions) error {
+		result, err := modules.ComputeTasks.PerformAction(s, args.ID, "cancel", nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
 }
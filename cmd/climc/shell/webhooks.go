@@ -0,0 +1,204 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/mcclient/options"
+)
+
+func init() {
+	type WebhookList struct {
+		options.BaseListOptions
+	}
+	R(&WebhookList{}, "webhook-list", "List webhooks", func(s *mcclient.ClientSession, args *WebhookList) error {
+		params, err := args.BaseListOptions.Params()
+		if err != nil {
+			return err
+		}
+		result, err := modules.Webhooks.List(s, params)
+		if err != nil {
+			return err
+		}
+		printList(result, modules.Webhooks.GetColumns(s))
+		return nil
+	})
+
+	type WebhookCreate struct {
+		NAME          string `help:"Name of webhook"`
+		URL           string `help:"Https url the events are posted to"`
+		SECRET        string `help:"Secret to sign the payloads with, at least 16 characters"`
+		ResourceTypes string `help:"Comma separated resource types to receive events of, e.g. server,disk, default all"`
+		Actions       string `help:"Comma separated actions to receive events of, e.g. create,delete, default all"`
+		Result        string `help:"Receive events of succeeded or failed operations only" choices:"any|success|failure"`
+		AllProjects   bool   `help:"Receive events of all projects, admin only"`
+		Desc          string `help:"Description"`
+	}
+	R(&WebhookCreate{}, "webhook-create", "Create a webhook", func(s *mcclient.ClientSession, args *WebhookCreate) error {
+		params := jsonutils.NewDict()
+		params.Add(jsonutils.NewString(args.NAME), "name")
+		params.Add(jsonutils.NewString(args.URL), "url")
+		params.Add(jsonutils.NewString(args.SECRET), "secret")
+		if len(args.ResourceTypes) > 0 {
+			params.Add(jsonutils.NewString(args.ResourceTypes), "resource_types")
+		}
+		if len(args.Actions) > 0 {
+			params.Add(jsonutils.NewString(args.Actions), "actions")
+		}
+		if len(args.Result) > 0 {
+			params.Add(jsonutils.NewString(args.Result), "result")
+		}
+		if args.AllProjects {
+			params.Add(jsonutils.JSONTrue, "all_projects")
+		}
+		if len(args.Desc) > 0 {
+			params.Add(jsonutils.NewString(args.Desc), "description")
+		}
+		result, err := modules.Webhooks.Create(s, params)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type WebhookUpdate struct {
+		ID            string `help:"ID or name of webhook"`
+		Name          string `help:"New name of webhook"`
+		Url           string `help:"Https url the events are posted to"`
+		ResourceTypes string `help:"Comma separated resource types to receive events of"`
+		Actions       string `help:"Comma separated actions to receive events of"`
+		Result        string `help:"Receive events of succeeded or failed operations only" choices:"any|success|failure"`
+		Enable        bool   `help:"Enable the webhook"`
+		Disable       bool   `help:"Disable the webhook"`
+		Desc          string `help:"Description"`
+	}
+	R(&WebhookUpdate{}, "webhook-update", "Update a webhook", func(s *mcclient.ClientSession, args *WebhookUpdate) error {
+		params := jsonutils.NewDict()
+		if len(args.Name) > 0 {
+			params.Add(jsonutils.NewString(args.Name), "name")
+		}
+		if len(args.Url) > 0 {
+			params.Add(jsonutils.NewString(args.Url), "url")
+		}
+		if len(args.ResourceTypes) > 0 {
+			params.Add(jsonutils.NewString(args.ResourceTypes), "resource_types")
+		}
+		if len(args.Actions) > 0 {
+			params.Add(jsonutils.NewString(args.Actions), "actions")
+		}
+		if len(args.Result) > 0 {
+			params.Add(jsonutils.NewString(args.Result), "result")
+		}
+		if args.Enable {
+			params.Add(jsonutils.JSONTrue, "enabled")
+		} else if args.Disable {
+			params.Add(jsonutils.JSONFalse, "enabled")
+		}
+		if len(args.Desc) > 0 {
+			params.Add(jsonutils.NewString(args.Desc), "description")
+		}
+		result, err := modules.Webhooks.Update(s, args.ID, params)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type WebhookSetSecret struct {
+		ID     string `help:"ID or name of webhook"`
+		SECRET string `help:"New secret, at least 16 characters"`
+	}
+	R(&WebhookSetSecret{}, "webhook-set-secret", "Change the secret a webhook signs payloads with", func(s *mcclient.ClientSession, args *WebhookSetSecret) error {
+		params := jsonutils.NewDict()
+		params.Add(jsonutils.NewString(args.SECRET), "secret")
+		result, err := modules.Webhooks.PerformAction(s, args.ID, "set-secret", params)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type WebhookShow struct {
+		ID string `help:"ID or name of webhook"`
+	}
+	R(&WebhookShow{}, "webhook-show", "Show details of a webhook", func(s *mcclient.ClientSession, args *WebhookShow) error {
+		result, err := modules.Webhooks.Get(s, args.ID, nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&WebhookShow{}, "webhook-delete", "Delete a webhook", func(s *mcclient.ClientSession, args *WebhookShow) error {
+		result, err := modules.Webhooks.Delete(s, args.ID, nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type WebhookDeliveryList struct {
+		options.BaseListOptions
+		Webhook string `help:"List deliveries of the webhook"`
+		Status  string `help:"List deliveries of the status" choices:"pending|succeeded|failed"`
+	}
+	R(&WebhookDeliveryList{}, "webhook-delivery-list", "List webhook deliveries", func(s *mcclient.ClientSession, args *WebhookDeliveryList) error {
+		params, err := args.BaseListOptions.Params()
+		if err != nil {
+			return err
+		}
+		if len(args.Webhook) > 0 {
+			params.Add(jsonutils.NewString(args.Webhook), "webhook")
+		}
+		if len(args.Status) > 0 {
+			params.Add(jsonutils.NewString(args.Status), "status")
+		}
+		result, err := modules.WebhookDeliveries.List(s, params)
+		if err != nil {
+			return err
+		}
+		printList(result, modules.WebhookDeliveries.GetColumns(s))
+		return nil
+	})
+
+	type WebhookDeliveryShow struct {
+		ID string `help:"ID of webhook delivery"`
+	}
+	R(&WebhookDeliveryShow{}, "webhook-delivery-show", "Show details of a webhook delivery", func(s *mcclient.ClientSession, args *WebhookDeliveryShow) error {
+		result, err := modules.WebhookDeliveries.Get(s, args.ID, nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&WebhookDeliveryShow{}, "webhook-delivery-redeliver", "Post a webhook delivery again", func(s *mcclient.ClientSession, args *WebhookDeliveryShow) error {
+		result, err := modules.WebhookDeliveries.PerformAction(s, args.ID, "redeliver", nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+}
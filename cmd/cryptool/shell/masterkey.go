@@ -0,0 +1,36 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"fmt"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/keymanager"
+	"yunion.io/x/onecloud/pkg/util/shellutils"
+)
+
+func init() {
+	type MasterKeyRotateOptions struct {
+		FILE string `help:"master key file, created if not exist"`
+	}
+	shellutils.R(&MasterKeyRotateOptions{}, "master-key-rotate", "Generate a new primary master key, stored secrets are re-wrapped with it when region service restarts", func(args *MasterKeyRotateOptions) error {
+		keyId, err := keymanager.RotateMasterKeyFile(args.FILE)
+		if err != nil {
+			return err
+		}
+		fmt.Println(keyId)
+		return nil
+	})
+}
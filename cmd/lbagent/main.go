@@ -47,6 +47,7 @@ func main() {
 	var haproxyHelper *lbagent.HaproxyHelper
 	var apiHelper *lbagent.ApiHelper
 	var haStateWatcher *lbagent.HaStateWatcher
+	var accessLogCollector *lbagent.AccessLogCollector
 	var err error
 	{
 		haStateWatcher, err = lbagent.NewHaStateWatcher(opts)
@@ -67,6 +68,12 @@ func main() {
 		}
 		apiHelper.SetHaStateProvider(haStateWatcher)
 	}
+	{
+		accessLogCollector, err = lbagent.NewAccessLogCollector(opts)
+		if err != nil {
+			log.Fatalf("init access log collector failed: %s", err)
+		}
+	}
 
 	{
 		wg := &sync.WaitGroup{}
@@ -74,10 +81,11 @@ func main() {
 		ctx, cancelFunc := context.WithCancel(context.Background())
 		ctx = context.WithValue(ctx, "wg", wg)
 		ctx = context.WithValue(ctx, "cmdChan", cmdChan)
-		wg.Add(3)
+		wg.Add(4)
 		go haStateWatcher.Run(ctx)
 		go haproxyHelper.Run(ctx)
 		go apiHelper.Run(ctx)
+		go accessLogCollector.Run(ctx)
 
 		go func() {
 			sigChan := make(chan os.Signal)
@@ -15,6 +15,8 @@
 package compute
 
 import (
+	"time"
+
 	"yunion.io/x/jsonutils"
 
 	"yunion.io/x/onecloud/pkg/apis"
@@ -27,6 +29,20 @@ type SchedtagConfig struct {
 	Strategy string `json:"strategy"`
 }
 
+// TopologySpreadConfig spreads guests of a group over topology domains, the
+// difference of guest count between any two domains does not exceed MaxSkew
+type TopologySpreadConfig struct {
+	apis.Meta
+
+	GroupId string `json:"group_id"`
+	// TopologyKey is rack, zone or schedtag:<key>
+	TopologyKey string `json:"topology_key"`
+	MaxSkew     int    `json:"max_skew"`
+	// Strategy is require to refuse hosts breaking MaxSkew, or prefer to
+	// only rank hosts of less loaded domains higher
+	Strategy string `json:"strategy"`
+}
+
 type NetworkConfig struct {
 	apis.Meta
 
@@ -105,6 +121,78 @@ type BaremetalDiskConfig struct {
 	Direct       *bool   `json:"direct,omitempty"`
 }
 
+// BaremetalDiskWipeCertificate records how a disk of baremetal was wiped
+// when the baremetal was released
+type BaremetalDiskWipeCertificate struct {
+	Dev    string `json:"dev"`
+	Driver string `json:"driver"`
+	Model  string `json:"model"`
+	Serial string `json:"serial"`
+	SizeMB int64  `json:"size_mb"`
+	// Health is SMART overall health assessment before wipe
+	Health string `json:"health"`
+
+	Method   string    `json:"method"`
+	Passes   int       `json:"passes,omitempty"`
+	StartAt  time.Time `json:"start_at"`
+	FinishAt time.Time `json:"finish_at"`
+	// Verified is set if sampled blocks hold no data written before wipe
+	Verified bool   `json:"verified"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// BaremetalInventory is hardware inventory of baremetal reported by BMC
+type BaremetalInventory struct {
+	Manufacture string `json:"manufacture"`
+	Model       string `json:"model"`
+	SN          string `json:"sn"`
+	UUID        string `json:"uuid"`
+	BiosVersion string `json:"bios_version"`
+	CpuCount    int    `json:"cpu_count"`
+	CpuDesc     string `json:"cpu_desc"`
+	MemMb       int    `json:"mem_mb"`
+}
+
+type BaremetalFirmware struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// BaremetalSensor is a reading of BMC sensor, Status is evaluated against
+// thresholds of BMC, or of baremetal agent for temperatures if BMC has none
+type BaremetalSensor struct {
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	Reading float64 `json:"reading"`
+	Unit    string  `json:"unit,omitempty"`
+	// State is asserted states of discrete sensors, e.g. power supplies
+	State  string `json:"state,omitempty"`
+	Status string `json:"status"`
+
+	LowerCritical float64 `json:"lower_critical,omitempty"`
+	UpperWarning  float64 `json:"upper_warning,omitempty"`
+	UpperCritical float64 `json:"upper_critical,omitempty"`
+}
+
+// BaremetalSelEvent is an entry of BMC system event log
+type BaremetalSelEvent struct {
+	Id       int    `json:"id"`
+	Time     string `json:"time"`
+	Sensor   string `json:"sensor"`
+	Event    string `json:"event"`
+	Asserted bool   `json:"asserted"`
+	Severity string `json:"severity"`
+}
+
+// BaremetalTelemetry is collected from BMC periodically by baremetal agent
+type BaremetalTelemetry struct {
+	Inventory   *BaremetalInventory `json:"inventory,omitempty"`
+	Firmwares   []BaremetalFirmware `json:"firmwares"`
+	Sensors     []BaremetalSensor   `json:"sensors"`
+	SelEvents   []BaremetalSelEvent `json:"sel_events"`
+	CollectedAt time.Time           `json:"collected_at"`
+}
+
 type ServerConfigs struct {
 	// prefer options
 	PreferRegion     string `json:"prefer_region_id"`
@@ -127,6 +215,13 @@ type ServerConfigs struct {
 	IsolatedDevices      []*IsolatedDeviceConfig `json:"isolated_devices"`
 	BaremetalDiskConfigs []*BaremetalDiskConfig  `json:"baremetal_disk_configs"`
 
+	TopologySpreads []*TopologySpreadConfig `json:"topology_spreads"`
+
+	// PriorityClass is low, normal or high
+	PriorityClass string `json:"priority_class"`
+	// PreemptionPolicy is never, plan or execute
+	PreemptionPolicy string `json:"preemption_policy"`
+
 	// DEPRECATE
 	Suggestion bool `json:"suggestion"`
 }
@@ -170,6 +265,14 @@ type ServerCreateInput struct {
 	EipChargeType      string          `json:"eip_charge_type,omitempty"`
 	Eip                string          `json:"eip,omitempty"`
 
+	// Groups the server joins after created, spread configs of groups are
+	// appended to TopologySpreads
+	Groups []string `json:"groups"`
+
+	// GuestImageId refers to a multi-disk guest image imported from OVA,
+	// its disks and hardware hints are filled into the input
+	GuestImageId string `json:"guest_image_id"`
+
 	OsType string `json:"os_type"`
 	// Fill by server
 	OsProfile    jsonutils.JSONObject `json:"__os_profile__"`
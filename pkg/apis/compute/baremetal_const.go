@@ -38,6 +38,41 @@ const (
 
 	HDD_DISK_SPEC_TYPE = "HDD"
 	SSD_DISK_SPEC_TYPE = "SSD"
+
+	DISK_WIPE_MODE_NONE = "none"
+	// DISK_WIPE_MODE_AUTO prefers secure erase, then TRIM, then overwrite
+	DISK_WIPE_MODE_AUTO      = "auto"
+	DISK_WIPE_MODE_OVERWRITE = "overwrite"
+
+	DISK_WIPE_METHOD_NVME_FORMAT      = "nvme_format"
+	DISK_WIPE_METHOD_ATA_SECURE_ERASE = "ata_secure_erase"
+	DISK_WIPE_METHOD_TRIM             = "trim"
+	DISK_WIPE_METHOD_OVERWRITE        = "overwrite"
+
+	// status of the last disk wipe of a baremetal, recorded in host metadata
+	DISK_WIPE_STATUS_WIPING   = "wiping"
+	DISK_WIPE_STATUS_VERIFIED = "verified"
+	DISK_WIPE_STATUS_FAILED   = "failed"
+
+	DISK_HEALTH_PASSED  = "passed"
+	DISK_HEALTH_FAILED  = "failed"
+	DISK_HEALTH_UNKNOWN = "unknown"
+
+	// status of BMC sensors and severity of SEL events
+	HARDWARE_STATUS_OK       = "ok"
+	HARDWARE_STATUS_WARNING  = "warning"
+	HARDWARE_STATUS_CRITICAL = "critical"
+	HARDWARE_STATUS_UNKNOWN  = "unknown"
+
+	SENSOR_TYPE_TEMPERATURE  = "temperature"
+	SENSOR_TYPE_FAN          = "fan"
+	SENSOR_TYPE_VOLTAGE      = "voltage"
+	SENSOR_TYPE_POWER_SUPPLY = "power_supply"
+	SENSOR_TYPE_OTHER        = "other"
+
+	// events notified when baremetal hardware fails
+	BAREMETAL_SEL_CRITICAL    = "sel_critical"
+	BAREMETAL_SENSOR_CRITICAL = "sensor_critical"
 )
 
 var (
@@ -62,7 +97,18 @@ var (
 		DISK_DRIVER_MARVELRAID,
 	)
 
-	DISK_DRIVERS = sets.NewString(
+	// DISK_DRIVERS_SOFT_RAID are drivers of disks not behind a raid
+	// controller, raid of them is built with linux software raid
+	DISK_DRIVERS_SOFT_RAID = sets.NewString(
 		DISK_DRIVER_LINUX,
-		DISK_DRIVER_PCIE).Union(DISK_DRIVERS_RAID)
+		DISK_DRIVER_PCIE,
+	)
+
+	DISK_WIPE_MODES = sets.NewString(
+		DISK_WIPE_MODE_NONE,
+		DISK_WIPE_MODE_AUTO,
+		DISK_WIPE_MODE_OVERWRITE,
+	)
+
+	DISK_DRIVERS = DISK_DRIVERS_SOFT_RAID.Union(DISK_DRIVERS_RAID)
 )
@@ -42,6 +42,9 @@ const (
 	DISK_POST_MIGRATE  = "post_migrate"
 	DISK_MIGRATING     = "migrating"
 
+	DISK_START_MIGRATE_STORAGE = "start_migrate_storage"
+	DISK_MIGRATING_STORAGE     = "migrating_storage"
+
 	DISK_START_SNAPSHOT = "start_snapshot"
 	DISK_SNAPSHOTING    = "snapshoting"
 
@@ -67,6 +67,9 @@ const (
 	VM_MIGRATING      = "migrating"
 	VM_MIGRATE_FAILED = "migrate_failed"
 
+	VM_MIGRATE_STORAGE        = "migrate_storage"
+	VM_MIGRATE_STORAGE_FAILED = "migrate_storage_fail"
+
 	VM_CHANGE_FLAVOR      = "change_flavor"
 	VM_CHANGE_FLAVOR_FAIL = "change_flavor_fail"
 	VM_REBUILD_ROOT       = "rebuild_root"
@@ -107,6 +110,9 @@ const (
 	VM_IMPORT        = "import"
 	VM_IMPORT_FAILED = "import_fail"
 
+	VM_CONVERTING     = "converting"
+	VM_CONVERT_FAILED = "convert_fail"
+
 	SHUTDOWN_STOP      = "stop"
 	SHUTDOWN_TERMINATE = "terminate"
 
@@ -129,6 +135,39 @@ const (
 	HYPERVISOR_DEFAULT = HYPERVISOR_KVM
 )
 
+const (
+	GUEST_PRIORITY_CLASS_LOW    = "low"
+	GUEST_PRIORITY_CLASS_NORMAL = "normal"
+	GUEST_PRIORITY_CLASS_HIGH   = "high"
+
+	// PREEMPTION_POLICY_NEVER never preempts other servers
+	PREEMPTION_POLICY_NEVER = "never"
+	// PREEMPTION_POLICY_PLAN reports lower priority servers to preempt when schedule fails
+	PREEMPTION_POLICY_PLAN = "plan"
+	// PREEMPTION_POLICY_EXECUTE stops lower priority servers and schedules again
+	PREEMPTION_POLICY_EXECUTE = "execute"
+)
+
+var GUEST_PRIORITY_CLASSES = map[string]int{
+	GUEST_PRIORITY_CLASS_LOW:    0,
+	GUEST_PRIORITY_CLASS_NORMAL: 100,
+	GUEST_PRIORITY_CLASS_HIGH:   1000,
+}
+
+var PREEMPTION_POLICIES = []string{
+	PREEMPTION_POLICY_NEVER,
+	PREEMPTION_POLICY_PLAN,
+	PREEMPTION_POLICY_EXECUTE,
+}
+
+// GuestPriority returns priority value of class, unknown class is treated as normal
+func GuestPriority(class string) int {
+	if priority, ok := GUEST_PRIORITY_CLASSES[class]; ok {
+		return priority
+	}
+	return GUEST_PRIORITY_CLASSES[GUEST_PRIORITY_CLASS_NORMAL]
+}
+
 var VM_RUNNING_STATUS = []string{VM_START_START, VM_STARTING, VM_RUNNING, VM_BLOCK_STREAM}
 var VM_CREATING_STATUS = []string{VM_CREATE_NETWORK, VM_CREATE_DISK, VM_START_DEPLOY, VM_DEPLOYING}
 
@@ -198,4 +237,8 @@ const (
 	VM_METADATA_OS_DISTRO           = "os_distribution"
 	VM_METADATA_OS_NAME             = "os_name"
 	VM_METADATA_OS_VERSION          = "os_version"
+
+	// VM_METADATA_CONVERTED_FROM keeps external id of the source vm of a
+	// guest converted to kvm, the source vm is skipped by cloud sync
+	VM_METADATA_CONVERTED_FROM = "__converted_from"
 )
@@ -0,0 +1,40 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compute
+
+// SEsxiAccessInfo is used by host to download disks of an esxi guest,
+// Password is encrypted with id of the guest
+type SEsxiAccessInfo struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Account  string `json:"account"`
+	Password string `json:"password"`
+}
+
+type SEsxiConvertDisk struct {
+	DiskId string `json:"disk_id"`
+	// DatastoreId is external id of the storage holding the disk
+	DatastoreId string `json:"datastore_id"`
+	// Path is access path of the vmdk descriptor in the datastore
+	Path            string `json:"path"`
+	TargetStorageId string `json:"target_storage_id"`
+}
+
+// SEsxiConvertConfig is sent to the kvm host converting an esxi guest, root
+// disk comes first in Disks
+type SEsxiConvertConfig struct {
+	VCenter SEsxiAccessInfo    `json:"vcenter"`
+	Disks   []SEsxiConvertDisk `json:"disks"`
+}
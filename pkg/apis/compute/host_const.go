@@ -43,6 +43,9 @@ const (
 	NIC_TYPE_ADMIN = "admin"
 	// #NIC_TYPE_NORMAL = 'normal'
 
+	BMC_TYPE_IPMI    = "ipmi"
+	BMC_TYPE_REDFISH = "redfish"
+
 	BAREMETAL_INIT           = "init"
 	BAREMETAL_PREPARE        = "prepare"
 	BAREMETAL_PREPARE_FAIL   = "prepare_fail"
@@ -61,6 +64,8 @@ const (
 	BAREMETAL_CONVERTING     = "converting"
 	BAREMETAL_START_FAIL     = "start_fail"
 	BAREMETAL_STOP_FAIL      = "stop_fail"
+	BAREMETAL_WIPING         = "wiping"
+	BAREMETAL_WIPE_FAIL      = "wipe_fail"
 
 	HOST_STATUS_RUNNING = BAREMETAL_RUNNING
 	HOST_STATUS_READY   = BAREMETAL_READY
@@ -77,3 +82,5 @@ const (
 var HOST_TYPES = []string{HOST_TYPE_BAREMETAL, HOST_TYPE_HYPERVISOR, HOST_TYPE_ESXI, HOST_TYPE_KUBELET, HOST_TYPE_XEN, HOST_TYPE_ALIYUN, HOST_TYPE_AZURE, HOST_TYPE_AWS, HOST_TYPE_QCLOUD, HOST_TYPE_HUAWEI, HOST_TYPE_OPENSTACK, HOST_TYPE_UCLOUD}
 
 var NIC_TYPES = []string{NIC_TYPE_IPMI, NIC_TYPE_ADMIN}
+
+var BMC_TYPES = []string{BMC_TYPE_IPMI, BMC_TYPE_REDFISH}
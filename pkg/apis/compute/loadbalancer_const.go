@@ -58,11 +58,14 @@ const (
 	LB_STATUS_STOP_FAILED  = "stop_failed"
 
 	LB_STATUS_UNKNOWN = "unknown"
+
+	LB_STATUS_DRAINING = "draining"
 )
 
 var LB_STATUS_SPEC = choices.NewChoices(
 	LB_STATUS_ENABLED,
 	LB_STATUS_DISABLED,
+	LB_STATUS_DRAINING,
 )
 
 const (
@@ -333,6 +336,17 @@ var LB_HA_STATES = choices.NewChoices(
 	LB_HA_STATE_UNKNOWN,
 )
 
+const (
+	// percentage of listener traffic shifted to the target backend group
+	LB_SHIFT_WEIGHT_MIN = 0
+	LB_SHIFT_WEIGHT_MAX = 100
+
+	LB_SHIFT_STEP_DEFAULT     = 10
+	LB_SHIFT_INTERVAL_DEFAULT = 60
+
+	LB_DRAIN_TIMEOUT_DEFAULT = 300
+)
+
 const (
 	LBAGENT_QUERY_ORIG_KEY = "_orig"
 	LBAGENT_QUERY_ORIG_VAL = "lbagent"
@@ -24,4 +24,13 @@ const (
 	CONTAINER_AGGREGATE = "container"
 )
 
+const (
+	TOPOLOGY_KEY_RACK = "rack"
+	TOPOLOGY_KEY_ZONE = "zone"
+	// hosts with schedtag named <key>.<domain> are in the domain of topology
+	// schedtag:<key>
+	TOPOLOGY_KEY_SCHEDTAG_PREFIX = "schedtag:"
+	TOPOLOGY_SCHEDTAG_SEP        = "."
+)
+
 var STRATEGY_LIST = []string{STRATEGY_REQUIRE, STRATEGY_EXCLUDE, STRATEGY_PREFER, STRATEGY_AVOID}
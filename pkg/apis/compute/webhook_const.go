@@ -0,0 +1,33 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compute
+
+const (
+	WEBHOOK_RESULT_ANY     = "any"
+	WEBHOOK_RESULT_SUCCESS = "success"
+	WEBHOOK_RESULT_FAILURE = "failure"
+
+	WEBHOOK_DELIVERY_PENDING   = "pending"
+	WEBHOOK_DELIVERY_SUCCEEDED = "succeeded"
+	WEBHOOK_DELIVERY_FAILED    = "failed"
+
+	WEBHOOK_HEADER_ID        = "X-Webhook-Id"
+	WEBHOOK_HEADER_DELIVERY  = "X-Webhook-Delivery"
+	WEBHOOK_HEADER_EVENT     = "X-Webhook-Event"
+	WEBHOOK_HEADER_TIMESTAMP = "X-Webhook-Timestamp"
+	// HMAC-SHA256 of "<timestamp>.<body>" keyed by the secret of webhook,
+	// in the form of sha256=<hex>
+	WEBHOOK_HEADER_SIGNATURE = "X-Webhook-Signature"
+)
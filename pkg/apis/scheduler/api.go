@@ -102,8 +102,27 @@ type CandidateResource struct {
 	Error string `json:"error"`
 }
 
+// PreemptionVictim is a lower priority server to stop to make room
+type PreemptionVictim struct {
+	GuestId       string `json:"guest_id"`
+	Name          string `json:"name"`
+	ProjectId     string `json:"project_id"`
+	PriorityClass string `json:"priority_class"`
+	VcpuCount     int    `json:"vcpu_count"`
+	VmemSize      int    `json:"vmem_size"`
+}
+
+type PreemptionPlan struct {
+	HostId   string              `json:"host_id"`
+	HostName string              `json:"host_name"`
+	Victims  []*PreemptionVictim `json:"victims"`
+}
+
 type ScheduleOutput struct {
 	apis.Meta
 
 	Candidates []*CandidateResource `json:"candidates"`
+
+	// Preemption is set when no candidate fits but preempting victims would make room
+	Preemption *PreemptionPlan `json:"preemption,omitempty"`
 }
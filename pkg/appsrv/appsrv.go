@@ -56,6 +56,8 @@ type Application struct {
 	defHandlerInfo    SHandlerInfo
 	cors              *Cors
 	middlewares       []MiddlewareFunc
+	metrics           *sAppMetrics
+	rateLimiter       *SRateLimiter
 
 	isExiting       bool
 	idleConnsClosed chan struct{}
@@ -86,6 +88,7 @@ func NewApplication(name string, connMax int, db bool) *Application {
 		readHeaderTimeout: DEFAULT_READ_HEADER_TIMEOUT,
 		writeTimeout:      DEFAULT_WRITE_TIMEOUT,
 		processTimeout:    DEFAULT_PROCESS_TIMEOUT,
+		metrics:           newAppMetrics(),
 	}
 	app.SetContext(appctx.APP_CONTEXT_KEY_APP, &app)
 	app.SetContext(appctx.APP_CONTEXT_KEY_APPNAME, app.name)
@@ -203,9 +206,11 @@ func (app *Application) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	} else {
 		counter = &hi.counter5XX
 	}
-	duration := float64(time.Since(start).Nanoseconds()) / 1000000
+	elapsed := time.Since(start)
+	duration := float64(elapsed.Nanoseconds()) / 1000000
 	counter.hit += 1
 	counter.duration += duration
+	app.metrics.observeRequest(r.Method, hi, lrw.status, elapsed)
 	skipLog := false
 	if params != nil {
 		if params.SkipLog {
@@ -243,6 +248,10 @@ func (app *Application) defaultHandle(w http.ResponseWriter, r *http.Request, ri
 		// log.Print("Found handler", params)
 		hand, ok := handler.(*SHandlerInfo)
 		if ok {
+			if app.rateLimiter != nil && !app.rateLimiter.allowRequest(w, r, hand) {
+				app.metrics.rateLimited.WithLabelValues(r.Method, hand.GetPath()).Inc()
+				return hand, nil
+			}
 			fw := newResponseWriterChannel(w)
 			worker := make(chan *SWorker)
 			to := hand.processTimeout
@@ -329,6 +338,7 @@ func (app *Application) addDefaultHandlers() {
 	app.AddDefaultHandler("POST", "/ping", PingHandler, "ping")
 	app.AddDefaultHandler("GET", "/ping", PingHandler, "ping")
 	app.AddDefaultHandler("GET", "/worker_stats", WorkerStatsHandler, "worker_stats")
+	app.AddDefaultHandler("GET", "/metrics", MetricsHandler, "metrics")
 }
 
 func timeoutHandle(h http.Handler) http.HandlerFunc {
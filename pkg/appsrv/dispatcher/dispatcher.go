@@ -48,6 +48,8 @@ func AddModelDispatcher(prefix string, app *appsrv.Application, manager IModelDi
 			manager.CustomizeHandlerInfo(h)
 		}
 	}
+	// watch
+	addWatchHandler(prefix, app, manager, metadata, tags)
 	// Head
 	h = app.AddHandler2("HEAD",
 		fmt.Sprintf("%s/%s/<resid>", prefix, manager.KeywordPlural()),
@@ -109,6 +111,8 @@ func AddModelDispatcher(prefix string, app *appsrv.Application, manager IModelDi
 		fmt.Sprintf("%s/%s/<resid>", prefix, manager.KeywordPlural()),
 		manager.Filter(deleteHandler), metadata, "delete", tags)
 	manager.CustomizeHandlerInfo(h)
+
+	addOpenAPIModel(prefix, app, manager)
 }
 
 func fetchEnv(ctx context.Context, w http.ResponseWriter, r *http.Request) (IModelDispatchHandler, map[string]string, jsonutils.JSONObject, jsonutils.JSONObject) {
@@ -52,6 +52,16 @@ type IModelDispatchHandler interface {
 	FetchUpdateHeaderData(ctx context.Context, header http.Header) (jsonutils.JSONObject, error)
 }
 
+// IWatchDispatchHandler is implemented by the model dispatchers able to
+// stream the changes of their resources
+type IWatchDispatchHandler interface {
+	// Watch returns the change events after sequence number since, a
+	// negative since means new events only. Each event carries its "seq"
+	// and "event" type. The channel is closed when ctx is done or the
+	// watcher falls behind, the client then resumes from the last seq.
+	Watch(ctx context.Context, query jsonutils.JSONObject, since int64) (<-chan jsonutils.JSONObject, error)
+}
+
 type IJointModelDispatchHandler interface {
 	IMiddlewareFilter
 
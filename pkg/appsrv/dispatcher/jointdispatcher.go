@@ -102,6 +102,8 @@ func AddJointModelDispatcher(prefix string, app *appsrv.Application, manager IJo
 			manager.MasterKeywordPlural()),
 		manager.Filter(detachHandler),
 		metadata, "detach", tags)
+
+	addOpenAPIJointModel(prefix, app, manager)
 }
 
 func fetchJointEnv(ctx context.Context, w http.ResponseWriter, r *http.Request) (IJointModelDispatchHandler, map[string]string, jsonutils.JSONObject, jsonutils.JSONObject) {
@@ -0,0 +1,403 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/util/version"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+)
+
+const (
+	OPENAPI_VERSION = "3.0.2"
+	OPENAPI_PATH    = "/openapi.json"
+)
+
+// SOpenAPIResourceSpec describes a resource for the OpenAPI document. The
+// schemas are JSON schema objects, the actions and specs are the names
+// used in the urls
+type SOpenAPIResourceSpec struct {
+	Resource jsonutils.JSONObject
+	Create   jsonutils.JSONObject
+	Update   jsonutils.JSONObject
+
+	// the query parameters accepted by list besides the common ones
+	ListFilters []string
+
+	Actions      []string
+	ClassActions []string
+	Specs        []string
+}
+
+// IOpenAPIDispatchHandler is implemented by the dispatchers able to describe
+// their resources, the others are documented with free form objects
+type IOpenAPIDispatchHandler interface {
+	OpenAPISpec() *SOpenAPIResourceSpec
+}
+
+type sOpenAPIModelEntry struct {
+	prefix  string
+	manager IModelDispatchHandler
+}
+
+type sOpenAPIJointEntry struct {
+	prefix  string
+	manager IJointModelDispatchHandler
+}
+
+type sOpenAPIRegistry struct {
+	lock   *sync.Mutex
+	models []sOpenAPIModelEntry
+	joints []sOpenAPIJointEntry
+}
+
+var (
+	openapiRegistries     = make(map[*appsrv.Application]*sOpenAPIRegistry)
+	openapiRegistriesLock = &sync.Mutex{}
+)
+
+// getOpenAPIRegistry returns the registry of the resources served by app,
+// the document is served by the first dispatcher added to the app
+func getOpenAPIRegistry(app *appsrv.Application) *sOpenAPIRegistry {
+	openapiRegistriesLock.Lock()
+	defer openapiRegistriesLock.Unlock()
+
+	reg, ok := openapiRegistries[app]
+	if !ok {
+		reg = &sOpenAPIRegistry{lock: &sync.Mutex{}}
+		openapiRegistries[app] = reg
+		app.AddDefaultHandler("GET", OPENAPI_PATH, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			appsrv.SendJSON(w, reg.document(app.GetName()))
+		}, "openapi")
+	}
+	return reg
+}
+
+func addOpenAPIModel(prefix string, app *appsrv.Application, manager IModelDispatchHandler) {
+	reg := getOpenAPIRegistry(app)
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+	reg.models = append(reg.models, sOpenAPIModelEntry{prefix: prefix, manager: manager})
+}
+
+func addOpenAPIJointModel(prefix string, app *appsrv.Application, manager IJointModelDispatchHandler) {
+	reg := getOpenAPIRegistry(app)
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+	reg.joints = append(reg.joints, sOpenAPIJointEntry{prefix: prefix, manager: manager})
+}
+
+var openapiPathParamRegexp = regexp.MustCompile(`<([^>]+)>`)
+
+// openapiPath converts a route of the application into an OpenAPI path
+// along with its path parameters
+func openapiPath(route string) (string, []string) {
+	params := make([]string, 0)
+	for _, m := range openapiPathParamRegexp.FindAllStringSubmatch(route, -1) {
+		params = append(params, m[1])
+	}
+	return openapiPathParamRegexp.ReplaceAllString(route, "{$1}"), params
+}
+
+func schemaRef(name string) jsonutils.JSONObject {
+	return jsonutils.Marshal(map[string]string{"$ref": "#/components/schemas/" + name})
+}
+
+func objectSchema() jsonutils.JSONObject {
+	schema := jsonutils.NewDict()
+	schema.Add(jsonutils.NewString("object"), "type")
+	schema.Add(jsonutils.JSONTrue, "additionalProperties")
+	return schema
+}
+
+// wrappedSchema is the schema of a body wrapping the object under key
+func wrappedSchema(key string, schema jsonutils.JSONObject) jsonutils.JSONObject {
+	props := jsonutils.NewDict()
+	props.Add(schema, key)
+	ret := jsonutils.NewDict()
+	ret.Add(jsonutils.NewString("object"), "type")
+	ret.Add(props, "properties")
+	return ret
+}
+
+func listSchema(key string, schema jsonutils.JSONObject) jsonutils.JSONObject {
+	array := jsonutils.NewDict()
+	array.Add(jsonutils.NewString("array"), "type")
+	array.Add(schema, "items")
+	integer := jsonutils.Marshal(map[string]string{"type": "integer"})
+	props := jsonutils.NewDict()
+	props.Add(array, key)
+	props.Add(integer, "total")
+	props.Add(integer, "limit")
+	props.Add(integer, "offset")
+	props.Add(jsonutils.Marshal(map[string]string{"type": "string"}), "next_marker")
+	ret := jsonutils.NewDict()
+	ret.Add(jsonutils.NewString("object"), "type")
+	ret.Add(props, "properties")
+	return ret
+}
+
+func jsonContent(schema jsonutils.JSONObject) jsonutils.JSONObject {
+	media := jsonutils.NewDict()
+	media.Add(schema, "schema")
+	content := jsonutils.NewDict()
+	content.Add(media, "application/json")
+	return content
+}
+
+func jsonBody(schema jsonutils.JSONObject) jsonutils.JSONObject {
+	body := jsonutils.NewDict()
+	body.Add(jsonContent(schema), "content")
+	body.Add(jsonutils.JSONTrue, "required")
+	return body
+}
+
+func jsonResponses(schema jsonutils.JSONObject) jsonutils.JSONObject {
+	ok := jsonutils.NewDict()
+	ok.Add(jsonutils.NewString("OK"), "description")
+	if schema != nil {
+		ok.Add(jsonContent(schema), "content")
+	}
+	resps := jsonutils.NewDict()
+	resps.Add(ok, "200")
+	return resps
+}
+
+func parameter(name string, in string, schemaType string) jsonutils.JSONObject {
+	param := jsonutils.NewDict()
+	param.Add(jsonutils.NewString(name), "name")
+	param.Add(jsonutils.NewString(in), "in")
+	if in == "path" {
+		param.Add(jsonutils.JSONTrue, "required")
+	}
+	param.Add(jsonutils.Marshal(map[string]string{"type": schemaType}), "schema")
+	return param
+}
+
+var openapiListParams = []struct {
+	name       string
+	schemaType string
+}{
+	{"limit", "integer"},
+	{"offset", "integer"},
+	{"order_by", "string"},
+	{"order", "string"},
+	{"details", "boolean"},
+	{"search", "string"},
+	{"filter", "string"},
+	{"admin", "boolean"},
+	{"paging_marker", "string"},
+	{"with_total", "boolean"},
+	{"fields", "string"},
+}
+
+type sOpenAPIPaths struct {
+	paths *jsonutils.JSONDict
+	opIds map[string]int
+}
+
+// operationId returns a unique snake case operation id, a resource may be
+// served under several prefixes
+func (p *sOpenAPIPaths) operationId(opId string) string {
+	opId = strings.Replace(opId, "-", "_", -1)
+	p.opIds[opId] += 1
+	if cnt := p.opIds[opId]; cnt > 1 {
+		return fmt.Sprintf("%s_%d", opId, cnt)
+	}
+	return opId
+}
+
+func (p *sOpenAPIPaths) add(method string, route string, tag string, opId string, extraParams []jsonutils.JSONObject, body jsonutils.JSONObject, responses jsonutils.JSONObject) {
+	path, pathParams := openapiPath(route)
+	opId = p.operationId(opId)
+	op := jsonutils.NewDict()
+	op.Add(jsonutils.NewString(opId), "operationId")
+	op.Add(jsonutils.NewStringArray([]string{tag}), "tags")
+	params := make([]jsonutils.JSONObject, 0, len(pathParams)+len(extraParams))
+	for _, name := range pathParams {
+		params = append(params, parameter(name, "path", "string"))
+	}
+	params = append(params, extraParams...)
+	if len(params) > 0 {
+		op.Add(jsonutils.NewArray(params...), "parameters")
+	}
+	if body != nil {
+		op.Add(body, "requestBody")
+	}
+	op.Add(responses, "responses")
+
+	item, _ := p.paths.Get(path)
+	itemDict, ok := item.(*jsonutils.JSONDict)
+	if !ok {
+		itemDict = jsonutils.NewDict()
+		p.paths.Set(path, itemDict)
+	}
+	itemDict.Set(method, op)
+}
+
+func (p *sOpenAPIPaths) addModel(prefix string, manager IModelDispatchHandler, schemas *jsonutils.JSONDict) {
+	keyword := manager.Keyword()
+	plural := manager.KeywordPlural()
+
+	spec := &SOpenAPIResourceSpec{}
+	if h, ok := manager.(IOpenAPIDispatchHandler); ok {
+		spec = h.OpenAPISpec()
+	}
+	resource, create, update := schemaRef(keyword), schemaRef(keyword+"_create_input"), schemaRef(keyword+"_update_input")
+	for name, schema := range map[string]jsonutils.JSONObject{
+		keyword:                   spec.Resource,
+		keyword + "_create_input": spec.Create,
+		keyword + "_update_input": spec.Update,
+	} {
+		if schema == nil {
+			schema = objectSchema()
+		}
+		schemas.Set(name, schema)
+	}
+
+	listParams := make([]jsonutils.JSONObject, 0)
+	for _, param := range openapiListParams {
+		listParams = append(listParams, parameter(param.name, "query", param.schemaType))
+	}
+	for _, filter := range spec.ListFilters {
+		listParams = append(listParams, parameter(filter, "query", "string"))
+	}
+
+	base := fmt.Sprintf("%s/%s", prefix, plural)
+	item := fmt.Sprintf("%s/<%s_id>", base, keyword)
+	p.add("get", base, plural, "list_"+plural, listParams, nil, jsonResponses(listSchema(plural, resource)))
+	p.add("post", base, plural, "create_"+keyword, nil, jsonBody(wrappedSchema(keyword, create)), jsonResponses(wrappedSchema(keyword, resource)))
+	for _, ctx := range manager.ContextKeywordPlural() {
+		ctxRoute := fmt.Sprintf("%s/%s/<%s_id>/%s", prefix, ctx, ctx, plural)
+		p.add("get", ctxRoute, plural, fmt.Sprintf("list_%s_in_%s", plural, ctx), listParams, nil, jsonResponses(listSchema(plural, resource)))
+		p.add("post", ctxRoute, plural, fmt.Sprintf("create_%s_in_%s", keyword, ctx), nil, jsonBody(wrappedSchema(keyword, create)), jsonResponses(wrappedSchema(keyword, resource)))
+	}
+	if _, ok := manager.(IWatchDispatchHandler); ok {
+		watch := jsonutils.NewDict()
+		watch.Add(jsonutils.NewString("Server-sent events of the changes"), "description")
+		watch.Add(jsonutils.Marshal(map[string]interface{}{"text/event-stream": map[string]interface{}{"schema": map[string]string{"type": "string"}}}), "content")
+		watchResps := jsonutils.NewDict()
+		watchResps.Add(watch, "200")
+		p.add("get", fmt.Sprintf("%s/watch/%s", prefix, plural), plural, "watch_"+plural,
+			[]jsonutils.JSONObject{parameter("since_seq", "query", "integer")}, nil, watchResps)
+	}
+	p.add("get", item, plural, "get_"+keyword, nil, nil, jsonResponses(wrappedSchema(keyword, resource)))
+	p.add("put", item, plural, "update_"+keyword, nil, jsonBody(wrappedSchema(keyword, update)), jsonResponses(wrappedSchema(keyword, resource)))
+	p.add("delete", item, plural, "delete_"+keyword, nil, nil, jsonResponses(wrappedSchema(keyword, resource)))
+	for _, spec := range spec.Specs {
+		p.add("get", fmt.Sprintf("%s/%s", item, spec), plural, fmt.Sprintf("get_%s_%s", keyword, spec), nil, nil, jsonResponses(objectSchema()))
+	}
+	for _, action := range spec.Actions {
+		p.add("post", fmt.Sprintf("%s/%s", item, action), plural, fmt.Sprintf("perform_%s_%s", keyword, action),
+			nil, jsonBody(wrappedSchema(keyword, objectSchema())), jsonResponses(wrappedSchema(keyword, resource)))
+	}
+	for _, action := range spec.ClassActions {
+		p.add("post", fmt.Sprintf("%s/%s", base, action), plural, fmt.Sprintf("perform_%s_%s", plural, action),
+			nil, jsonBody(wrappedSchema(plural, objectSchema())), jsonResponses(objectSchema()))
+	}
+}
+
+func (p *sOpenAPIPaths) addJointModel(prefix string, manager IJointModelDispatchHandler, schemas *jsonutils.JSONDict) {
+	keyword := manager.Keyword()
+	plural := manager.KeywordPlural()
+	master := manager.MasterKeywordPlural()
+	slave := manager.SlaveKeywordPlural()
+
+	spec := &SOpenAPIResourceSpec{}
+	if h, ok := manager.(IOpenAPIDispatchHandler); ok {
+		spec = h.OpenAPISpec()
+	}
+	resource, create, update := schemaRef(keyword), schemaRef(keyword+"_attach_input"), schemaRef(keyword+"_update_input")
+	for name, schema := range map[string]jsonutils.JSONObject{
+		keyword:                   spec.Resource,
+		keyword + "_attach_input": spec.Create,
+		keyword + "_update_input": spec.Update,
+	} {
+		if schema == nil {
+			schema = objectSchema()
+		}
+		schemas.Set(name, schema)
+	}
+
+	listParams := make([]jsonutils.JSONObject, 0)
+	for _, param := range openapiListParams {
+		listParams = append(listParams, parameter(param.name, "query", param.schemaType))
+	}
+	p.add("get", fmt.Sprintf("%s/%s", prefix, plural), plural, "list_"+plural, listParams, nil, jsonResponses(listSchema(plural, resource)))
+	for _, pair := range [][2]string{{master, slave}, {slave, master}} {
+		from, to := pair[0], pair[1]
+		p.add("get", fmt.Sprintf("%s/%s/<%s_id>/%s", prefix, from, from, to), plural,
+			fmt.Sprintf("list_%s_of_%s", to, from), listParams, nil, jsonResponses(listSchema(plural, resource)))
+		route := fmt.Sprintf("%s/%s/<%s_id>/%s/<%s_id>", prefix, from, from, to, to)
+		p.add("get", route, plural, fmt.Sprintf("get_%s_%s", from, to), nil, nil, jsonResponses(wrappedSchema(keyword, resource)))
+		p.add("post", route, plural, fmt.Sprintf("attach_%s_%s", from, to), nil, jsonBody(wrappedSchema(keyword, create)), jsonResponses(wrappedSchema(keyword, resource)))
+		p.add("put", route, plural, fmt.Sprintf("update_%s_%s", from, to), nil, jsonBody(wrappedSchema(keyword, update)), jsonResponses(wrappedSchema(keyword, resource)))
+		p.add("delete", route, plural, fmt.Sprintf("detach_%s_%s", from, to), nil, nil, jsonResponses(wrappedSchema(keyword, resource)))
+	}
+}
+
+func (reg *sOpenAPIRegistry) document(title string) jsonutils.JSONObject {
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+
+	paths := &sOpenAPIPaths{paths: jsonutils.NewDict(), opIds: make(map[string]int)}
+	schemas := jsonutils.NewDict()
+	tags := make([]string, 0)
+	for _, entry := range reg.models {
+		paths.addModel(entry.prefix, entry.manager, schemas)
+		tags = append(tags, entry.manager.KeywordPlural())
+	}
+	for _, entry := range reg.joints {
+		paths.addJointModel(entry.prefix, entry.manager, schemas)
+		tags = append(tags, entry.manager.KeywordPlural())
+	}
+	sort.Strings(tags)
+	tagObjs := make([]jsonutils.JSONObject, 0, len(tags))
+	for i, tag := range tags {
+		if i > 0 && tags[i-1] == tag {
+			continue
+		}
+		tagObjs = append(tagObjs, jsonutils.Marshal(map[string]string{"name": tag}))
+	}
+
+	info := jsonutils.NewDict()
+	info.Add(jsonutils.NewString(title), "title")
+	info.Add(jsonutils.NewString(version.GetShortString()), "version")
+
+	securitySchemes := jsonutils.Marshal(map[string]interface{}{
+		"token": map[string]string{"type": "apiKey", "in": "header", "name": "X-Auth-Token"},
+	})
+	components := jsonutils.NewDict()
+	components.Add(schemas, "schemas")
+	components.Add(securitySchemes, "securitySchemes")
+
+	doc := jsonutils.NewDict()
+	doc.Add(jsonutils.NewString(OPENAPI_VERSION), "openapi")
+	doc.Add(info, "info")
+	doc.Add(jsonutils.NewArray(tagObjs...), "tags")
+	doc.Add(paths.paths, "paths")
+	doc.Add(components, "components")
+	doc.Add(jsonutils.Marshal([]map[string][]string{{"token": {}}}), "security")
+	return doc
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const (
+	// a watch is ended after a while and resumed by the client, the
+	// process timeout of the handler leaves it time to finish the stream
+	WATCH_STREAM_DURATION    = 30 * time.Minute
+	WATCH_PROCESS_TIMEOUT    = WATCH_STREAM_DURATION + time.Minute
+	WATCH_KEEPALIVE_INTERVAL = 15 * time.Second
+
+	WATCH_WORKER_COUNT = 128
+)
+
+var (
+	watchWorkerMan     *appsrv.SWorkerManager
+	watchWorkerManOnce sync.Once
+)
+
+// watchers hold their worker for the whole stream, keep them off the
+// request workers
+func getWatchWorkerManager() *appsrv.SWorkerManager {
+	watchWorkerManOnce.Do(func() {
+		watchWorkerMan = appsrv.NewWorkerManager("watch_worker", WATCH_WORKER_COUNT, 16, false)
+	})
+	return watchWorkerMan
+}
+
+func addWatchHandler(prefix string, app *appsrv.Application, manager IModelDispatchHandler, metadata map[string]interface{}, tags map[string]string) {
+	if _, ok := manager.(IWatchDispatchHandler); !ok {
+		return
+	}
+	h := app.AddHandler2("GET",
+		fmt.Sprintf("%s/watch/%s", prefix, manager.KeywordPlural()),
+		manager.Filter(watchHandler), metadata, "watch", tags)
+	h.SetProcessTimeout(WATCH_PROCESS_TIMEOUT).SetWorkerManager(getWatchWorkerManager())
+	manager.CustomizeHandlerInfo(h)
+}
+
+// watchSince returns the sequence number to resume from, given by the
+// Last-Event-ID header of a reconnecting EventSource or the since_seq query
+func watchSince(r *http.Request, query jsonutils.JSONObject) (int64, error) {
+	sinceStr := r.Header.Get("Last-Event-ID")
+	if len(sinceStr) == 0 {
+		sinceStr, _ = query.GetString("since_seq")
+	}
+	if len(sinceStr) == 0 {
+		return -1, nil
+	}
+	since, err := strconv.ParseInt(sinceStr, 10, 64)
+	if err != nil || since < 0 {
+		return -1, httperrors.NewInputParameterError("invalid sequence number %s", sinceStr)
+	}
+	return since, nil
+}
+
+func writeServerSentEvent(w http.ResponseWriter, ev jsonutils.JSONObject) error {
+	seq, _ := ev.Int("seq")
+	event, _ := ev.GetString("event")
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", seq, event, ev.String())
+	if err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// watchHandler streams the change events as server-sent events
+func watchHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	manager, params, query, _ := fetchEnv(ctx, w, r)
+	query = mergeQueryParams(params, query)
+	since, err := watchSince(r, query)
+	if err != nil {
+		httperrors.GeneralServerError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, WATCH_STREAM_DURATION)
+	defer cancel()
+	go func() {
+		select {
+		case <-r.Context().Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	events, err := manager.(IWatchDispatchHandler).Watch(ctx, query, since)
+	if err != nil {
+		httperrors.GeneralServerError(w, err)
+		return
+	}
+
+	appParams := appsrv.AppContextGetParams(ctx)
+	if appParams != nil {
+		appParams.SkipTrace = true
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	keepalive := time.NewTicker(WATCH_KEEPALIVE_INTERVAL)
+	defer keepalive.Stop()
+	for {
+		select {
+		case ev, more := <-events:
+			if !more {
+				return
+			}
+			if writeServerSentEvent(w, ev) != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+				return
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -59,6 +59,16 @@ func (this *SHandlerInfo) GetName(params map[string]string) string {
 	return strings.Join(path, "_")
 }
 
+// GetPath returns the path template the handler is registered at, e.g.
+// /servers/<resid>, or * for the default handler. Unlike the name, which
+// dispatcher handlers share across resources, it tells resources apart
+func (this *SHandlerInfo) GetPath() string {
+	if len(this.method) == 0 {
+		return "*"
+	}
+	return "/" + strings.Join(this.path, "/")
+}
+
 func (this *SHandlerInfo) GetTags() map[string]string {
 	return this.tags
 }
@@ -0,0 +1,139 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type sAppMetrics struct {
+	registry        *prometheus.Registry
+	requestDuration *prometheus.HistogramVec
+	rateLimited     *prometheus.CounterVec
+}
+
+func newAppMetrics() *sAppMetrics {
+	m := &sAppMetrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_request_duration_seconds",
+				Help:    "Duration of HTTP requests by handler path and status class",
+				Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+			},
+			[]string{"method", "handler", "status"},
+		),
+		rateLimited: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "appsrv_rate_limited_requests_total",
+				Help: "Number of requests rejected by the rate limiter",
+			},
+			[]string{"method", "handler"},
+		),
+	}
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.requestDuration,
+		m.rateLimited,
+		&sWorkerCollector{},
+	)
+	return m
+}
+
+func statusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+func (m *sAppMetrics) observeRequest(method string, hi *SHandlerInfo, status int, duration time.Duration) {
+	m.requestDuration.WithLabelValues(method, hi.GetPath(), statusClass(status)).Observe(duration.Seconds())
+}
+
+// RegisterMetrics adds collectors to the registry served at /metrics, so
+// that packages built on top of appsrv can export their own metrics
+func (app *Application) RegisterMetrics(collectors ...prometheus.Collector) {
+	app.metrics.registry.MustRegister(collectors...)
+}
+
+func MetricsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	app := AppContextApp(ctx)
+	promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+var (
+	workerQueueDesc = prometheus.NewDesc("appsrv_worker_queue_length",
+		"Number of tasks waiting in the queue of a worker manager", []string{"worker"}, nil)
+	workerQueueCapacityDesc = prometheus.NewDesc("appsrv_worker_queue_capacity",
+		"Maximal number of tasks the queue of a worker manager can hold", []string{"worker"}, nil)
+	workerMaxDesc = prometheus.NewDesc("appsrv_worker_max",
+		"Maximal number of concurrent workers of a worker manager", []string{"worker"}, nil)
+	workerActiveDesc = prometheus.NewDesc("appsrv_worker_active",
+		"Number of active workers of a worker manager", []string{"worker"}, nil)
+	workerDetachedDesc = prometheus.NewDesc("appsrv_worker_detached",
+		"Number of workers detached from a worker manager after timeout", []string{"worker"}, nil)
+)
+
+// sWorkerCollector reports the state of all worker managers of the process,
+// the same figures as WorkerStatsHandler
+type sWorkerCollector struct{}
+
+func (c *sWorkerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- workerQueueDesc
+	ch <- workerQueueCapacityDesc
+	ch <- workerMaxDesc
+	ch <- workerActiveDesc
+	ch <- workerDetachedDesc
+}
+
+func (c *sWorkerCollector) Collect(ch chan<- prometheus.Metric) {
+	type sWorkerFigures struct {
+		queue    int
+		capacity int
+		max      int
+		active   int
+		detached int
+	}
+	// worker manager names are not guaranteed to be unique
+	names := make([]string, 0)
+	figures := make(map[string]*sWorkerFigures)
+	for i := 0; i < len(workerManagers); i += 1 {
+		state := workerManagers[i].getState()
+		f, ok := figures[state.Name]
+		if !ok {
+			f = &sWorkerFigures{}
+			figures[state.Name] = f
+			names = append(names, state.Name)
+		}
+		f.queue += state.QueueCnt
+		f.capacity += state.Backlog * state.MaxWorkerCnt
+		f.max += state.MaxWorkerCnt
+		f.active += state.ActiveWorkerCnt
+		f.detached += state.DetachWorkerCnt
+	}
+	for _, name := range names {
+		f := figures[name]
+		ch <- prometheus.MustNewConstMetric(workerQueueDesc, prometheus.GaugeValue, float64(f.queue), name)
+		ch <- prometheus.MustNewConstMetric(workerQueueCapacityDesc, prometheus.GaugeValue, float64(f.capacity), name)
+		ch <- prometheus.MustNewConstMetric(workerMaxDesc, prometheus.GaugeValue, float64(f.max), name)
+		ch <- prometheus.MustNewConstMetric(workerActiveDesc, prometheus.GaugeValue, float64(f.active), name)
+		ch <- prometheus.MustNewConstMetric(workerDetachedDesc, prometheus.GaugeValue, float64(f.detached), name)
+	}
+}
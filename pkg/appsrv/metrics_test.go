@@ -0,0 +1,50 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	app := NewApplication("test-metrics", 2, false)
+	app.AddHandler2("GET", "/hello", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		Send(w, "world")
+	}, nil, "hello", nil)
+	// handlers of different resources sharing a name are told apart by path
+	for _, res := range []string{"servers", "networks"} {
+		app.AddHandler2("GET", "/"+res+"/<resid>", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			Send(w, "details")
+		}, nil, "get_details", nil)
+	}
+	app.addDefaultHandlers()
+
+	assert.HTTPBodyContains(t, app.ServeHTTP, "GET", "/hello", nil, "world")
+	assert.HTTPBodyContains(t, app.ServeHTTP, "GET", "/servers/s1", nil, "details")
+	assert.HTTPBodyContains(t, app.ServeHTTP, "GET", "/networks/n1", nil, "details")
+	for _, want := range []string{
+		`http_request_duration_seconds_count{handler="/hello",method="GET",status="2xx"} 1`,
+		`http_request_duration_seconds_count{handler="/servers/<resid>",method="GET",status="2xx"} 1`,
+		`http_request_duration_seconds_count{handler="/networks/<resid>",method="GET",status="2xx"} 1`,
+		`appsrv_worker_queue_length{worker="HttpGetRequestWorkerManager"}`,
+		`appsrv_worker_detached{worker="HttpGetRequestWorkerManager"}`,
+	} {
+		assert.HTTPBodyContains(t, app.ServeHTTP, "GET", "/metrics", nil, want)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const (
+	HEADER_RETRY_AFTER = "Retry-After"
+
+	rateLimitSweepInterval = time.Minute
+)
+
+// RateLimitIdentifyFunc returns the user and project a request is issued on
+// behalf of, and whether the caller is exempted from rate limiting. Requests
+// with an empty userId are not limited.
+type RateLimitIdentifyFunc func(r *http.Request) (userId string, projectId string, exempt bool)
+
+// SRateLimit describes a token bucket: Rate tokens are refilled per second up
+// to Burst. A non-positive Rate disables limiting.
+type SRateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+func (limit SRateLimit) isUnlimited() bool {
+	return limit.Rate <= 0
+}
+
+func (limit SRateLimit) capacity() float64 {
+	if limit.Burst < 1 {
+		return 1
+	}
+	return float64(limit.Burst)
+}
+
+type sTokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket up to now and consumes one token. When the bucket
+// is empty it returns the duration to wait for the next token.
+func (b *sTokenBucket) take(limit SRateLimit, now time.Time) (bool, time.Duration) {
+	capacity := limit.capacity()
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*limit.Rate)
+	}
+	b.last = now
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	if b.tokens >= 1 {
+		b.tokens -= 1
+		return true, 0
+	}
+	wait := (1 - b.tokens) / limit.Rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// SRateLimiter keeps a token bucket per user, project and handler
+type SRateLimiter struct {
+	identify RateLimitIdentifyFunc
+
+	lock          sync.Mutex
+	defaultLimit  SRateLimit
+	handlerLimits map[string]SRateLimit
+	buckets       map[string]*sTokenBucket
+	lastSweep     time.Time
+}
+
+func NewRateLimiter(identify RateLimitIdentifyFunc, limit SRateLimit) *SRateLimiter {
+	return &SRateLimiter{
+		identify:      identify,
+		defaultLimit:  limit,
+		handlerLimits: make(map[string]SRateLimit),
+		buckets:       make(map[string]*sTokenBucket),
+		lastSweep:     time.Now(),
+	}
+}
+
+// rateLimitKey returns the method and path template of a handler, e.g.
+// "GET /servers/<resid>", which buckets and overrides are keyed by
+func rateLimitKey(hi *SHandlerInfo) string {
+	return hi.method + " " + hi.GetPath()
+}
+
+// SetLimits replaces the default limit and the per-handler overrides, which
+// are keyed by method and path template, e.g. "GET /servers"
+func (l *SRateLimiter) SetLimits(limit SRateLimit, handlerLimits map[string]SRateLimit) {
+	if handlerLimits == nil {
+		handlerLimits = make(map[string]SRateLimit)
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.defaultLimit = limit
+	l.handlerLimits = handlerLimits
+}
+
+func (l *SRateLimiter) getLimit(handler string) SRateLimit {
+	if limit, ok := l.handlerLimits[handler]; ok {
+		return limit
+	}
+	return l.defaultLimit
+}
+
+func (l *SRateLimiter) take(userId, projectId, handler string, now time.Time) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	limit := l.getLimit(handler)
+	if limit.isUnlimited() {
+		return true, 0
+	}
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		l.sweep(now)
+	}
+	key := fmt.Sprintf("%s|%s|%s", userId, projectId, handler)
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &sTokenBucket{tokens: limit.capacity(), last: now}
+		l.buckets[key] = bucket
+	}
+	return bucket.take(limit, now)
+}
+
+// sweep drops the buckets that have been idle long enough to be full again,
+// they are indistinguishable from a fresh bucket
+func (l *SRateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) > rateLimitSweepInterval {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (l *SRateLimiter) allowRequest(w http.ResponseWriter, r *http.Request, hi *SHandlerInfo) bool {
+	userId, projectId, exempt := l.identify(r)
+	if len(userId) == 0 || exempt {
+		return true
+	}
+	key := rateLimitKey(hi)
+	allow, wait := l.take(userId, projectId, key, time.Now())
+	if allow {
+		return true
+	}
+	w.Header().Set(HEADER_RETRY_AFTER, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	httperrors.TooManyRequestsError(w, "too many requests to %s, retry after %s", key, wait.Round(time.Millisecond))
+	return false
+}
+
+// SetRateLimiter enables rate limiting of requests before they are queued to
+// the request workers, nil disables it
+func (app *Application) SetRateLimiter(limiter *SRateLimiter) {
+	app.rateLimiter = limiter
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	limit := SRateLimit{Rate: 2, Burst: 3}
+	now := time.Now()
+	bucket := &sTokenBucket{tokens: limit.capacity(), last: now}
+	for i := 0; i < 3; i++ {
+		allow, _ := bucket.take(limit, now)
+		assert.True(t, allow, "burst request %d", i)
+	}
+	allow, wait := bucket.take(limit, now)
+	assert.False(t, allow)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	allow, _ = bucket.take(limit, now.Add(wait))
+	assert.True(t, allow)
+
+	// an idle bucket never refills beyond its burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		allow, _ = bucket.take(limit, now)
+		assert.True(t, allow)
+	}
+	allow, _ = bucket.take(limit, now)
+	assert.False(t, allow)
+}
+
+func TestRateLimiter(t *testing.T) {
+	identify := func(r *http.Request) (string, string, bool) {
+		user := r.Header.Get("X-User")
+		return user, "project", user == "admin"
+	}
+	app := NewApplication("test-ratelimit", 2, false)
+	app.AddHandler2("GET", "/hello", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		Send(w, "world")
+	}, nil, "hello", nil)
+	limiter := NewRateLimiter(identify, SRateLimit{Rate: 0.001, Burst: 2})
+	app.SetRateLimiter(limiter)
+
+	serve := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/hello", nil)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		return rec
+	}
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, serve("alice").Code)
+	}
+	rec := serve("alice")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get(HEADER_RETRY_AFTER))
+
+	// buckets are per user, and exempted and anonymous callers are not limited
+	assert.Equal(t, http.StatusOK, serve("bob").Code)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve("admin").Code)
+		assert.Equal(t, http.StatusOK, serve("").Code)
+	}
+
+	// handler overrides take precedence over the default limit
+	limiter.SetLimits(SRateLimit{Rate: 0.001, Burst: 2}, map[string]SRateLimit{"GET /hello": {}})
+	assert.Equal(t, http.StatusOK, serve("alice").Code)
+}
+
+func TestRateLimiterPerRoute(t *testing.T) {
+	identify := func(r *http.Request) (string, string, bool) {
+		return "alice", "project", false
+	}
+	app := NewApplication("test-ratelimit-route", 2, false)
+	// dispatcher handlers of all resources share names such as "list"
+	for _, res := range []string{"servers", "networks"} {
+		app.AddHandler2("GET", "/"+res, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			Send(w, "list")
+		}, nil, "list", nil)
+	}
+	limiter := NewRateLimiter(identify, SRateLimit{})
+	limiter.SetLimits(SRateLimit{}, map[string]SRateLimit{"GET /servers": {Rate: 0.001, Burst: 1}})
+	app.SetRateLimiter(limiter)
+
+	serve := func(path string) int {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, serve("/servers"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("/servers"))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve("/networks"))
+	}
+}
@@ -118,6 +118,7 @@ func (agent *SBaremetalAgent) StartService() error {
 
 	agent.Manager = manager
 	agent.startPXEServices(manager)
+	manager.startTelemetryCollector()
 
 	agent.DoOnline(agent.GetAdminSession())
 	return nil
@@ -154,6 +155,7 @@ func (agent *SBaremetalAgent) startPXEServices(manager *SBaremetalManager) {
 	}
 	agent.PXEServer = &pxe.Server{
 		TFTPRootDir:      o.Options.TftpRoot,
+		HTTPPort:         o.Options.HttpBootPort,
 		Address:          dhcpListenIp.String(),
 		BaremetalManager: manager,
 	}
@@ -38,6 +38,7 @@ func initBaremetalsHandler(app *appsrv.Application) {
 	app.AddHandler("POST", bmActionPrefix("sync-ipmi"), bmObjMiddleware(handleBaremetalSyncIPMI))
 	app.AddHandler("POST", bmActionPrefix("prepare"), bmObjMiddleware(handleBaremetalPrepare))
 	app.AddHandler("POST", bmActionPrefix("reset-bmc"), bmObjMiddleware(handleBaremetalResetBMC))
+	app.AddHandler("POST", bmActionPrefix("disk-wipe"), bmObjMiddleware(handleBaremetalDiskWipe))
 
 	// server actions handler
 	app.AddHandler("POST", srvActionPrefix("create"), srvClassMiddleware(handleServerCreate))
@@ -110,6 +111,11 @@ func handleBaremetalResetBMC(ctx *Context, bm *baremetal.SBaremetalInstance) {
 	ctx.ResponseOk()
 }
 
+func handleBaremetalDiskWipe(ctx *Context, bm *baremetal.SBaremetalInstance) {
+	bm.StartBaremetalDiskWipeTask(ctx.UserCred(), ctx.TaskId(), ctx.Data())
+	ctx.ResponseOk()
+}
+
 func handleServerCreate(ctx *Context, bm *baremetal.SBaremetalInstance) {
 	err := bm.StartServerCreateTask(ctx.UserCred(), ctx.TaskId(), ctx.Data())
 	if err != nil {
@@ -41,14 +41,19 @@ import (
 	baremetalstatus "yunion.io/x/onecloud/pkg/baremetal/status"
 	"yunion.io/x/onecloud/pkg/baremetal/tasks"
 	baremetaltypes "yunion.io/x/onecloud/pkg/baremetal/types"
+	"yunion.io/x/onecloud/pkg/baremetal/utils/bmc"
 	"yunion.io/x/onecloud/pkg/baremetal/utils/detect_storages"
 	"yunion.io/x/onecloud/pkg/baremetal/utils/disktool"
+	"yunion.io/x/onecloud/pkg/baremetal/utils/diskwipe"
 	"yunion.io/x/onecloud/pkg/baremetal/utils/ipmitool"
 	raiddrivers "yunion.io/x/onecloud/pkg/baremetal/utils/raid/drivers"
+	"yunion.io/x/onecloud/pkg/baremetal/utils/raid/mdadm"
+	"yunion.io/x/onecloud/pkg/cloudcommon/keymanager"
 	"yunion.io/x/onecloud/pkg/cloudcommon/sshkeys"
 	"yunion.io/x/onecloud/pkg/cloudcommon/types"
 	"yunion.io/x/onecloud/pkg/compute/baremetal"
 	"yunion.io/x/onecloud/pkg/hostman/guestfs"
+	"yunion.io/x/onecloud/pkg/hostman/guestfs/fsdriver"
 	"yunion.io/x/onecloud/pkg/hostman/guestfs/sshpart"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
@@ -62,6 +67,8 @@ type SBaremetalManager struct {
 	Agent      *SBaremetalAgent
 	configPath string
 	baremetals *sBaremetalMap
+
+	stopTelemetry chan struct{}
 }
 
 func NewBaremetalManager(agent *SBaremetalAgent) (*SBaremetalManager, error) {
@@ -71,9 +78,10 @@ func NewBaremetalManager(agent *SBaremetalAgent) (*SBaremetalManager, error) {
 		return nil, err
 	}
 	return &SBaremetalManager{
-		Agent:      agent,
-		configPath: bmPaths,
-		baremetals: newBaremetalMap(),
+		Agent:         agent,
+		configPath:    bmPaths,
+		baremetals:    newBaremetalMap(),
+		stopTelemetry: make(chan struct{}),
 	}, nil
 }
 
@@ -207,6 +215,7 @@ func (m *SBaremetalManager) GetBaremetalByMac(mac net.HardwareAddr) pxe.IBaremet
 }
 
 func (m *SBaremetalManager) Stop() {
+	close(m.stopTelemetry)
 	for _, bm := range m.GetBaremetals() {
 		bm.Stop()
 	}
@@ -253,6 +262,11 @@ type SBaremetalInstance struct {
 	taskQueue  *tasks.TaskQueue
 	server     baremetaltypes.IBaremetalServer
 	serverLock *sync.Mutex
+
+	// plain text of envelope encrypted ipmi password, kept in memory only
+	ipmiPasswordSecret string
+	ipmiPassword       string
+	ipmiPasswordLock   *sync.Mutex
 }
 
 func newBaremetalInstance(man *SBaremetalManager, desc jsonutils.JSONObject) (*SBaremetalInstance, error) {
@@ -262,6 +276,8 @@ func newBaremetalInstance(man *SBaremetalManager, desc jsonutils.JSONObject) (*S
 		descLock:   new(sync.Mutex),
 		taskQueue:  tasks.NewTaskQueue(),
 		serverLock: new(sync.Mutex),
+
+		ipmiPasswordLock: new(sync.Mutex),
 	}
 	err := os.MkdirAll(bm.GetDir(), 0755)
 	if err != nil {
@@ -711,6 +727,20 @@ label start
 	return resp
 }
 
+func (b *SBaremetalInstance) GetIPXEScript(bootUrl string) string {
+	resp := "#!ipxe\n"
+	if b.NeedPXEBoot() {
+		resp += fmt.Sprintf("kernel %s/kernel initrd=initramfs token=%s url=%s\n",
+			bootUrl, auth.GetTokenString(), b.GetNotifyUrl())
+		resp += fmt.Sprintf("initrd %s/initramfs\n", bootUrl)
+		resp += "boot\n"
+	} else {
+		// return to firmware, which goes on with the next boot device
+		resp += "exit\n"
+	}
+	return resp
+}
+
 func (b *SBaremetalInstance) GetTaskQueue() *tasks.TaskQueue {
 	return b.taskQueue
 }
@@ -856,15 +886,40 @@ func (b *SBaremetalInstance) GetRawIPMIConfig() *types.SIPMIInfo {
 		return nil
 	}
 	if ipmiInfo.Password != "" {
-		ipmiInfo.Password, err = utils.DescryptAESBase64(b.GetId(), ipmiInfo.Password)
+		ipmiInfo.Password, err = b.decryptIPMIPassword(ipmiInfo.Password)
 		if err != nil {
-			log.Errorf("DescryptAESBase64 IPMI password error: %v", err)
+			log.Errorf("Decrypt IPMI password error: %v", err)
 			return nil
 		}
 	}
 	return &ipmiInfo
 }
 
+// decryptIPMIPassword returns plain text of ipmi password in host desc.
+// Envelope encrypted password can only be decrypted by region, so it is
+// fetched through ipmi spec of the host and cached until the password changes
+func (b *SBaremetalInstance) decryptIPMIPassword(password string) (string, error) {
+	if !keymanager.IsEncrypted(password) {
+		return utils.DescryptAESBase64(b.GetId(), password)
+	}
+	b.ipmiPasswordLock.Lock()
+	defer b.ipmiPasswordLock.Unlock()
+	if b.ipmiPasswordSecret == password {
+		return b.ipmiPassword, nil
+	}
+	ret, err := modules.Hosts.GetSpecific(b.GetClientSession(), b.GetId(), "ipmi", nil)
+	if err != nil {
+		return "", fmt.Errorf("get ipmi info: %v", err)
+	}
+	plain, err := ret.GetString("password")
+	if err != nil {
+		return "", fmt.Errorf("get ipmi password: %v", err)
+	}
+	b.ipmiPasswordSecret = password
+	b.ipmiPassword = plain
+	return plain, nil
+}
+
 func (b *SBaremetalInstance) GetServer() baremetaltypes.IBaremetalServer {
 	b.serverLock.Lock()
 	defer b.serverLock.Unlock()
@@ -917,12 +972,14 @@ func (b *SBaremetalInstance) SetExistingIPMIIPAddr(ipAddr string) {
 	b.desc.Set("ipmi_info", info)
 }
 
-func (b *SBaremetalInstance) GetIPMITool() *ipmitool.LanPlusIPMI {
+// GetBMC returns out-of-band management client of the type selected by
+// bmc_type of ipmi info
+func (b *SBaremetalInstance) GetBMC() (bmc.IBMC, error) {
 	conf := b.GetIPMIConfig()
 	if conf == nil {
-		return nil
+		return nil, fmt.Errorf("Baremetal %s ipmi config is empty", b.GetId())
 	}
-	return ipmitool.NewLanPlusIPMI(conf.IpAddr, conf.Username, conf.Password)
+	return bmc.NewBMC(conf)
 }
 
 func (b *SBaremetalInstance) GetIPMILanChannel() int {
@@ -936,43 +993,40 @@ func (b *SBaremetalInstance) GetIPMILanChannel() int {
 func (b *SBaremetalInstance) DoPXEBoot() error {
 	log.Infof("Do PXE Boot ........., wait")
 	b.ClearSSHConfig()
-	ipmiCli := b.GetIPMITool()
-	if ipmiCli != nil {
-		return ipmitool.DoRebootToPXE(ipmiCli)
+	bmcCli, err := b.GetBMC()
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("Baremetal %s ipmitool is nil", b.GetId())
+	return bmc.DoRebootToPXE(bmcCli)
 }
 
 /*
 func (b *SBaremetalInstance) DoDiskBoot() error {
 	log.Infof("Do DISK Boot ........., wait")
 	b.ClearSSHConfig()
-	ipmiCli := b.GetIPMITool()
-	if ipmiCli != nil {
-		return ipmitool.DoRebootToDisk(ipmiCli)
+	bmcCli, err := b.GetBMC()
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("Baremetal %s ipmitool is nil", b.GetId())
+	return bmc.DoRebootToDisk(bmcCli)
 }
 */
 
 func (b *SBaremetalInstance) GetPowerStatus() (string, error) {
-	ipmiCli := b.GetIPMITool()
-	if ipmiCli == nil {
-		return "", fmt.Errorf("Baremetal %s ipmitool is nil", b.GetId())
+	bmcCli, err := b.GetBMC()
+	if err != nil {
+		return "", err
 	}
-	return ipmitool.GetChassisPowerStatus(ipmiCli)
+	return bmcCli.GetPowerStatus()
 }
 
 func (b *SBaremetalInstance) DoPowerShutdown(soft bool) error {
 	b.ClearSSHConfig()
-	ipmiCli := b.GetIPMITool()
-	if ipmiCli != nil {
-		if soft {
-			return ipmitool.DoSoftShutdown(ipmiCli)
-		}
-		return ipmitool.DoHardShutdown(ipmiCli)
+	bmcCli, err := b.GetBMC()
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("Baremetal %s ipmitool is nil", b.GetId())
+	return bmcCli.DoPowerShutdown(soft)
 }
 
 func (b *SBaremetalInstance) GetStorageDriver() string {
@@ -1095,10 +1149,71 @@ func (b *SBaremetalInstance) StartServerDestroyTask(userCred mcclient.TokenCrede
 	b.StartNewTask(tasks.NewBaremetalServerDestroyTask, taskId, data)
 }
 
+func (b *SBaremetalInstance) StartBaremetalDiskWipeTask(userCred mcclient.TokenCredential, taskId string, data jsonutils.JSONObject) {
+	b.StartNewTask(tasks.NewBaremetalDiskWipeTask, taskId, data)
+}
+
+func (b *SBaremetalInstance) DoDiskWipe(term *ssh.Client, mode string) error {
+	if mode == "" {
+		mode = o.Options.DiskWipeMode
+	}
+	if mode == api.DISK_WIPE_MODE_NONE {
+		return nil
+	}
+	// region won't offer the baremetal again before wipe is verified
+	if err := b.reportDiskWipe(api.DISK_WIPE_STATUS_WIPING, nil, ""); err != nil {
+		return fmt.Errorf("Report disk wipe start: %v", err)
+	}
+	disks, err := diskwipe.GetDisks(term)
+	if err == nil && len(disks) == 0 {
+		err = fmt.Errorf("No disk found")
+	}
+	if err != nil {
+		b.reportDiskWipe(api.DISK_WIPE_STATUS_FAILED, nil, err.Error())
+		return err
+	}
+	certs := diskwipe.NewWiper(term, mode, o.Options.DiskWipePasses).WipeDisks(disks)
+	failed := []string{}
+	for _, cert := range certs {
+		if !cert.Verified {
+			failed = append(failed, cert.Dev)
+		}
+	}
+	if len(failed) > 0 {
+		err = fmt.Errorf("Wipe of disks %s not verified", strings.Join(failed, ","))
+		b.reportDiskWipe(api.DISK_WIPE_STATUS_FAILED, certs, err.Error())
+		return err
+	}
+	return b.reportDiskWipe(api.DISK_WIPE_STATUS_VERIFIED, certs, "")
+}
+
+func (b *SBaremetalInstance) reportDiskWipe(status string, certs []*api.BaremetalDiskWipeCertificate, reason string) error {
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString(status), "status")
+	if certs != nil {
+		params.Add(jsonutils.Marshal(certs), "certificates")
+	}
+	if reason != "" {
+		params.Add(jsonutils.NewString(reason), "reason")
+	}
+	_, err := modules.Hosts.PerformAction(b.GetClientSession(), b.GetId(), "disk-wipe-report", params)
+	if err != nil {
+		log.Errorf("Report baremetal %s disk wipe %s error: %v", b.GetId(), status, err)
+	}
+	return err
+}
+
 func (b *SBaremetalInstance) DelayedSyncIPMIInfo(data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
-	ipmiCli := b.GetIPMITool()
+	bmcCli, err := b.GetBMC()
+	if err != nil {
+		return nil, err
+	}
+	ipmiCli, ok := bmcCli.(bmc.ILanConfig)
+	if !ok {
+		return nil, fmt.Errorf("%s bmc does not support lan config", bmcCli.GetBMCType())
+	}
 	lanChannel := b.GetIPMILanChannel()
-	sysInfo, err := ipmitool.GetSysInfo(ipmiCli)
+	sysInfo, err := ipmiCli.GetSysInfo()
 	if err != nil {
 		return nil, err
 	}
@@ -1107,7 +1222,7 @@ func (b *SBaremetalInstance) DelayedSyncIPMIInfo(data jsonutils.JSONObject) (jso
 	}
 	retObj := make(map[string]string)
 	if ipAddr, _ := data.GetString("ip_addr"); ipAddr != "" {
-		err = ipmitool.SetLanStaticIP(ipmiCli, lanChannel, ipAddr)
+		err = ipmiCli.SetLanStaticIP(lanChannel, ipAddr)
 		if err != nil {
 			return nil, err
 		}
@@ -1115,7 +1230,7 @@ func (b *SBaremetalInstance) DelayedSyncIPMIInfo(data jsonutils.JSONObject) (jso
 		retObj["ipmi_ip_addr"] = ipAddr
 	}
 	if passwd, _ := data.GetString("password"); passwd != "" {
-		err = ipmitool.SetLanPasswd(ipmiCli, ipmitool.GetRootId(sysInfo), passwd)
+		err = ipmiCli.SetLanPasswd(ipmitool.GetRootId(sysInfo), passwd)
 		if err != nil {
 			return nil, err
 		}
@@ -1488,7 +1603,32 @@ func (s *SBaremetalServer) deployFs(term *ssh.Client, deployInfo *guestfs.SDeplo
 	if strings.ToLower(rootfs.GetOs()) == "windows" {
 		return nil, fmt.Errorf("Unsupported OS: %s", rootfs.GetOs())
 	}
-	return guestfs.DeployGuestFs(rootfs, s.desc, deployInfo)
+	ret, err := guestfs.DeployGuestFs(rootfs, s.desc, deployInfo)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.deployMdadmConfig(term, rootfs, layouts); err != nil {
+		return nil, fmt.Errorf("Deploy mdadm config: %v", err)
+	}
+	return ret, nil
+}
+
+func (s *SBaremetalServer) deployMdadmConfig(term *ssh.Client, rootfs fsdriver.IRootFsDriver, layouts []baremetal.Layout) error {
+	hasSoftRaid := false
+	for _, layout := range layouts {
+		if layout.Conf.Conf != baremetal.DISK_CONF_NONE && baremetal.DISK_DRIVERS_SOFT_RAID.Has(layout.Disks[0].Driver) {
+			hasSoftRaid = true
+			break
+		}
+	}
+	if !hasSoftRaid {
+		return nil
+	}
+	arrays, err := mdadm.GetDetailScan(term)
+	if err != nil {
+		return err
+	}
+	return rootfs.DeployMdadmConfig(rootfs.GetPartition(), arrays)
 }
 
 func (s *SBaremetalServer) GetNics() []types.SServerNic {
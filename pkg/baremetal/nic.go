@@ -17,13 +17,12 @@ package baremetal
 import (
 	"fmt"
 	"net"
-	"os"
-	"path/filepath"
 	"time"
 
 	"yunion.io/x/pkg/util/netutils"
 
 	o "yunion.io/x/onecloud/pkg/baremetal/options"
+	"yunion.io/x/onecloud/pkg/baremetal/pxe"
 	"yunion.io/x/onecloud/pkg/cloudcommon/types"
 	"yunion.io/x/onecloud/pkg/util/dhcp"
 )
@@ -78,20 +77,9 @@ func GetNicDHCPConfig(
 		default:
 			conf.BootFile = "pxelinux.0"
 		}
-		pxePath := filepath.Join(o.Options.TftpRoot, conf.BootFile)
-		if f, err := os.Open(pxePath); err != nil {
+		conf.BootBlock, err = pxe.GetTftpFileBlocks(conf.BootFile)
+		if err != nil {
 			return nil, err
-		} else {
-			if info, err := f.Stat(); err != nil {
-				return nil, err
-			} else {
-				pxeSize := info.Size()
-				pxeBlk := pxeSize / 512
-				if pxeSize > pxeBlk*512 {
-					pxeBlk += 1
-				}
-				conf.BootBlock = uint16(pxeBlk)
-			}
 		}
 	}
 	return conf, nil
@@ -34,9 +34,17 @@ type BaremetalOptions struct {
 	ForceDhcpProbeIpmi     bool   `default:"false" help:"Force DHCP probe IPMI interface network connection"`
 	TftpBlockSizeInBytes   int    `default:"1024" help:"tftp block size, default is 1024"`
 	TftpMaxTimeoutRetries  int    `default:"50" help:"Maximal tftp timeout retries, default is 50"`
+	HttpBootPort           int    `default:"8675" help:"Port of HTTP server serving UEFI HTTP boot and iPXE clients"`
+	EnableIpxeBoot         bool   `default:"false" help:"Chainload iPXE for PXE clients and fetch kernel over HTTP instead of pxelinux over TFTP, undionly.kpxe and ipxe.efi are expected in tftp root"`
+	DiskWipeMode           string `default:"auto" choices:"none|auto|overwrite" help:"How disks are wiped when a server is deleted, auto prefers ATA/NVMe secure erase, then TRIM, then overwrite"`
+	DiskWipePasses         int    `default:"1" help:"Number of overwrite passes of disk wipe, the last pass writes zeros"`
 	LengthyWorkerCount     int    `default:"8" help:"Parallel worker count for lengthy tasks"`
 	ShortWorkerCount       int    `default:"8" help:"Parallel worker count for short-lived tasks"`
 
+	TelemetryIntervalSeconds   int     `default:"300" help:"Interval in seconds of collecting sensor, SEL and firmware telemetry from BMC, 0 disables it"`
+	TemperatureWarningCelsius  float64 `default:"75" help:"Temperature warning threshold used when BMC reports none"`
+	TemperatureCriticalCelsius float64 `default:"85" help:"Temperature critical threshold used when BMC reports none"`
+
 	DefaultIpmiPassword       string `help:"Default IPMI passowrd"`
 	DefaultStrongIpmiPassword string `help:"Default strong IPMI passowrd"`
 
@@ -18,6 +18,8 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"yunion.io/x/jsonutils"
@@ -44,6 +46,8 @@ type NetworkInterfaceIdent struct {
 type DHCPHandler struct {
 	// baremetal manager
 	baremetalManager IBaremetalManager
+	// port of HTTP boot server
+	httpPort int
 }
 
 type dhcpRequest struct {
@@ -53,6 +57,7 @@ type dhcpRequest struct {
 	RelayAddr             net.IP           // IP address of DHCP relay agent
 	Options               dhcp.Options     // dhcp packet options
 	VendorClassId         string
+	UserClass             string
 	ClientArch            uint16
 	NetworkInterfaceIdent NetworkInterfaceIdent
 	ClientGuid            string
@@ -83,6 +88,11 @@ func (h *DHCPHandler) ServeDHCP(pkt dhcp.Packet, _ *net.UDPAddr, _ *net.Interfac
 	if conf == nil {
 		return nil, fmt.Errorf("Empty packet config")
 	}
+	if req.isPXERequest() {
+		if err := req.setBootFile(conf, h.httpPort); err != nil {
+			return nil, err
+		}
+	}
 	return dhcp.MakeReplyPacket(pkt, conf)
 }
 
@@ -110,6 +120,8 @@ func (h *DHCPHandler) newRequest(pkt dhcp.Packet, man IBaremetalManager) (*dhcpR
 			vendorClsId, err = req.Options.String(optCode)
 		case dhcp.OptionClientArchitecture:
 			cliArch, err = req.Options.Uint16(optCode)
+		case dhcp.OptionUserClass:
+			req.UserClass, err = req.Options.String(optCode)
 		case dhcp.OptionClientNetworkInterfaceIdentifier:
 			netIfIdentBs, err := req.Options.Bytes(optCode)
 			if err != nil {
@@ -320,7 +332,71 @@ func (req *dhcpRequest) isPXERequest() bool {
 	return dhcp.IsPXERequest(pkt)
 }
 
-func (s *Server) validateDHCP(pkt dhcp.Packet) (Machine, Firmware, error) {
+// isIPXE returns whether request comes from iPXE, which identifies itself in
+// user class option
+func (req *dhcpRequest) isIPXE() bool {
+	return strings.Contains(req.UserClass, "iPXE")
+}
+
+// setBootFile points firmware to the boot file fitting it.  UEFI HTTP boot
+// clients chainload iPXE over HTTP, iPXE fetches script of the machine over
+// HTTP, and PXE clients chainload iPXE over TFTP if enabled, or keep the
+// pxelinux config decided by baremetal instance
+func (req *dhcpRequest) setBootFile(conf *dhcp.ResponseConfig, httpPort int) error {
+	_, fwtype, err := validateDHCP(req.packet)
+	if err != nil {
+		// keep boot file decided by baremetal instance
+		log.Warningf("[DHCP] %s: %v", req.ClientMac, err)
+		return nil
+	}
+	serverIP := conf.ServerIP.String()
+	switch {
+	case req.isIPXE():
+		conf.BootFile = GetIPXEScriptUrl(serverIP, httpPort, req.ClientMac)
+		conf.BootBlock = 0
+	case fwtype.IsHTTP():
+		conf.BootServer = ""
+		conf.BootFile = GetHTTPBootUrl(serverIP, httpPort) + HTTPFilePrefix + getIPXEBootFile(fwtype)
+		conf.BootBlock = 0
+		conf.VendorClassId = dhcp.HTTPCLIENT
+	case o.Options.EnableIpxeBoot:
+		conf.BootFile = getIPXEBootFile(fwtype)
+		conf.BootBlock, err = GetTftpFileBlocks(conf.BootFile)
+		if err != nil {
+			return err
+		}
+	}
+	log.Infof("[DHCP] %s firmware %d ipxe %v boot from %s", req.ClientMac, fwtype, req.isIPXE(), conf.BootFile)
+	return nil
+}
+
+func getIPXEBootFile(fwtype Firmware) string {
+	switch fwtype {
+	case FirmwareEFI32, FirmwareEFI32HTTP:
+		return "ipxe-i386.efi"
+	case FirmwareEFI64, FirmwareEFIBC, FirmwareEFI64HTTP:
+		return "ipxe.efi"
+	default:
+		return "undionly.kpxe"
+	}
+}
+
+// GetTftpFileBlocks returns size in 512 bytes blocks of file under TFTP
+// root, which is reported in DHCP option 13
+func GetTftpFileBlocks(name string) (uint16, error) {
+	info, err := os.Stat(filepath.Join(o.Options.TftpRoot, name))
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	blocks := size / 512
+	if size > blocks*512 {
+		blocks += 1
+	}
+	return uint16(blocks), nil
+}
+
+func validateDHCP(pkt dhcp.Packet) (Machine, Firmware, error) {
 	var mach Machine
 	var fwtype Firmware
 	fwt, err := pkt.ParseOptions().Uint16(dhcp.OptionClientArchitecture)
@@ -372,8 +448,19 @@ func (s *Server) validateDHCP(pkt dhcp.Packet) (Machine, Firmware, error) {
 		// EFI x86-64
 		mach.Arch = ArchX64
 		fwtype = FirmwareEFIBC
+	case 15:
+		// EFI x86 HTTP boot
+		mach.Arch = ArchIA32
+		fwtype = FirmwareEFI32HTTP
+	case 16:
+		// EFI x86-64 HTTP boot
+		mach.Arch = ArchX64
+		fwtype = FirmwareEFI64HTTP
 	default:
-		return mach, 0, fmt.Errorf("unsupported client firmware type '%d'", fwtype)
+		return mach, 0, fmt.Errorf("unsupported client firmware type '%d'", fwt)
+	}
+	if fwtype.IsHTTP() && !strings.HasPrefix(getVendorClassId(pkt), dhcp.HTTPCLIENT) {
+		return mach, 0, fmt.Errorf("HTTP boot client architecture %d without %s vendor class", fwt, dhcp.HTTPCLIENT)
 	}
 
 	guid, _ := pkt.ParseOptions().Bytes(dhcp.OptionClientMachineIdentifier)
@@ -395,3 +482,8 @@ func (s *Server) validateDHCP(pkt dhcp.Packet) (Machine, Firmware, error) {
 	mach.MAC = pkt.CHAddr()
 	return mach, fwtype, nil
 }
+
+func getVendorClassId(pkt dhcp.Packet) string {
+	vendorClsId, _ := pkt.ParseOptions().String(dhcp.OptionVendorClassIdentifier)
+	return vendorClsId
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pxe
+
+import (
+	"net"
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/util/dhcp"
+)
+
+func TestSetBootFile(t *testing.T) {
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	cases := []struct {
+		name       string
+		arch       uint16
+		vendor     string
+		userClass  string
+		bootFile   string
+		vendorResp string
+	}{
+		{
+			name:       "UEFI HTTP boot",
+			arch:       16,
+			vendor:     "HTTPClient:Arch:00016:UNDI:003001",
+			bootFile:   "http://10.0.0.1:8675/tftp/ipxe.efi",
+			vendorResp: dhcp.HTTPCLIENT,
+		},
+		{
+			name:     "HTTP arch without HTTPClient vendor class",
+			arch:     16,
+			vendor:   "PXEClient:Arch:00016",
+			bootFile: "pxelinux.0",
+		},
+		{
+			name:      "iPXE",
+			arch:      0,
+			vendor:    "PXEClient:Arch:00000:UNDI:002001",
+			userClass: "iPXE",
+			bootFile:  "http://10.0.0.1:8675/ipxe/00-11-22-33-44-55",
+		},
+		{
+			name:     "legacy PXE",
+			arch:     0,
+			vendor:   "PXEClient:Arch:00000:UNDI:002001",
+			bootFile: "pxelinux.0",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			opts := []dhcp.Option{
+				{Code: dhcp.OptionClientArchitecture, Value: []byte{byte(c.arch >> 8), byte(c.arch)}},
+				{Code: dhcp.OptionVendorClassIdentifier, Value: []byte(c.vendor)},
+			}
+			if c.userClass != "" {
+				opts = append(opts, dhcp.Option{Code: dhcp.OptionUserClass, Value: []byte(c.userClass)})
+			}
+			pkt := dhcp.RequestPacket(dhcp.Discover, mac, nil, []byte{1, 2, 3, 4}, false, opts)
+			req, err := (&DHCPHandler{}).newRequest(pkt, nil)
+			if err != nil {
+				t.Fatalf("newRequest: %v", err)
+			}
+			conf := &dhcp.ResponseConfig{
+				ServerIP:   net.ParseIP("10.0.0.1"),
+				BootServer: "10.0.0.1",
+				BootFile:   "pxelinux.0",
+			}
+			if err := req.setBootFile(conf, 8675); err != nil {
+				t.Fatalf("setBootFile: %v", err)
+			}
+			if conf.BootFile != c.bootFile {
+				t.Errorf("want boot file %s, got %s", c.bootFile, conf.BootFile)
+			}
+			if conf.VendorClassId != c.vendorResp {
+				t.Errorf("want vendor class %q, got %q", c.vendorResp, conf.VendorClassId)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pxe
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"yunion.io/x/log"
+)
+
+const (
+	// HTTPFilePrefix serves files of TFTP root directory over HTTP
+	HTTPFilePrefix = "/tftp/"
+	// HTTPIPXEPrefix serves per machine iPXE script, followed by client mac
+	HTTPIPXEPrefix = "/ipxe/"
+)
+
+// HTTPHandler serves UEFI HTTP boot and iPXE clients.  Boot loaders, kernel
+// and initramfs are served from the TFTP root directory, which is much
+// faster than TFTP for large images
+type HTTPHandler struct {
+	RootDir          string
+	BaremetalManager IBaremetalManager
+
+	fileHandler http.Handler
+}
+
+func NewHTTPHandler(rootDir string, baremetalManager IBaremetalManager) *HTTPHandler {
+	return &HTTPHandler{
+		RootDir:          rootDir,
+		BaremetalManager: baremetalManager,
+		fileHandler:      http.StripPrefix(HTTPFilePrefix, http.FileServer(http.Dir(rootDir))),
+	}
+}
+
+func GetHTTPBootUrl(serverIP string, port int) string {
+	return fmt.Sprintf("http://%s:%d", serverIP, port)
+}
+
+// GetIPXEScriptUrl returns url of iPXE script of machine with mac
+func GetIPXEScriptUrl(serverIP string, port int, mac net.HardwareAddr) string {
+	return GetHTTPBootUrl(serverIP, port) + HTTPIPXEPrefix + strings.Replace(mac.String(), ":", "-", -1)
+}
+
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Debugf("[HTTP] %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, HTTPFilePrefix):
+		h.fileHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, HTTPIPXEPrefix):
+		h.sendIPXEScript(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *HTTPHandler) sendIPXEScript(w http.ResponseWriter, r *http.Request) {
+	macStr := strings.TrimPrefix(r.URL.Path, HTTPIPXEPrefix)
+	mac, err := net.ParseMAC(macStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid mac %q: %v", macStr, err), http.StatusBadRequest)
+		return
+	}
+	bmInstance := h.BaremetalManager.GetBaremetalByMac(mac)
+	if bmInstance == nil {
+		log.Errorf("[HTTP] Not found baremetal instance by mac: %s", mac)
+		http.NotFound(w, r)
+		return
+	}
+	// use the address client reached us by, so does the script
+	script := bmInstance.GetIPXEScript("http://" + r.Host + strings.TrimSuffix(HTTPFilePrefix, "/"))
+	log.Debugf("[HTTP] iPXE script for %s: %s", mac, script)
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(script))
+}
+
+func (s *Server) serveHTTP(l net.Listener, handler *HTTPHandler) error {
+	err := http.Serve(l, handler)
+	if err != nil {
+		return fmt.Errorf("HTTP boot server shut down: %v", err)
+	}
+	return nil
+}
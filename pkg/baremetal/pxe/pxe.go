@@ -29,6 +29,7 @@ import (
 const (
 	portDHCP = 67
 	portTFTP = 69
+	portHTTP = 8675
 )
 
 // Architecture describes a kind of CPU architecture
@@ -73,14 +74,30 @@ type Firmware int
 
 // The bootloaders that pxe knows how to handle
 const (
-	FirmwareX86PC   Firmware = iota // "Classic" x86 BIOS with PXE/UNDI support
-	FirmwareEFI32                   // 32-bit x86 processor running EFI
-	FirmwareEFI64                   // 64-bit x86 processor running EFI
-	FirmwareEFIBC                   // 64-bit x86 processor running EFI
-	FirmwareX86Ipxe                 // "Classic" x86 BIOS running iPXE (no UNDI support)
+	FirmwareX86PC     Firmware = iota // "Classic" x86 BIOS with PXE/UNDI support
+	FirmwareEFI32                     // 32-bit x86 processor running EFI
+	FirmwareEFI64                     // 64-bit x86 processor running EFI
+	FirmwareEFIBC                     // 64-bit x86 processor running EFI
+	FirmwareX86Ipxe                   // "Classic" x86 BIOS running iPXE (no UNDI support)
+	FirmwareEFI32HTTP                 // 32-bit x86 processor running EFI, booting from HTTP
+	FirmwareEFI64HTTP                 // 64-bit x86 processor running EFI, booting from HTTP
 	FirmwareUnknown
 )
 
+// IsEFI returns whether firmware is some kind of UEFI
+func (f Firmware) IsEFI() bool {
+	switch f {
+	case FirmwareEFI32, FirmwareEFI64, FirmwareEFIBC, FirmwareEFI32HTTP, FirmwareEFI64HTTP:
+		return true
+	}
+	return false
+}
+
+// IsHTTP returns whether firmware loads boot file with UEFI HTTP boot
+func (f Firmware) IsHTTP() bool {
+	return f == FirmwareEFI32HTTP || f == FirmwareEFI64HTTP
+}
+
 type IBaremetalManager interface {
 	GetZoneId() string
 	GetBaremetalByMac(mac net.HardwareAddr) IBaremetalInstance
@@ -96,6 +113,9 @@ type IBaremetalInstance interface {
 	InitAdminNetif(cliMac net.HardwareAddr, netConf *types.SNetworkConfig, nicType string, netType string) error
 	RegisterNetif(cliMac net.HardwareAddr, netConf *types.SNetworkConfig) error
 	GetTFTPResponse() string
+	// GetIPXEScript returns iPXE script booting the machine, files are
+	// fetched from bootUrl
+	GetIPXEScript(bootUrl string) string
 }
 
 type Server struct {
@@ -103,6 +123,7 @@ type Server struct {
 	Address          string
 	DHCPPort         int
 	TFTPPort         int
+	HTTPPort         int
 	TFTPRootDir      string
 	errs             chan error
 	BaremetalManager IBaremetalManager
@@ -118,6 +139,9 @@ func (s *Server) Serve() error {
 	if s.TFTPPort == 0 {
 		s.TFTPPort = portTFTP
 	}
+	if s.HTTPPort == 0 {
+		s.HTTPPort = portHTTP
+	}
 
 	tftpConn, err := net.ListenPacket("udp", fmt.Sprintf("%s:%d", s.Address, s.TFTPPort))
 	if err != nil {
@@ -128,6 +152,12 @@ func (s *Server) Serve() error {
 		return err
 	}
 
+	httpLis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.Address, s.HTTPPort))
+	if err != nil {
+		return err
+	}
+	httpHandler := NewHTTPHandler(s.TFTPRootDir, s.BaremetalManager)
+
 	log.Infof("DHCPServer Bind %s %d", s.Address, s.DHCPPort)
 	dhcpSrv, _, err := dhcp.NewDHCPServer2(s.Address, s.DHCPPort, false)
 	if err != nil {
@@ -136,10 +166,14 @@ func (s *Server) Serve() error {
 
 	s.errs = make(chan error)
 
-	dhcpHandler := &DHCPHandler{baremetalManager: s.BaremetalManager}
+	dhcpHandler := &DHCPHandler{
+		baremetalManager: s.BaremetalManager,
+		httpPort:         s.HTTPPort,
+	}
 
 	go func() { s.errs <- s.serveDHCP(dhcpSrv, dhcpHandler) }()
 	go func() { s.errs <- s.serveTFTP(tftpConn, tftpHandler) }()
+	go func() { s.errs <- s.serveHTTP(httpLis, httpHandler) }()
 
 	err = <-s.errs
 	return err
@@ -33,6 +33,8 @@ const (
 	CONVERTING     = "converting"
 	START_FAIL     = "start_fail"
 	STOP_FAIL      = "stop_fail"
+	WIPING         = "wiping"
+	WIPE_FAIL      = "wipe_fail"
 )
 
 const (
@@ -26,6 +26,7 @@ import (
 
 	o "yunion.io/x/onecloud/pkg/baremetal/options"
 	"yunion.io/x/onecloud/pkg/baremetal/profiles"
+	"yunion.io/x/onecloud/pkg/baremetal/utils/bmc"
 	"yunion.io/x/onecloud/pkg/baremetal/utils/detect_storages"
 	"yunion.io/x/onecloud/pkg/baremetal/utils/ipmitool"
 	"yunion.io/x/onecloud/pkg/cloudcommon/types"
@@ -102,7 +103,7 @@ func (task *sBaremetalPrepareTask) DoPrepare(cli *ssh.Client) error {
 	}
 	// set ipmi nic DHCP
 	if ipmiEnable {
-		sshIPMI := ipmitool.NewSSHIPMI(cli)
+		sshIPMI := bmc.NewIPMIBMCWithExecutor(ipmitool.NewSSHIPMI(cli))
 		// ipmitool.SetSysInfo
 		ipmiSysInfo := sysInfo.ToIPMISystemInfo()
 		SetIPMILanPortShared(sshIPMI, ipmiSysInfo)
@@ -113,7 +114,7 @@ func (task *sBaremetalPrepareTask) DoPrepare(cli *ssh.Client) error {
 		var ipmiLanChannel int = -1
 		for _, lanChannel := range ipmitool.GetLanChannels(ipmiSysInfo) {
 			log.Infof("Try lan channel %d ...", lanChannel)
-			conf, err := ipmitool.GetLanConfig(sshIPMI, lanChannel)
+			conf, err := sshIPMI.GetLanConfig(lanChannel)
 			if err != nil {
 				log.Errorf("Get lan channel %d config error: %v", lanChannel, err)
 				continue
@@ -131,12 +132,12 @@ func (task *sBaremetalPrepareTask) DoPrepare(cli *ssh.Client) error {
 			}
 			task.sendNicInfo(ipmiNic, -1, types.NIC_TYPE_IPMI, true, "")
 			rootId := ipmitool.GetRootId(ipmiSysInfo)
-			err = ipmitool.SetLanUserPasswd(sshIPMI, lanChannel, rootId, ipmiUser, ipmiPasswd)
+			err = sshIPMI.SetLanUserPasswd(lanChannel, rootId, ipmiUser, ipmiPasswd)
 			if err != nil {
 				// ignore the error
 				log.Errorf("Lan channel %d set user password error: %v", lanChannel, err)
 			}
-			err = ipmitool.EnableLanAccess(sshIPMI, lanChannel)
+			err = sshIPMI.EnableLanAccess(lanChannel)
 			if err != nil {
 				// ignore the error
 				log.Errorf("Lan channel %d enable lan access error: %v", lanChannel, err)
@@ -169,7 +170,7 @@ func (task *sBaremetalPrepareTask) DoPrepare(cli *ssh.Client) error {
 				task.baremetal.SetExistingIPMIIPAddr(tryAddrs[0])
 			}
 
-			err = ipmitool.SetLanDHCP(sshIPMI, lanChannel)
+			err = sshIPMI.SetLanDHCP(lanChannel)
 			if err != nil {
 				log.Errorf("Set lan channel %d dhcp error: %v", lanChannel, err)
 			}
@@ -180,7 +181,7 @@ func (task *sBaremetalPrepareTask) DoPrepare(cli *ssh.Client) error {
 				nic = task.baremetal.GetIPMINic(conf.Mac)
 			}
 			if len(nic.IpAddr) == 0 {
-				err = ipmitool.DoBMCReset(sshIPMI) // do BMC reset to force DHCP request
+				err = sshIPMI.DoBMCReset() // do BMC reset to force DHCP request
 				if err != nil {
 					log.Errorf("Do BMC reset error: %v", err)
 				}
@@ -196,7 +197,7 @@ func (task *sBaremetalPrepareTask) DoPrepare(cli *ssh.Client) error {
 			var tried int = 0
 			for tried < maxTries {
 				time.Sleep(2 * time.Second)
-				lanConf, err := ipmitool.GetLanConfig(sshIPMI, lanChannel)
+				lanConf, err := sshIPMI.GetLanConfig(lanChannel)
 				if err != nil {
 					log.Errorf("Get lan config at channel %d error: %v", lanChannel, err)
 					tried += 2
@@ -211,8 +212,7 @@ func (task *sBaremetalPrepareTask) DoPrepare(cli *ssh.Client) error {
 			if tried >= maxTries {
 				continue
 			}
-			err = ipmitool.SetLanStatic(
-				sshIPMI,
+			err = sshIPMI.SetLanStatic(
 				lanChannel,
 				nic.IpAddr,
 				nic.GetNetMask(),
@@ -294,14 +294,14 @@ func (task *sBaremetalPrepareTask) DoPrepare(cli *ssh.Client) error {
 	return nil
 }
 
-func (task *sBaremetalPrepareTask) tryLocalIpmiAddr(sshIPMI *ipmitool.SSHIPMI, ipmiNic *types.SNicDevInfo, lanChannel int, ipmiUser, ipmiPasswd, tryAddr string) bool {
+func (task *sBaremetalPrepareTask) tryLocalIpmiAddr(sshIPMI *bmc.SIPMIBMC, ipmiNic *types.SNicDevInfo, lanChannel int, ipmiUser, ipmiPasswd, tryAddr string) bool {
 	log.Infof("IP addr found in IPMI config, try use %s as IPMI address", tryAddr)
 	ipConf, err := task.getIPMIIPConfig(tryAddr)
 	if err != nil {
 		log.Errorf("Failed to get IPMI ipconfig for %s", tryAddr)
 		return false
 	}
-	err = ipmitool.SetLanStatic(sshIPMI, lanChannel, ipConf.IPAddr, ipConf.Netmask, ipConf.Gateway)
+	err = sshIPMI.SetLanStatic(lanChannel, ipConf.IPAddr, ipConf.Netmask, ipConf.Gateway)
 	if err != nil {
 		log.Errorf("Failed to set IPMI static net config %#v for %s", *ipConf, tryAddr)
 		return false
@@ -313,7 +313,7 @@ func (task *sBaremetalPrepareTask) tryLocalIpmiAddr(sshIPMI *ipmitool.SSHIPMI, i
 	maxTries := 5
 	time.Sleep(2 * time.Second)
 	for tried = 0; tried < maxTries; tried += 1 {
-		conf, err = ipmitool.GetLanConfig(sshIPMI, lanChannel)
+		conf, err = sshIPMI.GetLanConfig(lanChannel)
 		if err != nil {
 			log.Errorf("Failed to get lan config after set static network: %v", err)
 			continue
@@ -329,9 +329,9 @@ func (task *sBaremetalPrepareTask) tryLocalIpmiAddr(sshIPMI *ipmitool.SSHIPMI, i
 		log.Errorf("Failed to get lan config after %d tries", tried)
 		return false
 	}
-	rmcpIPMI := ipmitool.NewLanPlusIPMI(tryAddr, ipmiUser, ipmiPasswd)
+	rmcpIPMI := bmc.NewIPMIBMC(tryAddr, ipmiUser, ipmiPasswd)
 	for tried = 0; tried < maxTries; tried += 1 {
-		conf2, err := ipmitool.GetLanConfig(rmcpIPMI, lanChannel)
+		conf2, err := rmcpIPMI.GetLanConfig(lanChannel)
 		if err != nil {
 			log.Errorf("Failed to get lan channel %d config use RMCP mode: %v", lanChannel, err)
 			continue
@@ -559,18 +559,11 @@ func (task *sBaremetalPrepareTask) collectDiskInfo(diskInfo []*baremetal.Baremet
 	return size, diskType
 }
 
-func SetIPMILanPortShared(cli ipmitool.IPMIExecutor, sysInfo *types.SIPMISystemInfo) {
+func SetIPMILanPortShared(cli bmc.ILanConfig, sysInfo *types.SIPMISystemInfo) {
 	if !o.Options.IpmiLanPortShared {
 		return
 	}
-	oemName := strings.ToLower(sysInfo.Manufacture)
-	var err error
-	if strings.Contains(oemName, "huawei") {
-		err = ipmitool.SetHuaweiIPMILanPortShared(cli)
-	} else if strings.Contains(oemName, "dell") {
-		err = ipmitool.SetDellIPMILanPortShared(cli)
-	}
-	if err != nil {
-		log.Errorf("Set %s ipmi lan port shared failed: %v", oemName, err)
+	if err := cli.SetLanPortShared(sysInfo.Manufacture); err != nil {
+		log.Errorf("Set %s ipmi lan port shared failed: %v", sysInfo.Manufacture, err)
 	}
 }
@@ -15,9 +15,12 @@
 package tasks
 
 import (
+	"fmt"
+
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 
+	baremetalstatus "yunion.io/x/onecloud/pkg/baremetal/status"
 	"yunion.io/x/onecloud/pkg/util/ssh"
 )
 
@@ -44,6 +47,13 @@ func (self *SBaremetalServerDestroyTask) DoDeploys(term *ssh.Client) (jsonutils.
 	if err := self.Baremetal.GetServer().DoEraseDisk(term); err != nil {
 		log.Errorf("Delete server do erase disk: %v", err)
 	}
+	// wipe before tearing down raid, so logical volumes are still there.
+	// The server is kept if wipe fails, so the baremetal is not released
+	// until a retried deletion wipes the disks
+	if err := self.Baremetal.DoDiskWipe(term, ""); err != nil {
+		self.Baremetal.SyncStatus(baremetalstatus.WIPE_FAIL, err.Error())
+		return nil, fmt.Errorf("Delete server do disk wipe: %v", err)
+	}
 	if err := self.Baremetal.GetServer().DoDiskUnconfig(term); err != nil {
 		log.Errorf("Baremetal do disk unconfig: %v", err)
 	}
@@ -0,0 +1,61 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+
+	"yunion.io/x/jsonutils"
+
+	baremetalstatus "yunion.io/x/onecloud/pkg/baremetal/status"
+	"yunion.io/x/onecloud/pkg/util/ssh"
+)
+
+// SBaremetalDiskWipeTask wipes disks of a baremetal without server, e.g.
+// to retry a wipe failed on server deletion
+type SBaremetalDiskWipeTask struct {
+	*SBaremetalPXEBootTaskBase
+}
+
+func NewBaremetalDiskWipeTask(
+	baremetal IBaremetal,
+	taskId string,
+	data jsonutils.JSONObject,
+) (ITask, error) {
+	task := new(SBaremetalDiskWipeTask)
+	baseTask := newBaremetalPXEBootTaskBase(baremetal, taskId, data)
+	task.SBaremetalPXEBootTaskBase = baseTask
+	_, err := baseTask.InitPXEBootTask(task, data)
+	return task, err
+}
+
+func (self *SBaremetalDiskWipeTask) GetName() string {
+	return "BaremetalDiskWipeTask"
+}
+
+func (self *SBaremetalDiskWipeTask) OnPXEBoot(ctx context.Context, term *ssh.Client, args interface{}) error {
+	self.Baremetal.SyncStatus(baremetalstatus.WIPING, "")
+	mode, _ := self.data.GetString("mode")
+	if err := self.Baremetal.DoDiskWipe(term, mode); err != nil {
+		self.Baremetal.SyncStatus(baremetalstatus.WIPE_FAIL, err.Error())
+		return err
+	}
+	if err := self.EnsurePowerShutdown(false); err != nil {
+		return err
+	}
+	self.Baremetal.AutoSyncStatus()
+	SetTaskComplete(self, nil)
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	baremetaltypes "yunion.io/x/onecloud/pkg/baremetal/types"
 	"yunion.io/x/onecloud/pkg/cloudcommon/types"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/ssh"
 )
 
 type IBaremetal interface {
@@ -50,5 +51,9 @@ type IBaremetal interface {
 	DoPXEBoot() error
 	// DoDiskBoot() error
 
+	// DoDiskWipe wipes all disks by mode, or by the configured mode if mode
+	// is empty, and reports wipe certificates to region
+	DoDiskWipe(term *ssh.Client, mode string) error
+
 	RemoveServer()
 }
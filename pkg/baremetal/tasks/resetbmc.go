@@ -20,6 +20,7 @@ import (
 
 	"yunion.io/x/jsonutils"
 
+	"yunion.io/x/onecloud/pkg/baremetal/utils/bmc"
 	"yunion.io/x/onecloud/pkg/baremetal/utils/ipmitool"
 	"yunion.io/x/onecloud/pkg/cloudcommon/types"
 	"yunion.io/x/onecloud/pkg/util/ssh"
@@ -46,14 +47,16 @@ func (self *SBaremetalResetBMCTask) GetName() string {
 	return "BaremetalResetBMCTask"
 }
 
-func (self *SBaremetalResetBMCTask) GetIPMITool() *ipmitool.SSHIPMI {
-	return ipmitool.NewSSHIPMI(self.term)
+// GetBMC returns BMC client running ipmitool in band on the PXE booted
+// server, which keeps working while the BMC network is down
+func (self *SBaremetalResetBMCTask) GetBMC() bmc.IBMC {
+	return bmc.NewIPMIBMCWithExecutor(ipmitool.NewSSHIPMI(self.term))
 }
 
 func (self *SBaremetalResetBMCTask) OnPXEBoot(ctx context.Context, term *ssh.Client, args interface{}) error {
 	self.term = term
 	self.SetStage(self.WaitForBMCReady)
-	err := ipmitool.DoBMCReset(self.GetIPMITool())
+	err := self.GetBMC().DoBMCReset()
 	if err != nil {
 		return err
 	}
@@ -64,7 +67,7 @@ func (self *SBaremetalResetBMCTask) OnPXEBoot(ctx context.Context, term *ssh.Cli
 
 func (self *SBaremetalResetBMCTask) WaitForBMCReady(ctx context.Context, args interface{}) error {
 	self.SetStage(self.OnBMCReady)
-	status, err := ipmitool.GetChassisPowerStatus(self.GetIPMITool())
+	status, err := self.GetBMC().GetPowerStatus()
 	if err != nil {
 		return err
 	}
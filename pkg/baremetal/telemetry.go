@@ -0,0 +1,100 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baremetal
+
+import (
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/util/workqueue"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	o "yunion.io/x/onecloud/pkg/baremetal/options"
+	"yunion.io/x/onecloud/pkg/baremetal/utils/bmc"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+)
+
+const (
+	// count of latest SEL events reported each round, region tracks the
+	// last one seen so older events are not alerted again
+	TELEMETRY_SEL_EVENT_COUNT = 100
+)
+
+func (m *SBaremetalManager) startTelemetryCollector() {
+	if o.Options.TelemetryIntervalSeconds <= 0 {
+		log.Infof("Baremetal telemetry collection disabled")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(o.Options.TelemetryIntervalSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.collectTelemetry()
+			case <-m.stopTelemetry:
+				return
+			}
+		}
+	}()
+}
+
+func (m *SBaremetalManager) collectTelemetry() {
+	bms := make([]*SBaremetalInstance, 0)
+	for _, bm := range m.GetBaremetals() {
+		if bm.GetIPMIConfig() != nil {
+			bms = append(bms, bm)
+		}
+	}
+	workqueue.Parallelize(4, len(bms), func(i int) {
+		if err := bms[i].CollectTelemetry(); err != nil {
+			log.Errorf("Collect telemetry of baremetal %s: %v", bms[i].GetId(), err)
+		}
+	})
+}
+
+// CollectTelemetry reads inventory, firmware versions, sensors and latest
+// SEL events from BMC and reports them to region, parts BMC fails to give
+// are left out of the report
+func (b *SBaremetalInstance) CollectTelemetry() error {
+	bmcCli, err := b.GetBMC()
+	if err != nil {
+		return err
+	}
+	tel, ok := bmcCli.(bmc.ITelemetry)
+	if !ok {
+		return nil
+	}
+	report := api.BaremetalTelemetry{CollectedAt: time.Now().UTC()}
+	if inv, ok := bmcCli.(bmc.IInventory); ok {
+		if report.Inventory, err = inv.GetInventory(); err != nil {
+			log.Warningf("Get inventory of baremetal %s: %v", b.GetId(), err)
+		}
+	}
+	if report.Firmwares, err = tel.GetFirmwares(); err != nil {
+		log.Warningf("Get firmwares of baremetal %s: %v", b.GetId(), err)
+	}
+	if report.Sensors, err = tel.GetSensors(); err != nil {
+		log.Warningf("Get sensors of baremetal %s: %v", b.GetId(), err)
+	} else {
+		bmc.ApplyThresholds(report.Sensors, o.Options.TemperatureWarningCelsius, o.Options.TemperatureCriticalCelsius)
+	}
+	if report.SelEvents, err = tel.GetSelEvents(TELEMETRY_SEL_EVENT_COUNT); err != nil {
+		log.Warningf("Get SEL events of baremetal %s: %v", b.GetId(), err)
+	}
+	_, err = modules.Hosts.PerformAction(b.GetClientSession(), b.GetId(), "telemetry-report", jsonutils.Marshal(report))
+	return err
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bmc
+
+import (
+	"fmt"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/types"
+)
+
+const (
+	BOOT_DEV_PXE   = "pxe"
+	BOOT_DEV_DISK  = "disk"
+	BOOT_DEV_BIOS  = "bios"
+	BOOT_DEV_CDROM = "cdrom"
+	// BOOT_DEV_HTTP is UEFI HTTP boot
+	BOOT_DEV_HTTP = "http"
+)
+
+// IBMC is the out-of-band management interface of a baremetal used by
+// baremetal tasks, whatever protocol the BMC speaks
+type IBMC interface {
+	GetBMCType() string
+
+	GetPowerStatus() (string, error)
+	DoPowerOn() error
+	DoPowerShutdown(soft bool) error
+	// DoReboot hard resets the server, powering it on if it is off
+	DoReboot() error
+
+	// SetBootDev sets boot device used on next boot, or on all following
+	// boots if persistent is true
+	SetBootDev(dev string, persistent bool) error
+
+	DoBMCReset() error
+}
+
+// ILanConfig configures lan channels and user accounts of BMC.  IPMI BMC
+// implements it both in band, through ipmitool run on the booted server,
+// and out of band
+type ILanConfig interface {
+	GetSysInfo() (*types.SIPMISystemInfo, error)
+	GetLanConfig(channel int) (*types.SIPMILanConfig, error)
+	SetLanDHCP(channel int) error
+	SetLanStatic(channel int, ip, mask, gateway string) error
+	SetLanStaticIP(channel int, ip string) error
+	EnableLanAccess(channel int) error
+	SetLanUserPasswd(channel int, userId int, user, password string) error
+	SetLanPasswd(userId int, password string) error
+	// SetLanPortShared makes BMC share lan port with the server, which is
+	// only supported on some vendors' servers
+	SetLanPortShared(manufacture string) error
+}
+
+// IHttpBoot is implemented by BMC able to set UEFI HTTP boot URI
+type IHttpBoot interface {
+	SetHttpBootUri(uri string) error
+}
+
+// IVirtualMedia is implemented by BMC able to attach remote images as
+// virtual CD
+type IVirtualMedia interface {
+	InsertMedia(imageUrl string) error
+	EjectMedia() error
+}
+
+type SInventory = api.BaremetalInventory
+
+// IInventory is implemented by BMC able to report hardware inventory
+type IInventory interface {
+	GetInventory() (*SInventory, error)
+}
+
+// IEventSubscriber is implemented by BMC able to push events to a listener
+type IEventSubscriber interface {
+	// Subscribe asks BMC to post events of eventTypes to destination and
+	// returns id of the subscription
+	Subscribe(destination string, context string, eventTypes []string) (string, error)
+	Unsubscribe(id string) error
+}
+
+// ITelemetry is implemented by BMC able to report sensor readings, system
+// event log and firmware versions
+type ITelemetry interface {
+	GetSensors() ([]api.BaremetalSensor, error)
+	// GetSelEvents returns at most count latest events of system event log
+	GetSelEvents(count int) ([]api.BaremetalSelEvent, error)
+	GetFirmwares() ([]api.BaremetalFirmware, error)
+}
+
+var hardwareStatusSeverity = map[string]int{
+	api.HARDWARE_STATUS_UNKNOWN:  0,
+	api.HARDWARE_STATUS_OK:       1,
+	api.HARDWARE_STATUS_WARNING:  2,
+	api.HARDWARE_STATUS_CRITICAL: 3,
+}
+
+// ApplyThresholds sets tempWarning and tempCritical as thresholds of
+// temperature sensors BMC gives none, then raises status of each sensor to
+// the one its reading deserves if BMC reports a less severe one
+func ApplyThresholds(sensors []api.BaremetalSensor, tempWarning, tempCritical float64) {
+	for i := range sensors {
+		s := &sensors[i]
+		if s.Type == api.SENSOR_TYPE_TEMPERATURE && s.UpperWarning == 0 && s.UpperCritical == 0 {
+			s.UpperWarning = tempWarning
+			s.UpperCritical = tempCritical
+		}
+		status := api.HARDWARE_STATUS_UNKNOWN
+		if s.UpperWarning > 0 || s.UpperCritical > 0 || s.LowerCritical > 0 {
+			status = api.HARDWARE_STATUS_OK
+		}
+		switch {
+		case s.UpperCritical > 0 && s.Reading >= s.UpperCritical,
+			s.LowerCritical > 0 && s.Reading <= s.LowerCritical:
+			status = api.HARDWARE_STATUS_CRITICAL
+		case s.UpperWarning > 0 && s.Reading >= s.UpperWarning:
+			status = api.HARDWARE_STATUS_WARNING
+		}
+		if hardwareStatusSeverity[status] > hardwareStatusSeverity[s.Status] {
+			s.Status = status
+		}
+	}
+}
+
+// NewBMC returns BMC client of the type recorded in ipmi info, ipmi is used
+// if none is set
+func NewBMC(conf *types.SIPMIInfo) (IBMC, error) {
+	switch conf.BmcType {
+	case "", api.BMC_TYPE_IPMI:
+		return NewIPMIBMC(conf.IpAddr, conf.Username, conf.Password), nil
+	case api.BMC_TYPE_REDFISH:
+		return NewRedfishBMC("https://"+conf.IpAddr, conf.Username, conf.Password), nil
+	default:
+		return nil, fmt.Errorf("unsupported bmc type %q", conf.BmcType)
+	}
+}
+
+func DoRebootToPXE(b IBMC) error {
+	if err := b.SetBootDev(BOOT_DEV_PXE, false); err != nil {
+		return err
+	}
+	return b.DoReboot()
+}
+
+func DoRebootToDisk(b IBMC) error {
+	if err := b.SetBootDev(BOOT_DEV_DISK, true); err != nil {
+		return err
+	}
+	return b.DoReboot()
+}
+
+// DoRebootToHttpBoot reboots server with UEFI HTTP boot from uri, or from
+// the URI offered by DHCP if uri is empty
+func DoRebootToHttpBoot(b IBMC, uri string) error {
+	if uri != "" {
+		hb, ok := b.(IHttpBoot)
+		if !ok {
+			return fmt.Errorf("%s bmc does not support setting http boot uri", b.GetBMCType())
+		}
+		if err := hb.SetHttpBootUri(uri); err != nil {
+			return err
+		}
+	} else if err := b.SetBootDev(BOOT_DEV_HTTP, false); err != nil {
+		return err
+	}
+	return b.DoReboot()
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bmc
+
+import (
+	"fmt"
+	"strings"
+
+	"yunion.io/x/pkg/tristate"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/baremetal/utils/ipmitool"
+	"yunion.io/x/onecloud/pkg/cloudcommon/types"
+)
+
+// SIPMIBMC talks to BMC with ipmitool
+type SIPMIBMC struct {
+	exector ipmitool.IPMIExecutor
+}
+
+func NewIPMIBMC(host, user, password string) *SIPMIBMC {
+	return NewIPMIBMCWithExecutor(ipmitool.NewLanPlusIPMI(host, user, password))
+}
+
+func NewIPMIBMCWithExecutor(exector ipmitool.IPMIExecutor) *SIPMIBMC {
+	return &SIPMIBMC{exector: exector}
+}
+
+func (b *SIPMIBMC) GetBMCType() string {
+	return api.BMC_TYPE_IPMI
+}
+
+func (b *SIPMIBMC) GetExecutor() ipmitool.IPMIExecutor {
+	return b.exector
+}
+
+func (b *SIPMIBMC) GetPowerStatus() (string, error) {
+	return ipmitool.GetChassisPowerStatus(b.exector)
+}
+
+func (b *SIPMIBMC) DoPowerOn() error {
+	return ipmitool.DoPowerOn(b.exector)
+}
+
+func (b *SIPMIBMC) DoPowerShutdown(soft bool) error {
+	if soft {
+		return ipmitool.DoSoftShutdown(b.exector)
+	}
+	return ipmitool.DoHardShutdown(b.exector)
+}
+
+func (b *SIPMIBMC) DoReboot() error {
+	return ipmitool.DoReboot(b.exector)
+}
+
+func (b *SIPMIBMC) SetBootDev(dev string, persistent bool) error {
+	switch dev {
+	case BOOT_DEV_PXE:
+		return ipmitool.SetBootFlagPXE(b.exector)
+	case BOOT_DEV_DISK, BOOT_DEV_BIOS, BOOT_DEV_CDROM:
+		return ipmitool.SetBootFlags(b.exector, dev, tristate.True, persistent)
+	default:
+		return fmt.Errorf("ipmi bmc does not support boot device %s", dev)
+	}
+}
+
+func (b *SIPMIBMC) DoBMCReset() error {
+	return ipmitool.DoBMCReset(b.exector)
+}
+
+func (b *SIPMIBMC) GetSysInfo() (*types.SIPMISystemInfo, error) {
+	return ipmitool.GetSysInfo(b.exector)
+}
+
+func (b *SIPMIBMC) GetLanConfig(channel int) (*types.SIPMILanConfig, error) {
+	return ipmitool.GetLanConfig(b.exector, channel)
+}
+
+func (b *SIPMIBMC) SetLanDHCP(channel int) error {
+	return ipmitool.SetLanDHCP(b.exector, channel)
+}
+
+func (b *SIPMIBMC) SetLanStatic(channel int, ip, mask, gateway string) error {
+	return ipmitool.SetLanStatic(b.exector, channel, ip, mask, gateway)
+}
+
+func (b *SIPMIBMC) SetLanStaticIP(channel int, ip string) error {
+	return ipmitool.SetLanStaticIP(b.exector, channel, ip)
+}
+
+func (b *SIPMIBMC) EnableLanAccess(channel int) error {
+	return ipmitool.EnableLanAccess(b.exector, channel)
+}
+
+func (b *SIPMIBMC) SetLanUserPasswd(channel int, userId int, user, password string) error {
+	return ipmitool.SetLanUserPasswd(b.exector, channel, userId, user, password)
+}
+
+func (b *SIPMIBMC) SetLanPasswd(userId int, password string) error {
+	return ipmitool.SetLanPasswd(b.exector, userId, password)
+}
+
+func (b *SIPMIBMC) SetLanPortShared(manufacture string) error {
+	oemName := strings.ToLower(manufacture)
+	switch {
+	case strings.Contains(oemName, "huawei"):
+		return ipmitool.SetHuaweiIPMILanPortShared(b.exector)
+	case strings.Contains(oemName, "dell"):
+		return ipmitool.SetDellIPMILanPortShared(b.exector)
+	}
+	return nil
+}
+
+func (b *SIPMIBMC) GetInventory() (*SInventory, error) {
+	sysInfo, err := ipmitool.GetSysInfo(b.exector)
+	if err != nil {
+		return nil, err
+	}
+	return &SInventory{
+		Manufacture: sysInfo.Manufacture,
+		Model:       sysInfo.Model,
+		SN:          sysInfo.SN,
+	}, nil
+}
+
+func (b *SIPMIBMC) GetSensors() ([]api.BaremetalSensor, error) {
+	return ipmitool.GetSensors(b.exector)
+}
+
+func (b *SIPMIBMC) GetSelEvents(count int) ([]api.BaremetalSelEvent, error) {
+	return ipmitool.GetSelEvents(b.exector, count)
+}
+
+func (b *SIPMIBMC) GetFirmwares() ([]api.BaremetalFirmware, error) {
+	version, err := ipmitool.GetBMCFirmwareVersion(b.exector)
+	if err != nil {
+		return nil, err
+	}
+	return []api.BaremetalFirmware{{Name: "BMC", Version: version}}, nil
+}
@@ -0,0 +1,618 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bmc
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/types"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+const (
+	REDFISH_ROOT = "/redfish/v1"
+
+	redfishTimeout = 60 * time.Second
+)
+
+var redfishBootTargets = map[string]string{
+	BOOT_DEV_PXE:   "Pxe",
+	BOOT_DEV_DISK:  "Hdd",
+	BOOT_DEV_BIOS:  "BiosSetup",
+	BOOT_DEV_CDROM: "Cd",
+	BOOT_DEV_HTTP:  "UefiHttp",
+}
+
+// SRedfishBMC talks to BMC with DMTF Redfish API over HTTPS.  The first
+// system and manager found in the service root are managed
+type SRedfishBMC struct {
+	endpoint string
+	username string
+	password string
+	client   *http.Client
+
+	systemPath  string
+	managerPath string
+	chassisPath string
+}
+
+// NewRedfishBMC returns Redfish client of BMC at endpoint, e.g.
+// https://10.168.1.10.  Certificate of BMC is not verified as most of them
+// are self signed
+func NewRedfishBMC(endpoint, user, password string) *SRedfishBMC {
+	b := &SRedfishBMC{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		username: user,
+		password: password,
+		client:   httputils.GetTimeoutClient(redfishTimeout),
+	}
+	b.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if _, err := b.relativePath(req.URL.String()); err != nil {
+			return err
+		}
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return b
+}
+
+func (b *SRedfishBMC) GetBMCType() string {
+	return api.BMC_TYPE_REDFISH
+}
+
+// relativePath returns path of ref on BMC.  ref is either a path or an
+// absolute url, e.g. taken from Location header or @odata.id, which must
+// point to the BMC itself so that credentials are never sent to other hosts
+func (b *SRedfishBMC) relativePath(ref string) (string, error) {
+	if !strings.HasPrefix(ref, "http://") && !strings.HasPrefix(ref, "https://") {
+		return ref, nil
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid url %q: %s", ref, err)
+	}
+	ep, err := url.Parse(b.endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %s", b.endpoint, err)
+	}
+	if u.Scheme != ep.Scheme || u.Host != ep.Host {
+		return "", fmt.Errorf("url %s is not on bmc %s", ref, b.endpoint)
+	}
+	return strings.TrimPrefix(u.RequestURI(), ep.Path), nil
+}
+
+func (b *SRedfishBMC) request(method httputils.THttpMethod, path string, body jsonutils.JSONObject) (http.Header, jsonutils.JSONObject, error) {
+	relPath, err := b.relativePath(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("redfish %s: %s", method, err)
+	}
+	urlStr := b.endpoint + relPath
+	header := http.Header{}
+	auth := base64.StdEncoding.EncodeToString([]byte(b.username + ":" + b.password))
+	header.Set("Authorization", "Basic "+auth)
+	header.Set("OData-Version", "4.0")
+	hdr, resp, err := httputils.JSONRequest(b.client, context.Background(), method, urlStr, header, body, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("redfish %s %s: %s", method, path, err)
+	}
+	return hdr, resp, nil
+}
+
+func (b *SRedfishBMC) get(path string) (jsonutils.JSONObject, error) {
+	_, resp, err := b.request(httputils.GET, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("redfish GET %s: empty response", path)
+	}
+	return resp, nil
+}
+
+func (b *SRedfishBMC) getMembers(path string) ([]string, error) {
+	coll, err := b.get(path)
+	if err != nil {
+		return nil, err
+	}
+	members, _ := coll.GetArray("Members")
+	ret := make([]string, 0, len(members))
+	for _, m := range members {
+		id, _ := m.GetString("@odata.id")
+		if id != "" {
+			ret = append(ret, id)
+		}
+	}
+	return ret, nil
+}
+
+func (b *SRedfishBMC) getFirstMember(path string) (string, error) {
+	members, err := b.getMembers(path)
+	if err != nil {
+		return "", err
+	}
+	if len(members) == 0 {
+		return "", fmt.Errorf("no member found in %s", path)
+	}
+	return members[0], nil
+}
+
+func (b *SRedfishBMC) getSystemPath() (string, error) {
+	if b.systemPath == "" {
+		path, err := b.getFirstMember(REDFISH_ROOT + "/Systems")
+		if err != nil {
+			return "", err
+		}
+		b.systemPath = path
+	}
+	return b.systemPath, nil
+}
+
+func (b *SRedfishBMC) getManagerPath() (string, error) {
+	if b.managerPath == "" {
+		path, err := b.getFirstMember(REDFISH_ROOT + "/Managers")
+		if err != nil {
+			return "", err
+		}
+		b.managerPath = path
+	}
+	return b.managerPath, nil
+}
+
+// doAction posts params to target of action of resource, falling back to the
+// conventional target path if resource does not advertise it
+func (b *SRedfishBMC) doAction(res jsonutils.JSONObject, resPath string, action string, params jsonutils.JSONObject) error {
+	target, _ := res.GetString("Actions", "#"+action, "target")
+	if target == "" {
+		target = resPath + "/Actions/" + action
+	}
+	_, _, err := b.request(httputils.POST, target, params)
+	return err
+}
+
+func (b *SRedfishBMC) getSystem() (string, jsonutils.JSONObject, error) {
+	path, err := b.getSystemPath()
+	if err != nil {
+		return "", nil, err
+	}
+	system, err := b.get(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, system, nil
+}
+
+func (b *SRedfishBMC) GetPowerStatus() (string, error) {
+	_, system, err := b.getSystem()
+	if err != nil {
+		return "", err
+	}
+	state, err := system.GetString("PowerState")
+	if err != nil {
+		return "", fmt.Errorf("PowerState not found")
+	}
+	return strings.ToLower(state), nil
+}
+
+func (b *SRedfishBMC) resetSystem(resetType string) error {
+	path, system, err := b.getSystem()
+	if err != nil {
+		return err
+	}
+	params := jsonutils.NewDict()
+	params.Set("ResetType", jsonutils.NewString(resetType))
+	log.Debugf("[Redfish] reset system %s: %s", path, resetType)
+	return b.doAction(system, path, "ComputerSystem.Reset", params)
+}
+
+func (b *SRedfishBMC) DoPowerOn() error {
+	return b.resetSystem("On")
+}
+
+func (b *SRedfishBMC) DoPowerShutdown(soft bool) error {
+	if soft {
+		return b.resetSystem("GracefulShutdown")
+	}
+	return b.resetSystem("ForceOff")
+}
+
+func (b *SRedfishBMC) DoReboot() error {
+	status, err := b.GetPowerStatus()
+	if err != nil {
+		return err
+	}
+	if status == types.POWER_STATUS_OFF {
+		return b.DoPowerOn()
+	}
+	return b.resetSystem("ForceRestart")
+}
+
+func (b *SRedfishBMC) setBootOverride(boot *jsonutils.JSONDict) error {
+	path, err := b.getSystemPath()
+	if err != nil {
+		return err
+	}
+	params := jsonutils.NewDict()
+	params.Set("Boot", boot)
+	_, _, err = b.request(httputils.PATCH, path, params)
+	return err
+}
+
+func (b *SRedfishBMC) SetBootDev(dev string, persistent bool) error {
+	target, ok := redfishBootTargets[dev]
+	if !ok {
+		return fmt.Errorf("redfish bmc does not support boot device %s", dev)
+	}
+	enabled := "Once"
+	if persistent {
+		enabled = "Continuous"
+	}
+	boot := jsonutils.NewDict()
+	boot.Set("BootSourceOverrideEnabled", jsonutils.NewString(enabled))
+	boot.Set("BootSourceOverrideTarget", jsonutils.NewString(target))
+	if dev == BOOT_DEV_HTTP {
+		boot.Set("BootSourceOverrideMode", jsonutils.NewString("UEFI"))
+	}
+	return b.setBootOverride(boot)
+}
+
+// SetHttpBootUri makes server UEFI HTTP boot from uri on next boot instead
+// of the URI offered by DHCP
+func (b *SRedfishBMC) SetHttpBootUri(uri string) error {
+	boot := jsonutils.NewDict()
+	boot.Set("BootSourceOverrideEnabled", jsonutils.NewString("Once"))
+	boot.Set("BootSourceOverrideTarget", jsonutils.NewString(redfishBootTargets[BOOT_DEV_HTTP]))
+	boot.Set("BootSourceOverrideMode", jsonutils.NewString("UEFI"))
+	boot.Set("HttpBootUri", jsonutils.NewString(uri))
+	return b.setBootOverride(boot)
+}
+
+func (b *SRedfishBMC) DoBMCReset() error {
+	path, err := b.getManagerPath()
+	if err != nil {
+		return err
+	}
+	manager, err := b.get(path)
+	if err != nil {
+		return err
+	}
+	params := jsonutils.NewDict()
+	params.Set("ResetType", jsonutils.NewString("GracefulRestart"))
+	return b.doAction(manager, path, "Manager.Reset", params)
+}
+
+// getCdMedia returns the first virtual media slot able to hold CD or DVD
+func (b *SRedfishBMC) getCdMedia() (string, jsonutils.JSONObject, error) {
+	path, err := b.getManagerPath()
+	if err != nil {
+		return "", nil, err
+	}
+	manager, err := b.get(path)
+	if err != nil {
+		return "", nil, err
+	}
+	collPath, _ := manager.GetString("VirtualMedia", "@odata.id")
+	if collPath == "" {
+		return "", nil, fmt.Errorf("manager %s has no virtual media", path)
+	}
+	members, err := b.getMembers(collPath)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, m := range members {
+		media, err := b.get(m)
+		if err != nil {
+			return "", nil, err
+		}
+		mediaTypes, _ := media.GetArray("MediaTypes")
+		for _, t := range mediaTypes {
+			if mt, _ := t.GetString(); utils.IsInStringArray(mt, []string{"CD", "DVD"}) {
+				return m, media, nil
+			}
+		}
+	}
+	return "", nil, fmt.Errorf("no CD virtual media found")
+}
+
+func (b *SRedfishBMC) InsertMedia(imageUrl string) error {
+	path, media, err := b.getCdMedia()
+	if err != nil {
+		return err
+	}
+	params := jsonutils.NewDict()
+	params.Set("Image", jsonutils.NewString(imageUrl))
+	params.Set("Inserted", jsonutils.JSONTrue)
+	if media.Contains("Actions", "#VirtualMedia.InsertMedia") {
+		params.Set("WriteProtected", jsonutils.JSONTrue)
+		return b.doAction(media, path, "VirtualMedia.InsertMedia", params)
+	}
+	// BMC implementing schema older than VirtualMedia v1.2 takes PATCH
+	_, _, err = b.request(httputils.PATCH, path, params)
+	return err
+}
+
+func (b *SRedfishBMC) EjectMedia() error {
+	path, media, err := b.getCdMedia()
+	if err != nil {
+		return err
+	}
+	if media.Contains("Actions", "#VirtualMedia.EjectMedia") {
+		return b.doAction(media, path, "VirtualMedia.EjectMedia", jsonutils.NewDict())
+	}
+	params := jsonutils.NewDict()
+	params.Set("Image", jsonutils.JSONNull)
+	params.Set("Inserted", jsonutils.JSONFalse)
+	_, _, err = b.request(httputils.PATCH, path, params)
+	return err
+}
+
+func (b *SRedfishBMC) GetInventory() (*SInventory, error) {
+	_, system, err := b.getSystem()
+	if err != nil {
+		return nil, err
+	}
+	inv := &SInventory{}
+	inv.Manufacture, _ = system.GetString("Manufacturer")
+	inv.Model, _ = system.GetString("Model")
+	inv.SN, _ = system.GetString("SerialNumber")
+	inv.UUID, _ = system.GetString("UUID")
+	inv.BiosVersion, _ = system.GetString("BiosVersion")
+	cpuCount, _ := system.Int("ProcessorSummary", "Count")
+	inv.CpuCount = int(cpuCount)
+	inv.CpuDesc, _ = system.GetString("ProcessorSummary", "Model")
+	memGb, err := system.Float("MemorySummary", "TotalSystemMemoryGiB")
+	if err != nil {
+		// integral sizes are parsed as int
+		memGbInt, _ := system.Int("MemorySummary", "TotalSystemMemoryGiB")
+		memGb = float64(memGbInt)
+	}
+	inv.MemMb = int(memGb * 1024)
+	return inv, nil
+}
+
+func (b *SRedfishBMC) Subscribe(destination string, context string, eventTypes []string) (string, error) {
+	params := jsonutils.NewDict()
+	params.Set("Destination", jsonutils.NewString(destination))
+	params.Set("Protocol", jsonutils.NewString("Redfish"))
+	if context != "" {
+		params.Set("Context", jsonutils.NewString(context))
+	}
+	if len(eventTypes) > 0 {
+		params.Set("EventTypes", jsonutils.NewStringArray(eventTypes))
+	}
+	hdr, resp, err := b.request(httputils.POST, REDFISH_ROOT+"/EventService/Subscriptions", params)
+	if err != nil {
+		return "", err
+	}
+	if loc := hdr.Get("Location"); loc != "" {
+		return b.relativePath(loc)
+	}
+	if resp != nil {
+		if id, _ := resp.GetString("@odata.id"); id != "" {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("subscription location not found in response")
+}
+
+func (b *SRedfishBMC) Unsubscribe(id string) error {
+	_, _, err := b.request(httputils.DELETE, id, nil)
+	return err
+}
+
+func (b *SRedfishBMC) getChassis() (jsonutils.JSONObject, error) {
+	if b.chassisPath == "" {
+		path, err := b.getFirstMember(REDFISH_ROOT + "/Chassis")
+		if err != nil {
+			return nil, err
+		}
+		b.chassisPath = path
+	}
+	return b.get(b.chassisPath)
+}
+
+// getLink returns path of linked resource name of res, or the conventional
+// path under resPath if res does not advertise it
+func getLink(res jsonutils.JSONObject, resPath string, name string) string {
+	if link, _ := res.GetString(name, "@odata.id"); link != "" {
+		return link
+	}
+	return resPath + "/" + name
+}
+
+// jsonFloat returns number of keys, integral values are parsed as int
+func jsonFloat(obj jsonutils.JSONObject, keys ...string) float64 {
+	if f, err := obj.Float(keys...); err == nil {
+		return f
+	}
+	i, _ := obj.Int(keys...)
+	return float64(i)
+}
+
+func redfishStatus(health string) string {
+	switch health {
+	case "OK":
+		return api.HARDWARE_STATUS_OK
+	case "Warning":
+		return api.HARDWARE_STATUS_WARNING
+	case "Critical":
+		return api.HARDWARE_STATUS_CRITICAL
+	}
+	return api.HARDWARE_STATUS_UNKNOWN
+}
+
+func redfishSensor(res jsonutils.JSONObject, sensorType, readingKey, unit string) api.BaremetalSensor {
+	name, _ := res.GetString("Name")
+	if name == "" {
+		name, _ = res.GetString("FanName")
+	}
+	health, _ := res.GetString("Status", "Health")
+	return api.BaremetalSensor{
+		Name:          name,
+		Type:          sensorType,
+		Reading:       jsonFloat(res, readingKey),
+		Unit:          unit,
+		Status:        redfishStatus(health),
+		LowerCritical: jsonFloat(res, "LowerThresholdCritical"),
+		UpperWarning:  jsonFloat(res, "UpperThresholdNonCritical"),
+		UpperCritical: jsonFloat(res, "UpperThresholdCritical"),
+	}
+}
+
+func isAbsent(res jsonutils.JSONObject) bool {
+	state, _ := res.GetString("Status", "State")
+	return state == "Absent"
+}
+
+func (b *SRedfishBMC) GetSensors() ([]api.BaremetalSensor, error) {
+	chassis, err := b.getChassis()
+	if err != nil {
+		return nil, err
+	}
+	thermal, err := b.get(getLink(chassis, b.chassisPath, "Thermal"))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]api.BaremetalSensor, 0)
+	temps, _ := thermal.GetArray("Temperatures")
+	for _, t := range temps {
+		if !isAbsent(t) {
+			ret = append(ret, redfishSensor(t, api.SENSOR_TYPE_TEMPERATURE, "ReadingCelsius", "degrees C"))
+		}
+	}
+	fans, _ := thermal.GetArray("Fans")
+	for _, f := range fans {
+		if !isAbsent(f) {
+			unit, _ := f.GetString("ReadingUnits")
+			ret = append(ret, redfishSensor(f, api.SENSOR_TYPE_FAN, "Reading", unit))
+		}
+	}
+
+	power, err := b.get(getLink(chassis, b.chassisPath, "Power"))
+	if err != nil {
+		log.Warningf("get power of chassis %s: %v", b.chassisPath, err)
+		return ret, nil
+	}
+	volts, _ := power.GetArray("Voltages")
+	for _, v := range volts {
+		if !isAbsent(v) {
+			ret = append(ret, redfishSensor(v, api.SENSOR_TYPE_VOLTAGE, "ReadingVolts", "Volts"))
+		}
+	}
+	psus, _ := power.GetArray("PowerSupplies")
+	for _, p := range psus {
+		sensor := redfishSensor(p, api.SENSOR_TYPE_POWER_SUPPLY, "LastPowerOutputWatts", "Watts")
+		sensor.State, _ = p.GetString("Status", "State")
+		if isAbsent(p) {
+			sensor.Status = api.HARDWARE_STATUS_UNKNOWN
+		}
+		ret = append(ret, sensor)
+	}
+	return ret, nil
+}
+
+// getSelEntriesPath finds entries of SEL log service of system or manager
+func (b *SRedfishBMC) getSelEntriesPath() (string, error) {
+	for _, getPath := range []func() (string, error){b.getSystemPath, b.getManagerPath} {
+		resPath, err := getPath()
+		if err != nil {
+			continue
+		}
+		services, err := b.getMembers(resPath + "/LogServices")
+		if err != nil {
+			continue
+		}
+		for _, s := range services {
+			if strings.EqualFold(s[strings.LastIndex(s, "/")+1:], "sel") {
+				return s + "/Entries", nil
+			}
+		}
+	}
+	return "", fmt.Errorf("SEL log service not found")
+}
+
+func (b *SRedfishBMC) GetSelEvents(count int) ([]api.BaremetalSelEvent, error) {
+	entriesPath, err := b.getSelEntriesPath()
+	if err != nil {
+		return nil, err
+	}
+	coll, err := b.get(entriesPath)
+	if err != nil {
+		return nil, err
+	}
+	members, _ := coll.GetArray("Members")
+	ret := make([]api.BaremetalSelEvent, 0, len(members))
+	for i, m := range members {
+		idStr, _ := m.GetString("Id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			id = i + 1
+		}
+		event := api.BaremetalSelEvent{Id: id}
+		event.Time, _ = m.GetString("Created")
+		event.Sensor, _ = m.GetString("SensorType")
+		event.Event, _ = m.GetString("Message")
+		entryCode, _ := m.GetString("EntryCode")
+		event.Asserted = entryCode != "Deassert"
+		severity, _ := m.GetString("Severity")
+		event.Severity = redfishStatus(severity)
+		if !event.Asserted || event.Severity == api.HARDWARE_STATUS_UNKNOWN {
+			event.Severity = api.HARDWARE_STATUS_OK
+		}
+		ret = append(ret, event)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Id < ret[j].Id })
+	if count > 0 && len(ret) > count {
+		ret = ret[len(ret)-count:]
+	}
+	return ret, nil
+}
+
+func (b *SRedfishBMC) GetFirmwares() ([]api.BaremetalFirmware, error) {
+	_, system, err := b.getSystem()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]api.BaremetalFirmware, 0)
+	if version, _ := system.GetString("BiosVersion"); version != "" {
+		ret = append(ret, api.BaremetalFirmware{Name: "BIOS", Version: version})
+	}
+	managerPath, err := b.getManagerPath()
+	if err != nil {
+		return nil, err
+	}
+	manager, err := b.get(managerPath)
+	if err != nil {
+		return nil, err
+	}
+	if version, _ := manager.GetString("FirmwareVersion"); version != "" {
+		ret = append(ret, api.BaremetalFirmware{Name: "BMC", Version: version})
+	}
+	return ret, nil
+}
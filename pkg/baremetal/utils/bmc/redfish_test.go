@@ -0,0 +1,410 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bmc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+)
+
+const (
+	mockSystem   = "/redfish/v1/Systems/1"
+	mockManager  = "/redfish/v1/Managers/1"
+	mockChassis  = "/redfish/v1/Chassis/1"
+	mockCdMedia  = mockManager + "/VirtualMedia/2"
+	mockSubsColl = "/redfish/v1/EventService/Subscriptions"
+)
+
+// mockRedfish is a minimal Redfish service with one system, one manager
+// having a floppy and a CD virtual media
+type mockRedfish struct {
+	lock sync.Mutex
+
+	powerState string
+	boot       jsonutils.JSONObject
+	image      string
+	bmcResets  int
+	subs       map[string]jsonutils.JSONObject
+}
+
+func (m *mockRedfish) resources() map[string]string {
+	return map[string]string{
+		"/redfish/v1/Systems":  `{"Members":[{"@odata.id":"` + mockSystem + `"}]}`,
+		"/redfish/v1/Managers": `{"Members":[{"@odata.id":"` + mockManager + `"}]}`,
+		"/redfish/v1/Chassis":  `{"Members":[{"@odata.id":"` + mockChassis + `"}]}`,
+		mockSystem: `{
+			"Manufacturer":"Contoso","Model":"3500","SerialNumber":"SN0001",
+			"UUID":"38947555-7742-3448-3784-823347823834","BiosVersion":"P79 v1.45",
+			"PowerState":"` + m.powerState + `",
+			"ProcessorSummary":{"Count":2,"Model":"Multi-Core Intel(R) Xeon(R) processor 7xxx Series"},
+			"MemorySummary":{"TotalSystemMemoryGiB":96},
+			"Boot":` + m.boot.String() + `,
+			"Actions":{"#ComputerSystem.Reset":{"target":"` + mockSystem + `/Actions/ComputerSystem.Reset"}}
+		}`,
+		mockManager: `{
+			"FirmwareVersion":"1.45.455b66-rev4",
+			"VirtualMedia":{"@odata.id":"` + mockManager + `/VirtualMedia"},
+			"Actions":{"#Manager.Reset":{"target":"` + mockManager + `/Actions/Manager.Reset"}}
+		}`,
+		mockManager + "/VirtualMedia":   `{"Members":[{"@odata.id":"` + mockManager + `/VirtualMedia/1"},{"@odata.id":"` + mockCdMedia + `"}]}`,
+		mockManager + "/VirtualMedia/1": `{"MediaTypes":["Floppy","USBStick"]}`,
+		mockCdMedia: `{
+			"MediaTypes":["CD","DVD"],"Image":"` + m.image + `",
+			"Actions":{
+				"#VirtualMedia.InsertMedia":{"target":"` + mockCdMedia + `/Actions/VirtualMedia.InsertMedia"},
+				"#VirtualMedia.EjectMedia":{"target":"` + mockCdMedia + `/Actions/VirtualMedia.EjectMedia"}
+			}
+		}`,
+		mockSystem + "/LogServices":  `{"Members":[{"@odata.id":"` + mockSystem + `/LogServices/Log"}]}`,
+		mockManager + "/LogServices": `{"Members":[{"@odata.id":"` + mockManager + `/LogServices/SEL"}]}`,
+		mockManager + "/LogServices/SEL/Entries": `{"Members":[
+			{"Id":"3","Created":"2019-08-01T10:02:00Z","SensorType":"Power Supply","Message":"Power Supply AC lost","Severity":"Critical","EntryCode":"Assert"},
+			{"Id":"1","Created":"2019-08-01T10:00:00Z","SensorType":"Temperature","Message":"Upper Critical going high","Severity":"Critical","EntryCode":"Deassert"},
+			{"Id":"2","Created":"2019-08-01T10:01:00Z","SensorType":"Memory","Message":"Correctable ECC","Severity":"Warning","EntryCode":"Assert"}
+		]}`,
+		mockChassis: `{"Thermal":{"@odata.id":"` + mockChassis + `/Thermal"},"Power":{"@odata.id":"` + mockChassis + `/Power"}}`,
+		mockChassis + "/Thermal": `{
+			"Temperatures":[
+				{"Name":"CPU1 Temp","ReadingCelsius":62,"UpperThresholdNonCritical":80,"UpperThresholdCritical":90,"Status":{"State":"Enabled","Health":"OK"}},
+				{"Name":"CPU2 Temp","Status":{"State":"Absent"}}
+			],
+			"Fans":[{"Name":"Fan 1","Reading":2100,"ReadingUnits":"RPM","LowerThresholdCritical":500,"Status":{"State":"Enabled","Health":"Warning"}}]
+		}`,
+		mockChassis + "/Power": `{
+			"Voltages":[{"Name":"VRM1 Voltage","ReadingVolts":1.8,"Status":{"State":"Enabled","Health":"OK"}}],
+			"PowerSupplies":[{"Name":"PSU 2","LastPowerOutputWatts":0,"Status":{"State":"Enabled","Health":"Critical"}}]
+		}`,
+	}
+}
+
+func (m *mockRedfish) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if user, passwd, ok := r.BasicAuth(); !ok || user != "admin" || passwd != "passw0rd" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var body jsonutils.JSONObject
+	if d, _ := ioutil.ReadAll(r.Body); len(d) > 0 {
+		var err error
+		body, err = jsonutils.Parse(d)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	path := r.URL.Path
+	switch {
+	case r.Method == "GET":
+		if res, ok := m.resources()[path]; ok {
+			w.Write([]byte(res))
+			return
+		}
+	case r.Method == "POST" && path == mockSystem+"/Actions/ComputerSystem.Reset":
+		resetType, _ := body.GetString("ResetType")
+		switch resetType {
+		case "On", "ForceRestart":
+			m.powerState = "On"
+		case "ForceOff", "GracefulShutdown":
+			m.powerState = "Off"
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case r.Method == "PATCH" && path == mockSystem:
+		boot, err := body.Get("Boot")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.boot = boot
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case r.Method == "POST" && path == mockCdMedia+"/Actions/VirtualMedia.InsertMedia":
+		m.image, _ = body.GetString("Image")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case r.Method == "POST" && path == mockCdMedia+"/Actions/VirtualMedia.EjectMedia":
+		m.image = ""
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case r.Method == "POST" && path == mockManager+"/Actions/Manager.Reset":
+		m.bmcResets += 1
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case r.Method == "POST" && path == mockSubsColl:
+		id := mockSubsColl + "/1"
+		m.subs[id] = body
+		w.Header().Set("Location", id)
+		w.WriteHeader(http.StatusCreated)
+		return
+	case r.Method == "DELETE" && strings.HasPrefix(path, mockSubsColl+"/"):
+		if _, ok := m.subs[path]; ok {
+			delete(m.subs, path)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func newMockRedfish() (*mockRedfish, *SRedfishBMC, func()) {
+	m := &mockRedfish{
+		powerState: "Off",
+		boot:       jsonutils.NewDict(),
+		subs:       map[string]jsonutils.JSONObject{},
+	}
+	srv := httptest.NewTLSServer(m)
+	return m, NewRedfishBMC(srv.URL, "admin", "passw0rd"), srv.Close
+}
+
+func TestRedfishPower(t *testing.T) {
+	_, b, cleanup := newMockRedfish()
+	defer cleanup()
+
+	var _ IBMC = b
+	assertStatus := func(want string) {
+		t.Helper()
+		status, err := b.GetPowerStatus()
+		if err != nil {
+			t.Fatalf("GetPowerStatus: %s", err)
+		}
+		if status != want {
+			t.Fatalf("want power status %s, got %s", want, status)
+		}
+	}
+	assertStatus("off")
+	if err := b.DoReboot(); err != nil {
+		t.Fatalf("DoReboot: %s", err)
+	}
+	assertStatus("on")
+	if err := b.DoPowerShutdown(true); err != nil {
+		t.Fatalf("DoPowerShutdown: %s", err)
+	}
+	assertStatus("off")
+	if err := b.DoPowerOn(); err != nil {
+		t.Fatalf("DoPowerOn: %s", err)
+	}
+	assertStatus("on")
+
+	bad := NewRedfishBMC(b.endpoint, "admin", "wrong")
+	if _, err := bad.GetPowerStatus(); err == nil {
+		t.Errorf("expecting error with wrong password")
+	}
+}
+
+func TestRedfishBoot(t *testing.T) {
+	m, b, cleanup := newMockRedfish()
+	defer cleanup()
+
+	cases := []struct {
+		dev        string
+		persistent bool
+		target     string
+		enabled    string
+	}{
+		{BOOT_DEV_PXE, false, "Pxe", "Once"},
+		{BOOT_DEV_DISK, true, "Hdd", "Continuous"},
+		{BOOT_DEV_HTTP, false, "UefiHttp", "Once"},
+	}
+	for _, c := range cases {
+		if err := b.SetBootDev(c.dev, c.persistent); err != nil {
+			t.Fatalf("SetBootDev %s: %s", c.dev, err)
+		}
+		target, _ := m.boot.GetString("BootSourceOverrideTarget")
+		enabled, _ := m.boot.GetString("BootSourceOverrideEnabled")
+		if target != c.target || enabled != c.enabled {
+			t.Errorf("%s: want %s/%s, got %s/%s", c.dev, c.target, c.enabled, target, enabled)
+		}
+	}
+	if err := b.SetBootDev("floppy", false); err == nil {
+		t.Errorf("expecting error for unknown boot device")
+	}
+
+	const uri = "http://10.0.0.1/tftp/bootx64.efi"
+	if err := DoRebootToHttpBoot(b, uri); err != nil {
+		t.Fatalf("DoRebootToHttpBoot: %s", err)
+	}
+	if got, _ := m.boot.GetString("HttpBootUri"); got != uri {
+		t.Errorf("want http boot uri %s, got %s", uri, got)
+	}
+	if mode, _ := m.boot.GetString("BootSourceOverrideMode"); mode != "UEFI" {
+		t.Errorf("want UEFI boot mode, got %s", mode)
+	}
+	if m.powerState != "On" {
+		t.Errorf("server should be powered on after reboot")
+	}
+}
+
+func TestRedfishVirtualMedia(t *testing.T) {
+	m, b, cleanup := newMockRedfish()
+	defer cleanup()
+
+	const image = "http://10.0.0.1/images/rescue.iso"
+	if err := b.InsertMedia(image); err != nil {
+		t.Fatalf("InsertMedia: %s", err)
+	}
+	if m.image != image {
+		t.Errorf("want image %s inserted into CD, got %q", image, m.image)
+	}
+	if err := b.EjectMedia(); err != nil {
+		t.Fatalf("EjectMedia: %s", err)
+	}
+	if m.image != "" {
+		t.Errorf("image should be ejected, got %q", m.image)
+	}
+}
+
+func TestRedfishInventory(t *testing.T) {
+	_, b, cleanup := newMockRedfish()
+	defer cleanup()
+
+	inv, err := b.GetInventory()
+	if err != nil {
+		t.Fatalf("GetInventory: %s", err)
+	}
+	want := SInventory{
+		Manufacture: "Contoso",
+		Model:       "3500",
+		SN:          "SN0001",
+		UUID:        "38947555-7742-3448-3784-823347823834",
+		BiosVersion: "P79 v1.45",
+		CpuCount:    2,
+		CpuDesc:     "Multi-Core Intel(R) Xeon(R) processor 7xxx Series",
+		MemMb:       96 * 1024,
+	}
+	if *inv != want {
+		t.Errorf("want %#v, got %#v", want, *inv)
+	}
+}
+
+func TestRedfishEventsAndBMCReset(t *testing.T) {
+	m, b, cleanup := newMockRedfish()
+	defer cleanup()
+
+	id, err := b.Subscribe("https://10.0.0.1:8879/redfish/events", "bm-1", []string{"Alert"})
+	if err != nil {
+		t.Fatalf("Subscribe: %s", err)
+	}
+	sub, ok := m.subs[id]
+	if !ok {
+		t.Fatalf("subscription %s not created", id)
+	}
+	if ctx, _ := sub.GetString("Context"); ctx != "bm-1" {
+		t.Errorf("want context bm-1, got %s", ctx)
+	}
+	if err := b.Unsubscribe(id); err != nil {
+		t.Fatalf("Unsubscribe: %s", err)
+	}
+	if len(m.subs) != 0 {
+		t.Errorf("subscription should be deleted")
+	}
+
+	if err := b.DoBMCReset(); err != nil {
+		t.Fatalf("DoBMCReset: %s", err)
+	}
+	if m.bmcResets != 1 {
+		t.Errorf("want 1 bmc reset, got %d", m.bmcResets)
+	}
+}
+
+func TestRedfishTelemetry(t *testing.T) {
+	_, b, cleanup := newMockRedfish()
+	defer cleanup()
+
+	var _ ITelemetry = b
+	sensors, err := b.GetSensors()
+	if err != nil {
+		t.Fatalf("GetSensors: %s", err)
+	}
+	wantSensors := []api.BaremetalSensor{
+		{Name: "CPU1 Temp", Type: api.SENSOR_TYPE_TEMPERATURE, Reading: 62, Unit: "degrees C", Status: api.HARDWARE_STATUS_OK, UpperWarning: 80, UpperCritical: 90},
+		{Name: "Fan 1", Type: api.SENSOR_TYPE_FAN, Reading: 2100, Unit: "RPM", Status: api.HARDWARE_STATUS_WARNING, LowerCritical: 500},
+		{Name: "VRM1 Voltage", Type: api.SENSOR_TYPE_VOLTAGE, Reading: 1.8, Unit: "Volts", Status: api.HARDWARE_STATUS_OK},
+		{Name: "PSU 2", Type: api.SENSOR_TYPE_POWER_SUPPLY, Unit: "Watts", State: "Enabled", Status: api.HARDWARE_STATUS_CRITICAL},
+	}
+	if !reflect.DeepEqual(sensors, wantSensors) {
+		t.Errorf("want sensors %#v, got %#v", wantSensors, sensors)
+	}
+
+	events, err := b.GetSelEvents(2)
+	if err != nil {
+		t.Fatalf("GetSelEvents: %s", err)
+	}
+	wantEvents := []api.BaremetalSelEvent{
+		{Id: 2, Time: "2019-08-01T10:01:00Z", Sensor: "Memory", Event: "Correctable ECC", Asserted: true, Severity: api.HARDWARE_STATUS_WARNING},
+		{Id: 3, Time: "2019-08-01T10:02:00Z", Sensor: "Power Supply", Event: "Power Supply AC lost", Asserted: true, Severity: api.HARDWARE_STATUS_CRITICAL},
+	}
+	if !reflect.DeepEqual(events, wantEvents) {
+		t.Errorf("want events %#v, got %#v", wantEvents, events)
+	}
+
+	firmwares, err := b.GetFirmwares()
+	if err != nil {
+		t.Fatalf("GetFirmwares: %s", err)
+	}
+	wantFirmwares := []api.BaremetalFirmware{
+		{Name: "BIOS", Version: "P79 v1.45"},
+		{Name: "BMC", Version: "1.45.455b66-rev4"},
+	}
+	if !reflect.DeepEqual(firmwares, wantFirmwares) {
+		t.Errorf("want firmwares %#v, got %#v", wantFirmwares, firmwares)
+	}
+}
+
+func TestRedfishForeignUrl(t *testing.T) {
+	_, b, cleanup := newMockRedfish()
+	defer cleanup()
+
+	leaked := false
+	foreign := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = true
+		w.Write([]byte(`{"PowerState":"On"}`))
+	}))
+	defer foreign.Close()
+
+	// absolute url on bmc itself is followed
+	b.systemPath = b.endpoint + mockSystem
+	if _, err := b.GetPowerStatus(); err != nil {
+		t.Fatalf("GetPowerStatus with absolute url on bmc: %s", err)
+	}
+
+	b.systemPath = foreign.URL + mockSystem
+	if _, err := b.GetPowerStatus(); err == nil {
+		t.Errorf("absolute url on other host should be refused")
+	}
+
+	redirector := httptest.NewTLSServer(http.RedirectHandler(foreign.URL+mockSystem, http.StatusFound))
+	defer redirector.Close()
+	b.endpoint = redirector.URL
+	b.systemPath = mockSystem
+	if _, err := b.GetPowerStatus(); err == nil {
+		t.Errorf("redirect to other host should be refused")
+	}
+	if leaked {
+		t.Errorf("request sent to other host")
+	}
+}
@@ -17,10 +17,12 @@ package disktool
 import (
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 
 	"yunion.io/x/log"
 
+	"yunion.io/x/onecloud/pkg/baremetal/utils/raid/mdadm"
 	"yunion.io/x/onecloud/pkg/cloudcommon/types"
 	"yunion.io/x/onecloud/pkg/compute/baremetal"
 	fileutils "yunion.io/x/onecloud/pkg/util/fileutils2"
@@ -36,6 +38,10 @@ const (
 	NONRAID_DRIVER = "nonraid"
 	PCIE_DRIVER    = "pcie"
 
+	// MD_SUFFIX is appended to nonraid or pcie driver for software raid
+	// arrays built over disks of that driver
+	MD_SUFFIX = "-md"
+
 	LABEL_MSDOS = "msdos"
 	LABEL_GPT   = "gpt"
 )
@@ -544,7 +550,10 @@ func (tool *PartitionTool) DebugString() string {
 }
 
 func (tool *PartitionTool) parseLsDisk(lines []string, driver string) {
-	disks := sysutils.ParseDiskInfo(lines, driver)
+	tool.setDisksInfo(sysutils.ParseDiskInfo(lines, driver), driver)
+}
+
+func (tool *PartitionTool) setDisksInfo(disks []*types.SDiskInfo, driver string) {
 	if len(disks) == 0 {
 		return
 	}
@@ -566,6 +575,9 @@ func (tool *PartitionTool) FetchDiskConfs(diskConfs []baremetal.DiskConfiguratio
 		} else {
 			key = RAID_DRVIER
 		}
+		if baremetal.DISK_DRIVERS_SOFT_RAID.Has(d.Driver) && d.Conf != "" && d.Conf != baremetal.DISK_CONF_NONE {
+			key += MD_SUFFIX
+		}
 		if _, ok := tool.diskTable[key]; !ok {
 			tool.diskTable[key] = make([]*DiskPartitions, 0)
 		}
@@ -584,18 +596,104 @@ func (tool *PartitionTool) IsAllDisksReady() bool {
 	return true
 }
 
+func (tool *PartitionTool) hasMdDisks() bool {
+	for key := range tool.diskTable {
+		if strings.HasSuffix(key, MD_SUFFIX) {
+			return true
+		}
+	}
+	return false
+}
+
 func (tool *PartitionTool) RetrieveDiskInfo() error {
+	var arrays []*mdadm.SMdArray
+	if tool.hasMdDisks() {
+		var err error
+		arrays, err = mdadm.GetArrays(tool)
+		if err != nil {
+			return err
+		}
+	}
 	for _, driver := range []string{RAID_DRVIER, NONRAID_DRIVER, PCIE_DRIVER} {
 		cmd := fmt.Sprintf("/lib/mos/lsdisk --%s", driver)
 		ret, err := tool.Run(cmd)
 		if err != nil {
 			return err
 		}
-		tool.parseLsDisk(ret, driver)
+		if len(arrays) == 0 || driver == RAID_DRVIER {
+			tool.parseLsDisk(ret, driver)
+			continue
+		}
+		disks, mdDisks, err := tool.splitMdDisks(sysutils.ParseDiskInfo(ret, driver), arrays)
+		if err != nil {
+			return err
+		}
+		tool.setDisksInfo(disks, driver)
+		tool.setDisksInfo(mdDisks, driver+MD_SUFFIX)
 	}
 	return nil
 }
 
+// splitMdDisks separates disks used as raw disks from members of software
+// raid arrays, arrays over the members are returned as md disks in order
+// of creation
+func (tool *PartitionTool) splitMdDisks(disks []*types.SDiskInfo, arrays []*mdadm.SMdArray) ([]*types.SDiskInfo, []*types.SDiskInfo, error) {
+	mdDisks := make([]*types.SDiskInfo, 0)
+	members := make(map[string]bool)
+	for _, array := range arrays {
+		var driver string
+		for _, disk := range disks {
+			if array.HasMember(disk.Dev) {
+				members[disk.Dev] = true
+				driver = disk.Driver
+			}
+		}
+		if driver == "" {
+			continue
+		}
+		info, err := tool.getMdDiskInfo(array, driver)
+		if err != nil {
+			return nil, nil, err
+		}
+		mdDisks = append(mdDisks, info)
+	}
+	rawDisks := make([]*types.SDiskInfo, 0)
+	for _, disk := range disks {
+		if !members[disk.Dev] {
+			rawDisks = append(rawDisks, disk)
+		}
+	}
+	return rawDisks, mdDisks, nil
+}
+
+func (tool *PartitionTool) getMdDiskInfo(array *mdadm.SMdArray, driver string) (*types.SDiskInfo, error) {
+	sysPath := "/sys/block/" + array.GetName()
+	lines, err := tool.Run(fmt.Sprintf("cat %s/size %s/queue/logical_block_size", sysPath, sysPath))
+	if err != nil {
+		return nil, fmt.Errorf("Get size of %s: %v", array.GetName(), err)
+	}
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("Invalid size of %s: %v", array.GetName(), lines)
+	}
+	sector, err := strconv.ParseInt(strings.TrimSpace(lines[0]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Parse sectors of %s: %v", array.GetName(), err)
+	}
+	block, err := strconv.ParseInt(strings.TrimSpace(lines[1]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Parse block size of %s: %v", array.GetName(), err)
+	}
+	return &types.SDiskInfo{
+		Dev:        array.GetName(),
+		Sector:     sector,
+		Block:      block,
+		Size:       sector * 512 / 1024 / 1024,
+		ModuleInfo: fmt.Sprintf("md %s", array.Level),
+		Kernel:     "md",
+		Driver:     driver,
+	}, nil
+}
+
 func (tool *PartitionTool) RetrievePartitionInfo() error {
 	for _, disk := range tool.disks {
 		if err := disk.RetrievePartitionInfo(); err != nil {
@@ -14,6 +14,13 @@
 
 package disktool
 
+import (
+	"fmt"
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/compute/baremetal"
+)
+
 // TODO: use mock ssh server backend test disktool
 /*
 import (
@@ -64,3 +71,53 @@ func TestSSHCreate(t *testing.T) {
 		t.Errorf("Failed to resize fs: %v", err)
 	}
 }*/
+
+type fakeRunner map[string][]string
+
+func (r fakeRunner) Run(cmds ...string) ([]string, error) {
+	ret := []string{}
+	for _, cmd := range cmds {
+		out, ok := r[cmd]
+		if !ok {
+			return nil, fmt.Errorf("unexpected command %q", cmd)
+		}
+		ret = append(ret, out...)
+	}
+	return ret, nil
+}
+
+func TestRetrieveSoftRaidDiskInfo(t *testing.T) {
+	runner := fakeRunner{
+		"cat /proc/mdstat": {
+			"md1 : active raid0 sdb[1] sda[0]",
+			"md0 : active raid1 nvme1n1[1] nvme0n1[0]",
+		},
+		"/lib/mos/lsdisk --raid": {},
+		"/lib/mos/lsdisk --nonraid": {
+			"sda 1953525168 512 1 sd - ata_piix",
+			"sdb 1953525168 512 1 sd - ata_piix",
+			"sdc 1953525168 512 1 sd - ata_piix",
+		},
+		"/lib/mos/lsdisk --pcie": {
+			"nvme0n1 3907029168 512 0 nvme 0108 nvme",
+			"nvme1n1 3907029168 512 0 nvme 0108 nvme",
+		},
+		"cat /sys/block/md0/size /sys/block/md0/queue/logical_block_size": {"3907028992", "512"},
+		"cat /sys/block/md1/size /sys/block/md1/queue/logical_block_size": {"3907049472", "512"},
+	}
+	tool := NewPartitionTool(runner)
+	tool.FetchDiskConfs([]baremetal.DiskConfiguration{
+		{Driver: baremetal.DISK_DRIVER_PCIE, Conf: baremetal.DISK_CONF_RAID1},
+		{Driver: baremetal.DISK_DRIVER_LINUX, Conf: baremetal.DISK_CONF_RAID0},
+		{Driver: baremetal.DISK_DRIVER_LINUX, Conf: baremetal.DISK_CONF_NONE},
+	})
+	if err := tool.RetrieveDiskInfo(); err != nil {
+		t.Fatalf("RetrieveDiskInfo: %v", err)
+	}
+	want := []string{"md0", "md1", "sdc"}
+	for i, disk := range tool.GetDisks() {
+		if disk.GetDevName() != want[i] {
+			t.Errorf("disk %d: want %s, got %s", i, want[i], disk.GetDevName())
+		}
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskwipe
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/util/seclib"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/baremetal/utils/detect_storages"
+	"yunion.io/x/onecloud/pkg/compute/baremetal"
+	"yunion.io/x/onecloud/pkg/util/ssh"
+	"yunion.io/x/onecloud/pkg/util/sysutils"
+)
+
+const (
+	// canaries are written to disk before wipe, a wipe is verified only if
+	// none of them can be read back afterwards
+	canaryBlockSize = 4096
+	canaryLength    = 32
+)
+
+type IRunner interface {
+	Run(cmds ...string) ([]string, error)
+}
+
+type SWiper struct {
+	runner IRunner
+	mode   string
+	passes int
+}
+
+// NewWiper returns a wiper erasing disks by mode, passes is the number of
+// overwrite passes, the last of which writes zeros
+func NewWiper(runner IRunner, mode string, passes int) *SWiper {
+	if passes < 1 {
+		passes = 1
+	}
+	return &SWiper{
+		runner: runner,
+		mode:   mode,
+		passes: passes,
+	}
+}
+
+// GetDisks returns all block devices of baremetal seen by the OS, including
+// logical volumes exported by raid controllers
+func GetDisks(term *ssh.Client) ([]*baremetal.BaremetalStorage, error) {
+	raid, nonRaid, pcie, err := detect_storages.DetectStorageInfo(term, false)
+	if err != nil {
+		return nil, fmt.Errorf("DetectStorageInfo: %v", err)
+	}
+	disks := make([]*baremetal.BaremetalStorage, 0)
+	if len(raid) > 0 {
+		ret, err := term.Run("/lib/mos/lsdisk --raid")
+		if err != nil {
+			return nil, fmt.Errorf("Fail to retrieve RAID DISK info: %v", err)
+		}
+		for _, info := range sysutils.ParseDiskInfo(ret, raid[0].Driver) {
+			disks = append(disks, &baremetal.BaremetalStorage{
+				Driver:     info.Driver,
+				Size:       info.Size,
+				Rotate:     info.Rotate,
+				Dev:        info.Dev,
+				Sector:     info.Sector,
+				Block:      info.Block,
+				ModuleInfo: info.ModuleInfo,
+			})
+		}
+	}
+	for _, disk := range append(nonRaid, pcie...) {
+		if disk.Dev != "" {
+			disks = append(disks, disk)
+		}
+	}
+	return disks, nil
+}
+
+func (w *SWiper) run(cmds ...string) ([]string, error) {
+	ret, err := w.runner.Run(cmds...)
+	if err != nil {
+		return ret, fmt.Errorf("%s: %v", strings.Join(cmds, "; "), err)
+	}
+	return ret, nil
+}
+
+// WipeDisks wipes disks one by one and returns a certificate for each
+func (w *SWiper) WipeDisks(disks []*baremetal.BaremetalStorage) []*api.BaremetalDiskWipeCertificate {
+	certs := make([]*api.BaremetalDiskWipeCertificate, 0, len(disks))
+	for _, disk := range disks {
+		cert := w.WipeDisk(disk)
+		if cert.Verified {
+			log.Infof("Disk %s wiped by %s", cert.Dev, cert.Method)
+		} else {
+			log.Errorf("Disk %s wipe not verified: %s", cert.Dev, cert.Reason)
+		}
+		certs = append(certs, cert)
+	}
+	return certs
+}
+
+func (w *SWiper) WipeDisk(disk *baremetal.BaremetalStorage) *api.BaremetalDiskWipeCertificate {
+	dev := "/dev/" + disk.Dev
+	cert := &api.BaremetalDiskWipeCertificate{
+		Dev:     dev,
+		Driver:  disk.Driver,
+		Model:   disk.ModuleInfo,
+		SizeMB:  disk.Size,
+		Health:  w.getHealth(dev),
+		StartAt: time.Now().UTC(),
+	}
+	if ret, err := w.run(fmt.Sprintf("lsblk -dno SERIAL %s", dev)); err == nil && len(ret) > 0 {
+		cert.Serial = strings.TrimSpace(ret[0])
+	}
+
+	reasons := []string{}
+	for _, method := range w.getMethods(disk) {
+		cert.Method = method
+		cert.Passes = 0
+		if method == api.DISK_WIPE_METHOD_OVERWRITE {
+			cert.Passes = w.passes
+		}
+		err := w.wipeAndVerify(disk, dev, method)
+		if err == nil {
+			cert.Verified = true
+			cert.Reason = ""
+			break
+		}
+		log.Warningf("Wipe %s by %s: %v", dev, method, err)
+		reasons = append(reasons, fmt.Sprintf("%s: %v", method, err))
+		cert.Reason = strings.Join(reasons, "; ")
+	}
+	cert.FinishAt = time.Now().UTC()
+	return cert
+}
+
+// getMethods returns wipe methods to try in order, overwrite is always the
+// last resort
+func (w *SWiper) getMethods(disk *baremetal.BaremetalStorage) []string {
+	methods := []string{}
+	if w.mode == api.DISK_WIPE_MODE_AUTO {
+		dev := "/dev/" + disk.Dev
+		if isNVMe(disk) {
+			if ret, err := w.run(fmt.Sprintf("nvme id-ctrl %s", dev)); err == nil {
+				if ok, _ := parseNVMeFormatSupport(ret); ok {
+					methods = append(methods, api.DISK_WIPE_METHOD_NVME_FORMAT)
+				}
+			}
+		} else if disk.Driver == baremetal.DISK_DRIVER_LINUX {
+			// raid controllers don't pass ATA security commands through
+			if ret, err := w.run(fmt.Sprintf("hdparm -I %s", dev)); err == nil {
+				if parseATASecurity(ret).canErase() {
+					methods = append(methods, api.DISK_WIPE_METHOD_ATA_SECURE_ERASE)
+				}
+			}
+		}
+		if !disk.Rotate {
+			methods = append(methods, api.DISK_WIPE_METHOD_TRIM)
+		}
+	}
+	return append(methods, api.DISK_WIPE_METHOD_OVERWRITE)
+}
+
+func isNVMe(disk *baremetal.BaremetalStorage) bool {
+	return strings.HasPrefix(disk.Dev, "nvme")
+}
+
+func (w *SWiper) wipeAndVerify(disk *baremetal.BaremetalStorage, dev string, method string) error {
+	canaries, err := w.writeCanaries(disk, dev)
+	if err != nil {
+		return fmt.Errorf("write canaries: %v", err)
+	}
+	switch method {
+	case api.DISK_WIPE_METHOD_NVME_FORMAT:
+		err = w.nvmeFormat(dev)
+	case api.DISK_WIPE_METHOD_ATA_SECURE_ERASE:
+		err = w.ataSecureErase(dev)
+	case api.DISK_WIPE_METHOD_TRIM:
+		_, err = w.run(fmt.Sprintf("blkdiscard %s", dev))
+	case api.DISK_WIPE_METHOD_OVERWRITE:
+		_, err = w.run(fmt.Sprintf("shred -n %d -z %s", w.passes-1, dev))
+	default:
+		err = fmt.Errorf("unknown wipe method %s", method)
+	}
+	if err != nil {
+		return err
+	}
+	return w.verifyCanaries(dev, canaries, method == api.DISK_WIPE_METHOD_OVERWRITE)
+}
+
+func (w *SWiper) nvmeFormat(dev string) error {
+	ret, err := w.run(fmt.Sprintf("nvme id-ctrl %s", dev))
+	if err != nil {
+		return err
+	}
+	_, crypto := parseNVMeFormatSupport(ret)
+	ses := 1
+	if crypto {
+		ses = 2
+	}
+	_, err = w.run(fmt.Sprintf("nvme format %s -s %d", dev, ses))
+	return err
+}
+
+func (w *SWiper) ataSecureErase(dev string) error {
+	ret, err := w.run(fmt.Sprintf("hdparm -I %s", dev))
+	if err != nil {
+		return err
+	}
+	eraseOpt := "--security-erase"
+	if parseATASecurity(ret).enhanced {
+		eraseOpt = "--security-erase-enhanced"
+	}
+	passwd := seclib.RandomPassword(12)
+	if _, err := w.run(fmt.Sprintf("hdparm --user-master u --security-set-pass %s %s", passwd, dev)); err != nil {
+		return err
+	}
+	if _, err := w.run(fmt.Sprintf("hdparm --user-master u %s %s %s", eraseOpt, passwd, dev)); err != nil {
+		// do not leave the disk locked by a password nobody knows
+		if _, err := w.run(fmt.Sprintf("hdparm --user-master u --security-disable %s %s", passwd, dev)); err != nil {
+			log.Errorf("Disable security of %s: %v", dev, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (w *SWiper) getHealth(dev string) string {
+	// smartctl exits non-zero when disk is failing, so parse output anyway
+	ret, _ := w.runner.Run(fmt.Sprintf("smartctl -H %s", dev))
+	return parseSmartHealth(ret)
+}
+
+// getCanaryBlocks returns blocks at the start, middle and end of disk
+func getCanaryBlocks(sizeMB int64) []int64 {
+	blocks := sizeMB * 1024 * 1024 / canaryBlockSize
+	if blocks <= 0 {
+		return nil
+	}
+	ret := []int64{0}
+	if blocks > 2 {
+		ret = append(ret, blocks/2)
+	}
+	if blocks > 1 {
+		ret = append(ret, blocks-1)
+	}
+	return ret
+}
+
+type sCanary struct {
+	block int64
+	data  string
+}
+
+func (w *SWiper) writeCanaries(disk *baremetal.BaremetalStorage, dev string) ([]sCanary, error) {
+	canaries := []sCanary{}
+	for _, block := range getCanaryBlocks(disk.Size) {
+		c := sCanary{
+			block: block,
+			data:  seclib.RandomPassword(canaryLength),
+		}
+		cmd := fmt.Sprintf("printf '%%s' %s | dd of=%s bs=%d seek=%d count=1 conv=sync,notrunc oflag=direct 2>/dev/null",
+			c.data, dev, canaryBlockSize, c.block)
+		if _, err := w.run(cmd); err != nil {
+			return nil, err
+		}
+		canaries = append(canaries, c)
+	}
+	if len(canaries) == 0 {
+		return nil, fmt.Errorf("invalid disk size %dMB", disk.Size)
+	}
+	return canaries, nil
+}
+
+func (w *SWiper) readBlock(dev string, block int64) (string, error) {
+	cmd := fmt.Sprintf("dd if=%s bs=%d skip=%d count=1 iflag=direct 2>/dev/null | od -An -v -tx1 | tr -d ' \\n'",
+		dev, canaryBlockSize, block)
+	ret, err := w.run(cmd)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(ret, ""), nil
+}
+
+func (w *SWiper) verifyCanaries(dev string, canaries []sCanary, wantZero bool) error {
+	for _, c := range canaries {
+		data, err := w.readBlock(dev, c.block)
+		if err != nil {
+			return fmt.Errorf("read block %d: %v", c.block, err)
+		}
+		if err := checkBlock(data, c.data, wantZero); err != nil {
+			return fmt.Errorf("block %d: %v", c.block, err)
+		}
+	}
+	return nil
+}
+
+// checkBlock checks hex dump of a wiped block
+func checkBlock(hexData string, canary string, wantZero bool) error {
+	if len(hexData) != canaryBlockSize*2 {
+		return fmt.Errorf("short read of %d bytes", len(hexData)/2)
+	}
+	if strings.Contains(hexData, hex.EncodeToString([]byte(canary))) {
+		return fmt.Errorf("data written before wipe is still readable")
+	}
+	if wantZero && strings.Trim(hexData, "0") != "" {
+		return fmt.Errorf("not zeroed")
+	}
+	return nil
+}
+
+type sATASecurity struct {
+	supported bool
+	enabled   bool
+	locked    bool
+	frozen    bool
+	enhanced  bool
+}
+
+func (s sATASecurity) canErase() bool {
+	return s.supported && !s.enabled && !s.locked && !s.frozen
+}
+
+// parseATASecurity parses Security section of `hdparm -I` output
+func parseATASecurity(lines []string) sATASecurity {
+	s := sATASecurity{}
+	inSection := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Security:") {
+			inSection = true
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if len(line) > 0 && line[0] != '\t' && line[0] != ' ' {
+			break
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		val := true
+		if fields[0] == "not" {
+			val = false
+			fields = fields[1:]
+		}
+		switch strings.Join(fields, " ") {
+		case "supported":
+			s.supported = val
+		case "enabled":
+			s.enabled = val
+		case "locked":
+			s.locked = val
+		case "frozen":
+			s.frozen = val
+		case "supported: enhanced erase":
+			s.enhanced = val
+		}
+	}
+	return s
+}
+
+// parseNVMeFormatSupport parses `nvme id-ctrl` output and returns whether
+// Format NVM and cryptographic erase are supported
+func parseNVMeFormatSupport(lines []string) (bool, bool) {
+	var oacs, fna int64
+	for _, line := range lines {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		if key != "oacs" && key != "fna" {
+			continue
+		}
+		val, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 0, 64)
+		if err != nil {
+			continue
+		}
+		if key == "oacs" {
+			oacs = val
+		} else {
+			fna = val
+		}
+	}
+	return oacs&0x2 != 0, fna&0x4 != 0
+}
+
+// parseSmartHealth parses `smartctl -H` output of ATA, NVMe or SCSI disk
+func parseSmartHealth(lines []string) string {
+	for _, line := range lines {
+		var result string
+		if strings.HasPrefix(line, "SMART overall-health self-assessment test result:") ||
+			strings.HasPrefix(line, "SMART Health Status:") {
+			result = strings.TrimSpace(line[strings.Index(line, ":")+1:])
+		} else {
+			continue
+		}
+		if result == "PASSED" || result == "OK" {
+			return api.DISK_HEALTH_PASSED
+		}
+		return api.DISK_HEALTH_FAILED
+	}
+	return api.DISK_HEALTH_UNKNOWN
+}
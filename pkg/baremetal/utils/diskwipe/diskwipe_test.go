@@ -0,0 +1,193 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskwipe
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/compute/baremetal"
+)
+
+const hdparmOutput = `/dev/sda:
+
+ATA device, with non-removable media
+	Model Number:       INTEL SSDSC2BB480G4
+	Serial Number:      BTWL3405084Y480QGN
+Security: 
+	Master password revision code = 65534
+		supported
+	not	enabled
+	not	locked
+	%s	frozen
+	not	expired: security count
+		supported: enhanced erase
+	2min for SECURITY ERASE UNIT. 2min for ENHANCED SECURITY ERASE UNIT.
+Logical Unit WWN Device Identifier: 55cd2e404b6d8a1c
+Checksum: correct`
+
+func TestParseATASecurity(t *testing.T) {
+	s := parseATASecurity(strings.Split(fmt.Sprintf(hdparmOutput, "not"), "\n"))
+	want := sATASecurity{supported: true, enhanced: true}
+	if s != want {
+		t.Errorf("want %#v, got %#v", want, s)
+	}
+	if !s.canErase() {
+		t.Errorf("should be able to erase")
+	}
+	s = parseATASecurity(strings.Split(fmt.Sprintf(hdparmOutput, ""), "\n"))
+	if !s.frozen || s.canErase() {
+		t.Errorf("frozen disk should not be erased: %#v", s)
+	}
+}
+
+func TestParseNVMeFormatSupport(t *testing.T) {
+	cases := []struct {
+		oacs   string
+		fna    string
+		format bool
+		crypto bool
+	}{
+		{"0x17", "0x4", true, true},
+		{"0x6", "0", true, false},
+		{"0x5", "0x4", false, true},
+	}
+	for _, c := range cases {
+		lines := []string{
+			"NVME Identify Controller:",
+			"vid       : 0x8086",
+			"oacs      : " + c.oacs,
+			"fna       : " + c.fna,
+		}
+		format, crypto := parseNVMeFormatSupport(lines)
+		if format != c.format || crypto != c.crypto {
+			t.Errorf("oacs %s fna %s: want %v/%v, got %v/%v", c.oacs, c.fna, c.format, c.crypto, format, crypto)
+		}
+	}
+}
+
+func TestParseSmartHealth(t *testing.T) {
+	cases := map[string]string{
+		"SMART overall-health self-assessment test result: PASSED":  api.DISK_HEALTH_PASSED,
+		"SMART overall-health self-assessment test result: FAILED!": api.DISK_HEALTH_FAILED,
+		"SMART Health Status: OK":                                   api.DISK_HEALTH_PASSED,
+		"Smartctl open device: /dev/sda failed":                     api.DISK_HEALTH_UNKNOWN,
+	}
+	for line, want := range cases {
+		if got := parseSmartHealth([]string{"=== START OF READ SMART DATA SECTION ===", line}); got != want {
+			t.Errorf("%q: want %s, got %s", line, want, got)
+		}
+	}
+}
+
+func TestGetCanaryBlocks(t *testing.T) {
+	if got := getCanaryBlocks(1); len(got) != 3 || got[0] != 0 || got[1] != 128 || got[2] != 255 {
+		t.Errorf("unexpected canary blocks %v", got)
+	}
+	if got := getCanaryBlocks(0); len(got) != 0 {
+		t.Errorf("want no canary for empty disk, got %v", got)
+	}
+}
+
+// fakeDisk is a 1MB disk holding blocks written by canaries
+type fakeDisk struct {
+	blocks     map[int64]string
+	trimClears bool
+	cmds       []string
+}
+
+var (
+	writeRe = regexp.MustCompile(`^printf '%s' (\w+) \| dd of=\S+ bs=\d+ seek=(\d+)`)
+	readRe  = regexp.MustCompile(`^dd if=\S+ bs=\d+ skip=(\d+)`)
+)
+
+func (d *fakeDisk) Run(cmds ...string) ([]string, error) {
+	cmd := cmds[0]
+	d.cmds = append(d.cmds, cmd)
+	if m := writeRe.FindStringSubmatch(cmd); m != nil {
+		block, _ := strconv.ParseInt(m[2], 10, 64)
+		d.blocks[block] = m[1]
+		return nil, nil
+	}
+	if m := readRe.FindStringSubmatch(cmd); m != nil {
+		block, _ := strconv.ParseInt(m[1], 10, 64)
+		data := hex.EncodeToString([]byte(d.blocks[block]))
+		return []string{data + strings.Repeat("0", canaryBlockSize*2-len(data))}, nil
+	}
+	switch {
+	case strings.HasPrefix(cmd, "smartctl"):
+		return []string{"SMART overall-health self-assessment test result: PASSED"}, nil
+	case strings.HasPrefix(cmd, "lsblk"):
+		return []string{"S3EWNX0K"}, nil
+	case strings.HasPrefix(cmd, "hdparm -I"):
+		return strings.Split(fmt.Sprintf(hdparmOutput, ""), "\n"), nil
+	case strings.HasPrefix(cmd, "blkdiscard"):
+		if d.trimClears {
+			d.blocks = map[int64]string{}
+		}
+		return nil, nil
+	case strings.HasPrefix(cmd, "shred"):
+		d.blocks = map[int64]string{}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected command %q", cmd)
+}
+
+func TestWipeDisk(t *testing.T) {
+	ssd := &baremetal.BaremetalStorage{
+		Dev:        "sda",
+		Driver:     baremetal.DISK_DRIVER_LINUX,
+		Size:       1,
+		ModuleInfo: "INTEL SSDSC2BB480G4",
+	}
+	cases := []struct {
+		name       string
+		mode       string
+		trimClears bool
+		method     string
+		passes     int
+	}{
+		// frozen disk can't be secure erased, TRIM is verified
+		{"trim", api.DISK_WIPE_MODE_AUTO, true, api.DISK_WIPE_METHOD_TRIM, 0},
+		// TRIM leaves data readable, fallback to overwrite
+		{"fallback", api.DISK_WIPE_MODE_AUTO, false, api.DISK_WIPE_METHOD_OVERWRITE, 3},
+		{"overwrite", api.DISK_WIPE_MODE_OVERWRITE, true, api.DISK_WIPE_METHOD_OVERWRITE, 3},
+	}
+	for _, c := range cases {
+		disk := &fakeDisk{blocks: map[int64]string{}, trimClears: c.trimClears}
+		cert := NewWiper(disk, c.mode, 3).WipeDisk(ssd)
+		if !cert.Verified {
+			t.Errorf("%s: wipe not verified: %s", c.name, cert.Reason)
+		}
+		if cert.Method != c.method || cert.Passes != c.passes {
+			t.Errorf("%s: want method %s passes %d, got %s %d", c.name, c.method, c.passes, cert.Method, cert.Passes)
+		}
+		if cert.Dev != "/dev/sda" || cert.Serial != "S3EWNX0K" || cert.Health != api.DISK_HEALTH_PASSED {
+			t.Errorf("%s: unexpected certificate %#v", c.name, cert)
+		}
+		if c.mode == api.DISK_WIPE_MODE_OVERWRITE {
+			for _, cmd := range disk.cmds {
+				if strings.HasPrefix(cmd, "blkdiscard") {
+					t.Errorf("%s: should not trim", c.name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipmitool
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/pkg/util/stringutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+)
+
+var (
+	// SEL events containing these are critical when asserted, checked
+	// before warning ones as "uncorrectable ecc" contains "correctable ecc"
+	selCriticalEvents = []string{
+		"failure detected", "ac lost", "input lost", "uncorrectable ecc",
+		"upper critical", "lower critical", "non-recoverable", "ierr",
+		"thermal trip", "machine check", "drive fault", "redundancy lost",
+	}
+	selWarningEvents = []string{
+		"predictive failure", "correctable ecc", "non-critical",
+		"redundancy degraded",
+	}
+)
+
+func splitColumns(line string) []string {
+	cols := strings.Split(line, "|")
+	for i := range cols {
+		cols[i] = strings.TrimSpace(cols[i])
+	}
+	return cols
+}
+
+func parseThreshold(val string) float64 {
+	f, _ := strconv.ParseFloat(val, 64)
+	return f
+}
+
+func sensorTypeByUnit(unit string) string {
+	switch strings.ToLower(unit) {
+	case "degrees c":
+		return api.SENSOR_TYPE_TEMPERATURE
+	case "rpm":
+		return api.SENSOR_TYPE_FAN
+	case "volts":
+		return api.SENSOR_TYPE_VOLTAGE
+	}
+	return api.SENSOR_TYPE_OTHER
+}
+
+func sensorStatus(status string) string {
+	switch strings.ToLower(status) {
+	case "ok":
+		return api.HARDWARE_STATUS_OK
+	case "nc", "lnc", "unc":
+		return api.HARDWARE_STATUS_WARNING
+	case "cr", "nr", "lcr", "ucr", "lnr", "unr":
+		return api.HARDWARE_STATUS_CRITICAL
+	}
+	return api.HARDWARE_STATUS_UNKNOWN
+}
+
+// ParseSensors parses threshold based sensors from output of `ipmitool
+// sensor`, whose columns are name, reading, unit, status, then lower
+// non-recoverable, lower critical, lower non-critical, upper non-critical,
+// upper critical and upper non-recoverable thresholds.  Discrete sensors
+// and sensors without reading are skipped
+func ParseSensors(lines []string) []api.BaremetalSensor {
+	ret := make([]api.BaremetalSensor, 0)
+	for _, line := range lines {
+		cols := splitColumns(line)
+		if len(cols) < 10 || cols[2] == "discrete" {
+			continue
+		}
+		reading, err := strconv.ParseFloat(cols[1], 64)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, api.BaremetalSensor{
+			Name:          cols[0],
+			Type:          sensorTypeByUnit(cols[2]),
+			Reading:       reading,
+			Unit:          cols[2],
+			Status:        sensorStatus(cols[3]),
+			LowerCritical: parseThreshold(cols[5]),
+			UpperWarning:  parseThreshold(cols[7]),
+			UpperCritical: parseThreshold(cols[8]),
+		})
+	}
+	return ret
+}
+
+// ParsePowerSupplies parses power supply state from output of `ipmitool sdr
+// type 0x08`, columns are name, id, status, entity and states
+func ParsePowerSupplies(lines []string) []api.BaremetalSensor {
+	ret := make([]api.BaremetalSensor, 0)
+	for _, line := range lines {
+		cols := splitColumns(line)
+		if len(cols) < 5 || cols[2] == "ns" {
+			continue
+		}
+		sensor := api.BaremetalSensor{
+			Name:   cols[0],
+			Type:   api.SENSOR_TYPE_POWER_SUPPLY,
+			State:  cols[4],
+			Status: sensorStatus(cols[2]),
+		}
+		if severity := getEventSeverity(cols[4]); severity != api.HARDWARE_STATUS_OK {
+			sensor.Status = severity
+		}
+		ret = append(ret, sensor)
+	}
+	return ret
+}
+
+func getEventSeverity(event string) string {
+	event = strings.ToLower(event)
+	for _, e := range selCriticalEvents {
+		if strings.Contains(event, e) {
+			return api.HARDWARE_STATUS_CRITICAL
+		}
+	}
+	for _, e := range selWarningEvents {
+		if strings.Contains(event, e) {
+			return api.HARDWARE_STATUS_WARNING
+		}
+	}
+	return api.HARDWARE_STATUS_OK
+}
+
+// ParseSelEvents parses output of `ipmitool sel elist`, columns are record
+// id in hex, date, time, sensor, event and optional direction
+func ParseSelEvents(lines []string) []api.BaremetalSelEvent {
+	ret := make([]api.BaremetalSelEvent, 0)
+	for _, line := range lines {
+		cols := splitColumns(line)
+		if len(cols) < 5 {
+			continue
+		}
+		id, err := strconv.ParseInt(cols[0], 16, 64)
+		if err != nil {
+			continue
+		}
+		event := api.BaremetalSelEvent{
+			Id:       int(id),
+			Time:     cols[1] + " " + cols[2],
+			Sensor:   cols[3],
+			Event:    cols[4],
+			Asserted: true,
+		}
+		if len(cols) > 5 && cols[5] == "Deasserted" {
+			event.Asserted = false
+		}
+		if event.Asserted {
+			event.Severity = getEventSeverity(event.Sensor + " " + event.Event)
+		} else {
+			event.Severity = api.HARDWARE_STATUS_OK
+		}
+		ret = append(ret, event)
+	}
+	return ret
+}
+
+func GetSensors(exector IPMIExecutor) ([]api.BaremetalSensor, error) {
+	lines, err := ExecuteCommands(exector, newArgs("sensor"))
+	if err != nil {
+		return nil, fmt.Errorf("get sensors: %v", err)
+	}
+	sensors := ParseSensors(lines)
+	// 0x08 is sensor type of power supply
+	lines, err = ExecuteCommands(exector, newArgs("sdr", "type", "0x08"))
+	if err != nil {
+		return nil, fmt.Errorf("get power supplies: %v", err)
+	}
+	return append(sensors, ParsePowerSupplies(lines)...), nil
+}
+
+func GetSelEvents(exector IPMIExecutor, count int) ([]api.BaremetalSelEvent, error) {
+	lines, err := ExecuteCommands(exector, newArgs("sel", "elist", "last", count))
+	if err != nil {
+		return nil, fmt.Errorf("get sel events: %v", err)
+	}
+	return ParseSelEvents(lines), nil
+}
+
+func GetBMCFirmwareVersion(exector IPMIExecutor) (string, error) {
+	lines, err := ExecuteCommands(exector, newArgs("mc", "info"))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range lines {
+		key, val := stringutils.SplitKeyValue(line)
+		if key == "Firmware Revision" {
+			return val, nil
+		}
+	}
+	return "", fmt.Errorf("firmware revision not found")
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipmitool
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+)
+
+func TestParseSensors(t *testing.T) {
+	out := `CPU1 Temp        | 62.000     | degrees C  | ok    | 0.000     | 0.000     | 0.000     | 84.000    | 89.000    | 90.000
+FAN1             | 600.000    | RPM        | cr    | 300.000   | 700.000   | 900.000   | na        | na        | na
+12V              | 12.096     | Volts      | ok    | 10.173    | 10.299    | 10.740    | 13.260    | 13.701    | 13.827
+FAN2             | na         | RPM        | na    | na        | na        | na        | na        | na        | na
+PS1 Status       | 0x1        | discrete   | 0x0100| na        | na        | na        | na        | na        | na`
+	got := ParseSensors(strings.Split(out, "\n"))
+	want := []api.BaremetalSensor{
+		{Name: "CPU1 Temp", Type: api.SENSOR_TYPE_TEMPERATURE, Reading: 62, Unit: "degrees C", Status: api.HARDWARE_STATUS_OK, UpperWarning: 84, UpperCritical: 89},
+		{Name: "FAN1", Type: api.SENSOR_TYPE_FAN, Reading: 600, Unit: "RPM", Status: api.HARDWARE_STATUS_CRITICAL, LowerCritical: 700},
+		{Name: "12V", Type: api.SENSOR_TYPE_VOLTAGE, Reading: 12.096, Unit: "Volts", Status: api.HARDWARE_STATUS_OK, LowerCritical: 10.299, UpperWarning: 13.26, UpperCritical: 13.701},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestParsePowerSupplies(t *testing.T) {
+	out := `PS1 Status       | C8h | ok  | 10.1 | Presence detected
+PS2 Status       | C9h | ok  | 10.2 | Presence detected, Power Supply AC lost
+PS3 Status       | CAh | ns  | 10.3 | No Reading`
+	got := ParsePowerSupplies(strings.Split(out, "\n"))
+	want := []api.BaremetalSensor{
+		{Name: "PS1 Status", Type: api.SENSOR_TYPE_POWER_SUPPLY, State: "Presence detected", Status: api.HARDWARE_STATUS_OK},
+		{Name: "PS2 Status", Type: api.SENSOR_TYPE_POWER_SUPPLY, State: "Presence detected, Power Supply AC lost", Status: api.HARDWARE_STATUS_CRITICAL},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestParseSelEvents(t *testing.T) {
+	out := `   1 | 08/01/2019 | 10:00:00 | Temperature CPU1 Temp | Upper Critical going high | Asserted
+   2 | 08/01/2019 | 10:05:00 | Temperature CPU1 Temp | Upper Critical going high | Deasserted
+   a | 08/01/2019 | 10:06:00 | Memory #0x53 | Correctable ECC | Asserted
+   b | 08/01/2019 | 10:07:00 | Memory #0x53 | Uncorrectable ECC | Asserted
+   c | 08/01/2019 | 10:08:00 | Event Logging Disabled #0x07 | Log area reset/cleared | Asserted`
+	got := ParseSelEvents(strings.Split(out, "\n"))
+	want := []api.BaremetalSelEvent{
+		{Id: 1, Time: "08/01/2019 10:00:00", Sensor: "Temperature CPU1 Temp", Event: "Upper Critical going high", Asserted: true, Severity: api.HARDWARE_STATUS_CRITICAL},
+		{Id: 2, Time: "08/01/2019 10:05:00", Sensor: "Temperature CPU1 Temp", Event: "Upper Critical going high", Asserted: false, Severity: api.HARDWARE_STATUS_OK},
+		{Id: 10, Time: "08/01/2019 10:06:00", Sensor: "Memory #0x53", Event: "Correctable ECC", Asserted: true, Severity: api.HARDWARE_STATUS_WARNING},
+		{Id: 11, Time: "08/01/2019 10:07:00", Sensor: "Memory #0x53", Event: "Uncorrectable ECC", Asserted: true, Severity: api.HARDWARE_STATUS_CRITICAL},
+		{Id: 12, Time: "08/01/2019 10:08:00", Sensor: "Event Logging Disabled #0x07", Event: "Log area reset/cleared", Asserted: true, Severity: api.HARDWARE_STATUS_OK},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v, got %#v", want, got)
+	}
+}
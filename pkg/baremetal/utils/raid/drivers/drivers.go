@@ -20,6 +20,7 @@ import (
 	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/baremetal/utils/raid"
 	_ "yunion.io/x/onecloud/pkg/baremetal/utils/raid/hpssactl"
+	"yunion.io/x/onecloud/pkg/baremetal/utils/raid/mdadm"
 	_ "yunion.io/x/onecloud/pkg/baremetal/utils/raid/megactl"
 	_ "yunion.io/x/onecloud/pkg/baremetal/utils/raid/mvcli"
 	_ "yunion.io/x/onecloud/pkg/baremetal/utils/raid/sas2iru"
@@ -27,9 +28,15 @@ import (
 	"yunion.io/x/onecloud/pkg/util/ssh"
 )
 
+// GetDriver returns raid driver of disks of driver name, disks not behind a
+// raid controller get the software raid driver. It is not registered in
+// RaidDrivers because GetDrivers is used to detect raid controllers
 func GetDriver(name string, term *ssh.Client) raid.IRaidDriver {
 	factory := raid.RaidDrivers[name]
 	if factory == nil {
+		if baremetal.DISK_DRIVERS_SOFT_RAID.Has(name) {
+			return mdadm.NewMdadmRaid(name, term)
+		}
 		return nil
 	}
 	return factory(term)
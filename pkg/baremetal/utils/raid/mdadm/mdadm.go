@@ -0,0 +1,369 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdadm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/baremetal/utils/raid"
+	"yunion.io/x/onecloud/pkg/compute/baremetal"
+	"yunion.io/x/onecloud/pkg/util/ssh"
+	"yunion.io/x/onecloud/pkg/util/sysutils"
+)
+
+const (
+	MDSTAT_PATH = "/proc/mdstat"
+)
+
+var (
+	mdArrayRegexp  = regexp.MustCompile(`^md(\d+)\s*:\s*(.*)$`)
+	mdMemberRegexp = regexp.MustCompile(`^([^\[\s]+)\[\d+\]`)
+	partSuffixExp  = regexp.MustCompile(`^\d+$`)
+)
+
+func GetCommand(args ...string) string {
+	bin := "/sbin/mdadm"
+	return raid.GetCommand(bin, args...)
+}
+
+// IRunner runs commands on the baremetal, ssh.Client is one
+type IRunner interface {
+	Run(cmds ...string) ([]string, error)
+}
+
+// SMdArray is a software raid array listed in /proc/mdstat
+type SMdArray struct {
+	Index   int
+	State   string
+	Level   string
+	Members []string
+}
+
+func (a *SMdArray) GetName() string {
+	return fmt.Sprintf("md%d", a.Index)
+}
+
+func (a *SMdArray) GetDev() string {
+	return "/dev/" + a.GetName()
+}
+
+// HasMember tells whether disk dev, or a partition of it, is a member of the array
+func (a *SMdArray) HasMember(dev string) bool {
+	for _, m := range a.Members {
+		if m == dev {
+			return true
+		}
+		if strings.HasPrefix(m, dev) && isPartSuffix(dev, m[len(dev):]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPartSuffix tells whether suffix appended to disk dev names a partition,
+// e.g. sda1 of sda and nvme0n1p1 of nvme0n1
+func isPartSuffix(dev, suffix string) bool {
+	if len(dev) > 0 && dev[len(dev)-1] >= '0' && dev[len(dev)-1] <= '9' {
+		if !strings.HasPrefix(suffix, "p") {
+			return false
+		}
+		suffix = suffix[1:]
+	}
+	return partSuffixExp.MatchString(suffix)
+}
+
+// ParseMdstat parses arrays from content of /proc/mdstat, sorted by index
+func ParseMdstat(lines []string) []*SMdArray {
+	ret := make([]*SMdArray, 0)
+	for _, line := range lines {
+		m := mdArrayRegexp.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		idx, _ := strconv.Atoi(m[1])
+		array := &SMdArray{Index: idx, Members: make([]string, 0)}
+		for i, field := range strings.Fields(m[2]) {
+			if i == 0 {
+				array.State = field
+				continue
+			}
+			if mm := mdMemberRegexp.FindStringSubmatch(field); mm != nil {
+				array.Members = append(array.Members, mm[1])
+			} else if strings.HasPrefix(field, "raid") || field == "linear" {
+				array.Level = field
+			}
+		}
+		ret = append(ret, array)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Index < ret[j].Index })
+	return ret
+}
+
+// GetFreeIndex returns the lowest md index not used by arrays
+func GetFreeIndex(arrays []*SMdArray) int {
+	used := make(map[int]bool)
+	for _, a := range arrays {
+		used[a.Index] = true
+	}
+	idx := 0
+	for used[idx] {
+		idx++
+	}
+	return idx
+}
+
+func GetArrays(term IRunner) ([]*SMdArray, error) {
+	lines, err := term.Run("cat " + MDSTAT_PATH)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %v", MDSTAT_PATH, err)
+	}
+	return ParseMdstat(lines), nil
+}
+
+type SMdadmAdapter struct {
+	index int
+	raid  *SMdadmRaid
+	devs  []*baremetal.BaremetalStorage
+}
+
+func (adapter *SMdadmAdapter) GetIndex() int {
+	return adapter.index
+}
+
+func (adapter *SMdadmAdapter) GetDevices() []*baremetal.BaremetalStorage {
+	return adapter.devs
+}
+
+func (adapter *SMdadmAdapter) hasMember(array *SMdArray) bool {
+	for _, dev := range adapter.devs {
+		if array.HasMember(dev.Dev) {
+			return true
+		}
+	}
+	return false
+}
+
+func (adapter *SMdadmAdapter) getArrays() ([]*SMdArray, error) {
+	arrays, err := GetArrays(adapter.raid.term)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*SMdArray, 0)
+	for _, a := range arrays {
+		if adapter.hasMember(a) {
+			ret = append(ret, a)
+		}
+	}
+	return ret, nil
+}
+
+func (adapter *SMdadmAdapter) GetLogicVolumes() ([]int, error) {
+	arrays, err := adapter.getArrays()
+	if err != nil {
+		return nil, err
+	}
+	lvs := []int{}
+	for _, a := range arrays {
+		lvs = append(lvs, a.Index)
+	}
+	return lvs, nil
+}
+
+func (adapter *SMdadmAdapter) RemoveLogicVolumes() error {
+	arrays, err := adapter.getArrays()
+	if err != nil {
+		return fmt.Errorf("Failed to get arrays: %v", err)
+	}
+	for _, a := range arrays {
+		if _, err := adapter.raid.term.Run(GetCommand("--stop", a.GetDev())); err != nil {
+			return fmt.Errorf("Stop array %s: %v", a.GetName(), err)
+		}
+	}
+	adapter.zeroSuperblocks()
+	return nil
+}
+
+// zeroSuperblocks removes md metadata left on disks, so arrays of previous
+// deployment will not be assembled again
+func (adapter *SMdadmAdapter) zeroSuperblocks() {
+	for _, dev := range adapter.devs {
+		cmd := GetCommand("--zero-superblock", "--force", "/dev/"+dev.Dev)
+		if _, err := adapter.raid.term.Run(cmd); err != nil {
+			log.Debugf("zero superblock of %s: %v", dev.Dev, err)
+		}
+	}
+}
+
+func (adapter *SMdadmAdapter) PreBuildRaid(confs []*api.BaremetalDiskConfig) error {
+	return nil
+}
+
+func (adapter *SMdadmAdapter) buildRaid(level string, devs []*baremetal.BaremetalStorage, conf *api.BaremetalDiskConfig) error {
+	arrays, err := GetArrays(adapter.raid.term)
+	if err != nil {
+		return err
+	}
+	array := &SMdArray{Index: GetFreeIndex(arrays)}
+	// raid1 keeps superblock at the end of disks, so each member starts
+	// with the boot sector and firmware is able to boot from it
+	metadata := "1.2"
+	if level == "1" {
+		metadata = "1.0"
+	}
+	args := []string{
+		"--create", array.GetDev(), "--run", "--metadata=" + metadata,
+		fmt.Sprintf("--level=%s", level),
+		fmt.Sprintf("--raid-devices=%d", len(devs)),
+	}
+	if len(devs) == 1 {
+		// mdadm refuses single device array without force
+		args = append(args, "--force")
+	}
+	if conf.Strip != nil && level != "1" {
+		args = append(args, fmt.Sprintf("--chunk=%d", *conf.Strip))
+	}
+	for _, dev := range devs {
+		args = append(args, "/dev/"+dev.Dev)
+	}
+	if _, err := adapter.raid.term.Run(GetCommand(args...)); err != nil {
+		return fmt.Errorf("Create array %s: %v", array.GetName(), err)
+	}
+	return nil
+}
+
+func (adapter *SMdadmAdapter) BuildRaid0(devs []*baremetal.BaremetalStorage, conf *api.BaremetalDiskConfig) error {
+	return adapter.buildRaid("0", devs, conf)
+}
+
+func (adapter *SMdadmAdapter) BuildRaid1(devs []*baremetal.BaremetalStorage, conf *api.BaremetalDiskConfig) error {
+	return adapter.buildRaid("1", devs, conf)
+}
+
+func (adapter *SMdadmAdapter) BuildRaid5(devs []*baremetal.BaremetalStorage, conf *api.BaremetalDiskConfig) error {
+	return adapter.buildRaid("5", devs, conf)
+}
+
+func (adapter *SMdadmAdapter) BuildRaid10(devs []*baremetal.BaremetalStorage, conf *api.BaremetalDiskConfig) error {
+	return adapter.buildRaid("10", devs, conf)
+}
+
+func (adapter *SMdadmAdapter) BuildNoneRaid(devs []*baremetal.BaremetalStorage) error {
+	// raw disks are used directly
+	return nil
+}
+
+// SMdadmRaid builds linux software raid over disks not behind a raid
+// controller, driver is DISK_DRIVER_LINUX for SATA/SAS disks of HBA and
+// DISK_DRIVER_PCIE for NVMe disks
+type SMdadmRaid struct {
+	term     *ssh.Client
+	driver   string
+	adapters []*SMdadmAdapter
+}
+
+func NewMdadmRaid(driver string, term *ssh.Client) raid.IRaidDriver {
+	return &SMdadmRaid{
+		term:     term,
+		driver:   driver,
+		adapters: make([]*SMdadmAdapter, 0),
+	}
+}
+
+func (r *SMdadmRaid) GetName() string {
+	return r.driver
+}
+
+func (r *SMdadmRaid) ParsePhyDevs() error {
+	var lsArg string
+	switch r.driver {
+	case baremetal.DISK_DRIVER_LINUX:
+		lsArg = "--nonraid"
+	case baremetal.DISK_DRIVER_PCIE:
+		lsArg = "--pcie"
+	default:
+		return fmt.Errorf("Unsupported driver %s", r.driver)
+	}
+	ret, err := r.term.Run("/lib/mos/lsdisk " + lsArg)
+	if err != nil {
+		return fmt.Errorf("List disks: %v", err)
+	}
+	adapter := &SMdadmAdapter{
+		index: 0,
+		raid:  r,
+		devs:  make([]*baremetal.BaremetalStorage, 0),
+	}
+	for _, info := range sysutils.ParseDiskInfo(ret, r.driver) {
+		adapter.devs = append(adapter.devs, &baremetal.BaremetalStorage{
+			Driver:     info.Driver,
+			Size:       info.Size,
+			Rotate:     info.Rotate,
+			Dev:        info.Dev,
+			Sector:     info.Sector,
+			Block:      info.Block,
+			ModuleInfo: info.ModuleInfo,
+			Kernel:     info.Kernel,
+			PCIClass:   info.PCIClass,
+		})
+	}
+	if len(adapter.devs) == 0 {
+		return fmt.Errorf("Empty devices")
+	}
+	r.adapters = []*SMdadmAdapter{adapter}
+	return nil
+}
+
+func (r *SMdadmRaid) PreBuildRaid(_ []*api.BaremetalDiskConfig, _ int) error {
+	return nil
+}
+
+func (r *SMdadmRaid) GetAdapters() []raid.IRaidAdapter {
+	ret := make([]raid.IRaidAdapter, 0)
+	for _, a := range r.adapters {
+		ret = append(ret, a)
+	}
+	return ret
+}
+
+func (r *SMdadmRaid) CleanRaid() error {
+	for _, a := range r.adapters {
+		if err := a.RemoveLogicVolumes(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDetailScan returns ARRAY lines of running arrays used as mdadm.conf of
+// deployed system
+func GetDetailScan(term IRunner) (string, error) {
+	lines, err := term.Run(GetCommand("--detail", "--scan"))
+	if err != nil {
+		return "", err
+	}
+	ret := []string{}
+	for _, l := range lines {
+		if strings.HasPrefix(l, "ARRAY ") {
+			ret = append(ret, l)
+		}
+	}
+	return strings.Join(ret, "\n"), nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdadm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mdstat = `Personalities : [raid1] [raid0] [raid10]
+md1 : active raid10 sdd[3] sdc[2] sdb[1] sda[0]
+      3906762752 blocks super 1.2 512K chunks 2 near-copies [4/4] [UUUU]
+      bitmap: 0/30 pages [0KB], 65536KB chunk
+
+md0 : active (auto-read-only) raid1 nvme1n1[1] nvme0n1[0](F)
+      1953382464 blocks super 1.0 [2/1] [_U]
+      	resync=PENDING
+
+md127 : inactive sde1[0](S) nvme2n1p2[1](S)
+      976630488 blocks super 1.2
+
+unused devices: <none>`
+
+func TestParseMdstat(t *testing.T) {
+	arrays := ParseMdstat(strings.Split(mdstat, "\n"))
+	want := []SMdArray{
+		{Index: 0, State: "active", Level: "raid1", Members: []string{"nvme1n1", "nvme0n1"}},
+		{Index: 1, State: "active", Level: "raid10", Members: []string{"sdd", "sdc", "sdb", "sda"}},
+		{Index: 127, State: "inactive", Members: []string{"sde1", "nvme2n1p2"}},
+	}
+	if len(arrays) != len(want) {
+		t.Fatalf("want %d arrays, got %d", len(want), len(arrays))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(*arrays[i], want[i]) {
+			t.Errorf("array %d: want %#v, got %#v", i, want[i], *arrays[i])
+		}
+	}
+	if idx := GetFreeIndex(arrays); idx != 2 {
+		t.Errorf("want free index 2, got %d", idx)
+	}
+
+	cases := []struct {
+		array  int
+		dev    string
+		member bool
+	}{
+		{0, "nvme0n1", true},
+		{0, "nvme0n11", false},
+		{1, "sda", true},
+		{1, "sde", false},
+		{2, "sde", true},
+		{2, "sd", false},
+		{2, "nvme2n1", true},
+		{2, "nvme2n", false},
+		{2, "nvme2", false},
+	}
+	for _, c := range cases {
+		if got := arrays[c.array].HasMember(c.dev); got != c.member {
+			t.Errorf("%s HasMember(%s): want %v, got %v", arrays[c.array].GetName(), c.dev, c.member, got)
+		}
+	}
+}
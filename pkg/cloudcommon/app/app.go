@@ -30,6 +30,7 @@ func InitApp(options *common_options.CommonOptions, dbAccess bool) *appsrv.Appli
 	// cache := appsrv.NewCache(options.AuthTokenCacheSize)
 	app := appsrv.NewApplication(options.ApplicationID, options.RequestWorkerCount, dbAccess)
 	app.CORSAllowHosts(options.CorsHosts)
+	initRateLimit(app, options)
 
 	// app.SetContext(appsrv.APP_CONTEXT_KEY_CACHE, cache)
 	// if dbConn != nil {
@@ -0,0 +1,118 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+// RATE_LIMIT_PARAMETER is the yunionconf parameter in the service namespace
+// that overrides the rate limits of the options, handlers are given by
+// method and registered path, its value looks like
+// {"rate": 10, "burst": 50, "handlers": {"GET /servers": {"rate": 2, "burst": 10}}}
+const RATE_LIMIT_PARAMETER = "rate_limit"
+
+func initRateLimit(app *appsrv.Application, options *common_options.CommonOptions) {
+	if !options.EnableRateLimit {
+		return
+	}
+	limiter := appsrv.NewRateLimiter(auth.RateLimitIdentity, defaultRateLimit(options))
+	app.SetRateLimiter(limiter)
+	go syncRateLimits(limiter, options)
+}
+
+func defaultRateLimit(options *common_options.CommonOptions) appsrv.SRateLimit {
+	return appsrv.SRateLimit{
+		Rate:  options.RateLimitRequestsPerSecond,
+		Burst: options.RateLimitBurst,
+	}
+}
+
+func syncRateLimits(limiter *appsrv.SRateLimiter, options *common_options.CommonOptions) {
+	interval := time.Duration(options.RateLimitSyncIntervalSeconds) * time.Second
+	if interval <= 0 {
+		return
+	}
+	for {
+		if auth.IsAuthed() {
+			limit, handlerLimits, err := fetchRateLimits(options)
+			if err != nil {
+				log.Errorf("fetch rate limits from yunionconf fail: %s", err)
+			} else {
+				limiter.SetLimits(limit, handlerLimits)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+func fetchRateLimits(options *common_options.CommonOptions) (appsrv.SRateLimit, map[string]appsrv.SRateLimit, error) {
+	limit := defaultRateLimit(options)
+	s := auth.GetAdminSession(context.Background(), options.Region, "")
+	param, err := modules.Parameters.GetInContext(s, RATE_LIMIT_PARAMETER, nil, &modules.ServicesV3, consts.GetServiceType())
+	if err != nil {
+		if je, ok := err.(*httputils.JSONClientError); ok && je.Code == 404 {
+			return limit, nil, nil
+		}
+		return limit, nil, err
+	}
+	value, err := param.Get("value")
+	if err != nil {
+		return limit, nil, err
+	}
+	return parseRateLimits(value, limit)
+}
+
+func parseRateLimits(value jsonutils.JSONObject, limit appsrv.SRateLimit) (appsrv.SRateLimit, map[string]appsrv.SRateLimit, error) {
+	// parameter-create stores the value as a JSON encoded string
+	if str, ok := value.(*jsonutils.JSONString); ok {
+		var err error
+		value, err = jsonutils.ParseString(str.Value())
+		if err != nil {
+			return limit, nil, err
+		}
+	}
+	err := value.Unmarshal(&limit)
+	if err != nil {
+		return limit, nil, err
+	}
+	handlerLimits := make(map[string]appsrv.SRateLimit)
+	if value.Contains("handlers") {
+		handlers, err := value.GetMap("handlers")
+		if err != nil {
+			return limit, nil, err
+		}
+		for name, v := range handlers {
+			hl := limit
+			err := v.Unmarshal(&hl)
+			if err != nil {
+				return limit, nil, err
+			}
+			handlerLimits[name] = hl
+		}
+	}
+	return limit, handlerLimits, nil
+}
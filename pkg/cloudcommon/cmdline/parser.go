@@ -209,6 +209,37 @@ func ParseIsolatedDevice(desc string, idx int) (*compute.IsolatedDeviceConfig, e
 	return dev, nil
 }
 
+// ParseTopologySpreadConfig desc format: <groupId>:<topologyKey>:<maxSkew>[:<strategy>],
+// topologyKey is rack, zone or schedtag:<key>
+func ParseTopologySpreadConfig(desc string) (*compute.TopologySpreadConfig, error) {
+	if len(desc) == 0 {
+		return nil, ErrorEmptyDesc
+	}
+	parts := strings.Split(desc, ":")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("Invalid desc: %s", desc)
+	}
+	conf := &compute.TopologySpreadConfig{
+		GroupId:  parts[0],
+		Strategy: compute.STRATEGY_REQUIRE,
+	}
+	last := len(parts) - 1
+	if len(parts) > 3 && utils.IsInStringArray(parts[last], []string{compute.STRATEGY_REQUIRE, compute.STRATEGY_PREFER}) {
+		conf.Strategy = parts[last]
+		last -= 1
+	}
+	maxSkew, err := strconv.Atoi(parts[last])
+	if err != nil || maxSkew < 1 {
+		return nil, fmt.Errorf("Invalid max skew: %s", parts[last])
+	}
+	conf.MaxSkew = maxSkew
+	conf.TopologyKey = strings.Join(parts[1:last], ":")
+	if len(conf.GroupId) == 0 || len(conf.TopologyKey) == 0 {
+		return nil, fmt.Errorf("Invalid desc: %s", desc)
+	}
+	return conf, nil
+}
+
 func ParseBaremetalDiskConfig(desc string) (*compute.BaremetalDiskConfig, error) {
 	bdc := new(compute.BaremetalDiskConfig)
 	bdc.Type = compute.DISK_TYPE_HYBRID
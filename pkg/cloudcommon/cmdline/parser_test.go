@@ -65,6 +65,47 @@ func TestParseSchedtagConfig(t *testing.T) {
 	}
 }
 
+func TestParseTopologySpreadConfig(t *testing.T) {
+	tests := []struct {
+		desc    string
+		want    *compute.TopologySpreadConfig
+		wantErr bool
+	}{
+		{
+			desc:    "",
+			wantErr: true,
+		},
+		{
+			desc: "group1:rack:1",
+			want: &compute.TopologySpreadConfig{GroupId: "group1", TopologyKey: "rack", MaxSkew: 1, Strategy: "require"},
+		},
+		{
+			desc: "group1:schedtag:power:2:prefer",
+			want: &compute.TopologySpreadConfig{GroupId: "group1", TopologyKey: "schedtag:power", MaxSkew: 2, Strategy: "prefer"},
+		},
+		{
+			desc:    "group1:zone:0",
+			wantErr: true,
+		},
+		{
+			desc:    "group1:1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := ParseTopologySpreadConfig(tt.desc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTopologySpreadConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTopologySpreadConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseRange(t *testing.T) {
 	type args struct {
 		rangeStr string
@@ -14,6 +14,10 @@
 
 package consts
 
+import (
+	"time"
+)
+
 /// Global virtual resource namespace
 
 var (
@@ -27,3 +31,19 @@ func EnableGlobalVirtualResourceNamespace() {
 func IsGlobalVirtualResourceNamespace() bool {
 	return globalVirtualResourceNamespace
 }
+
+/// Idempotency keys
+
+var (
+	idempotencyKeyExpire time.Duration
+)
+
+// SetIdempotencyKeyExpire sets how long the responses of requests carrying an
+// Idempotency-Key header are kept for replay, 0 disables idempotency keys
+func SetIdempotencyKeyExpire(expire time.Duration) {
+	idempotencyKeyExpire = expire
+}
+
+func GetIdempotencyKeyExpire() time.Duration {
+	return idempotencyKeyExpire
+}
@@ -24,6 +24,7 @@ import (
 
 	"yunion.io/x/onecloud/pkg/appctx"
 	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
 )
@@ -99,6 +100,8 @@ type SCronJobManager struct {
 	stop    chan struct{}
 	running bool
 	workers *appsrv.SWorkerManager
+
+	leaderName string
 }
 
 func GetCronJobManager(idDbWorker bool) *SCronJobManager {
@@ -112,6 +115,12 @@ func GetCronJobManager(idDbWorker bool) *SCronJobManager {
 	return manager
 }
 
+// EnableLeaderElection makes the jobs run only on the replica that leads
+// name, the other replicas wait in Start until they take over.
+func (self *SCronJobManager) EnableLeaderElection(name string) {
+	self.leaderName = name
+}
+
 func (self *SCronJobManager) AddJob1(name string, interval time.Duration, jobFunc TCronJobFunction) {
 	self.AddJob1WithStartRun(name, interval, jobFunc, false)
 }
@@ -174,6 +183,11 @@ func (self *SCronJobManager) Stop() {
 }
 
 func (self *SCronJobManager) run() {
+	if len(self.leaderName) > 0 {
+		log.Infof("Cron jobs wait for leadership of %s", self.leaderName)
+		lockman.ElectLeader(context.Background(), self.leaderName)
+		log.Infof("Cron jobs lead %s", self.leaderName)
+	}
 	now := time.Now()
 	self.Next(now)
 	heap.Init(&self.jobs)
@@ -19,6 +19,9 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -27,6 +30,7 @@ import (
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/etcd"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 )
 
@@ -43,6 +47,8 @@ func InitDB(options *common_options.DBOptions) {
 		sqlchemy.DEBUG_SQLCHEMY = true
 	}
 
+	consts.SetIdempotencyKeyExpire(time.Duration(options.IdempotencyKeyExpireHours) * time.Hour)
+
 	dialect, sqlStr, err := options.GetDBConnection()
 	if err != nil {
 		log.Fatalf("Invalid SqlConnection string: %s", options.SqlConnection)
@@ -53,8 +59,29 @@ func InitDB(options *common_options.DBOptions) {
 	}
 	sqlchemy.SetDB(dbConn)
 
-	lm := lockman.NewInMemoryLockManager()
-	// lm := lockman.NewNoopLockManager()
+	var lm lockman.ILockManager
+	switch options.LockmanMethod {
+	case "etcd":
+		err := etcd.InitDefaultEtcdClient(&options.SEtcdOptions)
+		if err != nil {
+			log.Fatalf("init etcd client for lockman fail %s", err)
+		}
+		lm = lockman.NewEtcdLockManager(etcd.Default())
+	case "mysql":
+		if dialect != "mysql" {
+			log.Fatalf("mysql lockman requires a mysql database, not %s", dialect)
+		}
+		// locks hold their connections, keep them out of the query pool
+		lockConn, err := sql.Open(dialect, sqlStr)
+		if err != nil {
+			log.Fatalf("open lockman db connection fail %s", err)
+		}
+		lm = lockman.NewMysqlLockManager(lockConn)
+	default:
+		lm = lockman.NewInMemoryLockManager()
+		// lm = lockman.NewNoopLockManager()
+	}
+	log.Infof("Lock manager: %s", options.LockmanMethod)
 	lockman.Init(lm)
 }
 
@@ -75,6 +102,41 @@ func AppDBInit(app *appsrv.Application) {
 	}
 
 	app.AddDefaultHandler("GET", "/db_stats", DBStatsHandler, "db_stats")
+	app.RegisterMetrics(&sDBStatsCollector{})
+}
+
+var (
+	dbConnectionsDesc = prometheus.NewDesc("db_connections",
+		"Number of database connections by state", []string{"state"}, nil)
+	dbMaxOpenConnectionsDesc = prometheus.NewDesc("db_max_open_connections",
+		"Maximal number of open database connections", nil, nil)
+	dbWaitCountDesc = prometheus.NewDesc("db_wait_count_total",
+		"Total number of waits for a database connection", nil, nil)
+	dbWaitDurationDesc = prometheus.NewDesc("db_wait_duration_seconds_total",
+		"Total time spent waiting for a database connection", nil, nil)
+)
+
+// sDBStatsCollector exports the connection pool statistics of /db_stats
+type sDBStatsCollector struct{}
+
+func (c *sDBStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbConnectionsDesc
+	ch <- dbMaxOpenConnectionsDesc
+	ch <- dbWaitCountDesc
+	ch <- dbWaitDurationDesc
+}
+
+func (c *sDBStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	dbConn := sqlchemy.GetDB()
+	if dbConn == nil {
+		return
+	}
+	stats := dbConn.Stats()
+	ch <- prometheus.MustNewConstMetric(dbConnectionsDesc, prometheus.GaugeValue, float64(stats.InUse), "in_use")
+	ch <- prometheus.MustNewConstMetric(dbConnectionsDesc, prometheus.GaugeValue, float64(stats.Idle), "idle")
+	ch <- prometheus.MustNewConstMetric(dbMaxOpenConnectionsDesc, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds())
 }
 
 func DBStatsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,315 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const (
+	CHANGE_EVENT_CREATED = "created"
+	CHANGE_EVENT_UPDATED = "updated"
+	CHANGE_EVENT_DELETED = "deleted"
+
+	CHANGE_FEED_POLL_INTERVAL = time.Second
+	CHANGE_FEED_BATCH_SIZE    = 1024
+	CHANGE_FEED_BUFFER_SIZE   = 256
+)
+
+// SChangeFeedFilter selects the events of a watcher
+type SChangeFeedFilter struct {
+	ObjType string
+	ObjIds  []string
+	// events of other projects are dropped if set
+	ProjectId string
+}
+
+func (filter *SChangeFeedFilter) match(opslog *SOpsLog) bool {
+	if opslog.ObjType != filter.ObjType {
+		return false
+	}
+	if len(filter.ObjIds) > 0 && !utils.IsInStringArray(opslog.ObjId, filter.ObjIds) {
+		return false
+	}
+	if len(filter.ProjectId) > 0 {
+		ownerId := opslog.OwnerProjectId
+		if len(ownerId) == 0 {
+			ownerId = opslog.ProjectId
+		}
+		if ownerId != filter.ProjectId {
+			return false
+		}
+	}
+	return true
+}
+
+type sChangeFeedSubscriber struct {
+	filter *SChangeFeedFilter
+	events chan *SOpsLog
+}
+
+// SChangeFeed fans the operation logs out to watchers. The logs are polled
+// from the database rather than taken from LogEvent, so that the events of
+// all replicas of the service are seen, LogEvent only wakes the poller up.
+type SChangeFeed struct {
+	lock        *sync.Mutex
+	subscribers map[*sChangeFeedSubscriber]bool
+	running     bool
+	lastSeq     int64
+	wakeup      chan struct{}
+}
+
+var ChangeFeed *SChangeFeed
+
+func init() {
+	ChangeFeed = &SChangeFeed{
+		lock:        &sync.Mutex{},
+		subscribers: make(map[*sChangeFeedSubscriber]bool),
+		wakeup:      make(chan struct{}, 1),
+	}
+}
+
+func changeEventOfAction(action string) string {
+	switch action {
+	case ACT_CREATE, ACT_SYNC_CREATE:
+		return CHANGE_EVENT_CREATED
+	case ACT_DELETE:
+		return CHANGE_EVENT_DELETED
+	default:
+		return CHANGE_EVENT_UPDATED
+	}
+}
+
+// ChangeEvent is the form of an operation log sent to watchers and webhooks
+func (opslog *SOpsLog) ChangeEvent() jsonutils.JSONObject {
+	ownerId := opslog.OwnerProjectId
+	if len(ownerId) == 0 {
+		ownerId = opslog.ProjectId
+	}
+	ev := jsonutils.NewDict()
+	ev.Add(jsonutils.NewInt(opslog.Id), "seq")
+	ev.Add(jsonutils.NewString(changeEventOfAction(opslog.Action)), "event")
+	ev.Add(jsonutils.NewString(opslog.Action), "action")
+	ev.Add(jsonutils.NewString(opslog.ObjType), "obj_type")
+	ev.Add(jsonutils.NewString(opslog.ObjId), "obj_id")
+	ev.Add(jsonutils.NewString(opslog.ObjName), "obj_name")
+	ev.Add(jsonutils.NewString(ownerId), "tenant_id")
+	ev.Add(jsonutils.NewString(opslog.UserId), "user_id")
+	ev.Add(jsonutils.NewString(opslog.User), "user")
+	ev.Add(jsonutils.NewTimeString(opslog.OpsTime), "ops_time")
+	if len(opslog.Notes) > 0 {
+		notes, err := jsonutils.ParseString(opslog.Notes)
+		if err != nil {
+			notes = jsonutils.NewString(opslog.Notes)
+		}
+		ev.Add(notes, "notes")
+	}
+	return ev
+}
+
+// notify makes the poller pick up a freshly inserted log without waiting for
+// the next poll
+func (feed *SChangeFeed) notify() {
+	select {
+	case feed.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// FetchLogsAfter returns the logs after sequence number seq in order
+func (manager *SOpsLogManager) FetchLogsAfter(seq int64, objType string, limit int) ([]SOpsLog, error) {
+	q := manager.Query().GT("id", seq)
+	if len(objType) > 0 {
+		q = q.Equals("obj_type", objType)
+	}
+	q = q.Asc("id").Limit(limit)
+	logs := make([]SOpsLog, 0)
+	err := FetchModelObjects(manager, q, &logs)
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// FetchLastSeq returns the sequence number of the latest log
+func (manager *SOpsLogManager) FetchLastSeq() (int64, error) {
+	opslog := SOpsLog{}
+	err := manager.Query().Desc("id").Limit(1).First(&opslog)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return opslog.Id, err
+}
+
+func (feed *SChangeFeed) subscribe(filter *SChangeFeedFilter) (*sChangeFeedSubscriber, error) {
+	feed.lock.Lock()
+	defer feed.lock.Unlock()
+
+	if !feed.running {
+		lastSeq, err := OpsLog.FetchLastSeq()
+		if err != nil {
+			return nil, err
+		}
+		feed.lastSeq = lastSeq
+		feed.running = true
+		go feed.poll()
+	}
+	sub := &sChangeFeedSubscriber{
+		filter: filter,
+		events: make(chan *SOpsLog, CHANGE_FEED_BUFFER_SIZE),
+	}
+	feed.subscribers[sub] = true
+	return sub, nil
+}
+
+func (feed *SChangeFeed) unsubscribe(sub *sChangeFeedSubscriber) {
+	feed.lock.Lock()
+	defer feed.lock.Unlock()
+
+	if _, ok := feed.subscribers[sub]; ok {
+		delete(feed.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (feed *SChangeFeed) poll() {
+	ticker := time.NewTicker(CHANGE_FEED_POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-feed.wakeup:
+		}
+		if !feed.pollOnce() {
+			return
+		}
+	}
+}
+
+// pollOnce dispatches the new logs, returns false and stops the poller when
+// nobody watches
+func (feed *SChangeFeed) pollOnce() bool {
+	feed.lock.Lock()
+	defer feed.lock.Unlock()
+
+	if len(feed.subscribers) == 0 {
+		feed.running = false
+		return false
+	}
+	for {
+		logs, err := OpsLog.FetchLogsAfter(feed.lastSeq, "", CHANGE_FEED_BATCH_SIZE)
+		if err != nil {
+			log.Errorf("poll change feed fail %s", err)
+			return true
+		}
+		for i := range logs {
+			feed.dispatch(&logs[i])
+			feed.lastSeq = logs[i].Id
+		}
+		if len(logs) < CHANGE_FEED_BATCH_SIZE {
+			return true
+		}
+	}
+}
+
+func (feed *SChangeFeed) dispatch(opslog *SOpsLog) {
+	for sub := range feed.subscribers {
+		if !sub.filter.match(opslog) {
+			continue
+		}
+		select {
+		case sub.events <- opslog:
+		default:
+			// the watcher falls behind, drop it and let it resume from the
+			// last event it has got
+			log.Warningf("change feed watcher of %s falls behind, drop it", sub.filter.ObjType)
+			delete(feed.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Watch streams the events matching filter after sequence number since, or
+// only the new events if since is negative. The returned channel is closed
+// when ctx is done or the watcher falls behind.
+func (feed *SChangeFeed) Watch(ctx context.Context, filter *SChangeFeedFilter, since int64) (<-chan jsonutils.JSONObject, error) {
+	if !consts.OpsLogEnabled() {
+		return nil, httperrors.NewUnsupportOperationError("operation log is disabled")
+	}
+	// subscribe before fetching the backlog so that no event is lost in
+	// between, the events seen twice are skipped by sequence number
+	sub, err := feed.subscribe(filter)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	out := make(chan jsonutils.JSONObject)
+	go func() {
+		defer close(out)
+		defer feed.unsubscribe(sub)
+
+		send := func(opslog *SOpsLog) bool {
+			if opslog.Id <= since {
+				return true
+			}
+			select {
+			case out <- opslog.ChangeEvent():
+				since = opslog.Id
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for since >= 0 {
+			logs, err := OpsLog.FetchLogsAfter(since, filter.ObjType, CHANGE_FEED_BATCH_SIZE)
+			if err != nil {
+				log.Errorf("fetch change feed backlog fail %s", err)
+				return
+			}
+			for i := range logs {
+				if filter.match(&logs[i]) && !send(&logs[i]) {
+					return
+				}
+			}
+			if len(logs) < CHANGE_FEED_BATCH_SIZE {
+				break
+			}
+			// skip the rest of a batch dropped by the filter
+			since = logs[len(logs)-1].Id
+		}
+
+		for {
+			select {
+			case opslog, more := <-sub.events:
+				if !more || !send(opslog) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"testing"
+)
+
+func TestChangeFeedFilter(t *testing.T) {
+	cases := []struct {
+		filter SChangeFeedFilter
+		log    SOpsLog
+		want   bool
+	}{
+		{
+			filter: SChangeFeedFilter{ObjType: "server"},
+			log:    SOpsLog{ObjType: "disk", ObjId: "d1"},
+			want:   false,
+		},
+		{
+			filter: SChangeFeedFilter{ObjType: "server", ObjIds: []string{"s1"}},
+			log:    SOpsLog{ObjType: "server", ObjId: "s2"},
+			want:   false,
+		},
+		{
+			filter: SChangeFeedFilter{ObjType: "server", ProjectId: "p1"},
+			log:    SOpsLog{ObjType: "server", ObjId: "s1", ProjectId: "p1", OwnerProjectId: "p2"},
+			want:   false,
+		},
+		{
+			filter: SChangeFeedFilter{ObjType: "server", ProjectId: "p2"},
+			log:    SOpsLog{ObjType: "server", ObjId: "s1", ProjectId: "p1", OwnerProjectId: "p2"},
+			want:   true,
+		},
+		{
+			filter: SChangeFeedFilter{ObjType: "host", ProjectId: "p1"},
+			log:    SOpsLog{ObjType: "host", ObjId: "h1", ProjectId: "p1"},
+			want:   true,
+		},
+	}
+	for i, c := range cases {
+		if got := c.filter.match(&c.log); got != c.want {
+			t.Errorf("case %d: want %v got %v", i, c.want, got)
+		}
+	}
+}
@@ -342,6 +342,11 @@ func mergeFields(metaFields, queryFields []string, isAdmin bool) stringutils2.SS
 }
 
 func Query2List(manager IModelManager, ctx context.Context, userCred mcclient.TokenCredential, q *sqlchemy.SQuery, query jsonutils.JSONObject) ([]jsonutils.JSONObject, error) {
+	results, _, err := query2List(manager, ctx, userCred, q, query)
+	return results, err
+}
+
+func query2List(manager IModelManager, ctx context.Context, userCred mcclient.TokenCredential, q *sqlchemy.SQuery, query jsonutils.JSONObject) ([]jsonutils.JSONObject, []IModel, error) {
 	metaFields := listFields(manager, userCred)
 	fieldFilter := jsonutils.GetQueryStringArray(query, "field")
 	listF := mergeFields(metaFields, fieldFilter, IsAdminAllowList(userCred, manager))
@@ -357,19 +362,19 @@ func Query2List(manager IModelManager, ctx context.Context, userCred mcclient.To
 	results := make([]jsonutils.JSONObject, 0)
 	rows, err := q.Rows()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		item, err := NewModelObject(manager)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		extraData := jsonutils.NewDict()
 		if query.Contains("export_keys") {
 			RowMap, err := q.Row2Map(rows)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			extraKeys := manager.GetExportExtraKeys(ctx, query, RowMap)
 			if extraKeys != nil {
@@ -377,12 +382,12 @@ func Query2List(manager IModelManager, ctx context.Context, userCred mcclient.To
 			}
 			err = q.RowMap2Struct(RowMap, item)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		} else {
 			err = q.Row2Struct(rows, item)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		}
 
@@ -408,7 +413,7 @@ func Query2List(manager IModelManager, ctx context.Context, userCred mcclient.To
 			}
 		}
 	}
-	return results, nil
+	return results, items, nil
 }
 
 func fetchContextObjectId(manager IModelManager, ctx context.Context, userCred mcclient.TokenCredential, ctxId string, queryDict *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
@@ -466,18 +471,24 @@ func ListItems(manager IModelManager, ctx context.Context, userCred mcclient.Tok
 	if err != nil {
 		return nil, err
 	}
-	totalCnt, err := q.CountWithError()
-	if err != nil {
-		return nil, err
-	}
-	// log.Debugf("total count %d", totalCnt)
-	if totalCnt == 0 {
-		emptyList := modules.ListResult{Data: []jsonutils.JSONObject{}}
-		return &emptyList, nil
-	}
-	if int64(totalCnt) > maxLimit && (limit <= 0 || limit > maxLimit) {
-		limit = maxLimit
+	// counting is expensive on large tables, paging by marker skips it
+	// unless asked for, paging by offset counts unless told not to
+	pagingMarker, _ := queryDict.GetString("paging_marker")
+	useMarker := queryDict.Contains("paging_marker")
+	withTotal := jsonutils.QueryBoolean(queryDict, "with_total", !useMarker)
+	totalCnt := 0
+	if withTotal {
+		totalCnt, err = q.CountWithError()
+		if err != nil {
+			return nil, err
+		}
+		// log.Debugf("total count %d", totalCnt)
+		if totalCnt == 0 {
+			emptyList := modules.ListResult{Data: []jsonutils.JSONObject{}}
+			return &emptyList, nil
+		}
 	}
+	limit = listLimit(limit, maxLimit, totalCnt, withTotal, useMarker)
 	orderBy := jsonutils.GetQueryStringArray(queryDict, "order_by")
 	if len(orderBy) == 0 {
 		colSpec := manager.TableSpec().ColumnSpec("id")
@@ -494,6 +505,21 @@ func ListItems(manager IModelManager, ctx context.Context, userCred mcclient.Tok
 	if orderStr == "asc" {
 		order = sqlchemy.SQL_ORDER_ASC
 	}
+	var pagingKey *sPagingKey
+	if useMarker {
+		pagingKey, err = newPagingKey(manager, orderBy)
+		if err != nil {
+			return nil, err
+		}
+		orderBy = pagingKey.fields
+		if len(pagingMarker) > 0 {
+			q, err = pagingKey.filterAfter(q, pagingMarker, order)
+			if err != nil {
+				return nil, err
+			}
+		}
+		offset = 0
+	}
 	if order == sqlchemy.SQL_ORDER_ASC {
 		for _, orderByField := range orderBy {
 			q = q.Asc(orderByField)
@@ -507,18 +533,30 @@ func ListItems(manager IModelManager, ctx context.Context, userCred mcclient.Tok
 	if err != nil {
 		return nil, err
 	}
+	if useMarker && !customizeFilters.IsEmpty() {
+		return nil, httperrors.NewInputParameterError("paging by marker is not supported with the given filters")
+	}
 	if customizeFilters.IsEmpty() {
-		if limit > 0 {
+		if useMarker {
+			// one more row tells whether there is a next page
+			q = q.Limit(int(limit) + 1)
+		} else if limit > 0 {
 			q = q.Limit(int(limit))
 		}
 		if offset > 0 {
 			q = q.Offset(int(offset))
 		}
 	}
-	retList, err := Query2List(manager, ctx, userCred, q, queryDict)
+	retList, items, err := query2List(manager, ctx, userCred, q, queryDict)
 	if err != nil {
 		return nil, httperrors.NewGeneralError(err)
 	}
+	if useMarker {
+		page, nextMarker := pagingKey.cutPage(retList, items, limit)
+		result := calculateListResult(sparseFields(page, queryDict), int64(totalCnt), limit, 0, false)
+		result.NextMarker = nextMarker
+		return result, nil
+	}
 	retCount := len(retList)
 
 	// apply customizeFilters
@@ -526,15 +564,15 @@ func ListItems(manager IModelManager, ctx context.Context, userCred mcclient.Tok
 	if err != nil {
 		return nil, httperrors.NewGeneralError(err)
 	}
-	if len(retList) != retCount {
-		totalCnt = len(retList)
-	}
 	paginate := false
 	if !customizeFilters.IsEmpty() {
 		// query not use Limit and Offset, do manual pagination
 		paginate = true
 	}
-	return calculateListResult(retList, int64(totalCnt), limit, offset, paginate), nil
+	if len(retList) != retCount || (paginate && !withTotal) {
+		totalCnt = len(retList)
+	}
+	return calculateListResult(sparseFields(retList, queryDict), int64(totalCnt), limit, offset, paginate), nil
 }
 
 func calculateListResult(data []jsonutils.JSONObject, total, limit, offset int64, paginate bool) *modules.ListResult {
@@ -592,6 +630,40 @@ func (dispatcher *DBModelDispatcher) List(ctx context.Context, query jsonutils.J
 	return items, nil
 }
 
+func (dispatcher *DBModelDispatcher) Watch(ctx context.Context, query jsonutils.JSONObject, since int64) (<-chan jsonutils.JSONObject, error) {
+	userCred := fetchUserCredential(ctx)
+	manager := dispatcher.modelManager
+
+	isAdmin := jsonutils.QueryBoolean(query, "admin", false)
+	var isAllow bool
+	if consts.IsRbacEnabled() {
+		isAllow = isListRbacAllowed(manager, userCred, isAdmin)
+	} else {
+		isAllow = manager.AllowListItems(ctx, userCred, query)
+	}
+	if !isAllow {
+		return nil, httperrors.NewForbiddenError("Not allow to watch")
+	}
+
+	filter := &SChangeFeedFilter{
+		ObjType: manager.Keyword(),
+		ObjIds:  jsonutils.GetQueryStringArray(query, "id"),
+	}
+	if !isAdmin {
+		filter.ProjectId = manager.GetOwnerId(userCred)
+	} else {
+		tenant := jsonutils.GetAnyString(query, []string{"project", "project_id", "tenant", "tenant_id"})
+		if len(tenant) > 0 {
+			tobj, _ := TenantCacheManager.FetchTenantByIdOrName(ctx, tenant)
+			if tobj == nil {
+				return nil, httperrors.NewTenantNotFoundError("tenant %s not found", tenant)
+			}
+			filter.ProjectId = tobj.GetId()
+		}
+	}
+	return ChangeFeed.Watch(ctx, filter, since)
+}
+
 func getModelExtraDetails(item IModel, ctx context.Context, extra *jsonutils.JSONDict) *jsonutils.JSONDict {
 	err := item.ValidateDeleteCondition(ctx)
 	if err != nil {
@@ -734,6 +806,7 @@ func (dispatcher *DBModelDispatcher) Get(ctx context.Context, idStr string, quer
 			appParams.SkipLog = true
 		}
 	}
+	setETagHeader(ctx, model)
 	return getModelItemDetails(dispatcher.modelManager, model, ctx, userCred, query, isHead)
 }
 
@@ -936,6 +1009,13 @@ func (dispatcher *DBModelDispatcher) FetchCreateHeaderData(ctx context.Context,
 }
 
 func (dispatcher *DBModelDispatcher) Create(ctx context.Context, query jsonutils.JSONObject, data jsonutils.JSONObject, ctxId string) (jsonutils.JSONObject, error) {
+	op := fmt.Sprintf("create %s %s", dispatcher.Keyword(), ctxId)
+	return withIdempotencyKey(ctx, op, query, data, func() (jsonutils.JSONObject, error) {
+		return dispatcher.create(ctx, query, data, ctxId)
+	})
+}
+
+func (dispatcher *DBModelDispatcher) create(ctx context.Context, query jsonutils.JSONObject, data jsonutils.JSONObject, ctxId string) (jsonutils.JSONObject, error) {
 	userCred := fetchUserCredential(ctx)
 
 	ownerProjId, err := fetchOwnerProjectId(ctx, dispatcher.modelManager, userCred, data)
@@ -1006,6 +1086,21 @@ func expandMultiCreateParams(data jsonutils.JSONObject, count int) ([]jsonutils.
 }
 
 func (dispatcher *DBModelDispatcher) BatchCreate(ctx context.Context, query jsonutils.JSONObject, data jsonutils.JSONObject, count int, ctxId string) ([]modules.SubmitResult, error) {
+	op := fmt.Sprintf("batch-create %s %s %d", dispatcher.Keyword(), ctxId, count)
+	result, err := withIdempotencyKey(ctx, op, query, data, func() (jsonutils.JSONObject, error) {
+		results, err := dispatcher.batchCreate(ctx, query, data, count, ctxId)
+		if err != nil {
+			return nil, err
+		}
+		return modules.SubmitResults2JSON(results), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json2SubmitResults(result)
+}
+
+func (dispatcher *DBModelDispatcher) batchCreate(ctx context.Context, query jsonutils.JSONObject, data jsonutils.JSONObject, count int, ctxId string) ([]modules.SubmitResult, error) {
 	userCred := fetchUserCredential(ctx)
 
 	ownerProjId, err := fetchOwnerProjectId(ctx, dispatcher.modelManager, userCred, data)
@@ -1129,6 +1224,13 @@ func managerPerformCheckCreateData(
 }
 
 func (dispatcher *DBModelDispatcher) PerformClassAction(ctx context.Context, action string, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	op := fmt.Sprintf("perform %s %s", dispatcher.KeywordPlural(), action)
+	return withIdempotencyKey(ctx, op, query, data, func() (jsonutils.JSONObject, error) {
+		return dispatcher.performClassAction(ctx, action, query, data)
+	})
+}
+
+func (dispatcher *DBModelDispatcher) performClassAction(ctx context.Context, action string, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
 	userCred := fetchUserCredential(ctx)
 
 	ownerProjId, err := fetchOwnerProjectId(ctx, dispatcher.modelManager, userCred, data)
@@ -1149,6 +1251,13 @@ func (dispatcher *DBModelDispatcher) PerformClassAction(ctx context.Context, act
 }
 
 func (dispatcher *DBModelDispatcher) PerformAction(ctx context.Context, idStr string, action string, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	op := fmt.Sprintf("perform %s %s %s", dispatcher.Keyword(), idStr, action)
+	return withIdempotencyKey(ctx, op, query, data, func() (jsonutils.JSONObject, error) {
+		return dispatcher.performAction(ctx, idStr, action, query, data)
+	})
+}
+
+func (dispatcher *DBModelDispatcher) performAction(ctx context.Context, idStr string, action string, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
 	userCred := fetchUserCredential(ctx)
 	model, err := fetchItem(dispatcher.modelManager, ctx, userCred, idStr, nil)
 	if err == sql.ErrNoRows {
@@ -1160,13 +1269,21 @@ func (dispatcher *DBModelDispatcher) PerformAction(ctx context.Context, idStr st
 	lockman.LockObject(ctx, model)
 	defer lockman.ReleaseObject(ctx, model)
 
+	model, err = checkIfMatch(ctx, dispatcher.modelManager, model)
+	if err != nil {
+		return nil, err
+	}
+
 	modelValue := reflect.ValueOf(model)
 	result, err := objectPerformAction(dispatcher, model, modelValue, ctx, userCred, action, query, data)
-	if err == nil && result == nil {
+	if err != nil {
+		return nil, err
+	}
+	refreshETagHeader(ctx, dispatcher.modelManager, model)
+	if result == nil {
 		return getItemDetails(dispatcher.modelManager, model, ctx, userCred, query)
-	} else {
-		return result, err
 	}
+	return result, nil
 }
 
 func objectPerformAction(dispatcher *DBModelDispatcher, model IModel, modelValue reflect.Value, ctx context.Context, userCred mcclient.TokenCredential, action string, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
@@ -1333,7 +1450,17 @@ func (dispatcher *DBModelDispatcher) Update(ctx context.Context, idStr string, q
 	lockman.LockObject(ctx, model)
 	defer lockman.ReleaseObject(ctx, model)
 
-	return updateItem(dispatcher.modelManager, model, ctx, userCred, query, data)
+	model, err = checkIfMatch(ctx, dispatcher.modelManager, model)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := updateItem(dispatcher.modelManager, model, ctx, userCred, query, data)
+	if err != nil {
+		return nil, err
+	}
+	refreshETagHeader(ctx, dispatcher.modelManager, model)
+	return result, nil
 }
 
 func DeleteModel(ctx context.Context, userCred mcclient.TokenCredential, item IModel) error {
@@ -0,0 +1,121 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const (
+	HEADER_ETAG     = "ETag"
+	HEADER_IF_MATCH = "If-Match"
+)
+
+// IVersionedModel is implemented by models that keep track of their
+// revision, i.e. everything embedding SResourceBase
+type IVersionedModel interface {
+	GetUpdateVersion() int
+	GetUpdatedAt() time.Time
+}
+
+func formatETag(version int, updatedAt time.Time) string {
+	return fmt.Sprintf("\"%d-%x\"", version, updatedAt.UnixNano())
+}
+
+// ModelETag returns the entity tag of the current revision of model, or an
+// empty string if model does not track revisions
+func ModelETag(model IModel) string {
+	vmodel, ok := model.(IVersionedModel)
+	if !ok {
+		return ""
+	}
+	return formatETag(vmodel.GetUpdateVersion(), vmodel.GetUpdatedAt())
+}
+
+// matchETag checks etag against the values of If-Match headers. Only strong
+// comparison is done, so a weak tag never matches.
+func matchETag(ifMatch []string, etag string) bool {
+	for _, v := range ifMatch {
+		for _, tag := range strings.Split(v, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || (len(etag) > 0 && tag == etag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func requestIfMatch(ctx context.Context) []string {
+	appParams := appsrv.AppContextGetParams(ctx)
+	if appParams == nil || appParams.Request == nil {
+		return nil
+	}
+	return appParams.Request.Header[HEADER_IF_MATCH]
+}
+
+// checkIfMatch honours the If-Match header of the current request. It must
+// be called with model locked, and model is reloaded so that changes
+// committed while waiting for the lock are taken into account. The returned
+// model should be used in place of the original one.
+func checkIfMatch(ctx context.Context, manager IModelManager, model IModel) (IModel, error) {
+	ifMatch := requestIfMatch(ctx)
+	if len(ifMatch) == 0 {
+		return model, nil
+	}
+	current, err := FetchById(manager, model.GetId())
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	etag := ModelETag(current)
+	if !matchETag(ifMatch, etag) {
+		return nil, httperrors.NewPreconditionFailedError("%s %s has been modified, current etag is %s", manager.Keyword(), model.GetId(), etag)
+	}
+	return current, nil
+}
+
+func setETagHeader(ctx context.Context, model IModel) {
+	etag := ModelETag(model)
+	if len(etag) == 0 {
+		return
+	}
+	appParams := appsrv.AppContextGetParams(ctx)
+	if appParams == nil || appParams.Response == nil {
+		return
+	}
+	appParams.Response.Header().Set(HEADER_ETAG, etag)
+}
+
+// refreshETagHeader reloads model after it has been modified so that the
+// ETag header reflects the new revision
+func refreshETagHeader(ctx context.Context, manager IModelManager, model IModel) {
+	if _, ok := model.(IVersionedModel); !ok {
+		return
+	}
+	current, err := FetchById(manager, model.GetId())
+	if err != nil {
+		log.Debugf("reload %s %s for etag: %s", manager.Keyword(), model.GetId(), err)
+		return
+	}
+	setETagHeader(ctx, current)
+}
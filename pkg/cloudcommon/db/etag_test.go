@@ -0,0 +1,59 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestModelETag(t *testing.T) {
+	item := &sOpenAPITestModel{}
+	item.UpdateVersion = 3
+	item.UpdatedAt = time.Date(2019, 7, 1, 8, 0, 0, 0, time.UTC)
+	etag := ModelETag(item)
+	if etag != formatETag(3, item.UpdatedAt) {
+		t.Fatalf("etag %s", etag)
+	}
+
+	item.UpdateVersion = 4
+	if ModelETag(item) == etag {
+		t.Errorf("etag should change with update version")
+	}
+
+	cases := []struct {
+		name    string
+		ifMatch []string
+		want    bool
+	}{
+		{"exact", []string{etag}, true},
+		{"any", []string{"*"}, true},
+		{"list", []string{`"1-0", ` + etag}, true},
+		{"multiple headers", []string{`"1-0"`, etag}, true},
+		{"mismatch", []string{`"1-0"`}, false},
+		{"weak", []string{"W/" + etag}, false},
+		{"unquoted", []string{etag[1 : len(etag)-1]}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := matchETag(c.ifMatch, etag); got != c.want {
+				t.Errorf("matchETag(%q, %s) = %v, want %v", c.ifMatch, etag, got, c.want)
+			}
+		})
+	}
+	if matchETag([]string{`""`}, "") {
+		t.Errorf("unversioned model should only match *")
+	}
+}
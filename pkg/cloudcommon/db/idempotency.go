@@ -0,0 +1,232 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+)
+
+const (
+	HEADER_IDEMPOTENCY_KEY     = "Idempotency-Key"
+	HEADER_IDEMPOTENT_REPLAYED = "Idempotent-Replayed"
+
+	IDEMPOTENCY_KEY_MAX_LENGTH = 255
+)
+
+type SIdempotencyKeyManager struct {
+	SModelBaseManager
+}
+
+// SIdempotencyKey remembers the response of a successful request carrying an
+// Idempotency-Key header, so that a retry of the same request gets the same
+// response instead of being executed again
+type SIdempotencyKey struct {
+	SModelBase
+
+	Id          string    `width:"64" charset:"ascii" primary:"true"` // sha256 of user id and key
+	UserId      string    `width:"128" charset:"ascii" nullable:"false"`
+	RequestHash string    `width:"64" charset:"ascii" nullable:"false"`
+	Response    string    `length:"medium" charset:"utf8" nullable:"true"`
+	CreatedAt   time.Time `nullable:"false" created_at:"true"`
+	ExpiredAt   time.Time `nullable:"false" index:"true"`
+}
+
+var IdempotencyKeyManager *SIdempotencyKeyManager
+
+func init() {
+	IdempotencyKeyManager = &SIdempotencyKeyManager{SModelBaseManager: NewModelBaseManager(SIdempotencyKey{}, "idempotency_keys_tbl", "idempotency_key", "idempotency_keys")}
+}
+
+func (key *SIdempotencyKey) GetId() string {
+	return key.Id
+}
+
+func (key *SIdempotencyKey) GetName() string {
+	return key.Id
+}
+
+func (key *SIdempotencyKey) GetModelManager() IModelManager {
+	return IdempotencyKeyManager
+}
+
+// isEnabled tells whether idempotency keys are honoured, which requires
+// the service to register the manager so that its table exists
+func (manager *SIdempotencyKeyManager) isEnabled() bool {
+	return consts.GetIdempotencyKeyExpire() > 0 && GetModelManager(manager.Keyword()) != nil
+}
+
+func idempotencyKeyId(userCred mcclient.TokenCredential, key string) string {
+	userId := ""
+	if userCred != nil {
+		userId = userCred.GetUserId()
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(userId+"\n"+key)))
+}
+
+func idempotencyRequestHash(op string, query jsonutils.JSONObject, data jsonutils.JSONObject) string {
+	h := sha256.New()
+	h.Write([]byte(op))
+	for _, obj := range []jsonutils.JSONObject{query, data} {
+		h.Write([]byte("\n"))
+		if obj != nil {
+			h.Write([]byte(obj.String()))
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// fetchKey returns the unexpired record of id, or nil if there is none. An
+// expired record is removed so that the key can be stored again.
+func (manager *SIdempotencyKeyManager) fetchKey(id string) (*SIdempotencyKey, error) {
+	q := manager.Query().Equals("id", id)
+	count, err := q.CountWithError()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	record := &SIdempotencyKey{}
+	record.SetModelManager(manager)
+	err = q.First(record)
+	if err != nil {
+		return nil, err
+	}
+	if record.ExpiredAt.After(time.Now().UTC()) {
+		return record, nil
+	}
+	sql := fmt.Sprintf("DELETE FROM `%s` WHERE `id` = ?", manager.TableSpec().Name())
+	if _, err := sqlchemy.GetDB().Exec(sql, id); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (manager *SIdempotencyKeyManager) saveKey(id string, userCred mcclient.TokenCredential, requestHash string, result jsonutils.JSONObject) error {
+	record := &SIdempotencyKey{
+		Id:          id,
+		RequestHash: requestHash,
+		ExpiredAt:   time.Now().UTC().Add(consts.GetIdempotencyKeyExpire()),
+	}
+	record.SetModelManager(manager)
+	if userCred != nil {
+		record.UserId = userCred.GetUserId()
+	}
+	if result != nil {
+		record.Response = result.String()
+	}
+	return manager.TableSpec().Insert(record)
+}
+
+// PurgeExpired removes records whose replay window has passed
+func (manager *SIdempotencyKeyManager) PurgeExpired(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	if !manager.isEnabled() {
+		return
+	}
+	sql := fmt.Sprintf("DELETE FROM `%s` WHERE `expired_at` < ?", manager.TableSpec().Name())
+	if _, err := sqlchemy.GetDB().Exec(sql, time.Now().UTC()); err != nil {
+		log.Errorf("purge expired idempotency keys: %s", err)
+	}
+}
+
+func requestIdempotencyKey(ctx context.Context) string {
+	appParams := appsrv.AppContextGetParams(ctx)
+	if appParams == nil || appParams.Request == nil {
+		return ""
+	}
+	return appParams.Request.Header.Get(HEADER_IDEMPOTENCY_KEY)
+}
+
+// withIdempotencyKey runs do unless the request carries an Idempotency-Key
+// that has already been used, in which case the stored response is
+// replayed. Reusing a key for a different request is rejected. Only
+// successful responses are stored, so a failed request can be retried with
+// the same key.
+func withIdempotencyKey(ctx context.Context, op string, query jsonutils.JSONObject, data jsonutils.JSONObject, do func() (jsonutils.JSONObject, error)) (jsonutils.JSONObject, error) {
+	key := requestIdempotencyKey(ctx)
+	if len(key) == 0 || !IdempotencyKeyManager.isEnabled() {
+		return do()
+	}
+	if len(key) > IDEMPOTENCY_KEY_MAX_LENGTH {
+		return nil, httperrors.NewInputParameterError("%s longer than %d characters", HEADER_IDEMPOTENCY_KEY, IDEMPOTENCY_KEY_MAX_LENGTH)
+	}
+
+	userCred := fetchUserCredential(ctx)
+	id := idempotencyKeyId(userCred, key)
+	// hash before do() gets a chance to modify data
+	requestHash := idempotencyRequestHash(op, query, data)
+
+	lockman.LockRawObject(ctx, IdempotencyKeyManager.Keyword(), id)
+	defer lockman.ReleaseRawObject(ctx, IdempotencyKeyManager.Keyword(), id)
+
+	record, err := IdempotencyKeyManager.fetchKey(id)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	if record != nil {
+		if record.RequestHash != requestHash {
+			return nil, httperrors.NewConflictError("%s %s has been used for a different request", HEADER_IDEMPOTENCY_KEY, key)
+		}
+		if appParams := appsrv.AppContextGetParams(ctx); appParams.Response != nil {
+			appParams.Response.Header().Set(HEADER_IDEMPOTENT_REPLAYED, "true")
+		}
+		if len(record.Response) == 0 {
+			return nil, nil
+		}
+		result, err := jsonutils.ParseString(record.Response)
+		if err != nil {
+			return nil, httperrors.NewInternalServerError("invalid stored response for %s %s: %s", HEADER_IDEMPOTENCY_KEY, key, err)
+		}
+		return result, nil
+	}
+
+	result, err := do()
+	if err != nil {
+		return nil, err
+	}
+	err = IdempotencyKeyManager.saveKey(id, userCred, requestHash, result)
+	if err != nil {
+		log.Errorf("save %s %s: %s", HEADER_IDEMPOTENCY_KEY, key, err)
+	}
+	return result, nil
+}
+
+func json2SubmitResults(obj jsonutils.JSONObject) ([]modules.SubmitResult, error) {
+	objs, err := obj.GetArray("data")
+	if err != nil {
+		return nil, err
+	}
+	results := make([]modules.SubmitResult, len(objs))
+	for i := range objs {
+		status, _ := objs[i].Int("status")
+		results[i].Status = int(status)
+		results[i].Data, _ = objs[i].Get("data")
+	}
+	return results, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"testing"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+)
+
+func TestIdempotencyRequestHash(t *testing.T) {
+	data := jsonutils.NewDict()
+	data.Set("name", jsonutils.NewString("vm1"))
+	data.Set("vcpu_count", jsonutils.NewInt(2))
+	same := jsonutils.NewDict()
+	same.Set("vcpu_count", jsonutils.NewInt(2))
+	same.Set("name", jsonutils.NewString("vm1"))
+
+	h := idempotencyRequestHash("create server ", nil, data)
+	if h != idempotencyRequestHash("create server ", nil, same) {
+		t.Errorf("hash should not depend on key order")
+	}
+	if h == idempotencyRequestHash("create disk ", nil, data) {
+		t.Errorf("hash should depend on the operation")
+	}
+	same.Set("vcpu_count", jsonutils.NewInt(4))
+	if h == idempotencyRequestHash("create server ", nil, same) {
+		t.Errorf("hash should depend on the body")
+	}
+
+	if idempotencyKeyId(nil, "k1") == idempotencyKeyId(nil, "k2") {
+		t.Errorf("different keys should have different ids")
+	}
+}
+
+func TestJson2SubmitResults(t *testing.T) {
+	results := []modules.SubmitResult{
+		{Status: 200, Data: jsonutils.Marshal(map[string]string{"id": "a"})},
+		{Status: 400, Data: jsonutils.NewString("bad")},
+	}
+	obj, err := jsonutils.ParseString(modules.SubmitResults2JSON(results).String())
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	got, err := json2SubmitResults(obj)
+	if err != nil {
+		t.Fatalf("json2SubmitResults: %s", err)
+	}
+	if len(got) != 2 || got[0].Status != 200 || got[1].Status != 400 {
+		t.Fatalf("got %#v", got)
+	}
+	if id, _ := got[0].Data.GetString("id"); id != "a" {
+		t.Errorf("data %s", got[0].Data)
+	}
+}
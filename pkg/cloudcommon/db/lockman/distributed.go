@@ -0,0 +1,98 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockman
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"yunion.io/x/log"
+)
+
+// iDistributedLocker holds a key across service replicas. A key is locked
+// at most once at a time by a process, reentrance and waiters within the
+// process are handled by SDistributedLockManager.
+type iDistributedLocker interface {
+	lock(ctx context.Context, key string) error
+	unlock(ctx context.Context, key string) error
+}
+
+// SDistributedLockManager serializes contexts of this process with an in
+// memory lock manager, the key is then held in the shared backend by the
+// outermost lock of the holding context.
+type SDistributedLockManager struct {
+	local  ILockManager
+	locker iDistributedLocker
+
+	depthLock *sync.Mutex
+	depth     map[string]int
+}
+
+func newDistributedLockManager(locker iDistributedLocker) *SDistributedLockManager {
+	return &SDistributedLockManager{
+		local:     NewInMemoryLockManager(),
+		locker:    locker,
+		depthLock: &sync.Mutex{},
+		depth:     make(map[string]int),
+	}
+}
+
+func (lockman *SDistributedLockManager) incDepth(key string) int {
+	lockman.depthLock.Lock()
+	defer lockman.depthLock.Unlock()
+
+	lockman.depth[key] += 1
+	return lockman.depth[key]
+}
+
+func (lockman *SDistributedLockManager) decDepth(key string) int {
+	lockman.depthLock.Lock()
+	defer lockman.depthLock.Unlock()
+
+	depth := lockman.depth[key] - 1
+	if depth <= 0 {
+		delete(lockman.depth, key)
+	} else {
+		lockman.depth[key] = depth
+	}
+	return depth
+}
+
+func (lockman *SDistributedLockManager) LockKey(ctx context.Context, key string) {
+	lockman.local.LockKey(ctx, key)
+	if lockman.incDepth(key) > 1 {
+		return
+	}
+	// the lock may outlive the request context, and a lock cannot fail
+	for {
+		err := lockman.locker.lock(context.Background(), key)
+		if err == nil {
+			return
+		}
+		log.Errorf("lock %s fail %s, retry later", key, err)
+		time.Sleep(time.Second)
+	}
+}
+
+func (lockman *SDistributedLockManager) UnlockKey(ctx context.Context, key string) {
+	if lockman.decDepth(key) <= 0 {
+		err := lockman.locker.unlock(context.Background(), key)
+		if err != nil {
+			log.Errorf("unlock %s fail %s", key, err)
+		}
+	}
+	lockman.local.UnlockKey(ctx, key)
+}
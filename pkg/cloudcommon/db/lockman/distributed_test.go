@@ -0,0 +1,74 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockman
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type fakeLocker struct {
+	mutex   sync.Mutex
+	held    map[string]bool
+	locks   int
+	unlocks int
+}
+
+func (locker *fakeLocker) lock(ctx context.Context, key string) error {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+	if locker.held[key] {
+		panic("key locked twice by the process")
+	}
+	locker.held[key] = true
+	locker.locks += 1
+	return nil
+}
+
+func (locker *fakeLocker) unlock(ctx context.Context, key string) error {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+	delete(locker.held, key)
+	locker.unlocks += 1
+	return nil
+}
+
+func TestDistributedLockManager(t *testing.T) {
+	locker := &fakeLocker{held: make(map[string]bool)}
+	Init(newDistributedLockManager(locker))
+
+	var wg sync.WaitGroup
+	for id := 0; id < 4; id += 1 {
+		wg.Add(1)
+		go func(localId int) {
+			defer wg.Done()
+			ctx := context.WithValue(context.Background(), "ID", localId)
+			obj := &FakeObject{Id: "distributed"}
+			LockObject(ctx, obj)
+			LockObject(ctx, obj)
+			ReleaseObject(ctx, obj)
+			ReleaseObject(ctx, obj)
+		}(id)
+	}
+	wg.Wait()
+
+	if locker.locks != 4 || locker.unlocks != 4 {
+		t.Errorf("expect 4 locks and unlocks, got %d and %d", locker.locks, locker.unlocks)
+	}
+	if len(locker.held) != 0 {
+		t.Errorf("keys still held: %v", locker.held)
+	}
+}
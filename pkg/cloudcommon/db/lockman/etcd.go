@@ -0,0 +1,54 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockman
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"yunion.io/x/pkg/util/stringutils"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/etcd"
+)
+
+const (
+	ETCD_LOCK_PREFIX = "/lockman/"
+)
+
+type sEtcdLocker struct {
+	client *etcd.SEtcdClient
+	holder string
+}
+
+func (locker *sEtcdLocker) lock(ctx context.Context, key string) error {
+	return locker.client.AcquireSessionKey(ctx, ETCD_LOCK_PREFIX+key, locker.holder)
+}
+
+func (locker *sEtcdLocker) unlock(ctx context.Context, key string) error {
+	return locker.client.ReleaseSessionKey(ctx, ETCD_LOCK_PREFIX+key, locker.holder)
+}
+
+// NewEtcdLockManager returns a lock manager shared by the replicas using
+// the same etcd namespace. Locks are attached to the lease of the client
+// session and are released by etcd if the replica dies.
+func NewEtcdLockManager(client *etcd.SEtcdClient) ILockManager {
+	hostname, _ := os.Hostname()
+	locker := &sEtcdLocker{
+		client: client,
+		holder: fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), stringutils.UUID4()),
+	}
+	return newDistributedLockManager(locker)
+}
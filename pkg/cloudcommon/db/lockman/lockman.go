@@ -33,6 +33,12 @@ type ILockManager interface {
 	UnlockKey(ctx context.Context, key string)
 }
 
+// ILeaderLockManager is implemented by lock managers that have to watch a
+// lock held for good, see ElectLeader
+type ILeaderLockManager interface {
+	ElectLeader(ctx context.Context, key string)
+}
+
 func getClassKey(manager ILockedClass, projectId string) string {
 	// assert(getattr(cls, '_resource_name_', None) is not None)
 	// return '%s-%s' % (cls._resource_name_, user_cred.tenant_id)
@@ -102,3 +108,15 @@ func ReleaseJointObject(ctx context.Context, model ILockedObject, model2 ILocked
 	key := getJointObjectKey(model, model2)
 	_lockman.UnlockKey(ctx, key)
 }
+
+// ElectLeader blocks until the process becomes the leader of name among the
+// replicas sharing the lock manager, the leadership is kept until the
+// process exits.
+func ElectLeader(ctx context.Context, name string) {
+	key := getRawObjectKey("leader", name)
+	if man, ok := _lockman.(ILeaderLockManager); ok {
+		man.ElectLeader(ctx, key)
+	} else {
+		_lockman.LockKey(ctx, key)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockman
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"yunion.io/x/log"
+)
+
+const (
+	// MySQL limits the name of a user lock to 64 characters
+	MYSQL_LOCK_NAME_MAX = 64
+
+	MYSQL_LOCK_WAIT_SECONDS = 10
+
+	MYSQL_LEADER_CHECK_INTERVAL = 10 * time.Second
+)
+
+// sMysqlLocker holds keys with GET_LOCK. A user lock belongs to the session
+// that gets it, so each held key keeps a connection of its own out of a
+// pool dedicated to locks.
+type sMysqlLocker struct {
+	db *sql.DB
+
+	connLock *sync.Mutex
+	conns    map[string]*sql.Conn
+}
+
+func mysqlLockName(key string) string {
+	if len(key) <= MYSQL_LOCK_NAME_MAX {
+		return key
+	}
+	return fmt.Sprintf("lockman-%x", md5.Sum([]byte(key)))
+}
+
+func (locker *sMysqlLocker) lock(ctx context.Context, key string) error {
+	conn, err := locker.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	name := mysqlLockName(key)
+	for {
+		var ret sql.NullInt64
+		err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, MYSQL_LOCK_WAIT_SECONDS).Scan(&ret)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		if ret.Valid && ret.Int64 == 1 {
+			break
+		}
+		log.Debugf("wait for mysql lock %s", name)
+	}
+
+	locker.connLock.Lock()
+	defer locker.connLock.Unlock()
+	locker.conns[key] = conn
+	return nil
+}
+
+func (locker *sMysqlLocker) popConn(key string) *sql.Conn {
+	locker.connLock.Lock()
+	defer locker.connLock.Unlock()
+
+	conn := locker.conns[key]
+	delete(locker.conns, key)
+	return conn
+}
+
+func (locker *sMysqlLocker) unlock(ctx context.Context, key string) error {
+	conn := locker.popConn(key)
+	if conn == nil {
+		return fmt.Errorf("mysql lock %s not held", key)
+	}
+	defer conn.Close()
+
+	var ret sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", mysqlLockName(key)).Scan(&ret)
+	if err != nil {
+		return err
+	}
+	if !ret.Valid || ret.Int64 != 1 {
+		return fmt.Errorf("mysql lock %s was lost before release", key)
+	}
+	return nil
+}
+
+// check tells whether the session of key still holds its lock
+func (locker *sMysqlLocker) check(ctx context.Context, key string) error {
+	locker.connLock.Lock()
+	conn := locker.conns[key]
+	locker.connLock.Unlock()
+	if conn == nil {
+		return fmt.Errorf("mysql lock %s not held", key)
+	}
+
+	var ret sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", mysqlLockName(key)).Scan(&ret)
+	if err != nil {
+		return err
+	}
+	if !ret.Valid || ret.Int64 != 1 {
+		return fmt.Errorf("mysql lock %s was lost", key)
+	}
+	return nil
+}
+
+type SMysqlLockManager struct {
+	*SDistributedLockManager
+
+	locker *sMysqlLocker
+}
+
+// NewMysqlLockManager returns a lock manager shared by the replicas using
+// the same database, the locks are held with GET_LOCK on connections of
+// dbConn, which should not be the pool serving queries.
+func NewMysqlLockManager(dbConn *sql.DB) ILockManager {
+	locker := &sMysqlLocker{
+		db:       dbConn,
+		connLock: &sync.Mutex{},
+		conns:    make(map[string]*sql.Conn),
+	}
+	return &SMysqlLockManager{
+		SDistributedLockManager: newDistributedLockManager(locker),
+		locker:                  locker,
+	}
+}
+
+// ElectLeader takes key for good. Unlike an etcd lease, a dropped session
+// does not stop the process, so the lock is watched and the process exits
+// once it is lost, leaving the leadership to another replica.
+func (lockman *SMysqlLockManager) ElectLeader(ctx context.Context, key string) {
+	lockman.LockKey(ctx, key)
+	go func() {
+		for {
+			time.Sleep(MYSQL_LEADER_CHECK_INTERVAL)
+			err := lockman.locker.check(context.Background(), key)
+			if err != nil {
+				log.Fatalf("lose leadership %s: %s", key, err)
+			}
+		}
+	}()
+}
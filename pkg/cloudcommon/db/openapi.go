@@ -0,0 +1,219 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+// OpenAPISpec describes the resource from the column tags of the model,
+// the same tags that decide the fields accepted by create and update
+func (dispatcher *DBModelDispatcher) OpenAPISpec() *dispatcher.SOpenAPIResourceSpec {
+	return modelOpenAPISpec(dispatcher.modelManager)
+}
+
+func modelOpenAPISpec(manager IModelManager) *dispatcher.SOpenAPIResourceSpec {
+	resource := newOpenAPISchema()
+	create := newOpenAPISchema()
+	update := newOpenAPISchema()
+	filters := make([]string, 0)
+	for _, col := range manager.TableSpec().Columns() {
+		tags := col.Tags()
+		list, get, search := tags["list"], tags["get"], tags["search"]
+		createTag, updateTag := tags["create"], tags["update"]
+		if len(list) > 0 || len(get) > 0 {
+			resource.addProperty(col, list == "admin" || (len(list) == 0 && get == "admin"), false)
+		}
+		if len(list) > 0 || len(search) > 0 {
+			filters = append(filters, col.Name())
+		}
+		if len(createTag) > 0 || len(updateTag) > 0 {
+			adminOnly := strings.HasPrefix(createTag, "admin_") || (len(createTag) == 0 && updateTag == "admin")
+			create.addProperty(col, adminOnly, createTag == "required" || createTag == "admin_required")
+		}
+		if len(updateTag) > 0 {
+			update.addProperty(col, updateTag == "admin", false)
+		}
+	}
+	// resources carry extra details computed by the model, and inputs
+	// are validated by the model beyond the columns
+	return &dispatcher.SOpenAPIResourceSpec{
+		Resource:     resource.jsonObject(true),
+		Create:       create.jsonObject(true),
+		Update:       update.jsonObject(false),
+		ListFilters:  filters,
+		Actions:      modelMethodNames(reflect.PtrTo(manager.TableSpec().DataType()), "Perform", 4),
+		ClassActions: modelMethodNames(reflect.TypeOf(manager), "Perform", 4),
+		Specs:        modelMethodNames(reflect.PtrTo(manager.TableSpec().DataType()), "GetDetails", 3),
+	}
+}
+
+type sOpenAPISchema struct {
+	properties *jsonutils.JSONDict
+	required   []string
+}
+
+func newOpenAPISchema() *sOpenAPISchema {
+	return &sOpenAPISchema{properties: jsonutils.NewDict(), required: make([]string, 0)}
+}
+
+func (schema *sOpenAPISchema) addProperty(col sqlchemy.IColumnSpec, adminOnly bool, required bool) {
+	prop := columnOpenAPISchema(col)
+	if adminOnly {
+		prop.Add(jsonutils.JSONTrue, "x-admin-only")
+	}
+	schema.properties.Add(prop, col.Name())
+	if required {
+		schema.required = append(schema.required, col.Name())
+	}
+}
+
+func (schema *sOpenAPISchema) jsonObject(additional bool) jsonutils.JSONObject {
+	ret := jsonutils.NewDict()
+	ret.Add(jsonutils.NewString("object"), "type")
+	ret.Add(schema.properties, "properties")
+	if len(schema.required) > 0 {
+		ret.Add(jsonutils.NewStringArray(schema.required), "required")
+	}
+	ret.Add(jsonutils.NewBool(additional), "additionalProperties")
+	return ret
+}
+
+var columnWidthRegexp = regexp.MustCompile(`^\w+\((\d+)\)`)
+
+func columnOpenAPISchema(col sqlchemy.IColumnSpec) *jsonutils.JSONDict {
+	prop := jsonutils.NewDict()
+	switch col.(type) {
+	case *sqlchemy.SBooleanColumn, *sqlchemy.STristateColumn:
+		prop.Add(jsonutils.NewString("boolean"), "type")
+	case *sqlchemy.SIntegerColumn:
+		prop.Add(jsonutils.NewString("integer"), "type")
+		if strings.HasPrefix(col.ColType(), "BIGINT") {
+			prop.Add(jsonutils.NewString("int64"), "format")
+		} else {
+			prop.Add(jsonutils.NewString("int32"), "format")
+		}
+	case *sqlchemy.SFloatColumn, *sqlchemy.SDecimalColumn:
+		prop.Add(jsonutils.NewString("number"), "type")
+	case *sqlchemy.SDateTimeColumn:
+		prop.Add(jsonutils.NewString("string"), "type")
+		prop.Add(jsonutils.NewString("date-time"), "format")
+	case *sqlchemy.CompoundColumn:
+		prop.Add(jsonutils.NewString("object"), "type")
+	default:
+		prop.Add(jsonutils.NewString("string"), "type")
+		if m := columnWidthRegexp.FindStringSubmatch(col.ColType()); len(m) > 1 {
+			width, _ := strconv.Atoi(m[1])
+			if width > 0 {
+				prop.Add(jsonutils.NewInt(int64(width)), "maxLength")
+			}
+		}
+	}
+	if col.IsNullable() && !col.IsPrimary() {
+		prop.Add(jsonutils.JSONTrue, "nullable")
+	}
+	if def := col.Default(); len(def) > 0 && col.IsSupportDefault() {
+		schemaType, _ := prop.GetString("type")
+		switch schemaType {
+		case "boolean":
+			prop.Add(jsonutils.NewBool(utils.ToBool(def)), "default")
+		case "integer":
+			if v, err := strconv.ParseInt(def, 10, 64); err == nil {
+				prop.Add(jsonutils.NewInt(v), "default")
+			}
+		case "number":
+			if v, err := strconv.ParseFloat(def, 64); err == nil {
+				prop.Add(jsonutils.NewFloat(v), "default")
+			}
+		default:
+			prop.Add(jsonutils.NewString(def), "default")
+		}
+	}
+	return prop
+}
+
+var (
+	contextType   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	userCredType  = reflect.TypeOf((*mcclient.TokenCredential)(nil)).Elem()
+	jsonType      = reflect.TypeOf((*jsonutils.JSONObject)(nil)).Elem()
+	generalAction = "PerformAction"
+)
+
+// modelMethodNames returns the kebab names of the methods dispatched by
+// name, that is the methods named prefix+Name taking the context, the user
+// credential and numIn-2 json objects, returning a json object and an error
+func modelMethodNames(t reflect.Type, prefix string, numIn int) []string {
+	names := make([]string, 0)
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		if !strings.HasPrefix(method.Name, prefix) || len(method.Name) == len(prefix) || method.Name == generalAction {
+			continue
+		}
+		// the receiver is the first argument
+		mt := method.Type
+		if mt.NumIn() != numIn+1 || mt.NumOut() != 2 {
+			continue
+		}
+		if mt.In(1) != contextType || mt.In(2) != userCredType || mt.Out(0) != jsonType {
+			continue
+		}
+		validArgs := true
+		for j := 3; j < mt.NumIn(); j++ {
+			if mt.In(j) != jsonType {
+				validArgs = false
+			}
+		}
+		if !validArgs {
+			continue
+		}
+		camel := method.Name[len(prefix):]
+		kebab := camel2Kebab(camel)
+		// names not spelled the way the dispatcher looks them up are not
+		// reachable
+		if utils.Kebab2Camel(kebab, "-") != camel {
+			continue
+		}
+		names = append(names, kebab)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func camel2Kebab(camel string) string {
+	var buf strings.Builder
+	for i, c := range camel {
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 {
+				buf.WriteByte('-')
+			}
+			buf.WriteRune(c - 'A' + 'a')
+		} else {
+			buf.WriteRune(c)
+		}
+	}
+	return buf.String()
+}
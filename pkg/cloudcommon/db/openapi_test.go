@@ -0,0 +1,94 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type sOpenAPITestModel struct {
+	SStandaloneResourceBase
+
+	Size    int    `nullable:"false" list:"user" create:"required"`
+	Comment string `width:"64" charset:"utf8" list:"user" create:"optional" update:"user"`
+	Secret  string `width:"64" charset:"ascii" create:"admin_optional"`
+}
+
+func (model *sOpenAPITestModel) PerformStartVm(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	return nil, nil
+}
+
+func (model *sOpenAPITestModel) PerformIPMI(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	return nil, nil
+}
+
+func (model *sOpenAPITestModel) PerformHelper(ctx context.Context) error {
+	return nil
+}
+
+func (model *sOpenAPITestModel) GetDetailsVnc(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	return nil, nil
+}
+
+func TestModelOpenAPISpec(t *testing.T) {
+	manager := NewStandaloneResourceBaseManager(sOpenAPITestModel{}, "openapi_tests_tbl", "openapi_test", "openapi_tests")
+	spec := modelOpenAPISpec(&manager)
+
+	for _, want := range []string{"start-vm", "i-p-m-i", "metadata"} {
+		if !utils.IsInStringArray(want, spec.Actions) {
+			t.Errorf("action %s not in %v", want, spec.Actions)
+		}
+	}
+	if utils.IsInStringArray("helper", spec.Actions) {
+		t.Errorf("helper is not an action")
+	}
+	if !utils.IsInStringArray("vnc", spec.Specs) {
+		t.Errorf("spec vnc not in %v", spec.Specs)
+	}
+
+	cases := []struct {
+		schema jsonutils.JSONObject
+		path   []string
+		want   string
+	}{
+		{spec.Resource, []string{"properties", "size", "type"}, "integer"},
+		{spec.Resource, []string{"properties", "comment", "maxLength"}, "64"},
+		{spec.Create, []string{"required"}, `["name","size"]`},
+		{spec.Create, []string{"properties", "secret", "x-admin-only"}, "true"},
+		{spec.Update, []string{"properties", "comment", "type"}, "string"},
+	}
+	for _, c := range cases {
+		v, err := c.schema.Get(c.path...)
+		if err != nil {
+			t.Errorf("%v not found in %s", c.path, c.schema)
+			continue
+		}
+		if v.String() != c.want && v.String() != `"`+c.want+`"` {
+			t.Errorf("%v = %s, want %s", c.path, v, c.want)
+		}
+	}
+	if spec.Resource.Contains("properties", "secret") {
+		t.Errorf("secret should not be in the resource schema")
+	}
+	if spec.Update.Contains("properties", "size") {
+		t.Errorf("size should not be in the update schema")
+	}
+}
@@ -71,6 +71,10 @@ const (
 	ACT_MIGRATE      = "migrate"
 	ACT_MIGRATE_FAIL = "migrate_fail"
 
+	ACT_MIGRATE_STORAGE      = "migrate_storage"
+	ACT_MIGRATE_STORAGE_FAIL = "migrate_storage_fail"
+	ACT_DECOMMISSION         = "decommission"
+
 	ACT_SPLIT = "net_split"
 	ACT_MERGE = "net_merge"
 
@@ -195,6 +199,18 @@ const (
 	ACT_HOST_IMPORT_LIBVIRT_SERVERS_FAIL = "host_import_libvirt_servers_fail"
 	ACT_GUEST_CREATE_FROM_IMPORT_SUCC    = "guest_create_from_import_succ"
 	ACT_GUEST_CREATE_FROM_IMPORT_FAIL    = "guest_create_from_import_fail"
+
+	ACT_DISK_WIPE_START    = "disk_wipe_start"
+	ACT_DISK_WIPE_COMPLETE = "disk_wipe_end"
+	ACT_DISK_WIPE_FAIL     = "disk_wipe_fail"
+
+	ACT_HARDWARE_ALERT = "hardware_alert"
+
+	ACT_GUEST_CONVERT_TO_KVM      = "guest_convert_to_kvm"
+	ACT_GUEST_CONVERT_TO_KVM_FAIL = "guest_convert_to_kvm_fail"
+
+	ACT_GUEST_PREEMPT      = "guest_preempt"
+	ACT_GUEST_PREEMPT_FAIL = "guest_preempt_fail"
 )
 
 type SOpsLogManager struct {
@@ -291,7 +307,9 @@ func (manager *SOpsLogManager) LogEvent(model IModel, action string, notes inter
 	err := manager.TableSpec().Insert(&opslog)
 	if err != nil {
 		log.Errorf("fail to insert opslog: %s", err)
+		return
 	}
+	ChangeFeed.notify()
 }
 
 func combineNotes(ctx context.Context, m2 IModel, notes jsonutils.JSONObject) *jsonutils.JSONDict {
@@ -0,0 +1,186 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/util/timeutils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+/*
+ * Paging by marker: a list with the query key paging_marker is paged on the
+ * sort key (order_by field, primary key) rather than by offset. An empty
+ * marker starts from the first page, the result carries next_marker when
+ * there are more rows. The marker is the base64 encoded json array of the
+ * sort key of the last row returned, clients should treat it as opaque.
+ */
+
+type sPagingKey struct {
+	fields  []string
+	colSpec []sqlchemy.IColumnSpec
+}
+
+func primaryColumn(manager IModelManager) sqlchemy.IColumnSpec {
+	var primary sqlchemy.IColumnSpec
+	for _, col := range manager.TableSpec().Columns() {
+		if col.IsPrimary() {
+			if primary != nil {
+				return nil
+			}
+			primary = col
+		}
+	}
+	return primary
+}
+
+// newPagingKey returns the sort key for paging by marker, the primary key
+// makes the key unique so that the order is stable
+func newPagingKey(manager IModelManager, orderBy []string) (*sPagingKey, error) {
+	primary := primaryColumn(manager)
+	if primary == nil {
+		return nil, httperrors.NewUnsupportOperationError("%s does not support paging by marker", manager.KeywordPlural())
+	}
+	if len(orderBy) > 1 {
+		return nil, httperrors.NewInputParameterError("paging by marker supports order by a single field")
+	}
+	key := &sPagingKey{}
+	if len(orderBy) == 1 && orderBy[0] != primary.Name() {
+		colSpec := manager.TableSpec().ColumnSpec(orderBy[0])
+		if colSpec == nil {
+			return nil, httperrors.NewInputParameterError("cannot page by marker on field %s", orderBy[0])
+		}
+		key.fields = append(key.fields, colSpec.Name())
+		key.colSpec = append(key.colSpec, colSpec)
+	}
+	key.fields = append(key.fields, primary.Name())
+	key.colSpec = append(key.colSpec, primary)
+	return key, nil
+}
+
+func (key *sPagingKey) encodeMarker(item IModel) string {
+	values := jsonutils.NewArray()
+	dict := jsonutils.Marshal(item)
+	for _, field := range key.fields {
+		v, _ := dict.GetString(field)
+		values.Add(jsonutils.NewString(v))
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(values.String()))
+}
+
+func (key *sPagingKey) decodeMarker(marker string) ([]interface{}, error) {
+	invalid := httperrors.NewInputParameterError("invalid paging_marker %s", marker)
+	data, err := base64.RawURLEncoding.DecodeString(marker)
+	if err != nil {
+		return nil, invalid
+	}
+	json, err := jsonutils.Parse(data)
+	if err != nil {
+		return nil, invalid
+	}
+	strs := []string{}
+	err = json.Unmarshal(&strs)
+	if err != nil || len(strs) != len(key.fields) {
+		return nil, invalid
+	}
+	values := make([]interface{}, len(strs))
+	for i, str := range strs {
+		if _, ok := key.colSpec[i].(*sqlchemy.SDateTimeColumn); ok {
+			tm, err := timeutils.ParseTimeStr(str)
+			if err != nil {
+				return nil, invalid
+			}
+			values[i] = tm
+		} else {
+			values[i] = key.colSpec[i].ConvertFromString(str)
+		}
+	}
+	return values, nil
+}
+
+// filterAfter keeps the rows after the row of the marker in the order
+func (key *sPagingKey) filterAfter(q *sqlchemy.SQuery, marker string, order sqlchemy.QueryOrderType) (*sqlchemy.SQuery, error) {
+	values, err := key.decodeMarker(marker)
+	if err != nil {
+		return nil, err
+	}
+	after := func(f sqlchemy.IQueryField, v interface{}) sqlchemy.ICondition {
+		if order == sqlchemy.SQL_ORDER_ASC {
+			return sqlchemy.GT(f, v)
+		}
+		return sqlchemy.LT(f, v)
+	}
+	// (f0 > v0) OR (f0 = v0 AND f1 > v1)
+	conds := make([]sqlchemy.ICondition, 0, len(key.fields))
+	for i := range key.fields {
+		and := make([]sqlchemy.ICondition, 0, i+1)
+		for j := 0; j < i; j++ {
+			and = append(and, sqlchemy.Equals(q.Field(key.fields[j]), values[j]))
+		}
+		and = append(and, after(q.Field(key.fields[i]), values[i]))
+		conds = append(conds, sqlchemy.AND(and...))
+	}
+	return q.Filter(sqlchemy.OR(conds...)), nil
+}
+
+// listLimit returns the page size of a list. Without a total, or when paging
+// by marker, the page size must be within (0, maxLimit] as the page is cut
+// by the limit, otherwise a non-positive limit lists all when there are not
+// too many rows.
+func listLimit(limit, maxLimit int64, totalCnt int, withTotal, useMarker bool) int64 {
+	if !withTotal || useMarker || int64(totalCnt) > maxLimit {
+		if limit <= 0 || limit > maxLimit {
+			return maxLimit
+		}
+	}
+	return limit
+}
+
+// cutPage keeps the first limit rows fetched with one extra row, and returns
+// the marker of the next page if there is more
+func (key *sPagingKey) cutPage(results []jsonutils.JSONObject, items []IModel, limit int64) ([]jsonutils.JSONObject, string) {
+	if limit <= 0 || int64(len(results)) <= limit {
+		return results, ""
+	}
+	return results[:limit], key.encodeMarker(items[limit-1])
+}
+
+// sparseFields keeps only the fields given by the fields query, which may be
+// repeated or comma separated, of the list results
+func sparseFields(results []jsonutils.JSONObject, query jsonutils.JSONObject) []jsonutils.JSONObject {
+	fields := make([]string, 0)
+	for _, f := range jsonutils.GetQueryStringArray(query, "fields") {
+		for _, field := range strings.Split(f, ",") {
+			field = strings.TrimSpace(field)
+			if len(field) > 0 {
+				fields = append(fields, field)
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return results
+	}
+	for i := range results {
+		if dict, ok := results[i].(*jsonutils.JSONDict); ok {
+			results[i] = dict.CopyIncludes(fields...)
+		}
+	}
+	return results
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/sqlchemy"
+)
+
+func TestPagingMarker(t *testing.T) {
+	manager := NewStandaloneResourceBaseManager(sOpenAPITestModel{}, "paging_tests_tbl", "paging_test", "paging_tests")
+	key, err := newPagingKey(&manager, []string{"size"})
+	if err != nil {
+		t.Fatalf("newPagingKey: %s", err)
+	}
+	if strings.Join(key.fields, ",") != "size,id" {
+		t.Fatalf("sort key %v, want [size id]", key.fields)
+	}
+	if _, err := newPagingKey(&manager, []string{"size", "name"}); err == nil {
+		t.Errorf("paging on two fields should fail")
+	}
+	if _, err := newPagingKey(&manager, []string{"no_such_field"}); err == nil {
+		t.Errorf("paging on an unknown field should fail")
+	}
+
+	item := &sOpenAPITestModel{Size: 42}
+	item.Id = "c5e3a0b4"
+	marker := key.encodeMarker(item)
+	values, err := key.decodeMarker(marker)
+	if err != nil {
+		t.Fatalf("decodeMarker: %s", err)
+	}
+	if len(values) != 2 || values[1] != "c5e3a0b4" {
+		t.Errorf("decoded %v", values)
+	}
+	if _, err := key.decodeMarker("not-a-marker"); err == nil {
+		t.Errorf("invalid marker should fail")
+	}
+
+	q, err := key.filterAfter(manager.Query(), marker, sqlchemy.SQL_ORDER_DESC)
+	if err != nil {
+		t.Fatalf("filterAfter: %s", err)
+	}
+	sql := q.String()
+	for _, want := range []string{"`size` < ( ? )", "`size` = ( ? )", "`id` < ( ? )"} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("%s not in %s", want, sql)
+		}
+	}
+}
+
+func TestListLimit(t *testing.T) {
+	cases := []struct {
+		limit     int64
+		totalCnt  int
+		withTotal bool
+		useMarker bool
+		want      int64
+	}{
+		{limit: 0, totalCnt: 10, withTotal: true, want: 0},
+		{limit: 0, totalCnt: 4096, withTotal: true, want: 2048},
+		{limit: 20, totalCnt: 4096, withTotal: true, want: 20},
+		{limit: 0, withTotal: false, want: 2048},
+		{limit: 0, totalCnt: 2, withTotal: true, useMarker: true, want: 2048},
+		{limit: -1, totalCnt: 2, withTotal: true, useMarker: true, want: 2048},
+		{limit: 10000, totalCnt: 2, withTotal: true, useMarker: true, want: 2048},
+		{limit: 20, useMarker: true, want: 20},
+	}
+	for _, c := range cases {
+		got := listLimit(c.limit, 2048, c.totalCnt, c.withTotal, c.useMarker)
+		if got != c.want {
+			t.Errorf("listLimit(%d, total %d, withTotal %v, marker %v) = %d, want %d", c.limit, c.totalCnt, c.withTotal, c.useMarker, got, c.want)
+		}
+	}
+}
+
+func TestCutPage(t *testing.T) {
+	manager := NewStandaloneResourceBaseManager(sOpenAPITestModel{}, "paging_tests_tbl", "paging_test", "paging_tests")
+	key, err := newPagingKey(&manager, nil)
+	if err != nil {
+		t.Fatalf("newPagingKey: %s", err)
+	}
+	results := []jsonutils.JSONObject{}
+	items := []IModel{}
+	for _, id := range []string{"a", "b", "c"} {
+		item := &sOpenAPITestModel{}
+		item.Id = id
+		items = append(items, item)
+		results = append(results, jsonutils.Marshal(map[string]string{"id": id}))
+	}
+
+	page, marker := key.cutPage(results, items, 2)
+	if len(page) != 2 || marker != key.encodeMarker(items[1]) {
+		t.Errorf("cut 3 rows at 2: %d rows, marker %q", len(page), marker)
+	}
+	page, marker = key.cutPage(results, items, 3)
+	if len(page) != 3 || marker != "" {
+		t.Errorf("cut 3 rows at 3: %d rows, marker %q", len(page), marker)
+	}
+	// a table of a single row with a zero limit used to read items[-1]
+	page, marker = key.cutPage(results[:1], items[:1], 0)
+	if len(page) != 1 || marker != "" {
+		t.Errorf("cut 1 row at 0: %d rows, marker %q", len(page), marker)
+	}
+}
+
+func TestSparseFields(t *testing.T) {
+	results := []jsonutils.JSONObject{
+		jsonutils.Marshal(map[string]string{"id": "1", "name": "a", "status": "ready"}),
+	}
+	query := jsonutils.Marshal(map[string]string{"fields": "id, status"})
+	results = sparseFields(results, query)
+	if got := results[0].String(); got != `{"id":"1","status":"ready"}` {
+		t.Errorf("sparse fields %s", got)
+	}
+}
@@ -63,6 +63,14 @@ func (model *SResourceBase) ResourceModelManager() IResourceModelManager {
 	return model.GetModelManager().(IResourceModelManager)
 }
 
+func (model *SResourceBase) GetUpdateVersion() int {
+	return model.UpdateVersion
+}
+
+func (model *SResourceBase) GetUpdatedAt() time.Time {
+	return model.UpdatedAt
+}
+
 /*func (model *SResourceBase) GetCustomizeColumns(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) *jsonutils.JSONDict {
 	extra := model.SModelBase.GetCustomizeColumns(ctx, userCred, query)
 	canDelete := CanDelete(model, ctx)
@@ -23,4 +23,5 @@ import (
 func AddTaskHandler(prefix string, app *appsrv.Application) {
 	handler := db.NewModelHandler(TaskManager)
 	dispatcher.AddModelDispatcher(prefix, app, handler)
+	app.RegisterMetrics(&sTaskCollector{})
 }
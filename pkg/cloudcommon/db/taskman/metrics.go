@@ -0,0 +1,56 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"yunion.io/x/log"
+)
+
+const taskMetricsWindow = 24 * time.Hour
+
+var tasksDesc = prometheus.NewDesc("taskman_tasks",
+	"Number of tasks by state, complete and failed only count tasks created in the last 24 hours",
+	[]string{"state"}, nil)
+
+// sTaskCollector exports task counts so that tasks piling up can be alerted
+// on, the queue of the task workers themselves is exported by appsrv
+type sTaskCollector struct{}
+
+func (c *sTaskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tasksDesc
+}
+
+func (c *sTaskCollector) Collect(ch chan<- prometheus.Metric) {
+	open, err := TaskManager.Query().NotIn("stage", []string{TASK_STAGE_COMPLETE, TASK_STAGE_FAILED}).CountWithError()
+	if err != nil {
+		log.Errorf("count open tasks: %s", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(tasksDesc, prometheus.GaugeValue, float64(open), "open")
+
+	since := time.Now().UTC().Add(-taskMetricsWindow)
+	for _, stage := range []string{TASK_STAGE_COMPLETE, TASK_STAGE_FAILED} {
+		cnt, err := TaskManager.Query().Equals("stage", stage).GE("created_at", since).CountWithError()
+		if err != nil {
+			log.Errorf("count %s tasks: %s", stage, err)
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(tasksDesc, prometheus.GaugeValue, float64(cnt), stage)
+	}
+}
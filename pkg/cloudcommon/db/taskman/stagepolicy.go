@@ -0,0 +1,374 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/util/timeutils"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	TASK_EVENT_STAGE_KEY   = "__stage__"
+	TASK_EVENT_TIMEOUT_KEY = "__timeout__"
+	TASK_EVENT_CANCEL_KEY  = "__cancel__"
+	TASK_EVENT_RETRY_KEY   = "__retry__"
+
+	TASK_CANCELLED_KEY   = "__cancelled"
+	TASK_RETRY_STAGE_KEY = "__retry_stage"
+	TASK_RETRY_INPUT_KEY = "__retry_input"
+	TASK_RETRIES_KEY     = "__retries"
+)
+
+// SStagePolicy describes how taskman supervises a stage of a task.
+//
+// Timeout is the deadline of the stage once the task enters it, usually the
+// time allowed for a remote callback. When it expires the stage handler
+// <Stage>Timeout is called if present, otherwise <Stage>Failed.
+//
+// MaxRetries marks the stage as idempotent: when the stage it hands over to
+// fails or times out, the task is rolled back and the stage is called again
+// with its original input, up to MaxRetries times, RetryInterval apart.
+//
+// OrphanTimeout opts the stage in to the orphan sweeper: a task which made no
+// progress at the stage for that long, and waits for no subtask, is failed.
+// Unlike Timeout it is measured from the last update of the task, so it
+// suits stages whose callbacks keep reporting progress.
+//
+// NonCancellable refuses to cancel the task while it waits at the stage, for
+// stages whose failure handler must not run before the remote operation
+// they wait for has finished.
+type SStagePolicy struct {
+	Timeout        time.Duration
+	MaxRetries     int
+	RetryInterval  time.Duration
+	OrphanTimeout  time.Duration
+	NonCancellable bool
+}
+
+var (
+	stagePolicyTable map[string]map[string]SStagePolicy
+
+	// tasks created before this moment belong to a previous run of the service
+	serviceStartAt time.Time
+)
+
+func init() {
+	stagePolicyTable = make(map[string]map[string]SStagePolicy)
+	serviceStartAt = timeutils.UtcNow()
+}
+
+// stageKey normalizes a stage saved as "on_init" to its handler name
+// "OnInit", handler names are kept as they are.
+func stageKey(stage string) string {
+	if strings.Contains(stage, "_") {
+		return utils.Kebab2Camel(stage, "_")
+	}
+	return stage
+}
+
+// RegisterStagePolicy sets the policy of a stage of a registered task, the
+// stage is given by its handler name, e.g. "OnInit" or "OnGuestStartComplete".
+func RegisterStagePolicy(task interface{}, stage string, policy SStagePolicy) {
+	taskName := gotypes.GetInstanceTypeName(task)
+	if !isTaskExist(taskName) {
+		log.Fatalf("Task %s not registered!", taskName)
+	}
+	policies, ok := stagePolicyTable[taskName]
+	if !ok {
+		policies = make(map[string]SStagePolicy)
+		stagePolicyTable[taskName] = policies
+	}
+	policies[stageKey(stage)] = policy
+}
+
+func getStagePolicy(taskName, stage string) *SStagePolicy {
+	if policies, ok := stagePolicyTable[taskName]; ok {
+		if policy, ok := policies[stageKey(stage)]; ok {
+			return &policy
+		}
+	}
+	return nil
+}
+
+func getStageDeadline(taskName, stage string) time.Time {
+	policy := getStagePolicy(taskName, stage)
+	if policy == nil || policy.Timeout <= 0 {
+		return time.Time{}
+	}
+	return timeutils.UtcNow().Add(policy.Timeout)
+}
+
+// saveRetryInput remembers the input of an idempotent stage so that it can
+// be replayed by retryStage, and forgets it once a later stage runs.
+func (self *STask) saveRetryInput(stageName string, data jsonutils.JSONObject) {
+	policy := getStagePolicy(self.TaskName, stageName)
+	if (policy == nil || policy.MaxRetries <= 0) && !self.Params.Contains(TASK_RETRY_STAGE_KEY) {
+		return
+	}
+	_, err := db.Update(self, func() error {
+		params := self.Params.CopyExcludes(TASK_RETRY_STAGE_KEY, TASK_RETRY_INPUT_KEY, TASK_RETRIES_KEY)
+		if policy != nil && policy.MaxRetries > 0 {
+			params.Set(TASK_RETRY_STAGE_KEY, jsonutils.NewString(self.Stage))
+			params.Set(TASK_RETRY_INPUT_KEY, data)
+			if jsonutils.QueryBoolean(data, TASK_EVENT_RETRY_KEY, false) {
+				retries, _ := self.Params.Int(TASK_RETRIES_KEY)
+				params.Set(TASK_RETRIES_KEY, jsonutils.NewInt(retries))
+			}
+		}
+		self.Params = params
+		return nil
+	})
+	if err != nil {
+		log.Errorf("save retry input of task %s fail %s", self.Id, err)
+	}
+}
+
+// retryStage rolls the task back to the last idempotent stage if its retry
+// budget allows, returns false when the failure should be handled as usual.
+func (self *STask) retryStage() bool {
+	retryStage, _ := self.Params.GetString(TASK_RETRY_STAGE_KEY)
+	if len(retryStage) == 0 {
+		return false
+	}
+	policy := getStagePolicy(self.TaskName, retryStage)
+	if policy == nil || policy.MaxRetries <= 0 {
+		return false
+	}
+	retries, _ := self.Params.Int(TASK_RETRIES_KEY)
+	if int(retries) >= policy.MaxRetries {
+		log.Warningf("Task %s(%s) stage %s retried %d times, give up", self.TaskName, self.Id, retryStage, retries)
+		return false
+	}
+	input := jsonutils.NewDict()
+	if saved, _ := self.Params.Get(TASK_RETRY_INPUT_KEY); saved != nil {
+		input.Update(saved)
+	}
+	input.Set(TASK_EVENT_RETRY_KEY, jsonutils.JSONTrue)
+	input.Set(TASK_EVENT_STAGE_KEY, jsonutils.NewString(retryStage))
+
+	params := jsonutils.NewDict()
+	params.Set(TASK_RETRIES_KEY, jsonutils.NewInt(retries+1))
+	if err := self.SetStage(retryStage, params); err != nil {
+		return false
+	}
+	log.Infof("Task %s(%s) retry stage %s (%d/%d)", self.TaskName, self.Id, retryStage, retries+1, policy.MaxRetries)
+	taskId := self.Id
+	time.AfterFunc(policy.RetryInterval, func() {
+		runTask(taskId, input)
+	})
+	return true
+}
+
+func (self *STask) IsCancelled() bool {
+	return jsonutils.QueryBoolean(self.Params, TASK_CANCELLED_KEY, false)
+}
+
+func (self *STask) isOpen() bool {
+	return !utils.IsInStringArray(self.Stage, []string{TASK_STAGE_COMPLETE, TASK_STAGE_FAILED})
+}
+
+func (self *STask) AllowPerformCancel(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, self, "cancel") || userCred.GetProjectId() == self.UserCred.GetProjectId()
+}
+
+func (self *STask) PerformCancel(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if !self.isOpen() {
+		return nil, httperrors.NewInvalidStatusError("Task %s is already %s", self.Id, self.Stage)
+	}
+	if task := self.nonCancellableTask(); task != nil {
+		return nil, httperrors.NewInvalidStatusError("Task %s(%s) can not be cancelled at stage %s", task.TaskName, task.Id, task.Stage)
+	}
+	self.Cancel(ctx, fmt.Sprintf("task cancelled by %s", userCred.GetUserName()))
+	return nil, nil
+}
+
+// Cancel fails the task through the failure handler of its current stage.
+// Pending subtasks are cancelled first, their failure is then reported to
+// this task the usual way.
+func (self *STask) Cancel(ctx context.Context, reason string) {
+	params := jsonutils.NewDict()
+	params.Set(TASK_CANCELLED_KEY, jsonutils.JSONTrue)
+	self.SaveParams(params)
+
+	subtasks := SubTaskManager.GetInitSubtasks(self.Id, self.Stage)
+	cancelled := 0
+	for i := range subtasks {
+		subtask := TaskManager.fetchTask(subtasks[i].SubtaskId)
+		if subtask != nil && subtask.isOpen() {
+			subtask.Cancel(ctx, reason)
+			cancelled += 1
+		}
+	}
+	if cancelled == 0 {
+		self.ScheduleRun(self.stageEventData(reason, TASK_EVENT_CANCEL_KEY))
+	}
+}
+
+// nonCancellableTask returns the task, this one or one of its pending
+// subtasks, that waits at a stage which must not be cancelled.
+func (self *STask) nonCancellableTask() *STask {
+	policy := getStagePolicy(self.TaskName, self.Stage)
+	if policy != nil && policy.NonCancellable {
+		return self
+	}
+	subtasks := SubTaskManager.GetInitSubtasks(self.Id, self.Stage)
+	for i := range subtasks {
+		subtask := TaskManager.fetchTask(subtasks[i].SubtaskId)
+		if subtask == nil || !subtask.isOpen() {
+			continue
+		}
+		if task := subtask.nonCancellableTask(); task != nil {
+			return task
+		}
+	}
+	return nil
+}
+
+func (self *STask) stageEventData(reason string, key string) *jsonutils.JSONDict {
+	data := jsonutils.NewDict()
+	data.Set("__status__", jsonutils.NewString("error"))
+	data.Set("__reason__", jsonutils.NewString(reason))
+	data.Set(key, jsonutils.JSONTrue)
+	data.Set(TASK_EVENT_STAGE_KEY, jsonutils.NewString(self.Stage))
+	return data
+}
+
+// timeout clears the deadline of the current stage and fires its timeout
+// handler.
+func (self *STask) timeout(reason string) {
+	_, err := db.Update(self, func() error {
+		self.StageDeadline = time.Time{}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("clear deadline of task %s fail %s", self.Id, err)
+		return
+	}
+	log.Warningf("Task %s(%s) stage %s: %s", self.TaskName, self.Id, self.Stage, reason)
+	self.ScheduleRun(self.stageEventData(reason, TASK_EVENT_TIMEOUT_KEY))
+}
+
+// stageNames returns the names a stage given by its handler name may be
+// saved as, e.g. "OnInit" and "on_init".
+func stageNames(stage string) []string {
+	return []string{stage, utils.CamelSplit(stage, "_")}
+}
+
+// sweepQuery selects open tasks the sweeper may act on: stages past their
+// deadline, stages opted in to orphan sweeping that made no progress within
+// their OrphanTimeout and, on start, tasks queued before the restart.
+func (manager *STaskManager) sweepQuery(now time.Time, isStart bool) *sqlchemy.SQuery {
+	q := manager.Query().NotIn("stage", []string{TASK_STAGE_COMPLETE, TASK_STAGE_FAILED})
+	conds := []sqlchemy.ICondition{
+		sqlchemy.AND(
+			sqlchemy.IsNotNull(q.Field("stage_deadline")),
+			sqlchemy.LT(q.Field("stage_deadline"), now),
+		),
+	}
+	for taskName, policies := range stagePolicyTable {
+		for stage, policy := range policies {
+			if policy.OrphanTimeout <= 0 {
+				continue
+			}
+			conds = append(conds, sqlchemy.AND(
+				sqlchemy.Equals(q.Field("task_name"), taskName),
+				sqlchemy.In(q.Field("stage"), stageNames(stage)),
+				sqlchemy.LT(q.Field("updated_at"), now.Add(-policy.OrphanTimeout)),
+			))
+		}
+	}
+	if isStart {
+		conds = append(conds, sqlchemy.AND(
+			sqlchemy.Equals(q.Field("stage"), TASK_INIT_STAGE),
+			sqlchemy.LT(q.Field("created_at"), serviceStartAt),
+		))
+	}
+	return q.Filter(sqlchemy.OR(conds...))
+}
+
+func (manager *STaskManager) fetchSweepTasks(now time.Time, isStart bool) ([]STask, error) {
+	q := manager.sweepQuery(now, isStart)
+	tasks := make([]STask, 0)
+	err := db.FetchModelObjects(manager, q, &tasks)
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// SweepTasks is a cron job that times out stages past their deadline and
+// fails tasks orphaned at stages with an OrphanTimeout. On the first run
+// after start it also resumes tasks that were queued but never run before
+// the service stopped.
+func (manager *STaskManager) SweepTasks(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	now := timeutils.UtcNow()
+	tasks, err := manager.fetchSweepTasks(now, isStart)
+	if err != nil {
+		log.Errorf("fetch tasks to sweep fail %s", err)
+		return
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		if !isTaskExist(task.TaskName) {
+			continue
+		}
+		func() {
+			lockman.LockRawObject(ctx, "tasks", task.Id)
+			defer lockman.ReleaseRawObject(ctx, "tasks", task.Id)
+
+			if task.Params == nil {
+				task.Params = jsonutils.NewDict()
+			}
+			switch {
+			case !task.StageDeadline.IsZero() && task.StageDeadline.Before(now):
+				task.timeout(fmt.Sprintf("stage %s timeout", task.Stage))
+			case task.isOrphan(now):
+				task.timeout(fmt.Sprintf("no progress at stage %s since %s", task.Stage, timeutils.FullIsoTime(task.UpdatedAt)))
+			case isStart && task.Stage == TASK_INIT_STAGE && task.CreatedAt.Before(serviceStartAt) && !task.hasOpenSubtasks():
+				log.Infof("Resume task %s(%s) queued before restart", task.TaskName, task.Id)
+				task.ScheduleRun(nil)
+			}
+		}()
+	}
+}
+
+func (self *STask) isOrphan(now time.Time) bool {
+	policy := getStagePolicy(self.TaskName, self.Stage)
+	if policy == nil || policy.OrphanTimeout <= 0 {
+		return false
+	}
+	return self.UpdatedAt.Add(policy.OrphanTimeout).Before(now) && !self.hasOpenSubtasks()
+}
+
+func (self *STask) hasOpenSubtasks() bool {
+	q := SubTaskManager.Query().Equals("task_id", self.Id).Equals("stage", self.Stage)
+	q = q.Filter(sqlchemy.Equals(q.Field("status"), SUBTASK_INIT))
+	cnt, err := q.CountWithError()
+	return err != nil || cnt > 0
+}
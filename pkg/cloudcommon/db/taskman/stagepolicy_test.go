@@ -0,0 +1,195 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/util/timeutils"
+)
+
+type sStagePolicyTestTask struct {
+	STask
+}
+
+type sStagePolicyOrphanTestTask struct {
+	STask
+}
+
+func init() {
+	RegisterTask(sStagePolicyTestTask{})
+	RegisterTask(sStagePolicyOrphanTestTask{})
+	RegisterStagePolicy(sStagePolicyTestTask{}, "OnInit", SStagePolicy{MaxRetries: 2, RetryInterval: time.Second})
+	RegisterStagePolicy(sStagePolicyTestTask{}, "OnResizeComplete", SStagePolicy{Timeout: time.Hour})
+	RegisterStagePolicy(sStagePolicyTestTask{}, "OnMirrorComplete", SStagePolicy{NonCancellable: true})
+	RegisterStagePolicy(sStagePolicyOrphanTestTask{}, "OnSyncComplete", SStagePolicy{OrphanTimeout: 6 * time.Hour})
+}
+
+func TestGetStagePolicy(t *testing.T) {
+	cases := []struct {
+		taskName string
+		stage    string
+		want     *SStagePolicy
+	}{
+		{"sStagePolicyTestTask", "OnInit", &SStagePolicy{MaxRetries: 2, RetryInterval: time.Second}},
+		{"sStagePolicyTestTask", TASK_INIT_STAGE, &SStagePolicy{MaxRetries: 2, RetryInterval: time.Second}},
+		{"sStagePolicyTestTask", "OnResizeComplete", &SStagePolicy{Timeout: time.Hour}},
+		{"sStagePolicyTestTask", "on_resize_complete", &SStagePolicy{Timeout: time.Hour}},
+		{"sStagePolicyTestTask", "OnResizeCompleteFailed", nil},
+		{"sStagePolicyTestTask", "on_mirror_complete", &SStagePolicy{NonCancellable: true}},
+		{"sStagePolicyOrphanTestTask", "OnInit", nil},
+		{"NoSuchTask", "OnInit", nil},
+	}
+	for _, c := range cases {
+		got := getStagePolicy(c.taskName, c.stage)
+		if (got == nil) != (c.want == nil) || (got != nil && *got != *c.want) {
+			t.Errorf("%s %s: want %#v, got %#v", c.taskName, c.stage, c.want, got)
+		}
+	}
+}
+
+func TestGetStageDeadline(t *testing.T) {
+	if deadline := getStageDeadline("sStagePolicyTestTask", "OnInit"); !deadline.IsZero() {
+		t.Errorf("stage without timeout should have no deadline, got %s", deadline)
+	}
+	before := timeutils.UtcNow()
+	deadline := getStageDeadline("sStagePolicyTestTask", "OnResizeComplete")
+	after := timeutils.UtcNow()
+	if deadline.Before(before.Add(time.Hour)) || deadline.After(after.Add(time.Hour)) {
+		t.Errorf("deadline %s not an hour after %s", deadline, before)
+	}
+}
+
+func TestStageNames(t *testing.T) {
+	got := stageNames("OnResizeComplete")
+	if len(got) != 2 || got[0] != "OnResizeComplete" || got[1] != "on_resize_complete" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestTaskIsOpen(t *testing.T) {
+	cases := map[string]bool{
+		TASK_INIT_STAGE:     true,
+		"OnResizeComplete":  true,
+		TASK_STAGE_COMPLETE: false,
+		TASK_STAGE_FAILED:   false,
+	}
+	for stage, want := range cases {
+		task := &STask{Stage: stage}
+		if got := task.isOpen(); got != want {
+			t.Errorf("stage %s: want open %v, got %v", stage, want, got)
+		}
+	}
+}
+
+func TestTaskIsCancelled(t *testing.T) {
+	task := &STask{Params: jsonutils.NewDict()}
+	if task.IsCancelled() {
+		t.Errorf("new task should not be cancelled")
+	}
+	task.Params.Set(TASK_CANCELLED_KEY, jsonutils.JSONTrue)
+	if !task.IsCancelled() {
+		t.Errorf("task should be cancelled")
+	}
+}
+
+func TestStageEventData(t *testing.T) {
+	task := &STask{Stage: "OnResizeComplete"}
+	data := task.stageEventData("stage OnResizeComplete timeout", TASK_EVENT_TIMEOUT_KEY)
+	if status, _ := data.GetString("__status__"); status != "error" {
+		t.Errorf("want status error, got %s", status)
+	}
+	if reason, _ := data.GetString("__reason__"); reason != "stage OnResizeComplete timeout" {
+		t.Errorf("unexpected reason %s", reason)
+	}
+	if !jsonutils.QueryBoolean(data, TASK_EVENT_TIMEOUT_KEY, false) {
+		t.Errorf("timeout flag not set")
+	}
+	if jsonutils.QueryBoolean(data, TASK_EVENT_CANCEL_KEY, false) {
+		t.Errorf("cancel flag should not be set")
+	}
+	if stage, _ := data.GetString(TASK_EVENT_STAGE_KEY); stage != "OnResizeComplete" {
+		t.Errorf("want stage OnResizeComplete, got %s", stage)
+	}
+}
+
+func TestTaskIsOrphan(t *testing.T) {
+	now := timeutils.UtcNow()
+	cases := []struct {
+		name      string
+		taskName  string
+		stage     string
+		updatedAt time.Time
+		want      bool
+	}{
+		{"stage not opted in", "sStagePolicyTestTask", "OnResizeComplete", now.Add(-48 * time.Hour), false},
+		{"no policy", "sStagePolicyOrphanTestTask", TASK_INIT_STAGE, now.Add(-48 * time.Hour), false},
+		{"recent progress", "sStagePolicyOrphanTestTask", "OnSyncComplete", now.Add(-time.Hour), false},
+	}
+	for _, c := range cases {
+		task := &STask{TaskName: c.taskName, Stage: c.stage}
+		task.UpdatedAt = c.updatedAt
+		if got := task.isOrphan(now); got != c.want {
+			t.Errorf("%s: want %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestSweepQuery(t *testing.T) {
+	now := timeutils.UtcNow()
+	cases := []struct {
+		isStart bool
+		want    []string
+		notWant []string
+	}{
+		{
+			isStart: false,
+			want: []string{
+				"`stage_deadline` IS NOT NULL",
+				"`stage_deadline` < ( ? )",
+				"`updated_at` < ( ? )",
+			},
+			notWant: []string{"`created_at` < ( ? )"},
+		},
+		{
+			isStart: true,
+			want: []string{
+				"`stage_deadline` < ( ? )",
+				"`updated_at` < ( ? )",
+				"`created_at` < ( ? )",
+			},
+		},
+	}
+	for _, c := range cases {
+		sql := TaskManager.sweepQuery(now, c.isStart).String()
+		for _, want := range c.want {
+			if !strings.Contains(sql, want) {
+				t.Errorf("isStart %v: %s not in %s", c.isStart, want, sql)
+			}
+		}
+		for _, notWant := range c.notWant {
+			if strings.Contains(sql, notWant) {
+				t.Errorf("isStart %v: %s should not be in %s", c.isStart, notWant, sql)
+			}
+		}
+		// only the stage opted in to orphan sweeping is selected by progress
+		if cnt := strings.Count(sql, "`updated_at` < ( ? )"); cnt != 1 {
+			t.Errorf("isStart %v: want 1 orphan condition, got %d in %s", c.isStart, cnt, sql)
+		}
+	}
+}
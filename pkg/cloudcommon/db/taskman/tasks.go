@@ -81,6 +81,9 @@ type STask struct {
 
 	Stage string `width:"64" charset:"ascii" nullable:"false" default:"on_init" list:"user"` // Column(VARCHAR(64, charset='ascii'), nullable=False, default='on_init')
 
+	// deadline of current stage, zero means the stage never times out
+	StageDeadline time.Time `nullable:"true" index:"true" list:"user"`
+
 	taskObject  db.IStandaloneModel   `ignore:"true"`
 	taskObjects []db.IStandaloneModel `ignore:"true"`
 }
@@ -218,6 +221,7 @@ func (manager *STaskManager) NewTask(ctx context.Context, taskName string, obj d
 		Params:   data,
 		Stage:    TASK_INIT_STAGE,
 	}
+	task.StageDeadline = getStageDeadline(taskName, TASK_INIT_STAGE)
 	err := manager.TableSpec().Insert(&task)
 	if err != nil {
 		log.Errorf("Task insert error %s", err)
@@ -260,6 +264,7 @@ func (manager *STaskManager) NewParallelTask(ctx context.Context, taskName strin
 		Params:   data,
 		Stage:    TASK_INIT_STAGE,
 	}
+	task.StageDeadline = getStageDeadline(taskName, TASK_INIT_STAGE)
 	err := manager.TableSpec().Insert(&task)
 	if err != nil {
 		log.Errorf("Task insert error %s", err)
@@ -337,9 +342,17 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 	ctx := ctxData.GetContext()
 
 	taskFailed := false
+	isTimeout := false
+	isCancel := false
 
 	data := odata
 	if data != nil {
+		if stage, _ := data.GetString(TASK_EVENT_STAGE_KEY); len(stage) > 0 && stage != task.Stage {
+			log.Warningf("Task %s(%s) already left stage %s, ignore stale event", task.TaskName, task.Id, stage)
+			return
+		}
+		isTimeout = jsonutils.QueryBoolean(data, TASK_EVENT_TIMEOUT_KEY, false)
+		isCancel = jsonutils.QueryBoolean(data, TASK_EVENT_CANCEL_KEY, false)
 		taskStatus, _ := data.GetString("__status__")
 		if len(taskStatus) > 0 && taskStatus != "OK" {
 			taskFailed = true
@@ -355,6 +368,10 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 		data = jsonutils.NewDict()
 	}
 
+	if taskFailed && !isCancel && task.retryStage() {
+		return
+	}
+
 	var stageName string
 	if taskFailed {
 		stageName = fmt.Sprintf("%sFailed", task.Stage)
@@ -363,6 +380,14 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 	}
 
 	funcValue := taskValue.MethodByName(stageName)
+	if taskFailed && isTimeout {
+		// optional timeout handler takes precedence over failure handler
+		timeoutValue := taskValue.MethodByName(utils.Kebab2Camel(task.Stage, "_") + "Timeout")
+		if timeoutValue.IsValid() && !timeoutValue.IsNil() {
+			stageName = utils.Kebab2Camel(task.Stage, "_") + "Timeout"
+			funcValue = timeoutValue
+		}
+	}
 
 	if !funcValue.IsValid() || funcValue.IsNil() {
 		log.Debugf("Stage %s not found, try kebab to camel and find again", stageName)
@@ -447,6 +472,10 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 
 	params[2] = reflect.ValueOf(data)
 
+	if !taskFailed {
+		task.saveRetryInput(stageName, data)
+	}
+
 	filled := reflectutils.FillEmbededStructValue(taskValue.Elem(), reflect.Indirect(reflect.ValueOf(task)))
 	if !filled {
 		log.Errorf("Cannot locate baseTask embedded struct, give up...")
@@ -456,6 +485,16 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 	log.Debugf("Call %s %s %#v", task.TaskName, stageName, params)
 	funcValue.Call(params)
 
+	if isCancel || isTimeout {
+		// the handler neither finished the task nor moved it to a cleanup
+		// stage, fail it so that it does not wait for the lost callback
+		t := TaskManager.fetchTask(task.Id)
+		if t != nil && t.Stage == task.Stage {
+			reason, _ := data.GetString("__reason__")
+			t.SetStageFailed(ctx, reason)
+		}
+	}
+
 	// call save request context
 	saveRequestContextFuncValue := taskValue.MethodByName("SaveRequestContext")
 	saveRequestContextFuncValue.Call([]reflect.Value{reflect.ValueOf(&ctxData)})
@@ -531,6 +570,7 @@ func (self *STask) SetStage(stageName string, data *jsonutils.JSONDict) error {
 			stageData.Add(jsonutils.NewTimeString(time.Now()), "complete_at")
 			stageList.Add(stageData)
 			self.Stage = stageName
+			self.StageDeadline = getStageDeadline(self.TaskName, stageName)
 		}
 		self.Params = params
 		return nil
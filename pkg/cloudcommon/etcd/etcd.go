@@ -289,3 +289,58 @@ func (cli *SEtcdClient) Delete(ctx context.Context, key string) ([]byte, error)
 		return nil, nil
 	}
 }
+
+// AcquireSessionKey blocks until key is created with val under the session
+// lease, so at most one session holds the key at a time. The key is dropped
+// by etcd if the session is lost.
+func (cli *SEtcdClient) AcquireSessionKey(ctx context.Context, key string, val string) error {
+	key = cli.getKey(key)
+	for {
+		nctx, cancel := context.WithTimeout(ctx, cli.requestTimeout)
+		resp, err := cli.client.Txn(nctx).
+			If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+			Then(clientv3.OpPut(key, val, clientv3.WithLease(cli.leaseId))).
+			Commit()
+		cancel()
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			return nil
+		}
+
+		// wait for the holder to delete the key, watching from the revision
+		// of the failed txn so that a release in between is not missed
+		wctx, wcancel := context.WithCancel(ctx)
+		wch := cli.client.Watch(wctx, key, clientv3.WithRev(resp.Header.Revision+1), clientv3.WithFilterPut())
+		for wresp := range wch {
+			if wresp.Err() != nil || len(wresp.Events) > 0 {
+				break
+			}
+		}
+		wcancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// ReleaseSessionKey deletes key if it is still held with val
+func (cli *SEtcdClient) ReleaseSessionKey(ctx context.Context, key string, val string) error {
+	nctx, cancel := context.WithTimeout(ctx, cli.requestTimeout)
+	defer cancel()
+
+	key = cli.getKey(key)
+
+	resp, err := cli.client.Txn(nctx).
+		If(clientv3.Compare(clientv3.Value(key), "=", val)).
+		Then(clientv3.OpDelete(key)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("key %s not held by %s", key, val)
+	}
+	return nil
+}
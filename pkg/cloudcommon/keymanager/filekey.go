@@ -0,0 +1,164 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymanager
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	KEY_SERVICE_FILE = "file"
+
+	masterKeyLen = 32
+)
+
+var masterKeyIdReg = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// SFileKeyService keeps master keys in a local file.  Each line of the file
+// is a key id and base64 encoded 256 bits key separated by white space.  The
+// first key is the primary one, the rest are kept for unwrapping data keys
+// that have not been re-wrapped yet
+//
+//	# comment
+//	k20191018120000 qmX3Y...=
+//	k20190101000000 8dK1a...=
+type SFileKeyService struct {
+	primaryKeyId string
+	keys         map[string][]byte
+}
+
+func init() {
+	RegisterKeyService(KEY_SERVICE_FILE, func(opts *SKeyManagerOptions) (IKeyService, error) {
+		return NewFileKeyService(opts.MasterKeyFile)
+	})
+}
+
+func NewFileKeyService(path string) (*SFileKeyService, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseMasterKeys(d)
+}
+
+func parseMasterKeys(d []byte) (*SFileKeyService, error) {
+	svc := &SFileKeyService{
+		keys: map[string][]byte{},
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(d))
+	lineno := 0
+	for scanner.Scan() {
+		lineno += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want key id and key", lineno)
+		}
+		keyId := fields[0]
+		if !masterKeyIdReg.MatchString(keyId) {
+			return nil, fmt.Errorf("line %d: invalid key id %q", lineno, keyId)
+		}
+		if _, ok := svc.keys[keyId]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key id %q", lineno, keyId)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: decode key: %s", lineno, err)
+		}
+		if len(key) != masterKeyLen {
+			return nil, fmt.Errorf("line %d: want %d bytes key, got %d", lineno, masterKeyLen, len(key))
+		}
+		svc.keys[keyId] = key
+		if svc.primaryKeyId == "" {
+			svc.primaryKeyId = keyId
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if svc.primaryKeyId == "" {
+		return nil, fmt.Errorf("no master key found")
+	}
+	return svc, nil
+}
+
+func (svc *SFileKeyService) PrimaryKeyId() string {
+	return svc.primaryKeyId
+}
+
+func (svc *SFileKeyService) WrapKey(dataKey []byte) (string, []byte, error) {
+	keyId := svc.primaryKeyId
+	wrapped, err := sealGCM(svc.keys[keyId], dataKey, []byte(keyId))
+	if err != nil {
+		return "", nil, err
+	}
+	return keyId, wrapped, nil
+}
+
+func (svc *SFileKeyService) UnwrapKey(keyId string, wrapped []byte) ([]byte, error) {
+	key, ok := svc.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("master key %q not found", keyId)
+	}
+	return openGCM(key, wrapped, []byte(keyId))
+}
+
+// RotateMasterKeyFile generates a new master key and puts it at the top of
+// the key file so that it becomes the primary one.  Old keys are kept.  The
+// file is created if it does not exist yet
+func RotateMasterKeyFile(path string) (string, error) {
+	old, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	keyId := "k" + time.Now().UTC().Format("20060102150405")
+	if len(old) > 0 {
+		svc, err := parseMasterKeys(old)
+		if err != nil {
+			return "", fmt.Errorf("parse %s: %s", path, err)
+		}
+		if _, ok := svc.keys[keyId]; ok {
+			return "", fmt.Errorf("master key %s already exists", keyId)
+		}
+	}
+	key := make([]byte, masterKeyLen)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s %s\n", keyId, base64.StdEncoding.EncodeToString(key))
+	buf.Write(old)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	return keyId, nil
+}
@@ -0,0 +1,304 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymanager
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/utils"
+)
+
+// Secrets are protected with envelope encryption.  Each secret is encrypted
+// with its own random data key, and the data key is wrapped by a master key
+// held by the key service.  The stored form is
+//
+//	enc:v1:<master key id>:<base64 wrapped data key>:<base64 nonce and ciphertext>
+//
+// The ciphertext is sealed with id of the record owning the secret as
+// additional data, so a secret copied to another record fails to decrypt.
+// Rotating master key only requires re-wrapping data keys.
+const (
+	envelopePrefix = "enc:v1:"
+	envelopeSep    = ":"
+
+	dataKeyLen = 32
+)
+
+// IKeyService wraps and unwraps data keys with master keys it manages
+type IKeyService interface {
+	// PrimaryKeyId returns id of the master key used for wrapping new data keys
+	PrimaryKeyId() string
+	WrapKey(dataKey []byte) (keyId string, wrapped []byte, err error)
+	UnwrapKey(keyId string, wrapped []byte) ([]byte, error)
+}
+
+type KeyServiceFactory func(opts *SKeyManagerOptions) (IKeyService, error)
+
+var (
+	keyServiceFactories = map[string]KeyServiceFactory{}
+
+	keyService     IKeyService
+	keyServiceLock sync.RWMutex
+)
+
+// RegisterKeyService makes a KMS available by name for use in KeyService
+// option
+func RegisterKeyService(name string, factory KeyServiceFactory) {
+	keyServiceFactories[name] = factory
+}
+
+func Init(opts *SKeyManagerOptions) error {
+	name := opts.KeyService
+	if name == "" {
+		name = KEY_SERVICE_FILE
+	}
+	if name == KEY_SERVICE_FILE && opts.MasterKeyFile == "" {
+		log.Warningf("master key file not set, stored secrets will not be envelope encrypted")
+		return nil
+	}
+	factory, ok := keyServiceFactories[name]
+	if !ok {
+		return fmt.Errorf("unknown key service %q", name)
+	}
+	svc, err := factory(opts)
+	if err != nil {
+		return fmt.Errorf("init key service %s: %s", name, err)
+	}
+	SetKeyService(svc)
+	log.Infof("key service %s initialized, primary master key %s", name, svc.PrimaryKeyId())
+	return nil
+}
+
+func SetKeyService(svc IKeyService) {
+	keyServiceLock.Lock()
+	defer keyServiceLock.Unlock()
+	keyService = svc
+}
+
+func getKeyService() IKeyService {
+	keyServiceLock.RLock()
+	defer keyServiceLock.RUnlock()
+	return keyService
+}
+
+// Enabled returns whether a key service is configured
+func Enabled() bool {
+	return getKeyService() != nil
+}
+
+// IsEncrypted returns whether secret is in envelope encrypted form
+func IsEncrypted(secret string) bool {
+	return strings.HasPrefix(secret, envelopePrefix)
+}
+
+type envelope struct {
+	keyId      string
+	wrappedKey []byte
+	ciphertext []byte
+}
+
+func parseEnvelope(secret string) (*envelope, error) {
+	if !IsEncrypted(secret) {
+		return nil, fmt.Errorf("not an encrypted secret")
+	}
+	parts := strings.Split(secret[len(envelopePrefix):], envelopeSep)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed encrypted secret")
+	}
+	env := &envelope{keyId: parts[0]}
+	var err error
+	env.wrappedKey, err = base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("decode wrapped key: %s", err)
+	}
+	env.ciphertext, err = base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode ciphertext: %s", err)
+	}
+	return env, nil
+}
+
+func (env *envelope) String() string {
+	return envelopePrefix + strings.Join([]string{
+		env.keyId,
+		base64.StdEncoding.EncodeToString(env.wrappedKey),
+		base64.StdEncoding.EncodeToString(env.ciphertext),
+	}, envelopeSep)
+}
+
+// Encrypt returns envelope encrypted form of plain bound to record id.  When
+// no key service is configured, it falls back to the legacy AES encryption
+// keyed by id
+func Encrypt(id, plain string) (string, error) {
+	svc := getKeyService()
+	if svc == nil {
+		return utils.EncryptAESBase64(id, plain)
+	}
+	return encrypt(svc, id, plain)
+}
+
+func encrypt(svc IKeyService, id, plain string) (string, error) {
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	ciphertext, err := sealGCM(dataKey, []byte(plain), []byte(id))
+	if err != nil {
+		return "", err
+	}
+	keyId, wrappedKey, err := svc.WrapKey(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("wrap data key: %s", err)
+	}
+	env := &envelope{
+		keyId:      keyId,
+		wrappedKey: wrappedKey,
+		ciphertext: ciphertext,
+	}
+	return env.String(), nil
+}
+
+// Decrypt returns plain text of secret owned by record id.  Secrets not in
+// envelope form are decrypted with the legacy AES encryption keyed by id
+func Decrypt(id, secret string) (string, error) {
+	if !IsEncrypted(secret) {
+		return utils.DescryptAESBase64(id, secret)
+	}
+	svc := getKeyService()
+	if svc == nil {
+		return "", fmt.Errorf("key service not configured")
+	}
+	env, err := parseEnvelope(secret)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := svc.UnwrapKey(env.keyId, env.wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key: %s", err)
+	}
+	plain, err := openGCM(dataKey, env.ciphertext, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// NeedsReencrypt returns whether secret should be passed through Reencrypt,
+// i.e. it is in legacy form or its data key is not wrapped by the primary
+// master key
+func NeedsReencrypt(secret string) bool {
+	svc := getKeyService()
+	if svc == nil || secret == "" {
+		return false
+	}
+	if !IsEncrypted(secret) {
+		return true
+	}
+	env, err := parseEnvelope(secret)
+	if err != nil {
+		return false
+	}
+	return env.keyId != svc.PrimaryKeyId()
+}
+
+// Reencrypt converts legacy secret to envelope form, or re-wraps data key of
+// envelope encrypted secret with the primary master key.  Ciphertext of the
+// secret itself is left untouched in the latter case
+func Reencrypt(id, secret string) (string, error) {
+	svc := getKeyService()
+	if svc == nil {
+		return "", fmt.Errorf("key service not configured")
+	}
+	if !IsEncrypted(secret) {
+		plain, err := utils.DescryptAESBase64(id, secret)
+		if err != nil {
+			return "", err
+		}
+		return encrypt(svc, id, plain)
+	}
+	return rewrap(svc, secret)
+}
+
+// EncryptPlain converts plain text stored by older versions to envelope
+// form bound to record id.  It is for secrets which were not encrypted at all
+func EncryptPlain(id, plain string) (string, error) {
+	svc := getKeyService()
+	if svc == nil {
+		return "", fmt.Errorf("key service not configured")
+	}
+	if IsEncrypted(plain) {
+		return rewrap(svc, plain)
+	}
+	return encrypt(svc, id, plain)
+}
+
+func rewrap(svc IKeyService, secret string) (string, error) {
+	env, err := parseEnvelope(secret)
+	if err != nil {
+		return "", err
+	}
+	if env.keyId == svc.PrimaryKeyId() {
+		return secret, nil
+	}
+	dataKey, err := svc.UnwrapKey(env.keyId, env.wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key: %s", err)
+	}
+	env.keyId, env.wrappedKey, err = svc.WrapKey(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("wrap data key: %s", err)
+	}
+	return env.String(), nil
+}
+
+func sealGCM(key, plain, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, additionalData), nil
+}
+
+func openGCM(key, sealed, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed data too short")
+	}
+	nonce := sealed[:aead.NonceSize()]
+	return aead.Open(nil, nonce, sealed[aead.NonceSize():], additionalData)
+}
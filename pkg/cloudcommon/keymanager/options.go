@@ -0,0 +1,20 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymanager
+
+type SKeyManagerOptions struct {
+	KeyService    string `default:"file" help:"Key service protecting data keys of stored secrets, file or name of a registered KMS"`
+	MasterKeyFile string `help:"Path of master key file used by file key service, stored secrets are not envelope encrypted if not set"`
+}
@@ -29,6 +29,7 @@ import (
 	"yunion.io/x/structarg"
 
 	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/cloudcommon/etcd"
 	"yunion.io/x/onecloud/pkg/util/atexit"
 )
 
@@ -68,6 +69,11 @@ type CommonOptions struct {
 	RbacPolicySyncPeriodSeconds      int  `help:"policy sync interval in seconds, default 15 minutes" default:"900"`
 	RbacPolicySyncFailedRetrySeconds int  `help:"seconds to wait after a failed sync, default 30 seconds" default:"30"`
 
+	EnableRateLimit              bool    `help:"Switch on per user and project API rate limiting" default:"false"`
+	RateLimitRequestsPerSecond   float64 `help:"Requests per second allowed for each user, project and handler, default 20" default:"20"`
+	RateLimitBurst               int     `help:"Burst of requests allowed for each user, project and handler, default 100" default:"100"`
+	RateLimitSyncIntervalSeconds int     `help:"interval in seconds to refresh rate limits from yunionconf, default 5 minutes" default:"300"`
+
 	structarg.BaseOptions
 }
 
@@ -77,6 +83,14 @@ type DBOptions struct {
 
 	GlobalVirtualResourceNamespace bool `help:"Per project namespace or global namespace for virtual resources"`
 	DebugSqlchemy                  bool `default:"false" help:"Print SQL executed by sqlchemy"`
+
+	TaskSweepIntervalSeconds int `default:"60" help:"Interval in seconds to check task stage deadlines and orphaned tasks"`
+
+	IdempotencyKeyExpireHours int `default:"24" help:"Hours to keep responses of requests with an Idempotency-Key header for replay, 0 to disable"`
+
+	LockmanMethod string `default:"inmemory" choices:"inmemory|etcd|mysql" help:"Lock manager backend, etcd or mysql is required to run multiple replicas of a service against the same database"`
+
+	etcd.SEtcdOptions
 }
 
 func (this *DBOptions) GetDBConnection() (dialect, connstr string, err error) {
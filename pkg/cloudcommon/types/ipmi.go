@@ -27,6 +27,8 @@ type SIPMIInfo struct {
 	IpAddr     string `json:"ip_addr"`
 	Present    bool   `json:"present"`
 	LanChannel int    `json:"lan_channel"`
+	// BmcType selects the protocol talking to BMC, ipmi or redfish
+	BmcType string `json:"bmc_type"`
 }
 
 func (info SIPMIInfo) ToPrepareParams() jsonutils.JSONObject {
@@ -42,5 +44,8 @@ func (info SIPMIInfo) ToPrepareParams() jsonutils.JSONObject {
 	}
 	data.Add(jsonutils.NewBool(info.Present), "ipmi_present")
 	data.Add(jsonutils.NewInt(int64(info.LanChannel)), "ipmi_lan_channel")
+	if info.BmcType != "" {
+		data.Add(jsonutils.NewString(info.BmcType), "ipmi_bmc_type")
+	}
 	return data
 }
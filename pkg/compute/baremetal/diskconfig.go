@@ -158,7 +158,7 @@ func MeetConfig(
 		return fmt.Errorf("%v more than 1 storages drivers", storageDrvs)
 	}
 	driver := storageDrvs.List()[0]
-	if conf.Conf != DISK_CONF_NONE && !DISK_DRIVERS_RAID.Has(driver) {
+	if conf.Conf != DISK_CONF_NONE && !DISK_DRIVERS_RAID.Has(driver) && !DISK_DRIVERS_SOFT_RAID.Has(driver) {
 		return fmt.Errorf("BaremetalStorage driver %s not support RAID", driver)
 	}
 
@@ -178,6 +178,10 @@ func MeetConfig(
 		return fmt.Errorf("Cannot divide a normal disk into splits")
 	}
 
+	if len(conf.Splits) > 0 && DISK_DRIVERS_SOFT_RAID.Has(driver) {
+		return fmt.Errorf("Cannot divide a software raid of %s disks into splits", driver)
+	}
+
 	if driver == DISK_DRIVER_MPT2SAS {
 		if conf.Conf == DISK_CONF_RAID5 {
 			return fmt.Errorf("%q not support RAID5", DISK_DRIVER_MPT2SAS)
@@ -539,6 +543,8 @@ type DiskConfiguration struct {
 	Adapter int
 	Block   int64
 	Size    int64
+	// Conf is raid config of the disk, DISK_CONF_NONE for a raw disk
+	Conf string
 }
 
 func GetDiskConfigurations(layouts []Layout) []DiskConfiguration {
@@ -550,15 +556,15 @@ func GetDiskConfigurations(layouts []Layout) []DiskConfiguration {
 		raidConf := rr.Conf.Conf
 		if raidConf == DISK_CONF_NONE {
 			for _, d := range rr.Disks {
-				disks = append(disks, DiskConfiguration{Driver: driver, Adapter: adapter, Block: block, Size: d.Size})
+				disks = append(disks, DiskConfiguration{Driver: driver, Adapter: adapter, Block: block, Size: d.Size, Conf: raidConf})
 			}
 		} else {
 			if len(rr.Conf.Size) != 0 {
 				for _, sz := range rr.Conf.Size {
-					disks = append(disks, DiskConfiguration{Driver: driver, Adapter: adapter, Block: block, Size: sz})
+					disks = append(disks, DiskConfiguration{Driver: driver, Adapter: adapter, Block: block, Size: sz, Conf: raidConf})
 				}
 			} else {
-				disks = append(disks, DiskConfiguration{Driver: driver, Adapter: adapter, Block: block, Size: rr.Size})
+				disks = append(disks, DiskConfiguration{Driver: driver, Adapter: adapter, Block: block, Size: rr.Size, Conf: raidConf})
 			}
 		}
 	}
@@ -55,6 +55,8 @@ var (
 
 	DISK_DRIVERS_RAID = api.DISK_DRIVERS_RAID
 
+	DISK_DRIVERS_SOFT_RAID = api.DISK_DRIVERS_SOFT_RAID
+
 	DISK_DRIVERS = api.DISK_DRIVERS
 )
 
@@ -343,6 +343,9 @@ func (self *SBaremetalGuestDriver) ValidateCreateDataOnHost(ctx context.Context,
 	if host.GetBaremetalServer() != nil {
 		return nil, httperrors.NewInsufficientResourceError("Baremetal %s is occupied", bmName)
 	}
+	if err := host.CheckDiskWiped(userCred); err != nil {
+		return nil, err
+	}
 	input.VmemSize = host.MemSize
 	input.VcpuCount = int(host.CpuCount)
 	return input, nil
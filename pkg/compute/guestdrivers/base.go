@@ -203,6 +203,10 @@ func (self *SBaseGuestDriver) RequestSyncToBackup(ctx context.Context, guest *mo
 	return fmt.Errorf("Not Implement")
 }
 
+func (self *SBaseGuestDriver) RequestDiskMigrateStorage(ctx context.Context, guest *models.SGuest, disk *models.SDisk, storage *models.SStorage, format string, task taskman.ITask) error {
+	return fmt.Errorf("Not Implement")
+}
+
 func (self *SBaseGuestDriver) GetMaxSecurityGroupCount() int {
 	return 5
 }
@@ -400,6 +400,18 @@ func (self *SKVMGuestDriver) RequestSyncToBackup(ctx context.Context, guest *mod
 	return nil
 }
 
+func (self *SKVMGuestDriver) RequestDiskMigrateStorage(ctx context.Context, guest *models.SGuest, disk *models.SDisk, storage *models.SStorage, format string, task taskman.ITask) error {
+	host := guest.GetHost()
+	body := jsonutils.NewDict()
+	body.Set("disk_id", jsonutils.NewString(disk.Id))
+	body.Set("target_storage_id", jsonutils.NewString(storage.Id))
+	body.Set("format", jsonutils.NewString(format))
+	url := fmt.Sprintf("%s/servers/%s/storage-migrate", host.ManagerUri, guest.Id)
+	header := self.getTaskRequestHeader(task)
+	_, _, err := httputils.JSONRequest(httputils.GetDefaultClient(), ctx, "POST", url, header, body, false)
+	return err
+}
+
 // kvm guest must add cpu first
 // if body has add_cpu_failed indicate dosen't exec add mem
 // 1. cpu added part of request --> add_cpu_failed: true && added_cpu: count
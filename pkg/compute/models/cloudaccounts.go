@@ -28,13 +28,13 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/tristate"
 	"yunion.io/x/pkg/util/timeutils"
-	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
 	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/keymanager"
 	"yunion.io/x/onecloud/pkg/cloudprovider"
 	"yunion.io/x/onecloud/pkg/compute/options"
 	"yunion.io/x/onecloud/pkg/httperrors"
@@ -67,8 +67,8 @@ type SCloudaccount struct {
 
 	AccessUrl string `width:"64" charset:"ascii" nullable:"true" list:"admin" update:"admin" create:"admin_optional"`
 
-	Account string `width:"128" charset:"ascii" nullable:"false" list:"admin" create:"admin_required"` // Column(VARCHAR(64, charset='ascii'), nullable=False)
-	Secret  string `width:"256" charset:"ascii" nullable:"false" list:"admin" create:"admin_required"` // Column(VARCHAR(256, charset='ascii'), nullable=False)
+	Account string `width:"128" charset:"ascii" nullable:"false" list:"admin" create:"admin_required"`  // Column(VARCHAR(64, charset='ascii'), nullable=False)
+	Secret  string `width:"1024" charset:"ascii" nullable:"false" list:"admin" create:"admin_required"` // Column(VARCHAR(256, charset='ascii'), nullable=False)
 
 	// BalanceKey string `width:"256" charset:"ascii" nullable:"true" list:"admin" update:"admin" create:"admin_optional"`
 
@@ -306,7 +306,7 @@ func (self *SCloudaccount) PostCreate(ctx context.Context, userCred mcclient.Tok
 }
 
 func (self *SCloudaccount) savePassword(secret string) error {
-	sec, err := utils.EncryptAESBase64(self.Id, secret)
+	sec, err := keymanager.Encrypt(self.Id, secret)
 	if err != nil {
 		return err
 	}
@@ -319,7 +319,7 @@ func (self *SCloudaccount) savePassword(secret string) error {
 }
 
 func (self *SCloudaccount) getPassword() (string, error) {
-	return utils.DescryptAESBase64(self.Id, self.Secret)
+	return keymanager.Decrypt(self.Id, self.Secret)
 }
 
 func (self *SCloudaccount) AllowPerformSync(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
@@ -23,6 +23,7 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudprovider"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/pkg/util/compare"
@@ -285,6 +286,7 @@ func (set SSyncResultSet) Add(manager db.IModelManager, result compare.SyncResul
 
 func (self *SCloudproviderregion) DoSync(ctx context.Context, userCred mcclient.TokenCredential, syncRange SSyncRange) error {
 	syncResults := SSyncResultSet{}
+	start := time.Now()
 
 	self.markSyncing(userCred)
 	defer self.markEndSync(ctx, userCred, syncResults, &syncRange.DeepSync)
@@ -305,9 +307,13 @@ func (self *SCloudproviderregion) DoSync(ctx context.Context, userCred mcclient.
 		}
 	}
 	log.Debugf("no need to do deep sync ... %v", syncRange.DeepSync)
+	defer func() {
+		observeCloudSync(provider.Provider, syncRange.DeepSync, err, time.Since(start))
+	}()
 
 	if localRegion.isManaged() {
-		remoteRegion, err := driver.GetIRegionById(localRegion.ExternalId)
+		var remoteRegion cloudprovider.ICloudRegion
+		remoteRegion, err = driver.GetIRegionById(localRegion.ExternalId)
 		if err == nil {
 			err = syncPublicCloudProviderInfo(ctx, userCred, syncResults, provider, driver, localRegion, remoteRegion, &syncRange)
 		}
@@ -737,6 +737,102 @@ func (self *SDisk) PerformResize(ctx context.Context, userCred mcclient.TokenCre
 	return nil, self.StartDiskResizeTask(ctx, userCred, int64(sizeMb), "", &pendingUsage, guest)
 }
 
+func (self *SDisk) AllowPerformMigrateStorage(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, self, "migrate-storage")
+}
+
+func (self *SDisk) PerformMigrateStorage(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	storageStr := jsonutils.GetAnyString(data, []string{"target_storage", "target_storage_id"})
+	if len(storageStr) == 0 {
+		return nil, httperrors.NewMissingParameterError("target_storage")
+	}
+	storageObj, err := StorageManager.FetchByIdOrName(userCred, storageStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, httperrors.NewResourceNotFoundError2(StorageManager.Keyword(), storageStr)
+		}
+		return nil, httperrors.NewGeneralError(err)
+	}
+	storage := storageObj.(*SStorage)
+	guest, err := self.ValidateMigrateStorage(storage)
+	if err != nil {
+		return nil, err
+	}
+	return nil, self.StartDiskMigrateStorageTask(ctx, userCred, guest, storage, "")
+}
+
+// ValidateMigrateStorage checks that the disk can be live mirrored to the
+// target storage and returns the running guest the disk is attached to.
+func (self *SDisk) ValidateMigrateStorage(storage *SStorage) (*SGuest, error) {
+	if self.Status != api.DISK_READY {
+		return nil, httperrors.NewInvalidStatusError("Cannot migrate storage of disk in status %s", self.Status)
+	}
+	if self.StorageId == storage.Id {
+		return nil, httperrors.NewInputParameterError("Disk is already on storage %s", storage.Name)
+	}
+	if len(self.BackupStorageId) > 0 {
+		return nil, httperrors.NewUnsupportOperationError("Cannot migrate storage of disk with backup")
+	}
+	guests := self.GetGuests()
+	if len(guests) != 1 {
+		return nil, httperrors.NewUnsupportOperationError("Only disk attached to exactly one guest can be migrated")
+	}
+	guest := &guests[0]
+	if guest.Hypervisor != api.HYPERVISOR_KVM {
+		return nil, httperrors.NewUnsupportOperationError("Cannot migrate storage of %s guest", guest.Hypervisor)
+	}
+	if guest.Status != api.VM_RUNNING {
+		return nil, httperrors.NewInvalidStatusError("Cannot migrate storage while guest status %s", guest.Status)
+	}
+	if cnt, err := self.GetSnapshotCount(); err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	} else if cnt > 0 {
+		return nil, httperrors.NewUnsupportOperationError("Cannot migrate storage of disk with %d snapshots", cnt)
+	}
+	if !storage.Enabled {
+		return nil, httperrors.NewInvalidStatusError("Target storage %s is disabled", storage.Name)
+	}
+	if !utils.IsInStringArray(storage.Status, []string{api.STORAGE_ENABLED, api.STORAGE_ONLINE}) {
+		return nil, httperrors.NewInvalidStatusError("Target storage %s is not online", storage.Name)
+	}
+	if !utils.IsInStringArray(storage.StorageType, api.STORAGE_LIMITED_TYPES) || storage.StorageType == api.STORAGE_BAREMETAL {
+		return nil, httperrors.NewUnsupportOperationError("Cannot migrate disk to %s storage", storage.StorageType)
+	}
+	host := guest.GetHost()
+	if host == nil || host.GetHoststorageOfId(storage.Id) == nil {
+		return nil, httperrors.NewInputParameterError("Target storage %s is not attached to host of guest %s", storage.Name, guest.Name)
+	}
+	if self.DiskSize > storage.GetFreeCapacity() && !storage.IsEmulated {
+		return nil, httperrors.NewOutOfResourceError("Not enough free space on storage %s", storage.Name)
+	}
+	return guest, nil
+}
+
+// GetMigrateStorageFormat returns the image format used for the disk once it
+// lives on the given storage; block backed storages only hold raw images.
+func (self *SDisk) GetMigrateStorageFormat(storage *SStorage) string {
+	if utils.IsInStringArray(storage.StorageType, []string{api.STORAGE_RBD, api.STORAGE_CLVM}) {
+		return "raw"
+	}
+	return "qcow2"
+}
+
+func (self *SDisk) StartDiskMigrateStorageTask(ctx context.Context, userCred mcclient.TokenCredential, guest *SGuest, storage *SStorage, parentTaskId string) error {
+	params := jsonutils.NewDict()
+	params.Set("guest_id", jsonutils.NewString(guest.Id))
+	params.Set("source_storage_id", jsonutils.NewString(self.StorageId))
+	params.Set("target_storage_id", jsonutils.NewString(storage.Id))
+	params.Set("format", jsonutils.NewString(self.GetMigrateStorageFormat(storage)))
+	self.SetStatus(userCred, api.DISK_START_MIGRATE_STORAGE, "")
+	guest.SetStatus(userCred, api.VM_MIGRATE_STORAGE, "")
+	task, err := taskman.TaskManager.NewTask(ctx, "DiskMigrateStorageTask", self, userCred, params, parentTaskId, "", nil)
+	if err != nil {
+		return err
+	}
+	task.ScheduleRun(nil)
+	return nil
+}
+
 func (self *SDisk) GetIStorage() (cloudprovider.ICloudStorage, error) {
 	storage := self.GetStorage()
 	if storage == nil {
@@ -14,7 +14,19 @@
 
 package models
 
-import "yunion.io/x/onecloud/pkg/cloudcommon/db"
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
 
 const (
 	REDIS_TYPE = "REDIS"
@@ -48,6 +60,148 @@ type SGroup struct {
 	ZoneId string `width:"36" charset:"ascii" nullable:"true" list:"user" update:"user" create:"required"` // Column(VARCHAR(36, charset='ascii'), nullable=True)
 
 	SchedStrategy string `width:"16" charset:"ascii" nullable:"true" default:"" list:"user" update:"user" create:"optional"` // Column(VARCHAR(16, charset='ascii'), nullable=True, default='')
+
+	// TopologyKey and MaxSkew spread guests of the group over racks, zones or
+	// domains of schedtag, SchedStrategy tells whether spreading is required
+	// or preferred
+	TopologyKey string `width:"64" charset:"ascii" nullable:"true" list:"user" update:"user" create:"optional"`
+	MaxSkew     int    `nullable:"false" default:"1" list:"user" update:"user" create:"optional"`
+}
+
+func validateTopologySpread(strategy string, topologyKey string, maxSkew int64) error {
+	if len(strategy) > 0 && !utils.IsInStringArray(strategy, []string{api.STRATEGY_REQUIRE, api.STRATEGY_PREFER}) {
+		return httperrors.NewInputParameterError("invalid sched_strategy %s, expect %s or %s", strategy, api.STRATEGY_REQUIRE, api.STRATEGY_PREFER)
+	}
+	if maxSkew < 1 {
+		return httperrors.NewInputParameterError("max_skew must be greater than 0")
+	}
+	switch {
+	case topologyKey == api.TOPOLOGY_KEY_RACK, topologyKey == api.TOPOLOGY_KEY_ZONE:
+		return nil
+	case strings.HasPrefix(topologyKey, api.TOPOLOGY_KEY_SCHEDTAG_PREFIX) && len(topologyKey) > len(api.TOPOLOGY_KEY_SCHEDTAG_PREFIX):
+		return nil
+	}
+	return httperrors.NewInputParameterError("invalid topology_key %s, expect %s, %s or %s<key>",
+		topologyKey, api.TOPOLOGY_KEY_RACK, api.TOPOLOGY_KEY_ZONE, api.TOPOLOGY_KEY_SCHEDTAG_PREFIX)
+}
+
+func validateGroupTopologySpread(data *jsonutils.JSONDict, group *SGroup) error {
+	strategy, topologyKey, maxSkew := "", "", int64(1)
+	if group != nil {
+		strategy, topologyKey, maxSkew = group.SchedStrategy, group.TopologyKey, int64(group.MaxSkew)
+	}
+	if data.Contains("sched_strategy") {
+		strategy, _ = data.GetString("sched_strategy")
+	}
+	if data.Contains("topology_key") {
+		topologyKey, _ = data.GetString("topology_key")
+	}
+	if data.Contains("max_skew") {
+		maxSkew, _ = data.Int("max_skew")
+	}
+	if len(topologyKey) == 0 {
+		return nil
+	}
+	return validateTopologySpread(strategy, topologyKey, maxSkew)
+}
+
+func (manager *SGroupManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerProjId string, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	if err := validateGroupTopologySpread(data, nil); err != nil {
+		return nil, err
+	}
+	return manager.SVirtualResourceBaseManager.ValidateCreateData(ctx, userCred, ownerProjId, query, data)
+}
+
+func (group *SGroup) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	if err := validateGroupTopologySpread(data, group); err != nil {
+		return nil, err
+	}
+	return group.SVirtualResourceBase.ValidateUpdateData(ctx, userCred, query, data)
+}
+
+// GetTopologySpread returns nil if guests of the group are not required to spread
+func (group *SGroup) GetTopologySpread() *api.TopologySpreadConfig {
+	if len(group.TopologyKey) == 0 {
+		return nil
+	}
+	strategy := group.SchedStrategy
+	if len(strategy) == 0 {
+		strategy = api.STRATEGY_REQUIRE
+	}
+	return &api.TopologySpreadConfig{
+		GroupId:     group.Id,
+		TopologyKey: group.TopologyKey,
+		MaxSkew:     group.MaxSkew,
+		Strategy:    strategy,
+	}
+}
+
+// validateServerGroups resolves groups the server joins and topology spreads
+// given by request, spreads configured on groups are appended
+func (manager *SGroupManager) validateServerGroups(userCred mcclient.TokenCredential, ownerProjId string, input *api.ServerCreateInput) error {
+	fetchGroup := func(groupId string) (*SGroup, error) {
+		obj, err := manager.FetchByIdOrName(userCred, groupId)
+		if err != nil {
+			return nil, httperrors.NewResourceNotFoundError("group %s not found", groupId)
+		}
+		group := obj.(*SGroup)
+		if group.ProjectId != ownerProjId {
+			return nil, httperrors.NewForbiddenError("group %s not belong to project %s", group.Name, ownerProjId)
+		}
+		return group, nil
+	}
+
+	for _, spread := range input.TopologySpreads {
+		group, err := fetchGroup(spread.GroupId)
+		if err != nil {
+			return err
+		}
+		spread.GroupId = group.Id
+		if spread.MaxSkew == 0 {
+			spread.MaxSkew = 1
+		}
+		if len(spread.Strategy) == 0 {
+			spread.Strategy = api.STRATEGY_REQUIRE
+		}
+		err = validateTopologySpread(spread.Strategy, spread.TopologyKey, int64(spread.MaxSkew))
+		if err != nil {
+			return err
+		}
+	}
+
+	hasSpread := func(groupId string) bool {
+		for _, spread := range input.TopologySpreads {
+			if spread.GroupId == groupId {
+				return true
+			}
+		}
+		return false
+	}
+	for i, groupId := range input.Groups {
+		group, err := fetchGroup(groupId)
+		if err != nil {
+			return err
+		}
+		input.Groups[i] = group.Id
+		// spread given by request overrides the one of group
+		if spread := group.GetTopologySpread(); spread != nil && !hasSpread(group.Id) {
+			input.TopologySpreads = append(input.TopologySpreads, spread)
+		}
+	}
+	return nil
+}
+
+func (group *SGroup) addGuest(ctx context.Context, userCred mcclient.TokenCredential, guest *SGuest) error {
+	groupguest := &SGroupguest{}
+	groupguest.SetModelManager(GroupguestManager)
+	groupguest.SrvtagId = group.Id
+	groupguest.GuestId = guest.Id
+	err := GroupguestManager.TableSpec().Insert(groupguest)
+	if err != nil {
+		return fmt.Errorf("insert groupguest: %v", err)
+	}
+	db.OpsLog.LogAttachEvent(ctx, group, guest, userCred, nil)
+	return nil
 }
 
 func (group *SGroup) GetNetworks() ([]SGroupnetwork, error) {
@@ -323,6 +323,19 @@ func (self *SGuest) StartGuestLiveMigrateTask(ctx context.Context, userCred mccl
 	return nil
 }
 
+// StartGuestMigrateStorageTask moves the given disks, an array of disk_id
+// and target_storage_id, of the running guest one after another
+func (self *SGuest) StartGuestMigrateStorageTask(ctx context.Context, userCred mcclient.TokenCredential, disks *jsonutils.JSONArray, parentTaskId string) error {
+	data := jsonutils.NewDict()
+	data.Set("disks", disks)
+	task, err := taskman.TaskManager.NewTask(ctx, "GuestMigrateStorageTask", self, userCred, data, parentTaskId, "", nil)
+	if err != nil {
+		return err
+	}
+	task.ScheduleRun(nil)
+	return nil
+}
+
 func (self *SGuest) AllowPerformClone(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
 	return self.IsOwner(userCred) || db.IsAdminAllowPerform(userCred, self, "clone")
 }
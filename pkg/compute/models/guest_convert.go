@@ -0,0 +1,254 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+func (self *SGuest) AllowPerformConvertToKvm(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, self, "convert-to-kvm")
+}
+
+// PerformConvertToKvm converts a stopped esxi guest into a kvm guest on
+// prefer_host, disks are exported from datastores and converted onto storage
+// of the host, nics and ips of the guest are kept
+func (self *SGuest) PerformConvertToKvm(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if self.GetHypervisor() != api.HYPERVISOR_ESXI {
+		return nil, httperrors.NewNotAcceptableError("Not allow for hypervisor %s", self.GetHypervisor())
+	}
+	if self.Status != api.VM_READY {
+		return nil, httperrors.NewServerStatusError("Cannot convert guest in status %s", self.Status)
+	}
+	preferHost, _ := data.GetString("prefer_host")
+	if len(preferHost) == 0 {
+		return nil, httperrors.NewMissingParameterError("prefer_host")
+	}
+	iHost, _ := HostManager.FetchByIdOrName(userCred, preferHost)
+	if iHost == nil {
+		return nil, httperrors.NewResourceNotFoundError("Host %s not found", preferHost)
+	}
+	host := iHost.(*SHost)
+	if host.HostType != api.HOST_TYPE_HYPERVISOR || !host.Enabled || host.HostStatus != api.HOST_ONLINE {
+		return nil, httperrors.NewInvalidStatusError("Host %s is not an enabled and online kvm host", host.Name)
+	}
+	storage, err := self.getConvertTargetStorage(userCred, host, data)
+	if err != nil {
+		return nil, err
+	}
+	guestnetworks, err := self.GetNetworks("")
+	if err != nil {
+		return nil, httperrors.NewInternalServerError("fetch guest networks: %v", err)
+	}
+	for _, gn := range guestnetworks {
+		network := gn.GetNetwork()
+		if len(host.getHostwiresOfId(network.WireId)) == 0 {
+			return nil, httperrors.NewInputParameterError("Host %s is not attached to wire of network %s", host.Name, network.Name)
+		}
+	}
+
+	params := jsonutils.NewDict()
+	params.Set("prefer_host_id", jsonutils.NewString(host.Id))
+	params.Set("target_storage_id", jsonutils.NewString(storage.Id))
+	if jsonutils.QueryBoolean(data, "delete_source", false) {
+		params.Set("delete_source", jsonutils.JSONTrue)
+	}
+	return nil, self.StartConvertEsxiToKvmTask(ctx, userCred, params, "")
+}
+
+// getConvertTargetStorage returns storage of host given by data, or the
+// online one with most free capacity.  Only file based storages are
+// supported as disks are converted by qemu-img on the host
+func (self *SGuest) getConvertTargetStorage(userCred mcclient.TokenCredential, host *SHost, data jsonutils.JSONObject) (*SStorage, error) {
+	sizeMb := 0
+	for _, gd := range self.GetDisks() {
+		sizeMb += gd.GetDisk().DiskSize
+	}
+	storageTypes := []string{api.STORAGE_LOCAL, api.STORAGE_NFS}
+	var target *SStorage
+	if storageStr, _ := data.GetString("storage"); len(storageStr) > 0 {
+		iStorage, _ := StorageManager.FetchByIdOrName(userCred, storageStr)
+		if iStorage == nil {
+			return nil, httperrors.NewResourceNotFoundError("Storage %s not found", storageStr)
+		}
+		target = iStorage.(*SStorage)
+		if host.GetHoststorageOfId(target.Id) == nil {
+			return nil, httperrors.NewInputParameterError("Storage %s is not attached to host %s", target.Name, host.Name)
+		}
+		if !utils.IsInStringArray(target.StorageType, storageTypes) {
+			return nil, httperrors.NewInputParameterError("Not support converting onto %s storage", target.StorageType)
+		}
+	} else {
+		storages := host.GetAttachedStorages("")
+		for i := range storages {
+			if !storages[i].Enabled || storages[i].Status != api.STORAGE_ONLINE ||
+				!utils.IsInStringArray(storages[i].StorageType, storageTypes) {
+				continue
+			}
+			if target == nil || storages[i].GetFreeCapacity() > target.GetFreeCapacity() {
+				target = &storages[i]
+			}
+		}
+		if target == nil {
+			return nil, httperrors.NewInsufficientResourceError("Host %s has no available storage", host.Name)
+		}
+	}
+	if target.GetFreeCapacity() < sizeMb {
+		return nil, httperrors.NewInsufficientResourceError("Storage %s has %dMB free, %dMB required", target.Name, target.GetFreeCapacity(), sizeMb)
+	}
+	return target, nil
+}
+
+func (self *SGuest) StartConvertEsxiToKvmTask(ctx context.Context, userCred mcclient.TokenCredential, params *jsonutils.JSONDict, parentTaskId string) error {
+	task, err := taskman.TaskManager.NewTask(ctx, "GuestConvertEsxiToKvmTask", self, userCred, params, parentTaskId, "", nil)
+	if err != nil {
+		log.Errorln(err)
+		return err
+	}
+	self.SetStatus(userCred, api.VM_CONVERTING, "")
+	task.ScheduleRun(nil)
+	return nil
+}
+
+// GetEsxiConvertConfig returns config for the kvm host to export disks of
+// the guest onto storage, vcenter password is encrypted with id of the guest
+func (self *SGuest) GetEsxiConvertConfig(storage *SStorage) (*api.SEsxiConvertConfig, error) {
+	account := self.GetHost().GetCloudaccount()
+	if account == nil {
+		return nil, fmt.Errorf("no cloudaccount of guest %s", self.Name)
+	}
+	host, port, err := account.getHostPort()
+	if err != nil {
+		return nil, err
+	}
+	passwd, err := account.getPassword()
+	if err != nil {
+		return nil, err
+	}
+	passwd, err = utils.EncryptAESBase64(self.Id, passwd)
+	if err != nil {
+		return nil, err
+	}
+	config := &api.SEsxiConvertConfig{
+		VCenter: api.SEsxiAccessInfo{
+			Host:     host,
+			Port:     port,
+			Account:  account.Account,
+			Password: passwd,
+		},
+		Disks: make([]api.SEsxiConvertDisk, 0),
+	}
+	for _, gd := range self.GetDisks() {
+		disk := gd.GetDisk()
+		config.Disks = append(config.Disks, api.SEsxiConvertDisk{
+			DiskId:          disk.Id,
+			DatastoreId:     disk.GetStorage().ExternalId,
+			Path:            disk.AccessPath,
+			TargetStorageId: storage.Id,
+		})
+	}
+	return config, nil
+}
+
+// SwitchToKvm moves records of a converted esxi guest onto the kvm host and
+// storage, disksPath maps disk id to path of the converted disk on host.
+// Guest is switched to emulated devices if the host was unable to inject
+// virtio drivers.  External id of the source vm is kept as a tombstone, so
+// the vm left in vcenter is not synced back as another guest
+func (self *SGuest) SwitchToKvm(ctx context.Context, userCred mcclient.TokenCredential, host *SHost, storage *SStorage, disksPath map[string]string, virtio bool) error {
+	diskDriver, nicDriver := "virtio", "virtio"
+	if !virtio {
+		diskDriver, nicDriver = "ide", "e1000"
+	}
+	if len(self.ExternalId) > 0 {
+		err := self.SetMetadata(ctx, api.VM_METADATA_CONVERTED_FROM, self.ExternalId, userCred)
+		if err != nil {
+			return err
+		}
+	}
+	guestdisks := self.GetDisks()
+	for i := range guestdisks {
+		disk := guestdisks[i].GetDisk()
+		path, ok := disksPath[disk.Id]
+		if !ok {
+			return fmt.Errorf("missing path of converted disk %s", disk.Id)
+		}
+		_, err := db.Update(disk, func() error {
+			disk.StorageId = storage.Id
+			disk.DiskFormat = "qcow2"
+			disk.AccessPath = path
+			disk.ExternalId = ""
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		_, err = db.Update(&guestdisks[i], func() error {
+			guestdisks[i].Driver = diskDriver
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	guestnetworks, err := self.GetNetworks("")
+	if err != nil {
+		return err
+	}
+	for i := range guestnetworks {
+		_, err := db.Update(&guestnetworks[i], func() error {
+			guestnetworks[i].Driver = nicDriver
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = db.Update(self, func() error {
+		self.Hypervisor = api.HYPERVISOR_KVM
+		self.HostId = host.Id
+		self.ExternalId = ""
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	db.OpsLog.LogEvent(self, db.ACT_GUEST_CONVERT_TO_KVM, fmt.Sprintf("host %s storage %s disk driver %s", host.Name, storage.Name, diskDriver), userCred)
+	return nil
+}
+
+// IsConvertedSourceVM returns whether vm of externalId is the source of a
+// guest converted to kvm
+func (manager *SGuestManager) IsConvertedSourceVM(externalId string) bool {
+	q := db.Metadata.Query().Equals("key", api.VM_METADATA_CONVERTED_FROM).Equals("value", externalId)
+	q = q.Startswith("id", manager.Keyword()+"::")
+	cnt, err := q.CountWithError()
+	if err != nil {
+		log.Errorf("query converted source vm %s: %v", externalId, err)
+		return false
+	}
+	return cnt > 0
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	imageapi "yunion.io/x/onecloud/pkg/apis/image"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+)
+
+// fillServerInputByGuestImage expands guest image imported from OVA into
+// disks of server, vcpu, memory, os type and nic drivers not given by user
+// are taken from hardware hints of the guest image
+func fillServerInputByGuestImage(ctx context.Context, userCred mcclient.TokenCredential, input *api.ServerCreateInput) error {
+	s := auth.GetSession(ctx, userCred, options.Options.Region, "")
+	guestImage, err := modules.GuestImages.Get(s, input.GuestImageId, nil)
+	if err != nil {
+		return httperrors.NewResourceNotFoundError("guest image %s: %s", input.GuestImageId, err)
+	}
+	status, _ := guestImage.GetString("status")
+	if status != imageapi.IMAGE_STATUS_ACTIVE {
+		return httperrors.NewInvalidStatusError("guest image %s is %s", input.GuestImageId, status)
+	}
+	disks, _ := guestImage.GetArray("disks")
+	if len(disks) == 0 {
+		return httperrors.NewInputParameterError("guest image %s has no disk", input.GuestImageId)
+	}
+
+	for i, disk := range disks {
+		imageId, _ := disk.GetString("image_id")
+		if i >= len(input.Disks) {
+			input.Disks = append(input.Disks, &api.DiskConfig{Index: i})
+		}
+		if len(input.Disks[i].ImageId) > 0 || len(input.Disks[i].SnapshotId) > 0 {
+			return httperrors.NewInputParameterError("disk %d is provided by guest image %s", i, input.GuestImageId)
+		}
+		input.Disks[i].ImageId = imageId
+	}
+
+	if input.VcpuCount == 0 {
+		vcpuCount, _ := guestImage.Int("vcpu_count")
+		input.VcpuCount = int(vcpuCount)
+	}
+	if input.VmemSize == 0 {
+		vmemSize, _ := guestImage.Int("vmem_size_mb")
+		input.VmemSize = int(vmemSize)
+	}
+	if len(input.OsType) == 0 {
+		input.OsType, _ = guestImage.GetString("os_type")
+	}
+
+	nics := make([]struct {
+		Driver string
+	}, 0)
+	if nicsJson, _ := guestImage.Get("nics"); nicsJson != nil {
+		if err := nicsJson.Unmarshal(&nics); err != nil {
+			log.Warningf("invalid nics hint of guest image %s: %s", input.GuestImageId, err)
+		}
+	}
+	for i := 0; i < len(nics) && i < len(input.Networks); i++ {
+		if len(input.Networks[i].Driver) == 0 {
+			input.Networks[i].Driver = nics[i].Driver
+		}
+	}
+	return nil
+}
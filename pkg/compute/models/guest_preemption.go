@@ -0,0 +1,111 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	schedapi "yunion.io/x/onecloud/pkg/apis/scheduler"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+func validateServerPriorityClass(userCred mcclient.TokenCredential, input *api.ServerCreateInput) error {
+	if len(input.PriorityClass) == 0 {
+		input.PriorityClass = api.GUEST_PRIORITY_CLASS_NORMAL
+	}
+	if _, ok := api.GUEST_PRIORITY_CLASSES[input.PriorityClass]; !ok {
+		return httperrors.NewInputParameterError("invalid priority_class %q", input.PriorityClass)
+	}
+	if len(input.PreemptionPolicy) == 0 {
+		input.PreemptionPolicy = api.PREEMPTION_POLICY_NEVER
+	}
+	if !utils.IsInStringArray(input.PreemptionPolicy, api.PREEMPTION_POLICIES) {
+		return httperrors.NewInputParameterError("invalid preemption_policy %q", input.PreemptionPolicy)
+	}
+	isAdmin := db.IsAdminAllowCreate(userCred, GuestManager)
+	// priority above normal and preemption affect servers of other projects
+	if !isAdmin && api.GuestPriority(input.PriorityClass) > api.GuestPriority(api.GUEST_PRIORITY_CLASS_NORMAL) {
+		return httperrors.NewForbiddenError("priority_class %s requires admin privilege", input.PriorityClass)
+	}
+	if !isAdmin && input.PreemptionPolicy != api.PREEMPTION_POLICY_NEVER {
+		return httperrors.NewForbiddenError("preemption_policy %s requires admin privilege", input.PreemptionPolicy)
+	}
+	return nil
+}
+
+func (self *SGuest) validatePriorityClassUpdate(userCred mcclient.TokenCredential, priorityClass string) error {
+	if _, ok := api.GUEST_PRIORITY_CLASSES[priorityClass]; !ok {
+		return httperrors.NewInputParameterError("invalid priority_class %q", priorityClass)
+	}
+	if api.GuestPriority(priorityClass) > api.GuestPriority(self.PriorityClass) &&
+		api.GuestPriority(priorityClass) > api.GuestPriority(api.GUEST_PRIORITY_CLASS_NORMAL) &&
+		!db.IsAdminAllowUpdate(userCred, self) {
+		return httperrors.NewForbiddenError("priority_class %s requires admin privilege", priorityClass)
+	}
+	return nil
+}
+
+// fetchPreemptionVictims returns victims of plan which are still preemptible,
+// i.e. running on planned host with priority lower than preemptor
+func (manager *SGuestManager) fetchPreemptionVictims(plan *schedapi.PreemptionPlan, priorityClass string) ([]*SGuest, error) {
+	victims := make([]*SGuest, 0, len(plan.Victims))
+	for _, victim := range plan.Victims {
+		guest := manager.FetchGuestById(victim.GuestId)
+		if guest == nil {
+			return nil, fmt.Errorf("preemption victim %s(%s) not found", victim.Name, victim.GuestId)
+		}
+		if guest.HostId != plan.HostId || guest.Status != api.VM_RUNNING {
+			return nil, fmt.Errorf("preemption victim %s is %s on host %s", guest.Name, guest.Status, guest.HostId)
+		}
+		if api.GuestPriority(guest.PriorityClass) >= api.GuestPriority(priorityClass) {
+			return nil, fmt.Errorf("preemption victim %s priority class %s is not lower than %s", guest.Name, guest.PriorityClass, priorityClass)
+		}
+		victims = append(victims, guest)
+	}
+	return victims, nil
+}
+
+// StartGuestPreemptTask stops victims of preemption plan one by one, so that
+// servers with higher priority class could be scheduled to the host
+func (manager *SGuestManager) StartGuestPreemptTask(
+	ctx context.Context, userCred mcclient.TokenCredential,
+	plan *schedapi.PreemptionPlan, priorityClass string, preemptor string, parentTaskId string,
+) error {
+	victims, err := manager.fetchPreemptionVictims(plan, priorityClass)
+	if err != nil {
+		return err
+	}
+	objs := make([]db.IStandaloneModel, len(victims))
+	for i := range victims {
+		objs[i] = victims[i]
+	}
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.Marshal(plan), "plan")
+	params.Add(jsonutils.NewString(preemptor), "preemptor")
+	task, err := taskman.TaskManager.NewParallelTask(ctx, "GuestPreemptTask", objs, userCred, params, parentTaskId, "", nil)
+	if err != nil {
+		return err
+	}
+	task.ScheduleRun(nil)
+	return nil
+}
@@ -149,6 +149,7 @@ type IGuestDriver interface {
 	RequestDeleteSnapshot(ctx context.Context, guest *SGuest, task taskman.ITask, params *jsonutils.JSONDict) error
 	RequestReloadDiskSnapshot(ctx context.Context, guest *SGuest, task taskman.ITask, params *jsonutils.JSONDict) error
 	RequestSyncToBackup(ctx context.Context, guest *SGuest, task taskman.ITask) error
+	RequestDiskMigrateStorage(ctx context.Context, guest *SGuest, disk *SDisk, storage *SStorage, format string, task taskman.ITask) error
 
 	IsSupportEip() bool
 
@@ -244,6 +244,9 @@ type SGuest struct {
 	Hypervisor string `width:"16" charset:"ascii" nullable:"false" default:"kvm" list:"user" create:"required"` // Column(VARCHAR(16, charset='ascii'), nullable=False, default=HYPERVISOR_DEFAULT)
 
 	InstanceType string `width:"64" charset:"ascii" nullable:"true" list:"user" create:"optional"`
+
+	// PriorityClass decides which servers could be preempted by others
+	PriorityClass string `width:"16" charset:"ascii" nullable:"false" default:"normal" list:"user" create:"optional" update:"user"`
 }
 
 func (manager *SGuestManager) AllowListItems(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
@@ -813,6 +816,13 @@ func (self *SGuest) ValidateUpdateData(ctx context.Context, userCred mcclient.To
 			return nil, httperrors.NewInputParameterError("name is too short")
 		}
 	}
+	if data.Contains("priority_class") {
+		priorityClass, _ := data.GetString("priority_class")
+		err = self.validatePriorityClassUpdate(userCred, priorityClass)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return self.SVirtualResourceBase.ValidateUpdateData(ctx, userCred, query, data)
 }
 
@@ -822,6 +832,22 @@ func (manager *SGuestManager) ValidateCreateData(ctx context.Context, userCred m
 	if err != nil {
 		return nil, err
 	}
+	if len(input.GuestImageId) > 0 {
+		err = fillServerInputByGuestImage(ctx, userCred, input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(input.Groups) > 0 || len(input.TopologySpreads) > 0 {
+		err = GroupManager.validateServerGroups(userCred, ownerProjId, input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = validateServerPriorityClass(userCred, input)
+	if err != nil {
+		return nil, err
+	}
 	resetPassword := true
 	if input.ResetPassword != nil {
 		resetPassword = *input.ResetPassword
@@ -1264,6 +1290,19 @@ func (guest *SGuest) PostCreate(ctx context.Context, userCred mcclient.TokenCred
 	if len(userData) > 0 {
 		guest.setUserData(ctx, userCred, userData)
 	}
+
+	groupIds := make([]string, 0)
+	data.Unmarshal(&groupIds, "groups")
+	for _, groupId := range groupIds {
+		group, _ := GroupManager.FetchById(groupId)
+		if group == nil {
+			continue
+		}
+		err := group.(*SGroup).addGuest(ctx, userCred, guest)
+		if err != nil {
+			log.Errorf("Server %s join group %s: %v", guest.Name, groupId, err)
+		}
+	}
 }
 
 func (guest *SGuest) setApptags(ctx context.Context, appTags []string, userCred mcclient.TokenCredential) {
@@ -4053,10 +4092,23 @@ func (self *SGuest) ToSchedDesc() *schedapi.ScheduleInput {
 	}*/
 
 	config.Hypervisor = self.GetHypervisor()
+	self.FillTopologySpreadSchedDesc(config.ServerConfigs)
 	desc.ServerConfig = *config
 	return desc
 }
 
+func (self *SGuest) FillTopologySpreadSchedDesc(desc *api.ServerConfigs) {
+	for _, groupguest := range self.GetGroups() {
+		group, _ := GroupManager.FetchById(groupguest.SrvtagId)
+		if group == nil {
+			continue
+		}
+		if spread := group.(*SGroup).GetTopologySpread(); spread != nil {
+			desc.TopologySpreads = append(desc.TopologySpreads, spread)
+		}
+	}
+}
+
 /*func (self *SGuest) FillGroupSchedDesc(desc *schedapi.ServerConfig) {
 	groups := make([]SGroupguest, 0)
 	err := GroupguestManager.Query().Equals("guest_id", self.Id).All(&groups)
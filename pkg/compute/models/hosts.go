@@ -32,6 +32,7 @@ import (
 	"yunion.io/x/pkg/util/fileutils"
 	"yunion.io/x/pkg/util/netutils"
 	"yunion.io/x/pkg/util/regutils"
+	"yunion.io/x/pkg/util/timeutils"
 	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
@@ -41,6 +42,8 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/keymanager"
+	"yunion.io/x/onecloud/pkg/cloudcommon/notifyclient"
 	"yunion.io/x/onecloud/pkg/cloudprovider"
 	"yunion.io/x/onecloud/pkg/compute/baremetal"
 	"yunion.io/x/onecloud/pkg/compute/options"
@@ -1088,6 +1091,31 @@ func (self *SHost) GetLeastUsedStorage(backend string) *SStorage {
 	return nil
 }
 
+// GetMigrateStorageTarget returns the attached storage with the most free
+// space that can take over the disk from source.
+func (self *SHost) GetMigrateStorageTarget(source *SStorage, disk *SDisk, sharedOnly bool) *SStorage {
+	candidates := make([]SStorage, 0)
+	for _, storage := range self.GetAttachedStorages("") {
+		if storage.Id == source.Id || storage.StorageType == api.STORAGE_BAREMETAL {
+			continue
+		}
+		if sharedOnly && storage.IsLocal() {
+			continue
+		}
+		if !utils.IsInStringArray(storage.Status, []string{api.STORAGE_ENABLED, api.STORAGE_ONLINE}) {
+			continue
+		}
+		if !utils.IsInStringArray(storage.StorageType, api.STORAGE_LIMITED_TYPES) {
+			continue
+		}
+		if storage.GetFreeCapacity() < disk.DiskSize {
+			continue
+		}
+		candidates = append(candidates, storage)
+	}
+	return _getLeastUsedStorage(candidates, nil)
+}
+
 func (self *SHost) GetWiresQuery() *sqlchemy.SQuery {
 	return HostwireManager.Query().Equals("host_id", self.Id)
 }
@@ -1841,6 +1869,10 @@ func (self *SHost) SyncHostVMs(ctx context.Context, userCred mcclient.TokenCrede
 	}
 
 	for i := 0; i < len(added); i += 1 {
+		if GuestManager.IsConvertedSourceVM(added[i].GetGlobalId()) {
+			// source vm left behind by conversion to kvm
+			continue
+		}
 		if added[i].GetBillingType() == billing_api.BILLING_TYPE_PREPAID {
 			vhost := HostManager.GetHostByRealExternalId(added[i].GetGlobalId())
 			if vhost != nil {
@@ -2337,7 +2369,7 @@ func (self *SHost) GetDetailsIpmi(ctx context.Context, userCred mcclient.TokenCr
 	if err != nil {
 		return nil, httperrors.NewNotFoundError("IPMI has no password information")
 	}
-	descryptedPassword, err := utils.DescryptAESBase64(self.Id, password)
+	descryptedPassword, err := keymanager.Decrypt(self.Id, password)
 	if err != nil {
 		return nil, err
 	}
@@ -2604,7 +2636,7 @@ func fetchIpmiInfo(data *jsonutils.JSONDict, hostId string) (*jsonutils.JSONDict
 			value, _ := data.GetString(key)
 			subkey := key[len(IPMI_KEY_PERFIX):]
 			if subkey == "password" && len(hostId) > 0 {
-				value, err = utils.EncryptAESBase64(hostId, value)
+				value, err = keymanager.Encrypt(hostId, value)
 				if err != nil {
 					log.Errorf("encrypt password failed %s", err)
 					return nil, err
@@ -2616,6 +2648,10 @@ func fetchIpmiInfo(data *jsonutils.JSONDict, hostId string) (*jsonutils.JSONDict
 					err = fmt.Errorf(msg)
 					return nil, err
 				}
+			} else if subkey == "bmc_type" {
+				if !utils.IsInStringArray(value, api.BMC_TYPES) {
+					return nil, httperrors.NewInputParameterError("%s: invalid bmc type %s, want one of %s", key, value, api.BMC_TYPES)
+				}
 			}
 			ipmiInfo.Set(subkey, jsonutils.NewString(value))
 		}
@@ -2745,6 +2781,58 @@ func (self *SHost) PerformMaintenance(ctx context.Context, userCred mcclient.Tok
 	return nil, nil
 }
 
+func (self *SHost) AllowPerformEvacuateStorage(ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, self, "evacuate-storage")
+}
+
+// PerformEvacuateStorage prepares a KVM host for maintenance of its local
+// disks: the local storages are disabled and the disks of running guests are
+// live migrated onto shared storage attached to the host.
+func (self *SHost) PerformEvacuateStorage(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if self.HostType != api.HOST_TYPE_HYPERVISOR {
+		return nil, httperrors.NewUnsupportOperationError("Cannot evacuate storage of %s host", self.HostType)
+	}
+	if self.HostStatus != api.HOST_ONLINE {
+		return nil, httperrors.NewInvalidStatusError("Cannot evacuate storage while host status %s", self.HostStatus)
+	}
+	var target *SStorage
+	targetStr := jsonutils.GetAnyString(data, []string{"target_storage", "target_storage_id"})
+	if len(targetStr) > 0 {
+		obj, err := StorageManager.FetchByIdOrName(userCred, targetStr)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, httperrors.NewResourceNotFoundError2(StorageManager.Keyword(), targetStr)
+			}
+			return nil, httperrors.NewGeneralError(err)
+		}
+		target = obj.(*SStorage)
+		if target.IsLocal() {
+			return nil, httperrors.NewInputParameterError("Target storage %s is local", target.Name)
+		}
+	}
+	// a guest may have disks on several local storages, plan them all
+	// before starting so that they are moved by one task per guest
+	storages := self.GetAttachedStorages(api.STORAGE_LOCAL)
+	plan := NewMigrateStoragePlan()
+	for i := range storages {
+		if _, err := storages[i].PerformDisable(ctx, userCred, query, data); err != nil {
+			return nil, err
+		}
+		plan.AddDisksOfStorage(&storages[i], target, true)
+	}
+	plan.Start(ctx, userCred)
+	ret := jsonutils.NewDict()
+	for _, storage := range storages {
+		ret.Set(storage.Id, plan.GetResult(storage.Id))
+	}
+	db.OpsLog.LogEvent(self, db.ACT_DECOMMISSION, ret, userCred)
+	logclient.AddActionLogWithContext(ctx, self, logclient.ACT_DECOMMISSION, ret, userCred, true)
+	return ret, nil
+}
+
 func (self *SHost) AllowPerformUnmaintenance(ctx context.Context,
 	userCred mcclient.TokenCredential,
 	query jsonutils.JSONObject,
@@ -3447,6 +3535,9 @@ func (self *SHost) PerformEnable(
 	data jsonutils.JSONObject,
 ) (jsonutils.JSONObject, error) {
 	if !self.Enabled {
+		if err := self.CheckDiskWiped(userCred); err != nil {
+			return nil, err
+		}
 		_, err := self.SEnabledStatusStandaloneResourceBase.PerformEnable(ctx, userCred, query, data)
 		if err != nil {
 			return nil, err
@@ -3476,6 +3567,121 @@ func (self *SHost) PerformDisable(ctx context.Context, userCred mcclient.TokenCr
 	return nil, nil
 }
 
+// CheckDiskWiped returns error if disks of baremetal are being wiped or the
+// last wipe was not verified, such baremetal must not be offered to tenants
+func (self *SHost) CheckDiskWiped(userCred mcclient.TokenCredential) error {
+	if !self.IsBaremetal {
+		return nil
+	}
+	switch self.GetMetadata("__disk_wipe_status", userCred) {
+	case api.DISK_WIPE_STATUS_WIPING:
+		return httperrors.NewInvalidStatusError("Disks of baremetal %s are being wiped", self.Name)
+	case api.DISK_WIPE_STATUS_FAILED:
+		return httperrors.NewInvalidStatusError("Disk wipe of baremetal %s is not verified: %s, wipe again by disk-wipe",
+			self.Name, self.GetMetadata("__disk_wipe_reason", userCred))
+	}
+	return nil
+}
+
+func (self *SHost) AllowPerformDiskWipeReport(ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, self, "disk-wipe-report")
+}
+
+// PerformDiskWipeReport is called by baremetal agent when disk wipe starts
+// and ends. Baremetal is disabled during wipe and enabled again only if
+// every disk is verified wiped
+func (self *SHost) PerformDiskWipeReport(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if !self.IsBaremetal {
+		return nil, httperrors.NewBadRequestError("Host %s is not a baremetal", self.Name)
+	}
+	status, _ := data.GetString("status")
+	if !utils.IsInStringArray(status, []string{api.DISK_WIPE_STATUS_WIPING, api.DISK_WIPE_STATUS_VERIFIED, api.DISK_WIPE_STATUS_FAILED}) {
+		return nil, httperrors.NewInputParameterError("Invalid disk wipe status %q", status)
+	}
+	reason, _ := data.GetString("reason")
+	certs := make([]api.BaremetalDiskWipeCertificate, 0)
+	if data.Contains("certificates") {
+		if err := data.Unmarshal(&certs, "certificates"); err != nil {
+			return nil, httperrors.NewInputParameterError("Invalid disk wipe certificates: %v", err)
+		}
+	}
+	metadata := map[string]interface{}{
+		"__disk_wipe_status": status,
+		"__disk_wipe_reason": reason,
+	}
+	if status == api.DISK_WIPE_STATUS_WIPING {
+		metadata["__disk_wipe_certificates"] = ""
+		if self.Enabled {
+			metadata["__disk_wipe_disabled"] = "true"
+		}
+	} else {
+		metadata["__disk_wipe_certificates"] = jsonutils.Marshal(certs)
+	}
+	if err := self.SetAllMetadata(ctx, metadata, userCred); err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+
+	switch status {
+	case api.DISK_WIPE_STATUS_WIPING:
+		db.OpsLog.LogEvent(self, db.ACT_DISK_WIPE_START, "", userCred)
+		if _, err := self.PerformDisable(ctx, userCred, nil, nil); err != nil {
+			return nil, err
+		}
+	case api.DISK_WIPE_STATUS_VERIFIED:
+		db.OpsLog.LogEvent(self, db.ACT_DISK_WIPE_COMPLETE, jsonutils.Marshal(certs), userCred)
+		logclient.AddSimpleActionLog(self, logclient.ACT_BM_DISK_WIPE, jsonutils.Marshal(certs), userCred, true)
+		if utils.ToBool(self.GetMetadata("__disk_wipe_disabled", userCred)) {
+			if _, err := self.PerformEnable(ctx, userCred, nil, nil); err != nil {
+				return nil, err
+			}
+			self.SetMetadata(ctx, "__disk_wipe_disabled", "", userCred)
+		}
+	case api.DISK_WIPE_STATUS_FAILED:
+		notes := jsonutils.NewDict()
+		notes.Add(jsonutils.NewString(reason), "reason")
+		notes.Add(jsonutils.Marshal(certs), "certificates")
+		db.OpsLog.LogEvent(self, db.ACT_DISK_WIPE_FAIL, notes, userCred)
+		logclient.AddSimpleActionLog(self, logclient.ACT_BM_DISK_WIPE, notes, userCred, false)
+	}
+	return nil, nil
+}
+
+func (self *SHost) AllowPerformDiskWipe(ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, self, "disk-wipe")
+}
+
+func (self *SHost) PerformDiskWipe(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if !self.IsBaremetal || self.HostType != api.HOST_TYPE_BAREMETAL {
+		return nil, httperrors.NewBadRequestError("Cannot wipe disks of non-baremetal host")
+	}
+	if self.Status != api.BAREMETAL_READY {
+		return nil, httperrors.NewInvalidStatusError("Cannot wipe disks in status %s", self.Status)
+	}
+	if self.GetBaremetalServer() != nil {
+		return nil, httperrors.NewBadRequestError("Cannot wipe disks of baremetal with server")
+	}
+	params := jsonutils.NewDict()
+	mode, _ := data.GetString("mode")
+	if len(mode) > 0 {
+		if mode == api.DISK_WIPE_MODE_NONE || !api.DISK_WIPE_MODES.Has(mode) {
+			return nil, httperrors.NewInputParameterError("Invalid disk wipe mode %q", mode)
+		}
+		params.Add(jsonutils.NewString(mode), "mode")
+	}
+	task, err := taskman.TaskManager.NewTask(ctx, "BaremetalDiskWipeTask", self, userCred, params, "", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	task.ScheduleRun(nil)
+	return nil, nil
+}
+
 func (self *SHost) AllowPerformCacheImage(ctx context.Context,
 	userCred mcclient.TokenCredential,
 	query jsonutils.JSONObject,
@@ -3956,3 +4162,87 @@ func (host *SHost) PerformStatus(ctx context.Context, userCred mcclient.TokenCre
 func (host *SHost) GetSchedtagJointManager() ISchedtagJointManager {
 	return HostschedtagManager
 }
+
+func (self *SHost) AllowPerformTelemetryReport(ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, self, "telemetry-report")
+}
+
+// PerformTelemetryReport is called periodically by baremetal agent with
+// hardware telemetry collected from BMC. Inventory, firmware and sensor
+// snapshots are kept as metadata without ops log, newly asserted critical
+// SEL events and sensors newly turned critical are alerted
+func (self *SHost) PerformTelemetryReport(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if !self.IsBaremetal {
+		return nil, httperrors.NewBadRequestError("Host %s is not a baremetal", self.Name)
+	}
+	report := api.BaremetalTelemetry{}
+	if err := data.Unmarshal(&report); err != nil {
+		return nil, httperrors.NewInputParameterError("Invalid telemetry report: %v", err)
+	}
+	metadata := map[string]interface{}{
+		"__telemetry_collected_at": timeutils.FullIsoTime(report.CollectedAt),
+	}
+	if report.Inventory != nil {
+		metadata["__inventory"] = jsonutils.Marshal(report.Inventory)
+	}
+	if report.Firmwares != nil {
+		metadata["__firmwares"] = jsonutils.Marshal(report.Firmwares)
+	}
+
+	alerts := make([]string, 0)
+	if report.Sensors != nil {
+		prevCritical := make([]string, 0)
+		if prev := self.GetMetadataJson("__critical_sensors", userCred); prev != nil {
+			prev.Unmarshal(&prevCritical)
+		}
+		critical := make([]string, 0)
+		for _, s := range report.Sensors {
+			if s.Status != api.HARDWARE_STATUS_CRITICAL {
+				continue
+			}
+			critical = append(critical, s.Name)
+			if !utils.IsInStringArray(s.Name, prevCritical) {
+				reason := fmt.Sprintf("Sensor %s is critical: reading %v %s %s", s.Name, s.Reading, s.Unit, s.State)
+				notifyclient.NotifySystemError(self.Id, self.Name, api.BAREMETAL_SENSOR_CRITICAL, reason)
+				alerts = append(alerts, reason)
+			}
+		}
+		metadata["__sensors"] = jsonutils.Marshal(report.Sensors)
+		metadata["__critical_sensors"] = jsonutils.Marshal(critical)
+	}
+	if report.SelEvents != nil {
+		// alert events after the last one seen, the first report only
+		// records where SEL is so history is not alerted
+		lastIdStr := self.GetMetadata("__sel_last_id", userCred)
+		lastId, _ := strconv.Atoi(lastIdStr)
+		maxId := 0
+		for _, ev := range report.SelEvents {
+			if ev.Id > maxId {
+				maxId = ev.Id
+			}
+		}
+		if maxId < lastId {
+			// SEL is cleared and record ids start over
+			lastId = 0
+		}
+		for _, ev := range report.SelEvents {
+			if len(lastIdStr) == 0 || ev.Id <= lastId || !ev.Asserted || ev.Severity != api.HARDWARE_STATUS_CRITICAL {
+				continue
+			}
+			reason := fmt.Sprintf("SEL #%d at %s: %s %s", ev.Id, ev.Time, ev.Sensor, ev.Event)
+			notifyclient.NotifySystemError(self.Id, self.Name, api.BAREMETAL_SEL_CRITICAL, reason)
+			alerts = append(alerts, reason)
+		}
+		metadata["__sel_last_id"] = strconv.Itoa(maxId)
+	}
+	if _, err := db.Metadata.SetValues(ctx, self, metadata, userCred); err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	if len(alerts) > 0 {
+		db.OpsLog.LogEvent(self, db.ACT_HARDWARE_ALERT, jsonutils.Marshal(alerts), userCred)
+	}
+	return nil, nil
+}
@@ -476,9 +476,54 @@ func (lbagent *SLoadbalancerAgent) PerformHb(ctx context.Context, userCred mccli
 		log.Infof("lbagent %s(%s) state changed: %s", lbagent.Name, lbagent.Id, diff)
 		db.OpsLog.LogEvent(lbagent, db.ACT_UPDATE, diff, userCred)
 	}
+	if lbagent.HaState == api.LB_HA_STATE_MASTER && data.Contains("backend_sessions") {
+		// only the master agent carries traffic
+		lbagent.updateBackendSessions(data)
+	}
 	return nil, nil
 }
 
+func (lbagent *SLoadbalancerAgent) updateBackendSessions(data *jsonutils.JSONDict) {
+	sessions, err := data.GetMap("backend_sessions")
+	if err != nil {
+		log.Errorf("lbagent %s(%s): invalid backend_sessions: %s", lbagent.Name, lbagent.Id, err)
+		return
+	}
+	for id, jn := range sessions {
+		n, err := jn.Int()
+		if err != nil {
+			log.Errorf("lbagent %s(%s): invalid sessions for backend %s: %s", lbagent.Name, lbagent.Id, id, err)
+			continue
+		}
+		obj, err := LoadbalancerBackendManager.FetchById(id)
+		if err != nil {
+			continue
+		}
+		lbb := obj.(*SLoadbalancerBackend)
+		if !lbagent.servesBackend(lbb) {
+			log.Warningf("lbagent %s(%s): ignore sessions for backend %s(%s) not served by lbagents", lbagent.Name, lbagent.Id, lbb.Name, lbb.Id)
+			continue
+		}
+		if err := lbb.SetDrainSessions(int(n)); err != nil {
+			log.Errorf("loadbalancer backend %s(%s): update drain sessions: %s", lbb.Name, lbb.Id, err)
+		}
+	}
+}
+
+// servesBackend tells whether lbb is served by lbagents, which carry the
+// traffic of all loadbalancers not managed by a cloud provider
+func (lbagent *SLoadbalancerAgent) servesBackend(lbb *SLoadbalancerBackend) bool {
+	if len(lbb.ManagerId) > 0 {
+		return false
+	}
+	lbbg := lbb.GetLoadbalancerBackendGroup()
+	if lbbg == nil {
+		return false
+	}
+	lb := lbbg.GetLoadbalancer()
+	return lb != nil && len(lb.ManagerId) == 0
+}
+
 func (lbagent *SLoadbalancerAgent) IsActive() bool {
 	if lbagent.HbLastSeen.IsZero() {
 		return false
@@ -310,6 +310,19 @@ func (lbbg *SLoadbalancerBackendGroup) ValidateDeleteCondition(ctx context.Conte
 				lbbg.Id, n, m.KeywordPlural())
 		}
 	}
+	{
+		n, err := LoadbalancerListenerManager.Query().
+			IsFalse("pending_deleted").
+			Equals("shift_backend_group_id", lbbg.Id).
+			CountWithError()
+		if err != nil {
+			return httperrors.NewInternalServerError("get shift refCount fail %s", err.Error())
+		}
+		if n > 0 {
+			return httperrors.NewResourceBusyError("backend group %s is still being shifted to by %d listeners",
+				lbbg.Id, n)
+		}
+	}
 
 	region := lbbg.GetRegion()
 	if region == nil {
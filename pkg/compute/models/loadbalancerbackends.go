@@ -17,6 +17,7 @@ package models
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -31,6 +32,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudprovider"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/logclient"
 )
 
 type SLoadbalancerBackendManager struct {
@@ -63,6 +65,13 @@ type SLoadbalancerBackend struct {
 	Weight         int    `width:"36" charset:"ascii" nullable:"false" list:"user" create:"optional" update:"user"`
 	Address        string `width:"36" charset:"ascii" nullable:"false" list:"user" create:"optional"`
 	Port           int    `nullable:"false" list:"user" create:"required" update:"user"`
+
+	// DrainDeadline is the time after which a draining backend will be
+	// removed regardless of remaining active sessions
+	DrainDeadline time.Time `nullable:"true" list:"user"`
+	// DrainSessions is the number of active sessions last reported by the
+	// master lbagent.  It's reset to -1 when draining starts
+	DrainSessions int `nullable:"false" default:"0" list:"user"`
 }
 
 func (man *SLoadbalancerBackendManager) pendingDeleteSubs(ctx context.Context, userCred mcclient.TokenCredential, q *sqlchemy.SQuery) {
@@ -162,6 +171,93 @@ func (lbb *SLoadbalancerBackend) AllowPerformStatus(ctx context.Context, userCre
 	return false
 }
 
+func (lbb *SLoadbalancerBackend) AllowPerformDrain(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return lbb.IsOwner(userCred) || db.IsAdminAllowPerform(userCred, lbb, "drain")
+}
+
+// PerformDrain stops sending new connections to the backend.  The backend
+// will be removed once the lbagent reports no active sessions on it, or when
+// the drain timeout expires.  Sessions of udp listeners are not counted by
+// lbagent, backends used by them get no new flows and are removed on timeout
+func (lbb *SLoadbalancerBackend) PerformDrain(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if lbb.Status == api.LB_STATUS_DRAINING {
+		return nil, httperrors.NewInvalidStatusError("loadbalancer backend %s is already draining", lbb.Name)
+	}
+	if lbb.Status != api.LB_STATUS_ENABLED {
+		return nil, httperrors.NewInvalidStatusError("cannot drain loadbalancer backend %s in status %s", lbb.Name, lbb.Status)
+	}
+	region := lbb.GetRegion()
+	if region == nil {
+		return nil, httperrors.NewResourceNotFoundError("failed to find region for loadbalancer backend %s", lbb.Name)
+	}
+	if err := region.GetDriver().ValidateDrainLoadbalancerBackendCondition(ctx, lbb); err != nil {
+		return nil, err
+	}
+	timeoutV := validators.NewRangeValidator("timeout", 1, 86400)
+	if err := timeoutV.Default(api.LB_DRAIN_TIMEOUT_DEFAULT).Validate(data.(*jsonutils.JSONDict)); err != nil {
+		return nil, err
+	}
+	diff, err := db.Update(lbb, func() error {
+		lbb.Status = api.LB_STATUS_DRAINING
+		lbb.DrainDeadline = time.Now().Add(time.Duration(timeoutV.Value) * time.Second)
+		lbb.DrainSessions = -1
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.OpsLog.LogEvent(lbb, db.ACT_UPDATE, diff, userCred)
+	logclient.AddActionLogWithContext(ctx, lbb, logclient.ACT_LB_DRAIN_BACKEND, diff, userCred, true)
+	return nil, nil
+}
+
+// SetDrainSessions records active sessions reported by lbagent for a
+// draining backend
+func (lbb *SLoadbalancerBackend) SetDrainSessions(sessions int) error {
+	if lbb.Status != api.LB_STATUS_DRAINING || lbb.DrainSessions == sessions {
+		return nil
+	}
+	_, err := db.Update(lbb, func() error {
+		lbb.DrainSessions = sessions
+		return nil
+	})
+	return err
+}
+
+// isDrained tells whether the draining backend can now be removed
+func (lbb *SLoadbalancerBackend) isDrained(now time.Time) bool {
+	if lbb.DrainSessions == 0 {
+		return true
+	}
+	return !lbb.DrainDeadline.IsZero() && now.After(lbb.DrainDeadline)
+}
+
+// CheckDrainingBackends removes draining backends that have no more active
+// sessions or whose drain timeout has expired
+func (man *SLoadbalancerBackendManager) CheckDrainingBackends(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	lbbs := []SLoadbalancerBackend{}
+	q := man.Query().Equals("status", api.LB_STATUS_DRAINING)
+	q = q.IsFalse("pending_deleted")
+	if err := db.FetchModelObjects(man, q, &lbbs); err != nil {
+		log.Errorf("fetch draining loadbalancer backends failed: %s", err)
+		return
+	}
+	now := time.Now()
+	for i := range lbbs {
+		lbb := &lbbs[i]
+		if !lbb.isDrained(now) {
+			continue
+		}
+		if lbb.DrainSessions != 0 {
+			log.Warningf("loadbalancer backend %s(%s) drain timeout with %d sessions", lbb.Name, lbb.Id, lbb.DrainSessions)
+		}
+		lbb.SetStatus(userCred, api.LB_STATUS_DELETING, "drained")
+		if err := lbb.StartLoadBalancerBackendDeleteTask(ctx, userCred, jsonutils.NewDict(), ""); err != nil {
+			log.Errorf("remove drained loadbalancer backend %s(%s) failed: %s", lbb.Name, lbb.Id, err)
+		}
+	}
+}
+
 func (lbb *SLoadbalancerBackend) GetLoadbalancerBackendGroup() *SLoadbalancerBackendGroup {
 	backendgroup, err := LoadbalancerBackendGroupManager.FetchById(lbb.BackendGroupId)
 	if err != nil {
@@ -27,12 +27,14 @@ import (
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/util/compare"
+	"yunion.io/x/pkg/util/stringutils"
 	"yunion.io/x/sqlchemy"
 
 	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/keymanager"
 	"yunion.io/x/onecloud/pkg/cloudcommon/validators"
 	"yunion.io/x/onecloud/pkg/cloudprovider"
 	"yunion.io/x/onecloud/pkg/httperrors"
@@ -207,7 +209,11 @@ func (lbcert *SLoadbalancerCertificate) ValidateUpdateData(ctx context.Context,
 		data.Set("certificate", jsonutils.NewString(lbcert.Certificate))
 	}
 	if !data.Contains("private_key") {
-		data.Set("private_key", jsonutils.NewString(lbcert.PrivateKey))
+		privateKey, err := lbcert.GetPrivateKey()
+		if err != nil {
+			return nil, httperrors.NewGeneralError(err)
+		}
+		data.Set("private_key", jsonutils.NewString(privateKey))
 	}
 	data, err := LoadbalancerCertificateManager.validateCertKey(ctx, data)
 	if err != nil {
@@ -220,7 +226,43 @@ func (lbcert *SLoadbalancerCertificate) ValidateUpdateData(ctx context.Context,
 	if region == nil {
 		return nil, httperrors.NewResourceNotFoundError("failed to find region for loadbalancer certificate %s", lbcert.Name)
 	}
-	return region.GetDriver().ValidateUpdateLoadbalancerCertificateData(ctx, userCred, data)
+	data, err = region.GetDriver().ValidateUpdateLoadbalancerCertificateData(ctx, userCred, data)
+	if err != nil {
+		return nil, err
+	}
+	if keymanager.Enabled() {
+		privateKey, _ := data.GetString("private_key")
+		privateKey, err = keymanager.Encrypt(lbcert.Id, privateKey)
+		if err != nil {
+			return nil, httperrors.NewGeneralError(err)
+		}
+		data.Set("private_key", jsonutils.NewString(privateKey))
+	}
+	return data, nil
+}
+
+func (lbcert *SLoadbalancerCertificate) CustomizeCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerProjId string, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
+	if keymanager.Enabled() {
+		// id is needed before insert, encrypted private key is bound to it
+		if len(lbcert.Id) == 0 {
+			lbcert.Id = stringutils.UUID4()
+		}
+		privateKey, err := keymanager.Encrypt(lbcert.Id, lbcert.PrivateKey)
+		if err != nil {
+			return err
+		}
+		lbcert.PrivateKey = privateKey
+	}
+	return lbcert.SVirtualResourceBase.CustomizeCreate(ctx, userCred, ownerProjId, query, data)
+}
+
+// GetPrivateKey returns private key in plain text.  Private keys stored by
+// older versions or when no key service is configured are not encrypted
+func (lbcert *SLoadbalancerCertificate) GetPrivateKey() (string, error) {
+	if !keymanager.IsEncrypted(lbcert.PrivateKey) {
+		return lbcert.PrivateKey, nil
+	}
+	return keymanager.Decrypt(lbcert.Id, lbcert.PrivateKey)
 }
 
 func (lbcert *SLoadbalancerCertificate) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerProjId string, query jsonutils.JSONObject, data jsonutils.JSONObject) {
@@ -247,6 +289,16 @@ func (lbcert *SLoadbalancerCertificate) GetCustomizeColumns(ctx context.Context,
 
 func (lbcert *SLoadbalancerCertificate) GetExtraDetails(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) (*jsonutils.JSONDict, error) {
 	extra := lbcert.GetCustomizeColumns(ctx, userCred, query)
+	// decrypt only on explicit get of a single certificate, lists carry
+	// the encrypted form
+	if keymanager.IsEncrypted(lbcert.PrivateKey) && db.IsAdminAllowGet(userCred, lbcert) {
+		privateKey, err := lbcert.GetPrivateKey()
+		if err != nil {
+			log.Errorf("decrypt private key of loadbalancer certificate %s: %s", lbcert.Name, err)
+		} else {
+			extra.Set("private_key", jsonutils.NewString(privateKey))
+		}
+	}
 	return extra, nil
 }
 
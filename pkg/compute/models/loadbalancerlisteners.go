@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -33,6 +34,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudprovider"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/logclient"
 )
 
 type SLoadbalancerListenerManager struct {
@@ -127,6 +129,8 @@ type SLoadbalancerListener struct {
 	AclType   string `width:"16" charset:"ascii" nullable:"false" list:"user" create:"optional" update:"user"`
 	AclId     string `width:"36" charset:"ascii" nullable:"false" list:"user" create:"optional" update:"user"`
 
+	EnableAccessLog bool `nullable:"false" default:"false" list:"user" create:"optional" update:"user"`
+
 	SLoadbalancerRateLimiter
 
 	SLoadbalancerTCPListener
@@ -136,6 +140,23 @@ type SLoadbalancerListener struct {
 
 	SLoadbalancerHealthCheck
 	SLoadbalancerHTTPRateLimiter
+
+	SLoadbalancerBackendGroupShift
+}
+
+// SLoadbalancerBackendGroupShift records progress of gradually moving
+// listener traffic from BackendGroupId to ShiftBackendGroupId.  ShiftWeight
+// is the percentage of traffic currently sent to the shift backend group.
+// It moves towards ShiftTargetWeight by ShiftStep every ShiftInterval
+// seconds.  When it reaches 100, the shift backend group becomes the default
+// one of the listener
+type SLoadbalancerBackendGroupShift struct {
+	ShiftBackendGroupId string    `width:"36" charset:"ascii" nullable:"false" list:"user"`
+	ShiftWeight         int       `nullable:"false" default:"0" list:"user"`
+	ShiftTargetWeight   int       `nullable:"false" default:"0" list:"user"`
+	ShiftStep           int       `nullable:"false" default:"0" list:"user"`
+	ShiftInterval       int       `nullable:"false" default:"0" list:"user"`
+	ShiftUpdatedAt      time.Time `nullable:"true" list:"user"`
 }
 
 func (man *SLoadbalancerListenerManager) checkListenerUniqueness(ctx context.Context, lb *SLoadbalancer, listenerType string, listenerPort int64) error {
@@ -222,6 +243,8 @@ func (man *SLoadbalancerListenerManager) ValidateCreateData(ctx context.Context,
 		"x_forwarded_for": validators.NewBoolValidator("x_forwarded_for").Default(true),
 		"gzip":            validators.NewBoolValidator("gzip").Default(false),
 
+		"enable_access_log": validators.NewBoolValidator("enable_access_log").Default(false),
+
 		"http_request_rate":         validators.NewNonNegativeValidator("http_request_rate").Default(0),
 		"http_request_rate_per_src": validators.NewNonNegativeValidator("http_request_rate_per_src").Default(0),
 	}
@@ -444,6 +467,8 @@ func (lblis *SLoadbalancerListener) ValidateUpdateData(ctx context.Context, user
 		"x_forwarded_for": validators.NewBoolValidator("x_forwarded_for"),
 		"gzip":            validators.NewBoolValidator("gzip"),
 
+		"enable_access_log": validators.NewBoolValidator("enable_access_log"),
+
 		"http_request_rate":         validators.NewNonNegativeValidator("http_request_rate"),
 		"http_request_rate_per_src": validators.NewNonNegativeValidator("http_request_rate_per_src"),
 
@@ -670,6 +695,138 @@ func (lblis *SLoadbalancerListener) GetLoadbalancerAcl() *SLoadbalancerAcl {
 	return acl.(*SLoadbalancerAcl)
 }
 
+func (lblis *SLoadbalancerListener) AllowPerformShiftWeight(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return lblis.IsOwner(userCred) || db.IsAdminAllowPerform(userCred, lblis, "shift-weight")
+}
+
+// PerformShiftWeight starts moving traffic of the listener to another backend
+// group in steps.  Shifting to weight 0 rolls back to the original backend
+// group
+func (lblis *SLoadbalancerListener) PerformShiftWeight(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	region := lblis.GetRegion()
+	if region == nil {
+		return nil, httperrors.NewResourceNotFoundError("failed to find region for loadbalancer listener %s", lblis.Name)
+	}
+	if err := region.GetDriver().ValidateShiftLoadbalancerListenerCondition(ctx, lblis); err != nil {
+		return nil, err
+	}
+	if lblis.BackendGroupId == "" {
+		return nil, httperrors.NewInvalidStatusError("loadbalancer listener %s has no backend group to shift from", lblis.Name)
+	}
+	ownerProjId := lblis.GetOwnerProjectId()
+	backendGroupV := validators.NewModelIdOrNameValidator("backend_group", "loadbalancerbackendgroup", ownerProjId)
+	weightV := validators.NewRangeValidator("weight", api.LB_SHIFT_WEIGHT_MIN, api.LB_SHIFT_WEIGHT_MAX)
+	stepV := validators.NewRangeValidator("step", 1, api.LB_SHIFT_WEIGHT_MAX)
+	intervalV := validators.NewRangeValidator("interval", 1, 86400)
+	keyV := map[string]validators.IValidator{
+		"backend_group": backendGroupV.Optional(lblis.ShiftBackendGroupId != ""),
+		"weight":        weightV.Default(api.LB_SHIFT_WEIGHT_MAX),
+		"step":          stepV.Default(api.LB_SHIFT_STEP_DEFAULT),
+		"interval":      intervalV.Default(api.LB_SHIFT_INTERVAL_DEFAULT),
+	}
+	for _, v := range keyV {
+		if err := v.Validate(data.(*jsonutils.JSONDict)); err != nil {
+			return nil, err
+		}
+	}
+	shiftBackendGroupId := lblis.ShiftBackendGroupId
+	if backendGroupV.Model != nil {
+		backendGroup := backendGroupV.Model.(*SLoadbalancerBackendGroup)
+		if backendGroup.LoadbalancerId != lblis.LoadbalancerId {
+			return nil, httperrors.NewInputParameterError("backend group %s(%s) belongs to loadbalancer %s, not %s",
+				backendGroup.Name, backendGroup.Id, backendGroup.LoadbalancerId, lblis.LoadbalancerId)
+		}
+		if backendGroup.Id == lblis.BackendGroupId {
+			return nil, httperrors.NewInputParameterError("backend group %s is already the default of listener %s",
+				backendGroup.Name, lblis.Name)
+		}
+		if shiftBackendGroupId != "" && shiftBackendGroupId != backendGroup.Id && lblis.ShiftWeight > 0 {
+			return nil, httperrors.NewConflictError("listener %s is shifting traffic to backend group %s",
+				lblis.Name, shiftBackendGroupId)
+		}
+		shiftBackendGroupId = backendGroup.Id
+	}
+	diff, err := db.Update(lblis, func() error {
+		lblis.ShiftBackendGroupId = shiftBackendGroupId
+		lblis.ShiftTargetWeight = int(weightV.Value)
+		lblis.ShiftStep = int(stepV.Value)
+		lblis.ShiftInterval = int(intervalV.Value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.OpsLog.LogEvent(lblis, db.ACT_UPDATE, diff, userCred)
+	logclient.AddActionLogWithContext(ctx, lblis, logclient.ACT_LB_SHIFT_BACKEND_GROUP, diff, userCred, true)
+	return nil, lblis.stepShiftWeight(ctx, userCred)
+}
+
+// nextShiftWeight returns weight after moving cur towards target by at most
+// step
+func nextShiftWeight(cur, target, step int) int {
+	if cur < target {
+		cur += step
+		if cur > target {
+			cur = target
+		}
+	} else if cur > target {
+		cur -= step
+		if cur < target {
+			cur = target
+		}
+	}
+	return cur
+}
+
+func (lblis *SLoadbalancerListener) stepShiftWeight(ctx context.Context, userCred mcclient.TokenCredential) error {
+	diff, err := db.UpdateWithLock(ctx, lblis, func() error {
+		weight := nextShiftWeight(lblis.ShiftWeight, lblis.ShiftTargetWeight, lblis.ShiftStep)
+		switch {
+		case weight == api.LB_SHIFT_WEIGHT_MAX && lblis.ShiftTargetWeight == api.LB_SHIFT_WEIGHT_MAX:
+			// all traffic shifted, make it the default
+			lblis.BackendGroupId = lblis.ShiftBackendGroupId
+			lblis.SLoadbalancerBackendGroupShift = SLoadbalancerBackendGroupShift{}
+		case weight == api.LB_SHIFT_WEIGHT_MIN && lblis.ShiftTargetWeight == api.LB_SHIFT_WEIGHT_MIN:
+			// rolled back
+			lblis.SLoadbalancerBackendGroupShift = SLoadbalancerBackendGroupShift{}
+		default:
+			lblis.ShiftWeight = weight
+			lblis.ShiftUpdatedAt = time.Now()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	db.OpsLog.LogEvent(lblis, db.ACT_UPDATE, diff, userCred)
+	return nil
+}
+
+// ProcessBackendGroupShifts advances traffic shifting of listeners whose
+// shift interval has elapsed
+func (man *SLoadbalancerListenerManager) ProcessBackendGroupShifts(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	lbls := []SLoadbalancerListener{}
+	q := man.Query().IsNotEmpty("shift_backend_group_id")
+	q = q.IsFalse("pending_deleted")
+	if err := db.FetchModelObjects(man, q, &lbls); err != nil {
+		log.Errorf("fetch shifting loadbalancer listeners failed: %s", err)
+		return
+	}
+	now := time.Now()
+	for i := range lbls {
+		lblis := &lbls[i]
+		if lblis.ShiftWeight == lblis.ShiftTargetWeight {
+			continue
+		}
+		if now.Sub(lblis.ShiftUpdatedAt) < time.Duration(lblis.ShiftInterval)*time.Second {
+			continue
+		}
+		if err := lblis.stepShiftWeight(ctx, userCred); err != nil {
+			log.Errorf("shift loadbalancer listener %s(%s) weight failed: %s", lblis.Name, lblis.Id, err)
+		}
+	}
+}
+
 func (lblis *SLoadbalancerListener) GetLoadbalancerBackendGroup() *SLoadbalancerBackendGroup {
 	group, err := LoadbalancerBackendGroupManager.FetchById(lblis.BackendGroupId)
 	if err != nil {
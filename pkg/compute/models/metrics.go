@@ -0,0 +1,44 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CloudSyncDuration records how long syncing a region of a cloud provider
+// takes, registered to the /metrics endpoint of the region service
+var CloudSyncDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "cloud_sync_duration_seconds",
+		Help:    "Duration of syncing a region of a cloud provider",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+	},
+	[]string{"provider", "deep", "result"},
+)
+
+func observeCloudSync(provider string, deep bool, err error, duration time.Duration) {
+	deepStr := "false"
+	if deep {
+		deepStr = "true"
+	}
+	result := "success"
+	if err != nil {
+		result = "fail"
+	}
+	CloudSyncDuration.WithLabelValues(provider, deepStr, result).Observe(duration.Seconds())
+}
@@ -0,0 +1,187 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/keymanager"
+)
+
+// ReencryptSecrets migrates stored secrets to envelope encryption and
+// re-wraps data keys not protected by the primary master key, which is
+// needed after master key rotation.  It does nothing if no key service is
+// configured
+func ReencryptSecrets() error {
+	if !keymanager.Enabled() {
+		return nil
+	}
+	if err := reencryptCloudaccountSecrets(); err != nil {
+		return err
+	}
+	if err := reencryptCloudproviderSecrets(); err != nil {
+		return err
+	}
+	if err := reencryptLoadbalancerCertificatePrivateKeys(); err != nil {
+		return err
+	}
+	if err := reencryptHostIpmiPasswords(); err != nil {
+		return err
+	}
+	if err := reencryptWebhookSecrets(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func reencryptCloudaccountSecrets() error {
+	accounts := []SCloudaccount{}
+	q := CloudaccountManager.Query()
+	if err := db.FetchModelObjects(CloudaccountManager, q, &accounts); err != nil {
+		return err
+	}
+	for i := range accounts {
+		account := &accounts[i]
+		if !keymanager.NeedsReencrypt(account.Secret) {
+			continue
+		}
+		secret, err := keymanager.Reencrypt(account.Id, account.Secret)
+		if err != nil {
+			log.Errorf("reencrypt secret of cloudaccount %s: %s", account.Name, err)
+			continue
+		}
+		if _, err := db.Update(account, func() error {
+			account.Secret = secret
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func reencryptCloudproviderSecrets() error {
+	providers := []SCloudprovider{}
+	q := CloudproviderManager.Query()
+	if err := db.FetchModelObjects(CloudproviderManager, q, &providers); err != nil {
+		return err
+	}
+	for i := range providers {
+		provider := &providers[i]
+		if !keymanager.NeedsReencrypt(provider.Secret) {
+			continue
+		}
+		secret, err := keymanager.Reencrypt(provider.Id, provider.Secret)
+		if err != nil {
+			log.Errorf("reencrypt secret of cloudprovider %s: %s", provider.Name, err)
+			continue
+		}
+		if _, err := db.Update(provider, func() error {
+			provider.Secret = secret
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func reencryptLoadbalancerCertificatePrivateKeys() error {
+	lbcerts := []SLoadbalancerCertificate{}
+	q := LoadbalancerCertificateManager.Query()
+	if err := db.FetchModelObjects(LoadbalancerCertificateManager, q, &lbcerts); err != nil {
+		return err
+	}
+	for i := range lbcerts {
+		lbcert := &lbcerts[i]
+		if !keymanager.NeedsReencrypt(lbcert.PrivateKey) {
+			continue
+		}
+		// private keys were stored in plain text before
+		privateKey, err := keymanager.EncryptPlain(lbcert.Id, lbcert.PrivateKey)
+		if err != nil {
+			log.Errorf("reencrypt private key of loadbalancer certificate %s: %s", lbcert.Name, err)
+			continue
+		}
+		if _, err := db.Update(lbcert, func() error {
+			lbcert.PrivateKey = privateKey
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func reencryptHostIpmiPasswords() error {
+	hosts := []SHost{}
+	q := HostManager.Query().IsNotNull("ipmi_info")
+	if err := db.FetchModelObjects(HostManager, q, &hosts); err != nil {
+		return err
+	}
+	for i := range hosts {
+		host := &hosts[i]
+		if host.IpmiInfo == nil {
+			continue
+		}
+		password, _ := host.IpmiInfo.GetString("password")
+		if !keymanager.NeedsReencrypt(password) {
+			continue
+		}
+		password, err := keymanager.Reencrypt(host.Id, password)
+		if err != nil {
+			log.Errorf("reencrypt ipmi password of host %s: %s", host.Name, err)
+			continue
+		}
+		ipmiInfo := jsonutils.NewDict()
+		ipmiInfo.Update(host.IpmiInfo)
+		ipmiInfo.Set("password", jsonutils.NewString(password))
+		if _, err := db.Update(host, func() error {
+			host.IpmiInfo = ipmiInfo
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func reencryptWebhookSecrets() error {
+	webhooks := []SWebhook{}
+	q := WebhookManager.Query()
+	if err := db.FetchModelObjects(WebhookManager, q, &webhooks); err != nil {
+		return err
+	}
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		if !keymanager.NeedsReencrypt(webhook.Secret) {
+			continue
+		}
+		secret, err := keymanager.Reencrypt(webhook.Id, webhook.Secret)
+		if err != nil {
+			log.Errorf("reencrypt secret of webhook %s: %s", webhook.Name, err)
+			continue
+		}
+		if _, err := db.Update(webhook, func() error {
+			webhook.Secret = secret
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
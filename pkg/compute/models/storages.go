@@ -16,6 +16,7 @@ package models
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"path"
 
@@ -33,6 +34,7 @@ import (
 	"yunion.io/x/onecloud/pkg/compute/options"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/logclient"
 )
 
 /*
@@ -350,6 +352,149 @@ func (self *SStorage) PerformOffline(ctx context.Context, userCred mcclient.Toke
 	return nil, nil
 }
 
+func (self *SStorage) AllowPerformDecommission(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, self, "decommission")
+}
+
+// PerformDecommission disables the storage and live migrates the disks of
+// running guests to target_storage, or to the least used storage attached
+// to the host of each guest. Disks that cannot be moved online are reported
+// back as skipped.
+func (self *SStorage) PerformDecommission(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	var target *SStorage
+	targetStr := jsonutils.GetAnyString(data, []string{"target_storage", "target_storage_id"})
+	if len(targetStr) > 0 {
+		obj, err := StorageManager.FetchByIdOrName(userCred, targetStr)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, httperrors.NewResourceNotFoundError2(StorageManager.Keyword(), targetStr)
+			}
+			return nil, httperrors.NewGeneralError(err)
+		}
+		target = obj.(*SStorage)
+		if target.Id == self.Id {
+			return nil, httperrors.NewInputParameterError("Target storage must differ from decommissioned storage")
+		}
+	}
+	if _, err := self.PerformDisable(ctx, userCred, query, data); err != nil {
+		return nil, err
+	}
+	ret := self.StartMigrateDisksOfRunningGuests(ctx, userCred, target, false)
+	db.OpsLog.LogEvent(self, db.ACT_DECOMMISSION, ret, userCred)
+	logclient.AddActionLogWithContext(ctx, self, logclient.ACT_DECOMMISSION, ret, userCred, true)
+	return ret, nil
+}
+
+// StartMigrateDisksOfRunningGuests starts a storage migration for every disk
+// on the storage used by a running guest. When target is nil a storage is
+// picked on the host of each guest, restricted to shared storages when
+// sharedOnly is set.
+func (self *SStorage) StartMigrateDisksOfRunningGuests(ctx context.Context, userCred mcclient.TokenCredential, target *SStorage, sharedOnly bool) *jsonutils.JSONDict {
+	plan := NewMigrateStoragePlan()
+	plan.AddDisksOfStorage(self, target, sharedOnly)
+	plan.Start(ctx, userCred)
+	return plan.GetResult(self.Id)
+}
+
+// SMigrateStoragePlan groups the disks to migrate by guest. All disks of a
+// guest are validated while the guest is still running, then moved one
+// after another by a single GuestMigrateStorageTask, as a guest migrating a
+// disk is no longer running and would refuse the migration of the others.
+type SMigrateStoragePlan struct {
+	guests    []*SGuest
+	disks     map[string]*jsonutils.JSONArray
+	storages  map[string]string
+	migrating map[string]*jsonutils.JSONArray
+	skipped   map[string]*jsonutils.JSONArray
+}
+
+func NewMigrateStoragePlan() *SMigrateStoragePlan {
+	return &SMigrateStoragePlan{
+		disks:     make(map[string]*jsonutils.JSONArray),
+		storages:  make(map[string]string),
+		migrating: make(map[string]*jsonutils.JSONArray),
+		skipped:   make(map[string]*jsonutils.JSONArray),
+	}
+}
+
+func (plan *SMigrateStoragePlan) getArray(arrays map[string]*jsonutils.JSONArray, key string) *jsonutils.JSONArray {
+	if _, ok := arrays[key]; !ok {
+		arrays[key] = jsonutils.NewArray()
+	}
+	return arrays[key]
+}
+
+func (plan *SMigrateStoragePlan) skip(storageId, diskId, reason string) {
+	plan.getArray(plan.skipped, storageId).Add(jsonutils.Marshal(map[string]string{"disk": diskId, "reason": reason}))
+}
+
+// AddDisksOfStorage adds the disks on the storage to the plan, the disks
+// that cannot be moved online are recorded as skipped.
+func (plan *SMigrateStoragePlan) AddDisksOfStorage(storage *SStorage, target *SStorage, sharedOnly bool) {
+	plan.getArray(plan.migrating, storage.Id)
+	plan.getArray(plan.skipped, storage.Id)
+	disks := storage.GetDisks()
+	for i := range disks {
+		disk := &disks[i]
+		guests := disk.GetGuests()
+		if len(guests) == 0 {
+			plan.skip(storage.Id, disk.Id, "not attached to any guest")
+			continue
+		}
+		targetStorage := target
+		if targetStorage == nil {
+			host := guests[0].GetHost()
+			if host == nil {
+				plan.skip(storage.Id, disk.Id, "guest has no host")
+				continue
+			}
+			targetStorage = host.GetMigrateStorageTarget(storage, disk, sharedOnly)
+			if targetStorage == nil {
+				plan.skip(storage.Id, disk.Id, "no available target storage on host")
+				continue
+			}
+		}
+		guest, err := disk.ValidateMigrateStorage(targetStorage)
+		if err != nil {
+			plan.skip(storage.Id, disk.Id, err.Error())
+			continue
+		}
+		if _, ok := plan.disks[guest.Id]; !ok {
+			plan.guests = append(plan.guests, guest)
+		}
+		plan.getArray(plan.disks, guest.Id).Add(jsonutils.Marshal(map[string]string{
+			"disk_id":           disk.Id,
+			"target_storage_id": targetStorage.Id,
+		}))
+		plan.storages[disk.Id] = storage.Id
+	}
+}
+
+// Start starts a storage migration task for each guest of the plan
+func (plan *SMigrateStoragePlan) Start(ctx context.Context, userCred mcclient.TokenCredential) {
+	for _, guest := range plan.guests {
+		disks := plan.disks[guest.Id]
+		err := guest.StartGuestMigrateStorageTask(ctx, userCred, disks, "")
+		for _, disk := range disks.Value() {
+			diskId, _ := disk.GetString("disk_id")
+			storageId := plan.storages[diskId]
+			if err != nil {
+				plan.skip(storageId, diskId, err.Error())
+			} else {
+				plan.getArray(plan.migrating, storageId).Add(jsonutils.NewString(diskId))
+			}
+		}
+	}
+}
+
+// GetResult returns the disks of the storage being migrated and skipped
+func (plan *SMigrateStoragePlan) GetResult(storageId string) *jsonutils.JSONDict {
+	ret := jsonutils.NewDict()
+	ret.Set("migrating", plan.getArray(plan.migrating, storageId))
+	ret.Set("skipped", plan.getArray(plan.skipped, storageId))
+	return ret
+}
+
 func (self *SStorage) GetHostCount() (int, error) {
 	return HoststorageManager.Query().Equals("storage_id", self.Id).CountWithError()
 }
@@ -0,0 +1,220 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/util/stringutils"
+	"yunion.io/x/pkg/util/timeutils"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	webhookRetryInterval    = 30 * time.Second
+	webhookMaxRetryInterval = time.Hour
+)
+
+type SWebhookDeliveryManager struct {
+	db.SStandaloneResourceBaseManager
+}
+
+var WebhookDeliveryManager *SWebhookDeliveryManager
+
+func init() {
+	WebhookDeliveryManager = &SWebhookDeliveryManager{
+		SStandaloneResourceBaseManager: db.NewStandaloneResourceBaseManager(
+			SWebhookDelivery{},
+			"webhook_deliveries_tbl",
+			"webhook_delivery",
+			"webhook_deliveries",
+		),
+	}
+}
+
+// SWebhookDelivery records an event to be posted to a webhook and the
+// outcome of the attempts to post it
+type SWebhookDelivery struct {
+	db.SStandaloneResourceBase
+
+	WebhookId string `width:"36" charset:"ascii" nullable:"false" index:"true" list:"user"`
+	EventSeq  int64  `nullable:"false" list:"user"`
+	ObjType   string `width:"40" charset:"ascii" nullable:"false" list:"user"`
+	ObjId     string `width:"128" charset:"ascii" nullable:"false" list:"user"`
+	ObjName   string `width:"128" charset:"utf8" nullable:"false" list:"user"`
+	Action    string `width:"32" charset:"utf8" nullable:"false" list:"user"`
+	Payload   string `charset:"utf8" nullable:"false" get:"user"`
+
+	Status        string    `width:"16" charset:"ascii" nullable:"false" index:"true" list:"user"`
+	Attempts      int       `nullable:"false" default:"0" list:"user"`
+	NextAttemptAt time.Time `nullable:"true" list:"user"`
+	ResponseCode  int       `nullable:"false" default:"0" list:"user"`
+	LastError     string    `width:"256" charset:"utf8" nullable:"true" list:"user"`
+	DeliveredAt   time.Time `nullable:"true" list:"user"`
+}
+
+func (manager *SWebhookDeliveryManager) createDelivery(webhook *SWebhook, opslog *db.SOpsLog) error {
+	payload := jsonutils.NewDict()
+	payload.Add(jsonutils.NewString(webhook.Id), "webhook_id")
+	payload.Add(opslog.ChangeEvent(), "event")
+
+	delivery := SWebhookDelivery{}
+	delivery.SetModelManager(manager)
+	delivery.Id = stringutils.UUID4()
+	delivery.Name = fmt.Sprintf("%s-%d", webhook.Name, opslog.Id)
+	delivery.WebhookId = webhook.Id
+	delivery.EventSeq = opslog.Id
+	delivery.ObjType = opslog.ObjType
+	delivery.ObjId = opslog.ObjId
+	delivery.ObjName = opslog.ObjName
+	delivery.Action = opslog.Action
+	payload.Add(jsonutils.NewString(delivery.Id), "delivery_id")
+	delivery.Payload = payload.String()
+	delivery.Status = api.WEBHOOK_DELIVERY_PENDING
+	delivery.NextAttemptAt = timeutils.UtcNow()
+	return manager.TableSpec().Insert(&delivery)
+}
+
+func (manager *SWebhookDeliveryManager) AllowListItems(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return true
+}
+
+func (manager *SWebhookDeliveryManager) ListItemFilter(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, query jsonutils.JSONObject) (*sqlchemy.SQuery, error) {
+	q, err := manager.SStandaloneResourceBaseManager.ListItemFilter(ctx, q, userCred, query)
+	if err != nil {
+		return nil, err
+	}
+	admin, _ := query.GetString("admin")
+	if !utils.ToBool(admin) || !db.IsAdminAllowList(userCred, manager) {
+		webhooks := WebhookManager.Query("id").Equals("tenant_id", userCred.GetProjectId())
+		q = q.In("webhook_id", webhooks.SubQuery())
+	}
+	webhookStr := jsonutils.GetAnyString(query, []string{"webhook", "webhook_id"})
+	if len(webhookStr) > 0 {
+		webhook, err := WebhookManager.FetchByIdOrName(userCred, webhookStr)
+		if err != nil {
+			return nil, httperrors.NewResourceNotFoundError2(WebhookManager.Keyword(), webhookStr)
+		}
+		q = q.Equals("webhook_id", webhook.GetId())
+	}
+	status, _ := query.GetString("status")
+	if len(status) > 0 {
+		q = q.Equals("status", status)
+	}
+	return q, nil
+}
+
+func (self *SWebhookDelivery) getWebhook() *SWebhook {
+	obj, err := WebhookManager.FetchById(self.WebhookId)
+	if err != nil {
+		return nil
+	}
+	return obj.(*SWebhook)
+}
+
+func (self *SWebhookDelivery) isOwner(userCred mcclient.TokenCredential) bool {
+	webhook := self.getWebhook()
+	return webhook != nil && webhook.IsOwner(userCred)
+}
+
+func (self *SWebhookDelivery) AllowGetDetails(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return self.isOwner(userCred) || db.IsAdminAllowGet(userCred, self)
+}
+
+func (self *SWebhookDelivery) AllowPerformRedeliver(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return self.isOwner(userCred) || db.IsAdminAllowPerform(userCred, self, "redeliver")
+}
+
+// PerformRedeliver schedules the delivery to be posted again, whatever the
+// outcome of the previous attempts
+func (self *SWebhookDelivery) PerformRedeliver(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if self.getWebhook() == nil {
+		return nil, httperrors.NewResourceNotFoundError2(WebhookManager.Keyword(), self.WebhookId)
+	}
+	_, err := db.Update(self, func() error {
+		self.Status = api.WEBHOOK_DELIVERY_PENDING
+		self.Attempts = 0
+		self.NextAttemptAt = timeutils.UtcNow()
+		return nil
+	})
+	if err != nil {
+		return nil, httperrors.NewInternalServerError("update delivery fail %s", err)
+	}
+	return nil, nil
+}
+
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryInterval
+	for i := 1; i < attempts && delay < webhookMaxRetryInterval; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxRetryInterval {
+		delay = webhookMaxRetryInterval
+	}
+	return delay
+}
+
+func (self *SWebhookDelivery) attempt(ctx context.Context, webhook *SWebhook) {
+	code, err := webhook.post(ctx, self)
+	_, uerr := db.Update(self, func() error {
+		self.Attempts += 1
+		self.ResponseCode = code
+		if err == nil {
+			self.Status = api.WEBHOOK_DELIVERY_SUCCEEDED
+			self.LastError = ""
+			self.DeliveredAt = timeutils.UtcNow()
+			return nil
+		}
+		self.LastError = truncateWebhookError(err.Error())
+		if self.Attempts >= options.Options.WebhookMaxAttempts {
+			self.Status = api.WEBHOOK_DELIVERY_FAILED
+		} else {
+			self.NextAttemptAt = timeutils.UtcNow().Add(webhookRetryDelay(self.Attempts))
+		}
+		return nil
+	})
+	if uerr != nil {
+		log.Errorf("update webhook delivery %s fail %s", self.Id, uerr)
+	}
+}
+
+func (self *SWebhookDelivery) markFailed(reason string) {
+	_, err := db.Update(self, func() error {
+		self.Status = api.WEBHOOK_DELIVERY_FAILED
+		self.LastError = reason
+		return nil
+	})
+	if err != nil {
+		log.Errorf("update webhook delivery %s fail %s", self.Id, err)
+	}
+}
+
+func truncateWebhookError(msg string) string {
+	if len(msg) > 256 {
+		return msg[:256]
+	}
+	return msg
+}
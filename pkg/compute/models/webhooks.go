@@ -0,0 +1,490 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/util/netutils"
+	"yunion.io/x/pkg/util/stringutils"
+	"yunion.io/x/pkg/util/timeutils"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/keymanager"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+const (
+	WEBHOOK_SECRET_MIN_LENGTH = 16
+
+	webhookEventBatchSize = 1024
+	webhookSendBatchSize  = 256
+	webhookSendWorkers    = 8
+)
+
+type SWebhookManager struct {
+	db.SVirtualResourceBaseManager
+}
+
+var WebhookManager *SWebhookManager
+
+func init() {
+	WebhookManager = &SWebhookManager{
+		SVirtualResourceBaseManager: db.NewVirtualResourceBaseManager(
+			SWebhook{},
+			"webhooks_tbl",
+			"webhook",
+			"webhooks",
+		),
+	}
+}
+
+// SWebhook posts the operation logs matching its filter to an https
+// endpoint.  Events are turned into deliveries by the dispatcher, LastSeq is
+// the id of the last operation log examined for the webhook
+type SWebhook struct {
+	db.SVirtualResourceBase
+
+	Url    string `width:"512" charset:"ascii" nullable:"false" list:"user" create:"required" update:"user"`
+	Secret string `width:"256" charset:"ascii" nullable:"false" create:"required"`
+
+	Enabled bool `nullable:"false" default:"true" list:"user" create:"optional" update:"user"`
+
+	// comma separated, empty for all
+	ResourceTypes string `width:"256" charset:"ascii" nullable:"false" default:"" list:"user" create:"optional" update:"user"`
+	Actions       string `width:"512" charset:"ascii" nullable:"false" default:"" list:"user" create:"optional" update:"user"`
+	Result        string `width:"16" charset:"ascii" nullable:"false" default:"any" list:"user" create:"optional" update:"user"`
+
+	// also receive the events of other projects
+	AllProjects bool `nullable:"false" default:"false" list:"admin" create:"admin_optional" update:"admin"`
+
+	LastSeq int64 `nullable:"false" default:"0" list:"admin"`
+}
+
+func validateWebhookUrl(urlStr string) error {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return httperrors.NewInputParameterError("invalid url %s: %s", urlStr, err)
+	}
+	if u.Scheme != "https" || len(u.Host) == 0 {
+		return httperrors.NewInputParameterError("url %s is not an https url", urlStr)
+	}
+	if options.Options.WebhookAllowPrivateNetwork {
+		return nil
+	}
+	host := u.Hostname()
+	if strings.ToLower(host) == "localhost" {
+		return httperrors.NewInputParameterError("url %s is not a public address", urlStr)
+	}
+	if ip := net.ParseIP(host); ip != nil && !isWebhookIPAllowed(ip) {
+		return httperrors.NewInputParameterError("url %s is not a public address", urlStr)
+	}
+	return nil
+}
+
+// isWebhookIPAllowed returns false for loopback, link-local, multicast and
+// private addresses, which webhooks must not reach unless
+// WebhookAllowPrivateNetwork is set
+func isWebhookIPAllowed(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		addr, err := netutils.NewIPV4Addr(ip4.String())
+		return err == nil && netutils.IsExitAddress(addr)
+	}
+	// unique local fc00::/7
+	return ip[0]&0xfe != 0xfc
+}
+
+// webhookDialControl checks the resolved address of every connection, so
+// that names resolving to private addresses and redirects are covered too
+func webhookDialControl(network, address string, c syscall.RawConn) error {
+	if options.Options.WebhookAllowPrivateNetwork {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isWebhookIPAllowed(ip) {
+		return fmt.Errorf("address %s is not a public address", host)
+	}
+	return nil
+}
+
+var (
+	webhookTransport     *http.Transport
+	webhookTransportOnce sync.Once
+)
+
+// getWebhookTransport returns the transport shared by all deliveries, it
+// verifies the certificate of https endpoints since payloads are signed
+// with the webhook secret
+func getWebhookTransport() *http.Transport {
+	webhookTransportOnce.Do(func() {
+		webhookTransport = &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 5 * time.Second,
+				Control: webhookDialControl,
+			}).DialContext,
+			IdleConnTimeout:     30 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig:     &tls.Config{},
+		}
+	})
+	return webhookTransport
+}
+
+func getWebhookHttpClient() *http.Client {
+	return &http.Client{
+		Transport: getWebhookTransport(),
+		Timeout:   time.Duration(options.Options.WebhookTimeoutSeconds) * time.Second,
+	}
+}
+
+func validateWebhookFilter(data *jsonutils.JSONDict) error {
+	for _, key := range []string{"resource_types", "actions"} {
+		if !data.Contains(key) {
+			continue
+		}
+		value, _ := data.GetString(key)
+		data.Set(key, jsonutils.NewString(strings.Join(splitWebhookFilter(value), ",")))
+	}
+	if data.Contains("result") {
+		result, _ := data.GetString("result")
+		if !utils.IsInStringArray(result, []string{api.WEBHOOK_RESULT_ANY, api.WEBHOOK_RESULT_SUCCESS, api.WEBHOOK_RESULT_FAILURE}) {
+			return httperrors.NewInputParameterError("invalid result %s, want %s, %s or %s", result,
+				api.WEBHOOK_RESULT_ANY, api.WEBHOOK_RESULT_SUCCESS, api.WEBHOOK_RESULT_FAILURE)
+		}
+	}
+	return nil
+}
+
+func validateWebhookSecret(data jsonutils.JSONObject) (string, error) {
+	secret, _ := data.GetString("secret")
+	if len(secret) < WEBHOOK_SECRET_MIN_LENGTH {
+		return "", httperrors.NewInputParameterError("secret must be at least %d characters", WEBHOOK_SECRET_MIN_LENGTH)
+	}
+	return secret, nil
+}
+
+func splitWebhookFilter(value string) []string {
+	ret := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+func (manager *SWebhookManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerProjId string, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	urlStr, _ := data.GetString("url")
+	if err := validateWebhookUrl(urlStr); err != nil {
+		return nil, err
+	}
+	secret, err := validateWebhookSecret(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateWebhookFilter(data); err != nil {
+		return nil, err
+	}
+	// secret is bound to the id of the webhook, allocate it here so that
+	// secret is never stored in plain text
+	id := stringutils.UUID4()
+	sec, err := keymanager.Encrypt(id, secret)
+	if err != nil {
+		return nil, httperrors.NewInternalServerError("encrypt secret fail %s", err)
+	}
+	data.Set("id", jsonutils.NewString(id))
+	data.Set("secret", jsonutils.NewString(sec))
+	return manager.SVirtualResourceBaseManager.ValidateCreateData(ctx, userCred, ownerProjId, query, data)
+}
+
+func (self *SWebhook) CustomizeCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerProjId string, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
+	self.Id, _ = data.GetString("id")
+	// only events logged from now on are delivered
+	lastSeq, err := db.OpsLog.FetchLastSeq()
+	if err != nil {
+		return httperrors.NewInternalServerError("fetch last event fail %s", err)
+	}
+	self.LastSeq = lastSeq
+	if len(self.Result) == 0 {
+		self.Result = api.WEBHOOK_RESULT_ANY
+	}
+	return self.SVirtualResourceBase.CustomizeCreate(ctx, userCred, ownerProjId, query, data)
+}
+
+func (self *SWebhook) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	if data.Contains("url") {
+		urlStr, _ := data.GetString("url")
+		if err := validateWebhookUrl(urlStr); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateWebhookFilter(data); err != nil {
+		return nil, err
+	}
+	return self.SVirtualResourceBase.ValidateUpdateData(ctx, userCred, query, data)
+}
+
+func (self *SWebhook) saveSecret(secret string) error {
+	sec, err := keymanager.Encrypt(self.Id, secret)
+	if err != nil {
+		return err
+	}
+	_, err = db.Update(self, func() error {
+		self.Secret = sec
+		return nil
+	})
+	return err
+}
+
+func (self *SWebhook) getSecret() (string, error) {
+	return keymanager.Decrypt(self.Id, self.Secret)
+}
+
+func (self *SWebhook) AllowPerformSetSecret(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return self.IsOwner(userCred) || db.IsAdminAllowPerform(userCred, self, "set-secret")
+}
+
+func (self *SWebhook) PerformSetSecret(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	secret, err := validateWebhookSecret(data)
+	if err != nil {
+		return nil, err
+	}
+	err = self.saveSecret(secret)
+	if err != nil {
+		return nil, httperrors.NewInternalServerError("save secret fail %s", err)
+	}
+	db.OpsLog.LogEvent(self, db.ACT_UPDATE, "set secret", userCred)
+	return nil, nil
+}
+
+func (self *SWebhook) matchEvent(opslog *db.SOpsLog) bool {
+	if !self.AllProjects {
+		ownerId := opslog.OwnerProjectId
+		if len(ownerId) == 0 {
+			ownerId = opslog.ProjectId
+		}
+		if ownerId != self.ProjectId {
+			return false
+		}
+	}
+	if resTypes := splitWebhookFilter(self.ResourceTypes); len(resTypes) > 0 && !utils.IsInStringArray(opslog.ObjType, resTypes) {
+		return false
+	}
+	if actions := splitWebhookFilter(self.Actions); len(actions) > 0 && !utils.IsInStringArray(opslog.Action, actions) {
+		return false
+	}
+	failed := strings.Contains(opslog.Action, "fail")
+	switch self.Result {
+	case api.WEBHOOK_RESULT_SUCCESS:
+		return !failed
+	case api.WEBHOOK_RESULT_FAILURE:
+		return failed
+	}
+	return true
+}
+
+// DispatchEvents turns new operation logs into deliveries of the matching
+// webhooks, and then sends the deliveries that are due
+func (manager *SWebhookManager) DispatchEvents(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	err := manager.dispatchEvents()
+	if err != nil {
+		log.Errorf("dispatch webhook events fail %s", err)
+	}
+	WebhookDeliveryManager.sendDueDeliveries(ctx)
+}
+
+func (manager *SWebhookManager) dispatchEvents() error {
+	webhooks := make([]SWebhook, 0)
+	q := manager.Query()
+	q = q.Filter(sqlchemy.OR(sqlchemy.IsNull(q.Field("pending_deleted")), sqlchemy.IsFalse(q.Field("pending_deleted"))))
+	err := db.FetchModelObjects(manager, q, &webhooks)
+	if err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+	since := webhooks[0].LastSeq
+	for i := range webhooks {
+		if webhooks[i].LastSeq < since {
+			since = webhooks[i].LastSeq
+		}
+	}
+	for {
+		logs, err := db.OpsLog.FetchLogsAfter(since, "", webhookEventBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		for i := range webhooks {
+			err := webhooks[i].enqueueEvents(logs)
+			if err != nil {
+				return err
+			}
+		}
+		since = logs[len(logs)-1].Id
+		if len(logs) < webhookEventBatchSize {
+			return nil
+		}
+	}
+}
+
+func (self *SWebhook) enqueueEvents(logs []db.SOpsLog) error {
+	lastSeq := self.LastSeq
+	for i := range logs {
+		opslog := &logs[i]
+		if opslog.Id <= self.LastSeq {
+			continue
+		}
+		lastSeq = opslog.Id
+		// deliveries are not logged, webhook events stay out of the
+		// deliveries to avoid feeding webhooks with their own changes
+		if !self.Enabled || opslog.ObjType == WebhookManager.Keyword() {
+			continue
+		}
+		if !self.matchEvent(opslog) {
+			continue
+		}
+		err := WebhookDeliveryManager.createDelivery(self, opslog)
+		if err != nil {
+			return err
+		}
+	}
+	if lastSeq == self.LastSeq {
+		return nil
+	}
+	_, err := db.Update(self, func() error {
+		self.LastSeq = lastSeq
+		return nil
+	})
+	return err
+}
+
+func (self *SWebhook) sign(timestamp string, body []byte) (string, error) {
+	secret, err := self.getSecret()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// post sends a payload to the webhook, returning the response code, if any
+func (self *SWebhook) post(ctx context.Context, delivery *SWebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+	signature, err := self.sign(timestamp, body)
+	if err != nil {
+		return 0, fmt.Errorf("sign payload fail %s", err)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(api.WEBHOOK_HEADER_ID, self.Id)
+	header.Set(api.WEBHOOK_HEADER_DELIVERY, delivery.Id)
+	header.Set(api.WEBHOOK_HEADER_EVENT, delivery.Action)
+	header.Set(api.WEBHOOK_HEADER_TIMESTAMP, timestamp)
+	header.Set(api.WEBHOOK_HEADER_SIGNATURE, signature)
+
+	client := getWebhookHttpClient()
+	resp, err := httputils.Request(client, ctx, httputils.POST, self.Url, header, bytes.NewReader(body), false)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (manager *SWebhookDeliveryManager) sendDueDeliveries(ctx context.Context) {
+	deliveries := make([]SWebhookDelivery, 0)
+	// deliveries of disabled webhooks stay pending without blocking others
+	disabled := WebhookManager.Query("id").IsFalse("enabled")
+	q := manager.Query().Equals("status", api.WEBHOOK_DELIVERY_PENDING)
+	q = q.LE("next_attempt_at", timeutils.UtcNow())
+	q = q.NotIn("webhook_id", disabled.SubQuery())
+	q = q.Asc("event_seq").Limit(webhookSendBatchSize)
+	err := db.FetchModelObjects(manager, q, &deliveries)
+	if err != nil {
+		log.Errorf("fetch due webhook deliveries fail %s", err)
+		return
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	webhooks := make(map[string]*SWebhook)
+	sem := make(chan struct{}, webhookSendWorkers)
+	wg := &sync.WaitGroup{}
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		webhook, ok := webhooks[delivery.WebhookId]
+		if !ok {
+			webhook = delivery.getWebhook()
+			webhooks[delivery.WebhookId] = webhook
+		}
+		if webhook == nil {
+			delivery.markFailed("webhook not found")
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			delivery.attempt(ctx, webhook)
+		}()
+	}
+	wg.Wait()
+}
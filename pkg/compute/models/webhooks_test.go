@@ -0,0 +1,244 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+)
+
+func TestWebhookSign(t *testing.T) {
+	cases := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{
+			name:      "payload",
+			secret:    "0123456789abcdef",
+			timestamp: "1570000000",
+			body:      `{"event":{}}`,
+			want:      "sha256=600f3247dc74aa79d499ac5c32c8065705b676b31c6568173f6f9d09b14e8205",
+		},
+		{
+			name:      "timestamp signed",
+			secret:    "0123456789abcdef",
+			timestamp: "1570000001",
+			body:      `{"event":{}}`,
+			want:      "sha256=18d9bbd80be3a6263712ccd9dd2e9b728f9c8daa5f4c822e274d1726c3e11188",
+		},
+		{
+			name:      "empty body",
+			secret:    "fedcba9876543210",
+			timestamp: "1570000000",
+			body:      "",
+			want:      "sha256=4b91ac308478daf1d2dfebcbf858cbdc91ba82bfd7322dcdef754de4f8bded9c",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			webhook := &SWebhook{}
+			webhook.Id = "webhook-id"
+			sec, err := utils.EncryptAESBase64(webhook.Id, c.secret)
+			if err != nil {
+				t.Fatalf("encrypt secret: %s", err)
+			}
+			webhook.Secret = sec
+			got, err := webhook.sign(c.timestamp, []byte(c.body))
+			if err != nil {
+				t.Fatalf("sign: %s", err)
+			}
+			if got != c.want {
+				t.Errorf("want %s, got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestWebhookMatchEvent(t *testing.T) {
+	newWebhook := func(resTypes, actions, result string, allProjects bool) *SWebhook {
+		webhook := &SWebhook{
+			ResourceTypes: resTypes,
+			Actions:       actions,
+			Result:        result,
+			AllProjects:   allProjects,
+		}
+		webhook.ProjectId = "p1"
+		return webhook
+	}
+	newLog := func(projectId, ownerProjectId, objType, action string) *db.SOpsLog {
+		return &db.SOpsLog{
+			ProjectId:      projectId,
+			OwnerProjectId: ownerProjectId,
+			ObjType:        objType,
+			Action:         action,
+		}
+	}
+	cases := []struct {
+		name    string
+		webhook *SWebhook
+		opslog  *db.SOpsLog
+		want    bool
+	}{
+		{
+			name:    "all events of own project",
+			webhook: newWebhook("", "", api.WEBHOOK_RESULT_ANY, false),
+			opslog:  newLog("p1", "", "server", "create"),
+			want:    true,
+		},
+		{
+			name:    "other project",
+			webhook: newWebhook("", "", api.WEBHOOK_RESULT_ANY, false),
+			opslog:  newLog("p2", "", "server", "create"),
+			want:    false,
+		},
+		{
+			name:    "owner project takes precedence",
+			webhook: newWebhook("", "", api.WEBHOOK_RESULT_ANY, false),
+			opslog:  newLog("p2", "p1", "server", "create"),
+			want:    true,
+		},
+		{
+			name:    "all projects",
+			webhook: newWebhook("", "", api.WEBHOOK_RESULT_ANY, true),
+			opslog:  newLog("p2", "", "server", "create"),
+			want:    true,
+		},
+		{
+			name:    "resource type matched",
+			webhook: newWebhook("disk, server", "", api.WEBHOOK_RESULT_ANY, false),
+			opslog:  newLog("p1", "", "server", "create"),
+			want:    true,
+		},
+		{
+			name:    "resource type not matched",
+			webhook: newWebhook("disk", "", api.WEBHOOK_RESULT_ANY, false),
+			opslog:  newLog("p1", "", "server", "create"),
+			want:    false,
+		},
+		{
+			name:    "action not matched",
+			webhook: newWebhook("", "delete", api.WEBHOOK_RESULT_ANY, false),
+			opslog:  newLog("p1", "", "server", "create"),
+			want:    false,
+		},
+		{
+			name:    "success only",
+			webhook: newWebhook("", "", api.WEBHOOK_RESULT_SUCCESS, false),
+			opslog:  newLog("p1", "", "server", "start_fail"),
+			want:    false,
+		},
+		{
+			name:    "failure only",
+			webhook: newWebhook("", "", api.WEBHOOK_RESULT_FAILURE, false),
+			opslog:  newLog("p1", "", "server", "start_fail"),
+			want:    true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.webhook.matchEvent(c.opslog); got != c.want {
+				t.Errorf("want %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: 30 * time.Second},
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 3, want: 2 * time.Minute},
+		{attempts: 7, want: 32 * time.Minute},
+		{attempts: 8, want: time.Hour},
+		{attempts: 100, want: time.Hour},
+	}
+	for _, c := range cases {
+		if got := webhookRetryDelay(c.attempts); got != c.want {
+			t.Errorf("attempts %d: want %s, got %s", c.attempts, c.want, got)
+		}
+	}
+}
+
+func TestWebhookIPAllowed(t *testing.T) {
+	cases := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "8.8.8.8", want: true},
+		{ip: "127.0.0.1", want: false},
+		{ip: "0.0.0.0", want: false},
+		{ip: "10.1.2.3", want: false},
+		{ip: "172.16.0.1", want: false},
+		{ip: "192.168.1.1", want: false},
+		{ip: "169.254.169.254", want: false},
+		{ip: "224.0.0.1", want: false},
+		{ip: "2001:4860:4860::8888", want: true},
+		{ip: "::1", want: false},
+		{ip: "fe80::1", want: false},
+		{ip: "fd00::1", want: false},
+		{ip: "::ffff:127.0.0.1", want: false},
+	}
+	for _, c := range cases {
+		if got := isWebhookIPAllowed(net.ParseIP(c.ip)); got != c.want {
+			t.Errorf("%s: want %v, got %v", c.ip, c.want, got)
+		}
+	}
+}
+
+func TestValidateWebhookUrl(t *testing.T) {
+	cases := []struct {
+		url   string
+		isErr bool
+	}{
+		{url: "https://example.com/hook"},
+		{url: "https://8.8.8.8:8443/hook"},
+		{url: "http://example.com/hook", isErr: true},
+		{url: "https://localhost/hook", isErr: true},
+		{url: "https://127.0.0.1/hook", isErr: true},
+		{url: "https://[::1]:443/hook", isErr: true},
+		{url: "https://169.254.169.254/latest/meta-data", isErr: true},
+		{url: "https://192.168.0.10/hook", isErr: true},
+	}
+	for _, c := range cases {
+		err := validateWebhookUrl(c.url)
+		if (err != nil) != c.isErr {
+			t.Errorf("%s: want error %v, got %v", c.url, c.isErr, err)
+		}
+	}
+}
+
+func TestWebhookHttpClient(t *testing.T) {
+	a, b := getWebhookHttpClient(), getWebhookHttpClient()
+	if a.Transport != b.Transport {
+		t.Errorf("transport should be shared by deliveries")
+	}
+	if tr := a.Transport.(*http.Transport); tr.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("certificate of webhook endpoint should be verified")
+	}
+}
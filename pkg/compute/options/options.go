@@ -15,6 +15,7 @@
 package options
 
 import (
+	"yunion.io/x/onecloud/pkg/cloudcommon/keymanager"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	"yunion.io/x/onecloud/pkg/cloudcommon/pending_delete"
 )
@@ -38,11 +39,14 @@ type ComputeOptions struct {
 
 	pending_delete.SPendingDeleteOptions
 
+	keymanager.SKeyManagerOptions
+
 	PrepaidExpireCheck              bool `default:"false" help:"clean expired servers or disks"`
 	PrepaidExpireCheckSeconds       int  `default:"600" help:"How long to wait to scan expired prepaid VM or disks, default is 10 minutes"`
 	ExpiredPrepaidMaxCleanBatchSize int  `default:"50" help:"How many expired prepaid servers can be deleted in a batch"`
 
 	LoadbalancerPendingDeleteCheckInterval int `default:"3600" help:"Interval between checks of pending deleted loadbalancer objects, defaults to 1h"`
+	LoadbalancerTrafficCheckInterval       int `default:"10" help:"Interval between checks of draining loadbalancer backends and listener traffic shifting, defaults to 10 seconds"`
 
 	ImageCacheStoragePolicy string `default:"least_used" choices:"best_fit|least_used" help:"Policy to choose storage for image cache, best_fit or least_used"`
 	MetricsRetentionDays    int32  `default:"30" help:"Retention days for monitoring metrics in influxdb"`
@@ -84,7 +88,8 @@ type ComputeOptions struct {
 	ConvertEsxiDefaultTemplate       string `help:"ESXI baremetal convert option"`
 	ConvertKubeletDockerVolumeSize   string `default:"256g" help:"Docker volume size"`
 
-	NfsDefaultImageCacheDir string `default:"image_cache"`
+	NfsDefaultImageCacheDir  string `default:"image_cache"`
+	ClvmDefaultImageCacheDir string `default:"/opt/cloud/workspace/clvm_image_cache" help:"Image cache directory on hosts for shared LVM storages"`
 
 	SnapshotCreateDiskProtocol string `help:"Snapshot create disk protocol" choices:"url|fuse" default:"fuse"`
 
@@ -107,6 +112,11 @@ type ComputeOptions struct {
 
 	IsSlaveNode bool `help:"Region service slave node"`
 
+	WebhookDispatchIntervalSeconds int  `help:"Interval to turn events into webhook deliveries and send them" default:"10"`
+	WebhookTimeoutSeconds          int  `help:"Timeout of a webhook request" default:"10"`
+	WebhookMaxAttempts             int  `help:"Give up a webhook delivery after this many failed attempts" default:"8"`
+	WebhookAllowPrivateNetwork     bool `help:"Allow webhooks to post to loopback, link-local and private addresses"`
+
 	SCapabilityOptions
 	common_options.CommonOptions
 	common_options.DBOptions
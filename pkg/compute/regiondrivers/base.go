@@ -21,6 +21,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	"yunion.io/x/onecloud/pkg/cloudprovider"
 	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 )
 
@@ -83,6 +84,10 @@ func (self *SBaseRegionDriver) RequestDeleteLoadbalancerBackend(ctx context.Cont
 	return fmt.Errorf("Not Implement RequestDeleteLoadbalancerBackend")
 }
 
+func (self *SBaseRegionDriver) ValidateDrainLoadbalancerBackendCondition(ctx context.Context, lbb *models.SLoadbalancerBackend) error {
+	return httperrors.NewNotImplementedError("draining loadbalancer backend is not supported")
+}
+
 func (self *SBaseRegionDriver) RequestCreateLoadbalancerListener(ctx context.Context, userCred mcclient.TokenCredential, lblis *models.SLoadbalancerListener, task taskman.ITask) error {
 	return fmt.Errorf("Not Implement RequestCreateLoadbalancerListener")
 }
@@ -107,6 +112,10 @@ func (self *SBaseRegionDriver) RequestSyncLoadbalancerListener(ctx context.Conte
 	return fmt.Errorf("Not Implement RequestSyncLoadbalancerListener")
 }
 
+func (self *SBaseRegionDriver) ValidateShiftLoadbalancerListenerCondition(ctx context.Context, lblis *models.SLoadbalancerListener) error {
+	return httperrors.NewNotImplementedError("shifting loadbalancer listener traffic is not supported")
+}
+
 func (self *SBaseRegionDriver) RequestCreateLoadbalancerListenerRule(ctx context.Context, userCred mcclient.TokenCredential, lbr *models.SLoadbalancerListenerRule, task taskman.ITask) error {
 	return fmt.Errorf("Not Implement RequestCreateLoadbalancerListenerRule")
 }
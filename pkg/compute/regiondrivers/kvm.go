@@ -27,6 +27,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	"yunion.io/x/onecloud/pkg/cloudprovider"
 	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 )
 
@@ -233,6 +234,18 @@ func (self *SKVMRegionDriver) ValidateDeleteLoadbalancerBackendCondition(ctx con
 	return nil
 }
 
+func (self *SKVMRegionDriver) ValidateDrainLoadbalancerBackendCondition(ctx context.Context, lbb *models.SLoadbalancerBackend) error {
+	return nil
+}
+
+func (self *SKVMRegionDriver) ValidateShiftLoadbalancerListenerCondition(ctx context.Context, lblis *models.SLoadbalancerListener) error {
+	switch lblis.ListenerType {
+	case api.LB_LISTENER_TYPE_TCP, api.LB_LISTENER_TYPE_HTTP, api.LB_LISTENER_TYPE_HTTPS:
+		return nil
+	}
+	return httperrors.NewUnsupportOperationError("cannot shift traffic of %s listener", lblis.ListenerType)
+}
+
 func (self *SKVMRegionDriver) ValidateDeleteLoadbalancerBackendGroupCondition(ctx context.Context, lbbg *models.SLoadbalancerBackendGroup) error {
 	return nil
 }
@@ -340,9 +340,13 @@ func (self *SManagedVirtualizationRegionDriver) RequestCreateLoadbalancerCertifi
 		if err != nil {
 			return nil, err
 		}
+		privateKey, err := lbcert.GetPrivateKey()
+		if err != nil {
+			return nil, err
+		}
 		certificate := &cloudprovider.SLoadbalancerCertificate{
 			Name:        lbcert.Name,
-			PrivateKey:  lbcert.PrivateKey,
+			PrivateKey:  privateKey,
 			Certificate: lbcert.Certificate,
 		}
 		iLoadbalancerCert, err := iRegion.CreateILoadBalancerCertificate(certificate)
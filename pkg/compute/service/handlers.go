@@ -31,6 +31,8 @@ import (
 func InitHandlers(app *appsrv.Application) {
 	db.InitAllManagers()
 
+	app.RegisterMetrics(models.CloudSyncDuration)
+
 	quotas.AddQuotaHandler(models.QuotaManager, "", app)
 	usages.AddUsageHandler("", app)
 	capabilities.AddCapabilityHandler("", app)
@@ -46,6 +48,7 @@ func InitHandlers(app *appsrv.Application) {
 		db.UserCacheManager,
 		db.TenantCacheManager,
 		db.Metadata,
+		db.IdempotencyKeyManager,
 		models.GuestcdromManager,
 		models.NetInterfaceManager,
 		models.VCenterManager,
@@ -99,6 +102,9 @@ func InitHandlers(app *appsrv.Application) {
 
 		models.ServerSkuManager,
 		models.ExternalProjectManager,
+
+		models.WebhookManager,
+		models.WebhookDeliveryManager,
 	} {
 		db.RegisterModelManager(manager)
 		handler := db.NewModelHandler(manager)
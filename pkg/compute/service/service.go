@@ -26,6 +26,8 @@ import (
 	app_common "yunion.io/x/onecloud/pkg/cloudcommon/app"
 	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/keymanager"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	_ "yunion.io/x/onecloud/pkg/compute/guestdrivers"
 	_ "yunion.io/x/onecloud/pkg/compute/hostdrivers"
@@ -62,6 +64,10 @@ func StartService() {
 		log.Infof("Auth complete!!")
 	})
 
+	if err := keymanager.Init(&opts.SKeyManagerOptions); err != nil {
+		log.Fatalf("init key manager: %s", err)
+	}
+
 	cloudcommon.InitDB(dbOpts)
 	defer cloudcommon.CloseDB()
 
@@ -86,10 +92,17 @@ func StartService() {
 	models.InitSyncWorkers(options.Options.CloudSyncWorkerCount)
 
 	if !opts.IsSlaveNode {
+		if err := models.ReencryptSecrets(); err != nil {
+			log.Errorf("ReencryptSecrets fail: %s", err)
+		}
+
 		cron := cronman.GetCronJobManager(true)
+		cron.EnableLeaderElection("region")
 		cron.AddJob1("CleanPendingDeleteServers", time.Duration(opts.PendingDeleteCheckSeconds)*time.Second, models.GuestManager.CleanPendingDeleteServers)
 		cron.AddJob1("CleanPendingDeleteDisks", time.Duration(opts.PendingDeleteCheckSeconds)*time.Second, models.DiskManager.CleanPendingDeleteDisks)
 		cron.AddJob1("CleanPendingDeleteLoadbalancers", time.Duration(opts.LoadbalancerPendingDeleteCheckInterval)*time.Second, models.LoadbalancerAgentManager.CleanPendingDeleteLoadbalancers)
+		cron.AddJob1("CheckDrainingLoadbalancerBackends", time.Duration(opts.LoadbalancerTrafficCheckInterval)*time.Second, models.LoadbalancerBackendManager.CheckDrainingBackends)
+		cron.AddJob1("ProcessLoadbalancerBackendGroupShifts", time.Duration(opts.LoadbalancerTrafficCheckInterval)*time.Second, models.LoadbalancerListenerManager.ProcessBackendGroupShifts)
 		if opts.PrepaidExpireCheck {
 			cron.AddJob1("CleanExpiredPrepaidServers", time.Duration(opts.PrepaidExpireCheckSeconds)*time.Second, models.GuestManager.DeleteExpiredPrepaidServers)
 		}
@@ -97,6 +110,10 @@ func StartService() {
 
 		cron.AddJob1WithStartRun("AutoSyncCloudaccountTask", time.Duration(opts.CloudAutoSyncIntervalSeconds)*time.Second, models.CloudaccountManager.AutoSyncCloudaccountTask, true)
 
+		cron.AddJob1WithStartRun("SweepTasks", time.Duration(opts.TaskSweepIntervalSeconds)*time.Second, taskman.TaskManager.SweepTasks, true)
+		cron.AddJob1("DispatchWebhooks", time.Duration(opts.WebhookDispatchIntervalSeconds)*time.Second, models.WebhookManager.DispatchEvents)
+		cron.AddJob1("PurgeIdempotencyKeys", time.Hour, db.IdempotencyKeyManager.PurgeExpired)
+
 		cron.AddJob2("AutoDiskSnapshot", opts.AutoSnapshotDay, opts.AutoSnapshotHour, 0, 0, models.DiskManager.AutoDiskSnapshot, false)
 		cron.AddJob2("SyncSkus", opts.SyncSkusDay, opts.SyncSkusHour, 0, 0, models.SyncSkus, true)
 
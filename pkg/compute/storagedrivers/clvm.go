@@ -0,0 +1,93 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagedrivers
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const CLVM_DEFAULT_THIN_POOL = "snapshot_pool"
+
+type SClvmStorageDriver struct {
+	SBaseStorageDriver
+}
+
+func init() {
+	driver := SClvmStorageDriver{}
+	models.RegisterStorageDriver(&driver)
+}
+
+func (self *SClvmStorageDriver) GetStorageType() string {
+	return api.STORAGE_CLVM
+}
+
+func (self *SClvmStorageDriver) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	vgName, _ := data.GetString("clvm_vg_name")
+	if len(vgName) == 0 {
+		return nil, httperrors.NewMissingParameterError("clvm_vg_name")
+	}
+	thinPool, _ := data.GetString("clvm_thin_pool")
+	if len(thinPool) == 0 {
+		thinPool = CLVM_DEFAULT_THIN_POOL
+	}
+
+	storages := []models.SStorage{}
+	q := models.StorageManager.Query().Equals("storage_type", api.STORAGE_CLVM)
+	if err := db.FetchModelObjects(models.StorageManager, q, &storages); err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	for i := 0; i < len(storages); i++ {
+		if vg, _ := storages[i].StorageConf.GetString("vg_name"); vg == vgName {
+			return nil, httperrors.NewDuplicateResourceError("Volume group %s is used by storage %s", vgName, storages[i].Name)
+		}
+	}
+
+	conf := jsonutils.NewDict()
+	conf.Set("vg_name", jsonutils.NewString(vgName))
+	conf.Set("thin_pool", jsonutils.NewString(thinPool))
+	data.Set("storage_conf", conf)
+
+	return data, nil
+}
+
+func (self *SClvmStorageDriver) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, storage *models.SStorage, data jsonutils.JSONObject) {
+	sc := &models.SStoragecache{}
+	sc.SetModelManager(models.StoragecacheManager)
+	sc.Name = fmt.Sprintf("imagecache-%s", storage.Id)
+	sc.Path = options.Options.ClvmDefaultImageCacheDir
+	if err := models.StoragecacheManager.TableSpec().Insert(sc); err != nil {
+		log.Errorf("insert storagecache for storage %s error: %v", storage.Name, err)
+		return
+	}
+	_, err := db.Update(storage, func() error {
+		storage.StoragecacheId = sc.Id
+		storage.Status = api.STORAGE_ONLINE
+		return nil
+	})
+	if err != nil {
+		log.Errorf("update storagecache info for storage %s error: %v", storage.Name, err)
+	}
+}
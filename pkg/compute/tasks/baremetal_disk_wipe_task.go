@@ -0,0 +1,60 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+type BaremetalDiskWipeTask struct {
+	SBaremetalBaseTask
+}
+
+func init() {
+	taskman.RegisterTask(BaremetalDiskWipeTask{})
+}
+
+func (self *BaremetalDiskWipeTask) OnInit(ctx context.Context, obj db.IStandaloneModel, body jsonutils.JSONObject) {
+	baremetal := obj.(*models.SHost)
+	url := fmt.Sprintf("/baremetals/%s/disk-wipe", baremetal.Id)
+	headers := self.GetTaskRequestHeader()
+	self.SetStage("OnDiskWipeComplete", nil)
+	baremetal.SetStatus(self.UserCred, api.BAREMETAL_WIPING, "")
+	_, err := baremetal.BaremetalSyncRequest(ctx, "POST", url, headers, self.Params)
+	if err != nil {
+		self.OnDiskWipeCompleteFailed(ctx, baremetal, jsonutils.NewString(err.Error()))
+	}
+}
+
+func (self *BaremetalDiskWipeTask) OnDiskWipeComplete(ctx context.Context, baremetal *models.SHost, body jsonutils.JSONObject) {
+	baremetal.SetStatus(self.UserCred, api.BAREMETAL_READY, "")
+	logclient.AddActionLogWithStartable(self, baremetal, logclient.ACT_BM_DISK_WIPE, "", self.UserCred, true)
+	self.SetStageComplete(ctx, nil)
+}
+
+func (self *BaremetalDiskWipeTask) OnDiskWipeCompleteFailed(ctx context.Context, baremetal *models.SHost, body jsonutils.JSONObject) {
+	baremetal.SetStatus(self.UserCred, api.BAREMETAL_WIPE_FAIL, body.String())
+	logclient.AddActionLogWithStartable(self, baremetal, logclient.ACT_BM_DISK_WIPE, body.String(), self.UserCred, false)
+	self.SetStageFailed(ctx, body.String())
+}
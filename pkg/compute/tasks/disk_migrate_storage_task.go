@@ -0,0 +1,217 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+// DiskMigrateStorageTask moves the disk of a running guest to another
+// storage attached to the same host: an empty disk is allocated on the
+// target storage, the guest mirrors its drive onto it and pivots, and the
+// copy on the source storage is released afterwards.
+type DiskMigrateStorageTask struct {
+	SDiskBaseTask
+}
+
+func init() {
+	taskman.RegisterTask(DiskMigrateStorageTask{})
+	// allocating an empty disk is safe to repeat, the mirror is not: a
+	// mirror job keeps running on the host whatever the region decides
+	taskman.RegisterStagePolicy(DiskMigrateStorageTask{}, "OnInit", taskman.SStagePolicy{MaxRetries: 2, RetryInterval: 30 * time.Second})
+	taskman.RegisterStagePolicy(DiskMigrateStorageTask{}, "OnTargetDiskCreated", taskman.SStagePolicy{Timeout: time.Hour})
+	// the mirror job keeps running on the host, the target must not be released under it
+	taskman.RegisterStagePolicy(DiskMigrateStorageTask{}, "OnDiskMirrored", taskman.SStagePolicy{NonCancellable: true})
+	taskman.RegisterStagePolicy(DiskMigrateStorageTask{}, "OnSourceDiskDeleted", taskman.SStagePolicy{Timeout: 30 * time.Minute})
+}
+
+func (self *DiskMigrateStorageTask) getGuest() *models.SGuest {
+	guestId, _ := self.Params.GetString("guest_id")
+	return models.GuestManager.FetchGuestById(guestId)
+}
+
+func (self *DiskMigrateStorageTask) getStorage(key string) *models.SStorage {
+	storageId, _ := self.Params.GetString(key)
+	return models.StorageManager.FetchStorageById(storageId)
+}
+
+func (self *DiskMigrateStorageTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	disk := obj.(*models.SDisk)
+	guest := self.getGuest()
+	storage := self.getStorage("target_storage_id")
+	if guest == nil || storage == nil {
+		self.taskFailed(ctx, disk, guest, "guest or target storage not found")
+		return
+	}
+	host := guest.GetHost()
+	if host == nil || host.HostStatus != api.HOST_ONLINE {
+		self.taskFailed(ctx, disk, guest, "host of guest is not online")
+		return
+	}
+	disk.SetStatus(self.UserCred, api.DISK_MIGRATING_STORAGE, "")
+	db.OpsLog.LogEvent(disk, db.ACT_MIGRATING, fmt.Sprintf("migrate storage to %s", storage.Name), self.UserCred)
+
+	format, _ := self.Params.GetString("format")
+	content := jsonutils.NewDict()
+	content.Set("format", jsonutils.NewString(format))
+	content.Set("size", jsonutils.NewInt(int64(disk.DiskSize)))
+	self.SetStage("OnTargetDiskCreated", nil)
+	err := host.GetHostDriver().RequestAllocateDiskOnStorage(ctx, host, storage, disk, self, content)
+	if err != nil {
+		self.taskFailed(ctx, disk, guest, fmt.Sprintf("allocate disk on target storage: %s", err))
+	}
+}
+
+func (self *DiskMigrateStorageTask) OnTargetDiskCreated(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	guest := self.getGuest()
+	storage := self.getStorage("target_storage_id")
+	format, _ := self.Params.GetString("format")
+	self.SetStage("OnDiskMirrored", nil)
+	err := guest.GetDriver().RequestDiskMigrateStorage(ctx, guest, disk, storage, format, self)
+	if err != nil {
+		self.cleanupTargetDisk(ctx, disk, guest, fmt.Sprintf("request drive mirror: %s", err))
+	}
+}
+
+func (self *DiskMigrateStorageTask) OnTargetDiskCreatedFailed(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	self.taskFailed(ctx, disk, self.getGuest(), data.String())
+}
+
+func (self *DiskMigrateStorageTask) OnDiskMirrored(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	guest := self.getGuest()
+	source := self.getStorage("source_storage_id")
+	storage := self.getStorage("target_storage_id")
+	format, _ := self.Params.GetString("format")
+	diskPath, _ := data.GetString("disk_path")
+	_, err := db.Update(disk, func() error {
+		disk.StorageId = storage.Id
+		disk.DiskFormat = format
+		if len(diskPath) > 0 {
+			disk.AccessPath = diskPath
+		}
+		return nil
+	})
+	if err != nil {
+		// the guest already runs on the target disk but the record still
+		// points at the source, keep both copies for the admin to fix
+		self.taskFailed(ctx, disk, guest, fmt.Sprintf("update storage of disk to %s: %s", storage.Id, err))
+		return
+	}
+	self.syncStoragecache(ctx, disk, storage)
+	source.ClearSchedDescCache()
+	storage.ClearSchedDescCache()
+
+	self.SetStage("OnSourceDiskDeleted", nil)
+	host := guest.GetHost()
+	err = host.GetHostDriver().RequestDeallocateDiskOnHost(ctx, host, source, disk, self)
+	if err != nil {
+		log.Errorf("deallocate disk %s on source storage %s: %s", disk.Id, source.Name, err)
+		self.OnSourceDiskDeleted(ctx, disk, nil)
+	}
+}
+
+// syncStoragecache makes sure the template of the disk is cached on the
+// storagecache of the target storage, so that later resets and rebuilds
+// find the image and the cache reference count follows the disk.
+func (self *DiskMigrateStorageTask) syncStoragecache(ctx context.Context, disk *models.SDisk, storage *models.SStorage) {
+	templateId := disk.GetTemplateId()
+	if len(templateId) == 0 {
+		return
+	}
+	cache := storage.GetStoragecache()
+	if cache == nil {
+		return
+	}
+	if models.StoragecachedimageManager.GetStoragecachedimage(cache.Id, templateId) != nil {
+		return
+	}
+	err := cache.StartImageCacheTask(ctx, self.UserCred, templateId, "", false, "")
+	if err != nil {
+		log.Errorf("cache image %s on storagecache %s: %s", templateId, cache.Id, err)
+	}
+}
+
+func (self *DiskMigrateStorageTask) OnDiskMirroredFailed(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	self.cleanupTargetDisk(ctx, disk, self.getGuest(), data.String())
+}
+
+func (self *DiskMigrateStorageTask) OnSourceDiskDeleted(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	disk.SetStatus(self.UserCred, api.DISK_READY, "")
+	db.OpsLog.LogEvent(disk, db.ACT_MIGRATE_STORAGE, disk.GetShortDesc(ctx), self.UserCred)
+	logclient.AddActionLogWithStartable(self, disk, logclient.ACT_MIGRATE_STORAGE, nil, self.UserCred, true)
+	self.SetStage("OnGuestSyncstatus", nil)
+	self.getGuest().StartSyncstatus(ctx, self.UserCred, self.GetTaskId())
+}
+
+func (self *DiskMigrateStorageTask) OnSourceDiskDeletedFailed(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	log.Errorf("deallocate disk %s on source storage: %s", disk.Id, data)
+	self.OnSourceDiskDeleted(ctx, disk, data)
+}
+
+func (self *DiskMigrateStorageTask) OnGuestSyncstatus(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	self.SetStageComplete(ctx, nil)
+}
+
+func (self *DiskMigrateStorageTask) OnGuestSyncstatusFailed(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	self.SetStageComplete(ctx, nil)
+}
+
+// cleanupTargetDisk releases the half-copied disk on the target storage
+// before failing the task; the guest keeps running on the source disk.
+func (self *DiskMigrateStorageTask) cleanupTargetDisk(ctx context.Context, disk *models.SDisk, guest *models.SGuest, reason string) {
+	storage := self.getStorage("target_storage_id")
+	host := guest.GetHost()
+	params := jsonutils.NewDict()
+	params.Set("reason", jsonutils.NewString(reason))
+	self.SetStage("OnTargetDiskCleaned", params)
+	err := host.GetHostDriver().RequestDeallocateDiskOnHost(ctx, host, storage, disk, self)
+	if err != nil {
+		log.Errorf("cleanup disk %s on target storage %s: %s", disk.Id, storage.Name, err)
+		self.taskFailed(ctx, disk, guest, reason)
+	}
+}
+
+func (self *DiskMigrateStorageTask) OnTargetDiskCleaned(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	reason, _ := self.Params.GetString("reason")
+	self.taskFailed(ctx, disk, self.getGuest(), reason)
+}
+
+func (self *DiskMigrateStorageTask) OnTargetDiskCleanedFailed(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	reason, _ := self.Params.GetString("reason")
+	log.Errorf("cleanup disk %s on target storage: %s", disk.Id, data)
+	self.taskFailed(ctx, disk, self.getGuest(), reason)
+}
+
+func (self *DiskMigrateStorageTask) taskFailed(ctx context.Context, disk *models.SDisk, guest *models.SGuest, reason string) {
+	disk.SetStatus(self.UserCred, api.DISK_READY, reason)
+	if guest != nil {
+		guest.SetStatus(self.UserCred, api.VM_MIGRATE_STORAGE_FAILED, reason)
+		guest.StartSyncstatus(ctx, self.UserCred, "")
+	}
+	db.OpsLog.LogEvent(disk, db.ACT_MIGRATE_STORAGE_FAIL, reason, self.UserCred)
+	logclient.AddActionLogWithStartable(self, disk, logclient.ACT_MIGRATE_STORAGE, reason, self.UserCred, false)
+	self.SetStageFailed(ctx, reason)
+}
@@ -17,6 +17,7 @@ package tasks
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -35,6 +36,7 @@ type DiskResizeTask struct {
 
 func init() {
 	taskman.RegisterTask(DiskResizeTask{})
+	taskman.RegisterStagePolicy(DiskResizeTask{}, "OnDiskResizeComplete", taskman.SStagePolicy{Timeout: time.Hour})
 }
 
 func (self *DiskResizeTask) SetDiskReady(ctx context.Context, disk *models.SDisk, userCred mcclient.TokenCredential, reason string) {
@@ -16,6 +16,7 @@ package tasks
 
 import (
 	"context"
+	"fmt"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -47,6 +48,22 @@ func (self *GuestBatchCreateTask) OnInit(ctx context.Context, objs []db.IStandal
 	StartScheduleObjects(ctx, self, objs)
 }
 
+func (self *GuestBatchCreateTask) OnPreemptComplete(ctx context.Context, objs []db.IStandaloneModel, data jsonutils.JSONObject) {
+	schedObjs := make([]IScheduleModel, len(objs))
+	for i := range objs {
+		schedObjs[i] = objs[i].(IScheduleModel)
+	}
+	doScheduleObjects(ctx, self, schedObjs)
+}
+
+func (self *GuestBatchCreateTask) OnPreemptCompleteFailed(ctx context.Context, objs []db.IStandaloneModel, data jsonutils.JSONObject) {
+	schedObjs := make([]IScheduleModel, len(objs))
+	for i := range objs {
+		schedObjs[i] = objs[i].(IScheduleModel)
+	}
+	onSchedulerRequestFail(ctx, self, schedObjs, fmt.Sprintf("preempt fail: %s", data))
+}
+
 func (self *GuestBatchCreateTask) OnScheduleFailCallback(ctx context.Context, obj IScheduleModel, reason string) {
 	self.SSchedTask.OnScheduleFailCallback(ctx, obj, reason)
 	guest := obj.(*models.SGuest)
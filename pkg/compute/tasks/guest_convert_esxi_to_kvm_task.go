@@ -0,0 +1,146 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+// GuestConvertEsxiToKvmTask exports disks of an esxi guest onto a kvm host,
+// switches the guest to the host, then deploys it to apply network configs
+type GuestConvertEsxiToKvmTask struct {
+	SGuestBaseTask
+}
+
+func init() {
+	taskman.RegisterTask(GuestConvertEsxiToKvmTask{})
+}
+
+func (self *GuestConvertEsxiToKvmTask) getTargets() (*models.SHost, *models.SStorage, error) {
+	hostId, _ := self.Params.GetString("prefer_host_id")
+	host := models.HostManager.FetchHostById(hostId)
+	if host == nil {
+		return nil, nil, fmt.Errorf("host %s not found", hostId)
+	}
+	storageId, _ := self.Params.GetString("target_storage_id")
+	storage := models.StorageManager.FetchStorageById(storageId)
+	if storage == nil {
+		return nil, nil, fmt.Errorf("storage %s not found", storageId)
+	}
+	return host, storage, nil
+}
+
+func (self *GuestConvertEsxiToKvmTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	guest := obj.(*models.SGuest)
+	host, storage, err := self.getTargets()
+	if err != nil {
+		self.TaskFailed(ctx, guest, err.Error())
+		return
+	}
+	config, err := guest.GetEsxiConvertConfig(storage)
+	if err != nil {
+		self.TaskFailed(ctx, guest, fmt.Sprintf("get convert config: %v", err))
+		return
+	}
+	// remember source vm as external id is cleared once switched to kvm
+	params := jsonutils.NewDict()
+	params.Set("source_host_id", jsonutils.NewString(guest.HostId))
+	params.Set("source_external_id", jsonutils.NewString(guest.ExternalId))
+	self.SetStage("OnEsxiDisksConverted", params)
+	_, err = host.Request(ctx, self.UserCred, "POST",
+		fmt.Sprintf("/servers/%s/convert-esxi", guest.Id),
+		self.GetTaskRequestHeader(), jsonutils.Marshal(config))
+	if err != nil {
+		self.TaskFailed(ctx, guest, fmt.Sprintf("request convert on host %s: %v", host.Name, err))
+	}
+}
+
+func (self *GuestConvertEsxiToKvmTask) OnEsxiDisksConverted(ctx context.Context, guest *models.SGuest, data jsonutils.JSONObject) {
+	host, storage, err := self.getTargets()
+	if err != nil {
+		self.TaskFailed(ctx, guest, err.Error())
+		return
+	}
+	disksPath := make(map[string]string)
+	if err := data.Unmarshal(&disksPath, "disks_path"); err != nil {
+		self.TaskFailed(ctx, guest, fmt.Sprintf("no disks path feedback: %v", err))
+		return
+	}
+	virtio := jsonutils.QueryBoolean(data, "virtio_drivers", false)
+	if err := guest.SwitchToKvm(ctx, self.UserCred, host, storage, disksPath, virtio); err != nil {
+		self.TaskFailed(ctx, guest, fmt.Sprintf("switch to kvm: %v", err))
+		return
+	}
+	// only rewrite network and driver config, keep the credentials of the source vm
+	kwargs := jsonutils.NewDict()
+	kwargs.Add(jsonutils.JSONFalse, "reset_password")
+	self.SetStage("OnGuestDeployComplete", nil)
+	guest.StartGuestDeployTask(ctx, self.UserCred, kwargs, "deploy", self.GetTaskId())
+}
+
+func (self *GuestConvertEsxiToKvmTask) OnEsxiDisksConvertedFailed(ctx context.Context, guest *models.SGuest, data jsonutils.JSONObject) {
+	self.TaskFailed(ctx, guest, data.String())
+}
+
+func (self *GuestConvertEsxiToKvmTask) OnGuestDeployComplete(ctx context.Context, guest *models.SGuest, data jsonutils.JSONObject) {
+	if jsonutils.QueryBoolean(self.Params, "delete_source", false) {
+		if err := self.deleteSourceVM(ctx); err != nil {
+			// guest is already running on kvm, keep the source vm for manual cleanup
+			log.Errorf("delete source vm of guest %s: %v", guest.Name, err)
+			db.OpsLog.LogEvent(guest, db.ACT_GUEST_CONVERT_TO_KVM_FAIL, fmt.Sprintf("delete source vm: %v", err), self.UserCred)
+		}
+	}
+	logclient.AddActionLogWithStartable(self, guest, logclient.ACT_VM_CONVERT_TO_KVM, "", self.UserCred, true)
+	self.SetStageComplete(ctx, nil)
+}
+
+func (self *GuestConvertEsxiToKvmTask) OnGuestDeployCompleteFailed(ctx context.Context, guest *models.SGuest, data jsonutils.JSONObject) {
+	self.TaskFailed(ctx, guest, data.String())
+}
+
+func (self *GuestConvertEsxiToKvmTask) deleteSourceVM(ctx context.Context) error {
+	hostId, _ := self.Params.GetString("source_host_id")
+	externalId, _ := self.Params.GetString("source_external_id")
+	host := models.HostManager.FetchHostById(hostId)
+	if host == nil {
+		return fmt.Errorf("source host %s not found", hostId)
+	}
+	ihost, err := host.GetIHost()
+	if err != nil {
+		return err
+	}
+	ivm, err := ihost.GetIVMById(externalId)
+	if err != nil {
+		return err
+	}
+	return ivm.DeleteVM(ctx)
+}
+
+func (self *GuestConvertEsxiToKvmTask) TaskFailed(ctx context.Context, guest *models.SGuest, reason string) {
+	guest.SetStatus(self.UserCred, api.VM_CONVERT_FAILED, reason)
+	db.OpsLog.LogEvent(guest, db.ACT_GUEST_CONVERT_TO_KVM_FAIL, reason, self.UserCred)
+	logclient.AddActionLogWithStartable(self, guest, logclient.ACT_VM_CONVERT_TO_KVM, reason, self.UserCred, false)
+	self.SetStageFailed(ctx, reason)
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+// GuestMigrateStorageTask moves disks of a running guest to other storages
+// one after another, each by a DiskMigrateStorageTask. It stops at the first
+// failure, the remaining disks are left on their storage.
+type GuestMigrateStorageTask struct {
+	SGuestBaseTask
+}
+
+func init() {
+	taskman.RegisterTask(GuestMigrateStorageTask{})
+}
+
+func (self *GuestMigrateStorageTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	self.migrateDisk(ctx, obj.(*models.SGuest), 0)
+}
+
+func (self *GuestMigrateStorageTask) migrateDisk(ctx context.Context, guest *models.SGuest, idx int) {
+	disks, _ := self.Params.GetArray("disks")
+	if idx >= len(disks) {
+		self.SetStageComplete(ctx, nil)
+		return
+	}
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewInt(int64(idx)), "disk_index")
+	self.SetStage("OnDiskMigrated", params)
+
+	diskId, _ := disks[idx].GetString("disk_id")
+	storageId, _ := disks[idx].GetString("target_storage_id")
+	disk := models.DiskManager.FetchDiskById(diskId)
+	storage := models.StorageManager.FetchStorageById(storageId)
+	if disk == nil || storage == nil {
+		self.OnDiskMigratedFailed(ctx, guest, jsonutils.NewString(fmt.Sprintf("disk %s or storage %s not found", diskId, storageId)))
+		return
+	}
+	// the guest is running again once the previous disk is moved
+	if _, err := disk.ValidateMigrateStorage(storage); err != nil {
+		self.OnDiskMigratedFailed(ctx, guest, jsonutils.NewString(err.Error()))
+		return
+	}
+	err := disk.StartDiskMigrateStorageTask(ctx, self.UserCred, guest, storage, self.GetTaskId())
+	if err != nil {
+		self.OnDiskMigratedFailed(ctx, guest, jsonutils.NewString(err.Error()))
+	}
+}
+
+func (self *GuestMigrateStorageTask) OnDiskMigrated(ctx context.Context, guest *models.SGuest, data jsonutils.JSONObject) {
+	idx, _ := self.Params.Int("disk_index")
+	self.migrateDisk(ctx, guest, int(idx)+1)
+}
+
+func (self *GuestMigrateStorageTask) OnDiskMigratedFailed(ctx context.Context, guest *models.SGuest, data jsonutils.JSONObject) {
+	db.OpsLog.LogEvent(guest, db.ACT_MIGRATE_STORAGE_FAIL, data, self.UserCred)
+	self.SetStageFailed(ctx, data.String())
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+
+	schedapi "yunion.io/x/onecloud/pkg/apis/scheduler"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+// GuestPreemptTask stops lower priority servers of a preemption plan one
+// after another, then notifies parent schedule task to schedule again
+type GuestPreemptTask struct {
+	taskman.STask
+}
+
+func init() {
+	taskman.RegisterTask(GuestPreemptTask{})
+}
+
+func (self *GuestPreemptTask) getPlan() *schedapi.PreemptionPlan {
+	plan := new(schedapi.PreemptionPlan)
+	self.GetParams().Unmarshal(plan, "plan")
+	return plan
+}
+
+func (self *GuestPreemptTask) getPreemptNotes() jsonutils.JSONObject {
+	notes := jsonutils.NewDict()
+	preemptor, _ := self.GetParams().GetString("preemptor")
+	notes.Add(jsonutils.NewString(preemptor), "preemptor")
+	notes.Add(jsonutils.NewString(self.getPlan().HostId), "host_id")
+	return notes
+}
+
+func (self *GuestPreemptTask) OnInit(ctx context.Context, objs []db.IStandaloneModel, data jsonutils.JSONObject) {
+	self.stopVictim(ctx, objs, 0)
+}
+
+func (self *GuestPreemptTask) stopVictim(ctx context.Context, objs []db.IStandaloneModel, idx int) {
+	if idx >= len(objs) {
+		models.HostManager.ClearSchedDescCache(self.getPlan().HostId)
+		self.SetStageComplete(ctx, nil)
+		return
+	}
+	guest := objs[idx].(*models.SGuest)
+	notes := self.getPreemptNotes()
+	db.OpsLog.LogEvent(guest, db.ACT_GUEST_PREEMPT, notes, self.UserCred)
+	logclient.AddActionLogWithStartable(self, guest, logclient.ACT_VM_PREEMPT, notes, self.UserCred, true)
+
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewInt(int64(idx)), "victim_index")
+	self.SetStage("OnVictimStopped", params)
+	err := guest.StartGuestStopTask(ctx, self.UserCred, false, self.GetTaskId())
+	if err != nil {
+		self.OnVictimStoppedFailed(ctx, objs, jsonutils.NewString(err.Error()))
+	}
+}
+
+func (self *GuestPreemptTask) OnVictimStopped(ctx context.Context, objs []db.IStandaloneModel, data jsonutils.JSONObject) {
+	idx, _ := self.GetParams().Int("victim_index")
+	self.stopVictim(ctx, objs, int(idx)+1)
+}
+
+func (self *GuestPreemptTask) OnVictimStoppedFailed(ctx context.Context, objs []db.IStandaloneModel, data jsonutils.JSONObject) {
+	idx, _ := self.GetParams().Int("victim_index")
+	if int(idx) < len(objs) {
+		guest := objs[idx].(*models.SGuest)
+		db.OpsLog.LogEvent(guest, db.ACT_GUEST_PREEMPT_FAIL, data, self.UserCred)
+		logclient.AddActionLogWithStartable(self, guest, logclient.ACT_VM_PREEMPT, data, self.UserCred, false)
+	}
+	self.SetStageFailed(ctx, fmt.Sprintf("preempt victim %d: %s", idx, data))
+}
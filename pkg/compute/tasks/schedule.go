@@ -17,6 +17,7 @@ package tasks
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -154,9 +155,63 @@ func doScheduleObjects(
 		onSchedulerRequestFail(ctx, task, objs, fmt.Sprintf("Scheduler fail: %s", err))
 		return
 	}
+	if output.Preemption != nil && onSchedulerPreemption(ctx, task, objs, schedInput, output) {
+		return
+	}
 	onSchedulerResults(ctx, task, objs, output.Candidates)
 }
 
+// IPreemptScheduleTask is a schedule task able to schedule again after lower
+// priority servers are preempted
+type IPreemptScheduleTask interface {
+	IScheduleTask
+
+	GetTaskId() string
+	GetParams() *jsonutils.JSONDict
+	OnPreemptComplete(ctx context.Context, objs []db.IStandaloneModel, data jsonutils.JSONObject)
+}
+
+// onSchedulerPreemption records preemption plan returned by scheduler, and
+// starts preempting when policy is execute. Returns true if schedule will be
+// retried after preemption.
+func onSchedulerPreemption(
+	ctx context.Context,
+	task IScheduleTask,
+	objs []IScheduleModel,
+	input *schedapi.ScheduleInput,
+	output *schedapi.ScheduleOutput,
+) bool {
+	plan := output.Preemption
+	victims := make([]string, len(plan.Victims))
+	for i, victim := range plan.Victims {
+		victims[i] = fmt.Sprintf("%s(%s)", victim.Name, victim.PriorityClass)
+	}
+	planDesc := fmt.Sprintf("preempting %s on host %s would make room", strings.Join(victims, ", "), plan.HostName)
+	for _, obj := range objs {
+		db.OpsLog.LogEvent(obj, db.ACT_GUEST_PREEMPT, plan, task.GetUserCred())
+	}
+
+	ptask, ok := task.(IPreemptScheduleTask)
+	if input.PreemptionPolicy == api.PREEMPTION_POLICY_EXECUTE && ok && !jsonutils.QueryBoolean(ptask.GetParams(), "preempted", false) {
+		params := jsonutils.NewDict()
+		params.Add(jsonutils.JSONTrue, "preempted")
+		task.SetStage("OnPreemptComplete", params)
+		err := models.GuestManager.StartGuestPreemptTask(ctx, task.GetUserCred(), plan, input.PriorityClass, objs[0].GetName(), ptask.GetTaskId())
+		if err == nil {
+			return true
+		}
+		log.Errorf("StartGuestPreemptTask fail: %v", err)
+		planDesc = fmt.Sprintf("%s, but preempt fail: %v", planDesc, err)
+	}
+
+	for _, candidate := range output.Candidates {
+		if len(candidate.Error) > 0 {
+			candidate.Error = fmt.Sprintf("%s, %s", candidate.Error, planDesc)
+		}
+	}
+	return false
+}
+
 func cancelPendingUsage(ctx context.Context, task IScheduleTask) {
 	pendingUsage := models.SQuota{}
 	err := task.GetPendingUsage(&pendingUsage)
@@ -15,6 +15,7 @@
 package fsdriver
 
 import (
+	"errors"
 	"fmt"
 	"path"
 	"path/filepath"
@@ -97,6 +98,18 @@ func (d *sGuestRootFsDriver) DeployFstabScripts(_ IDiskPartition, _ []jsonutils.
 	return nil
 }
 
+func (d *sGuestRootFsDriver) DeployMdadmConfig(_ IDiskPartition, _ string) error {
+	return nil
+}
+
+func (d *sGuestRootFsDriver) DeployVirtioDrivers(_ IDiskPartition) error {
+	return ErrVirtioDriversNotFound
+}
+
+// ErrVirtioDriversNotFound is returned by DeployVirtioDrivers when drivers
+// for the guest are not available on host, guest should use emulated devices
+var ErrVirtioDriversNotFound = errors.New("virtio drivers not found")
+
 func (d *sGuestRootFsDriver) EnableSerialConsole(rootfs IDiskPartition, sysInfo *jsonutils.JSONDict) error {
 	return nil
 }
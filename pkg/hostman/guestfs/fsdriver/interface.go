@@ -45,6 +45,12 @@ type IDiskPartition interface {
 	GetMountPath() string
 }
 
+// IChrootRunner is implemented by partitions able to run commands chrooted
+// into the mounted filesystem
+type IChrootRunner interface {
+	ChrootRun(cmd string) ([]string, error)
+}
+
 type IRootFsDriver interface {
 	GetPartition() IDiskPartition
 	GetName() string
@@ -61,6 +67,14 @@ type IRootFsDriver interface {
 	DeployStandbyNetworkingScripts(part IDiskPartition, nics, nicsStandby []jsonutils.JSONObject) error
 	DeployUdevSubsystemScripts(IDiskPartition) error
 	DeployFstabScripts(IDiskPartition, []jsonutils.JSONObject) error
+	// DeployMdadmConfig writes ARRAY lines of software raid into mdadm.conf
+	// and regenerates initramfs so the root array is assembled on boot
+	DeployMdadmConfig(part IDiskPartition, arrays string) error
+	// DeployVirtioDrivers makes initramfs include virtio drivers, or injects
+	// virtio drivers into windows, so guest converted from other hypervisors
+	// is able to find its root disk on kvm.  ErrVirtioDriversNotFound is
+	// returned if drivers for the guest are not available
+	DeployVirtioDrivers(IDiskPartition) error
 	GetLoginAccount(IDiskPartition, bool, bool) string
 	DeployPublicKey(IDiskPartition, string, *sshkeys.SSHKeys) error
 	ChangeUserPasswd(part IDiskPartition, account, gid, publicKey, password string) (string, error)
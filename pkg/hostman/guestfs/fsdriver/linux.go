@@ -46,6 +46,11 @@ const (
 	YUNIONROOT_USER = "cloudroot"
 )
 
+var (
+	// virtioModules are required by guest to boot from virtio disks
+	virtioModules = []string{"virtio_pci", "virtio_blk", "virtio_scsi", "virtio_net"}
+)
+
 type sLinuxRootFs struct {
 	*sGuestRootFsDriver
 }
@@ -190,6 +195,54 @@ func (l *sLinuxRootFs) DisableCloudinit(rootFs IDiskPartition) {
 	}
 }
 
+// initramfsTools are the initramfs generators looked up on distributions
+// without a dedicated driver, with the mdadm.conf path each of them reads
+var initramfsTools = []struct {
+	paths    []string
+	confPath string
+	cmd      string
+}{
+	{[]string{"/usr/bin/dracut", "/usr/sbin/dracut", "/sbin/dracut"}, "/etc/mdadm.conf", "dracut -f --regenerate-all"},
+	{[]string{"/usr/sbin/update-initramfs", "/sbin/update-initramfs"}, "/etc/mdadm/mdadm.conf", "update-initramfs -u -k all"},
+	{[]string{"/usr/bin/mkinitcpio", "/usr/sbin/mkinitcpio"}, "/etc/mdadm.conf", "mkinitcpio -P"},
+}
+
+// DeployMdadmConfig of distributions without a dedicated driver uses the
+// first initramfs generator found, the root array would not be assembled on
+// boot without it so an error is returned if none is installed
+func (l *sLinuxRootFs) DeployMdadmConfig(rootFs IDiskPartition, arrays string) error {
+	for _, tool := range initramfsTools {
+		for _, path := range tool.paths {
+			if rootFs.Exists(path, false) {
+				return deployMdadmConfig(rootFs, tool.confPath, arrays, tool.cmd)
+			}
+		}
+	}
+	return fmt.Errorf("no supported initramfs generator found to include mdadm config")
+}
+
+// deployMdadmConfig writes arrays into confPath and runs initramfsCmd inside
+// rootFs to include the config into initramfs
+func deployMdadmConfig(rootFs IDiskPartition, confPath, arrays, initramfsCmd string) error {
+	runner, ok := rootFs.(IChrootRunner)
+	if !ok {
+		return fmt.Errorf("partition %s can't run %q to include mdadm config", rootFs.GetMountPath(), initramfsCmd)
+	}
+	conf := "MAILADDR root\n" + arrays + "\n"
+	if err := rootFs.FilePutContents(confPath, conf, false, false); err != nil {
+		return fmt.Errorf("write %s: %v", confPath, err)
+	}
+	return updateInitramfs(runner, initramfsCmd)
+}
+
+// updateInitramfs runs initramfsCmd inside the root filesystem
+func updateInitramfs(runner IChrootRunner, initramfsCmd string) error {
+	if _, err := runner.ChrootRun(initramfsCmd); err != nil {
+		return fmt.Errorf("%s: %v", initramfsCmd, err)
+	}
+	return nil
+}
+
 func (l *sLinuxRootFs) DeployFstabScripts(rootFs IDiskPartition, disks []jsonutils.JSONObject) error {
 	fstabcont, err := rootFs.FileGetContents("/etc/fstab", false)
 	if err != nil {
@@ -441,6 +494,46 @@ func newDebianLikeRootFs(part IDiskPartition) *sDebianLikeRootFs {
 	}
 }
 
+func (d *sDebianLikeRootFs) DeployMdadmConfig(rootFs IDiskPartition, arrays string) error {
+	return deployMdadmConfig(rootFs, "/etc/mdadm/mdadm.conf", arrays, "update-initramfs -u -k all")
+}
+
+func (d *sDebianLikeRootFs) DeployVirtioDrivers(rootFs IDiskPartition) error {
+	runner, ok := rootFs.(IChrootRunner)
+	if !ok {
+		// initramfs can't be rebuilt to include the drivers
+		return ErrVirtioDriversNotFound
+	}
+	modulesPath := "/etc/initramfs-tools/modules"
+	modules := ""
+	if rootFs.Exists(modulesPath, false) {
+		content, err := rootFs.FileGetContents(modulesPath, false)
+		if err != nil {
+			return err
+		}
+		modules = string(content)
+	}
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(modules, "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+	appends := ""
+	for _, m := range virtioModules {
+		if !existing[m] {
+			appends += m + "\n"
+		}
+	}
+	if len(appends) > 0 {
+		if len(modules) > 0 && !strings.HasSuffix(modules, "\n") {
+			appends = "\n" + appends
+		}
+		if err := rootFs.FilePutContents(modulesPath, appends, true, false); err != nil {
+			return fmt.Errorf("write %s: %v", modulesPath, err)
+		}
+	}
+	return updateInitramfs(runner, "update-initramfs -u -k all")
+}
+
 func (d *sDebianLikeRootFs) GetReleaseInfo(rootFs IDiskPartition, driver IDebianRootFsDriver) *SReleaseInfo {
 	version, err := rootFs.FileGetContents(driver.VersionFilePath(), false)
 	if err != nil {
@@ -731,6 +824,29 @@ func (r *sRedhatLikeRootFs) RootSignatures() []string {
 	return append([]string{"/etc/sysconfig/network", "/etc/redhat-release"}, sig...)
 }
 
+func (r *sRedhatLikeRootFs) DeployMdadmConfig(rootFs IDiskPartition, arrays string) error {
+	return deployMdadmConfig(rootFs, "/etc/mdadm.conf", arrays, "dracut -f --regenerate-all")
+}
+
+func (r *sRedhatLikeRootFs) DeployVirtioDrivers(rootFs IDiskPartition) error {
+	runner, ok := rootFs.(IChrootRunner)
+	if !ok {
+		// initramfs can't be rebuilt to include the drivers
+		return ErrVirtioDriversNotFound
+	}
+	confPath := "/etc/dracut.conf.d/virtio.conf"
+	if !rootFs.Exists("/etc/dracut.conf.d", false) {
+		if err := rootFs.Mkdir("/etc/dracut.conf.d", 0755, false); err != nil {
+			return err
+		}
+	}
+	conf := fmt.Sprintf("add_drivers+=\" %s \"\n", strings.Join(virtioModules, " "))
+	if err := rootFs.FilePutContents(confPath, conf, false, false); err != nil {
+		return fmt.Errorf("write %s: %v", confPath, err)
+	}
+	return updateInitramfs(runner, "dracut -f --regenerate-all")
+}
+
 func (r *sRedhatLikeRootFs) DeployHostname(rootFs IDiskPartition, hn, domain string) error {
 	var sPath = "/etc/sysconfig/network"
 	centosHn := ""
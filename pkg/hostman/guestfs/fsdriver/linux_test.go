@@ -0,0 +1,89 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsdriver
+
+import (
+	"testing"
+)
+
+// sFakeChrootPartition records files written and commands run on it
+type sFakeChrootPartition struct {
+	IDiskPartition
+
+	files map[string]string
+	cmds  []string
+}
+
+func (p *sFakeChrootPartition) Exists(sPath string, caseInsensitive bool) bool {
+	_, ok := p.files[sPath]
+	return ok
+}
+
+func (p *sFakeChrootPartition) FilePutContents(sPath, content string, modAppend, caseInsensitive bool) error {
+	p.files[sPath] = content
+	return nil
+}
+
+func (p *sFakeChrootPartition) GetMountPath() string {
+	return "/mnt/fake"
+}
+
+func (p *sFakeChrootPartition) ChrootRun(cmd string) ([]string, error) {
+	p.cmds = append(p.cmds, cmd)
+	return nil, nil
+}
+
+func TestLinuxDeployMdadmConfig(t *testing.T) {
+	arrays := "ARRAY /dev/md0 metadata=1.2 UUID=5e1b7a3c:2d1f9e40:8a6b3c1d:0f2e4a59"
+	cases := []struct {
+		name     string
+		tool     string
+		confPath string
+		cmd      string
+		wantErr  bool
+	}{
+		{"dracut", "/usr/bin/dracut", "/etc/mdadm.conf", "dracut -f --regenerate-all", false},
+		{"initramfs-tools", "/usr/sbin/update-initramfs", "/etc/mdadm/mdadm.conf", "update-initramfs -u -k all", false},
+		{"mkinitcpio", "/usr/bin/mkinitcpio", "/etc/mdadm.conf", "mkinitcpio -P", false},
+		{"none", "", "", "", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			part := &sFakeChrootPartition{files: map[string]string{}}
+			if c.tool != "" {
+				part.files[c.tool] = ""
+			}
+			err := newLinuxRootFs(part).DeployMdadmConfig(part, arrays)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("want error without initramfs generator")
+				}
+				if _, ok := part.files["/etc/mdadm.conf"]; ok {
+					t.Errorf("mdadm.conf should not be written")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DeployMdadmConfig: %s", err)
+			}
+			if conf := part.files[c.confPath]; conf != "MAILADDR root\n"+arrays+"\n" {
+				t.Errorf("unexpected %s: %q", c.confPath, conf)
+			}
+			if len(part.cmds) != 1 || part.cmds[0] != c.cmd {
+				t.Errorf("want command %q, got %v", c.cmd, part.cmds)
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ package fsdriver
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"path"
 	"regexp"
@@ -323,6 +324,62 @@ func (w *SWindowsRootFs) PrepareFsForTemplate(IDiskPartition) error {
 	return nil
 }
 
+// DeployVirtioDrivers copies virtio-win drivers of VirtioWinDriverPath into
+// the guest, viostor is registered as boot driver so root disk is found on
+// virtio bus, other drivers are installed by plug and play on first boot
+func (w *SWindowsRootFs) DeployVirtioDrivers(rootFs IDiskPartition) error {
+	if len(options.HostOptions.VirtioWinDriverPath) == 0 {
+		return ErrVirtioDriversNotFound
+	}
+	arch := "x86"
+	if rinfo := w.GetReleaseInfo(rootFs); rinfo != nil && rinfo.Arch == "x86_64" {
+		arch = "amd64"
+	}
+	srcDir := path.Join(options.HostOptions.VirtioWinDriverPath, arch)
+	if !fileutils2.Exists(path.Join(srcDir, "viostor.sys")) {
+		log.Warningf("viostor.sys not found in %s", srcDir)
+		return ErrVirtioDriversNotFound
+	}
+	files, err := ioutil.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+	dstDir := "/Windows/Drivers/VirtIO"
+	if err := rootFs.Mkdir(dstDir, 0755, true); err != nil {
+		return fmt.Errorf("mkdir %s: %v", dstDir, err)
+	}
+	for _, f := range files {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		content, err := ioutil.ReadFile(path.Join(srcDir, f.Name()))
+		if err != nil {
+			return err
+		}
+		dsts := []string{path.Join(dstDir, f.Name())}
+		if f.Name() == "viostor.sys" {
+			dsts = append(dsts, "/Windows/System32/drivers/viostor.sys")
+		}
+		for _, dst := range dsts {
+			if err := rootFs.FilePutContents(dst, string(content), false, true); err != nil {
+				return fmt.Errorf("write %s: %v", dst, err)
+			}
+		}
+	}
+	confPath := rootFs.GetLocalPath("/windows/system32/config", true)
+	tool := winutils.NewWinRegTool(confPath)
+	if !tool.CheckPath() {
+		return fmt.Errorf("registry not found in %s", confPath)
+	}
+	if !tool.InstallVirtioStorDriver() {
+		return fmt.Errorf("register viostor driver failed")
+	}
+	if !tool.AddDevicePath(`%SystemRoot%\Drivers\VirtIO`) {
+		return fmt.Errorf("add virtio drivers to device path failed")
+	}
+	return nil
+}
+
 func (w *SWindowsRootFs) CommitChanges(part IDiskPartition) error {
 	confPath := part.GetLocalPath("/windows/system32/config", true)
 	tool := winutils.NewWinRegTool(confPath)
@@ -216,6 +216,28 @@ func (f *SLocalGuestFS) UserAdd(user string, caseInsensitive bool) error {
 	return nil
 }
 
+// ChrootRun runs cmd chrooted into the mounted filesystem, /dev, /proc and
+// /sys are bound as tools like dracut require them
+func (f *SLocalGuestFS) ChrootRun(cmd string) ([]string, error) {
+	binds := []string{"/dev", "/proc", "/sys"}
+	for i, dir := range binds {
+		if _, err := procutils.Run("mount", "--bind", dir, f.mountPath+dir); err != nil {
+			f.unbindDirs(binds[:i])
+			return nil, err
+		}
+	}
+	defer f.unbindDirs(binds)
+	return procutils.Run("chroot", f.mountPath, "/bin/sh", "-c", cmd)
+}
+
+func (f *SLocalGuestFS) unbindDirs(dirs []string) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if _, err := procutils.Run("umount", f.mountPath+dirs[i]); err != nil {
+			log.Errorf("umount %s%s: %v", f.mountPath, dirs[i], err)
+		}
+	}
+}
+
 func (f *SLocalGuestFS) FileGetContents(sPath string, caseInsensitive bool) ([]byte, error) {
 	sPath = f.GetLocalPath(sPath, caseInsensitive)
 	if len(sPath) > 0 {
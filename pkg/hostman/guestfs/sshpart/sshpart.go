@@ -365,6 +365,28 @@ func (p *SSHPartition) Passwd(user, password string, caseInsensitive bool) error
 	return err
 }
 
+// ChrootRun runs cmd chrooted into the partition, /dev, /proc and /sys are
+// bound as tools like dracut require them
+func (p *SSHPartition) ChrootRun(cmd string) ([]string, error) {
+	binds := []string{"/dev", "/proc", "/sys"}
+	for i, dir := range binds {
+		if _, err := p.term.Run(fmt.Sprintf("mount --bind %s %s%s", dir, p.mountPath, dir)); err != nil {
+			p.unbindDirs(binds[:i])
+			return nil, err
+		}
+	}
+	defer p.unbindDirs(binds)
+	return p.term.Run(fmt.Sprintf("/usr/sbin/chroot %s %s", p.mountPath, cmd))
+}
+
+func (p *SSHPartition) unbindDirs(dirs []string) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if _, err := p.term.Run(fmt.Sprintf("umount %s%s", p.mountPath, dirs[i])); err != nil {
+			log.Errorf("umount %s%s: %v", p.mountPath, dirs[i], err)
+		}
+	}
+}
+
 func (p *SSHPartition) osStat(sPath string) (os.FileInfo, error) {
 	cmd := fmt.Sprintf("ls -a -l -n -i -s -d %s", sPath)
 	ret, err := p.term.Run(cmd)
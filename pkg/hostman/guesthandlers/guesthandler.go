@@ -59,8 +59,10 @@ var (
 		"resume":               guestResume,
 		// "start-nbd-server":     guestStartNbdServer,
 		"drive-mirror":        guestDriveMirror,
+		"storage-migrate":     guestStorageMigrate,
 		"hotplug-cpu-mem":     guestHotplugCpuMem,
 		"create-from-libvirt": guestCreateFromLibvirt,
+		"convert-esxi":        guestConvertEsxi,
 	}
 )
 
@@ -369,6 +371,35 @@ func guestDriveMirror(ctx context.Context, sid string, body jsonutils.JSONObject
 	return nil, nil
 }
 
+func guestStorageMigrate(ctx context.Context, sid string, body jsonutils.JSONObject) (interface{}, error) {
+	if !guestman.GetGuestManager().IsGuestExist(sid) {
+		return nil, httperrors.NewNotFoundError("Guest %s not found", sid)
+	}
+	if guestman.GetGuestManager().Status(sid) != "running" {
+		return nil, httperrors.NewBadRequestError("Guest %s not running", sid)
+	}
+	diskId, err := body.GetString("disk_id")
+	if err != nil {
+		return nil, httperrors.NewMissingParameterError("disk_id")
+	}
+	targetStorageId, err := body.GetString("target_storage_id")
+	if err != nil {
+		return nil, httperrors.NewMissingParameterError("target_storage_id")
+	}
+	if storageman.GetManager().GetStorageDisk(targetStorageId, diskId) == nil {
+		return nil, httperrors.NewNotFoundError("Disk %s not found on storage %s", diskId, targetStorageId)
+	}
+	format, _ := body.GetString("format")
+	hostutils.DelayTaskWithoutReqctx(ctx, guestman.GetGuestManager().DiskStorageMigrate,
+		&guestman.SGuestDiskStorageMigrate{
+			Sid:             sid,
+			DiskId:          diskId,
+			TargetStorageId: targetStorageId,
+			Format:          format,
+		})
+	return nil, nil
+}
+
 func guestHotplugCpuMem(ctx context.Context, sid string, body jsonutils.JSONObject) (interface{}, error) {
 	if !guestman.GetGuestManager().IsGuestExist(sid) {
 		return nil, httperrors.NewNotFoundError("Guest %s not found", sid)
@@ -414,6 +445,22 @@ func guestCreateFromLibvirt(ctx context.Context, sid string, body jsonutils.JSON
 	return nil, nil
 }
 
+func guestConvertEsxi(ctx context.Context, sid string, body jsonutils.JSONObject) (interface{}, error) {
+	if guestman.GetGuestManager().IsGuestExist(sid) {
+		return nil, httperrors.NewBadRequestError("Guest %s exists", sid)
+	}
+	config := &compute.SEsxiConvertConfig{}
+	if err := body.Unmarshal(config); err != nil {
+		return nil, httperrors.NewInputParameterError("unmarshal convert config: %v", err)
+	}
+	if len(config.Disks) == 0 {
+		return nil, httperrors.NewMissingParameterError("disks")
+	}
+	hostutils.DelayTask(ctx, guestman.GetGuestManager().GuestConvertEsxi,
+		&guestman.SGuestConvertEsxi{Sid: sid, Config: config})
+	return nil, nil
+}
+
 func guestReloadDiskSnapshot(ctx context.Context, sid string, body jsonutils.JSONObject) (interface{}, error) {
 	if !guestman.GetGuestManager().IsGuestExist(sid) {
 		return nil, httperrors.NewNotFoundError("Guest %s not found", sid)
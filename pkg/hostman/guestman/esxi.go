@@ -0,0 +1,182 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestman
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/hostman/guestfs/fsdriver"
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+	"yunion.io/x/onecloud/pkg/hostman/storageman"
+	"yunion.io/x/onecloud/pkg/util/esxi"
+	"yunion.io/x/onecloud/pkg/util/qemuimg"
+	"yunion.io/x/onecloud/pkg/util/vmdkutils"
+)
+
+// IEsxiDatastore is the part of esxi.SDatastore used to export disks, so
+// the export is able to run against a simulated datastore
+type IEsxiDatastore interface {
+	FileGetContent(ctx context.Context, remotePath string) ([]byte, error)
+	Download(ctx context.Context, remotePath string, writer io.Writer) error
+}
+
+// GuestConvertEsxi exports disks of an esxi guest from its datastores,
+// converts them to qcow2 on storages of this host and injects virtio drivers
+// into the root disk, so the guest is able to boot on kvm.  virtio_drivers of
+// the result tells whether the guest is able to use virtio devices
+func (m *SGuestManager) GuestConvertEsxi(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	convert, ok := params.(*SGuestConvertEsxi)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	vc := convert.Config.VCenter
+	passwd, err := utils.DescryptAESBase64(convert.Sid, vc.Password)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt password: %v", err)
+	}
+	cli, err := esxi.NewESXiClient("", "", vc.Host, vc.Port, vc.Account, passwd)
+	if err != nil {
+		return nil, fmt.Errorf("connect %s: %v", vc.Host, err)
+	}
+
+	disksPath := jsonutils.NewDict()
+	converted := []string{}
+	for _, disk := range convert.Config.Disks {
+		storage := storageman.GetManager().GetStorage(disk.TargetStorageId)
+		if storage == nil {
+			err = fmt.Errorf("Host has no stroage %s", disk.TargetStorageId)
+			break
+		}
+		var ds *esxi.SDatastore
+		ds, err = cli.FindDatastoreByGlobalId(disk.DatastoreId)
+		if err != nil {
+			err = fmt.Errorf("find datastore %s: %v", disk.DatastoreId, err)
+			break
+		}
+		target := storage.CreateDisk(disk.DiskId).GetPath()
+		if err = ConvertEsxiDisk(ctx, ds, disk.Path, target); err != nil {
+			err = fmt.Errorf("convert disk %s: %v", disk.DiskId, err)
+			break
+		}
+		converted = append(converted, target)
+		disksPath.Set(disk.DiskId, jsonutils.NewString(target))
+	}
+	virtio := false
+	if err == nil && len(converted) > 0 {
+		err = deployVirtioDrivers(converted[0])
+		if err == fsdriver.ErrVirtioDriversNotFound {
+			log.Warningf("no virtio drivers for guest %s, emulated devices are used", convert.Sid)
+			err = nil
+		} else if err == nil {
+			virtio = true
+		}
+	}
+	if err != nil {
+		for _, target := range converted {
+			if e := os.Remove(target); e != nil {
+				log.Errorf("remove converted disk %s: %v", target, e)
+			}
+		}
+		return nil, err
+	}
+	ret := jsonutils.NewDict()
+	ret.Set("disks_path", disksPath)
+	ret.Set("virtio_drivers", jsonutils.NewBool(virtio))
+	return ret, nil
+}
+
+// ConvertEsxiDisk exports vmdk at vmdkPath of ds into a temporary directory
+// beside target, then converts it to qcow2 at target
+func ConvertEsxiDisk(ctx context.Context, ds IEsxiDatastore, vmdkPath, target string) error {
+	tmpDir, err := ioutil.TempDir(filepath.Dir(target), "esxi-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	descPath, err := exportEsxiDisk(ctx, ds, vmdkPath, tmpDir)
+	if err != nil {
+		return err
+	}
+	img, err := qemuimg.NewQemuImage(descPath)
+	if err != nil {
+		return err
+	}
+	if !img.IsValid() {
+		return fmt.Errorf("invalid vmdk %s", vmdkPath)
+	}
+	return img.Convert2Qcow2To(target, true)
+}
+
+// exportEsxiDisk downloads descriptor of vmdkPath and its flat extent into
+// dir, returns local path of the descriptor.  Disks with snapshots are
+// refused as their data spread over delta extents
+func exportEsxiDisk(ctx context.Context, ds IEsxiDatastore, vmdkPath, dir string) (string, error) {
+	content, err := ds.FileGetContent(ctx, vmdkPath)
+	if err != nil {
+		return "", fmt.Errorf("get descriptor %s: %v", vmdkPath, err)
+	}
+	info, err := vmdkutils.Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("parse descriptor %s: %v", vmdkPath, err)
+	}
+	if !info.IsFlat() {
+		return "", fmt.Errorf("%s has snapshots, consolidate them before converting", vmdkPath)
+	}
+	descPath := filepath.Join(dir, filepath.Base(vmdkPath))
+	if err := ioutil.WriteFile(descPath, content, 0644); err != nil {
+		return "", err
+	}
+	// extent lives beside the descriptor
+	extentPath := path.Join(path.Dir(vmdkPath), info.ExtentFile)
+	f, err := os.Create(filepath.Join(dir, info.ExtentFile))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := ds.Download(ctx, extentPath, f); err != nil {
+		return "", fmt.Errorf("download %s: %v", extentPath, err)
+	}
+	return descPath, nil
+}
+
+func deployVirtioDrivers(diskPath string) error {
+	disk := storageman.NewKVMGuestDisk(diskPath)
+	defer disk.Disconnect()
+	if !disk.Connect() {
+		return fmt.Errorf("connect disk %s failed", diskPath)
+	}
+	root := disk.MountKvmRootfs()
+	if root == nil {
+		return fmt.Errorf("root filesystem of %s not found", diskPath)
+	}
+	defer disk.UmountKvmRootfs(root)
+	if err := root.DeployVirtioDrivers(root.GetPartition()); err != nil {
+		if err == fsdriver.ErrVirtioDriversNotFound {
+			return err
+		}
+		return fmt.Errorf("deploy virtio drivers: %v", err)
+	}
+	return nil
+}
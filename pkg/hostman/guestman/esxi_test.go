@@ -0,0 +1,96 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestman
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeDatastore serves files by access path of disks, i.e. url of the
+// datastore joined with path of the file
+type fakeDatastore map[string]string
+
+func (ds fakeDatastore) FileGetContent(ctx context.Context, remotePath string) ([]byte, error) {
+	content, ok := ds[remotePath]
+	if !ok {
+		return nil, fmt.Errorf("404 Not Found")
+	}
+	return []byte(content), nil
+}
+
+func (ds fakeDatastore) Download(ctx context.Context, remotePath string, writer io.Writer) error {
+	content, ok := ds[remotePath]
+	if !ok {
+		return fmt.Errorf("404 Not Found")
+	}
+	_, err := io.Copy(writer, strings.NewReader(content))
+	return err
+}
+
+const (
+	flatDescriptor = `CID=ab798ecb
+parentCID=ffffffff
+createType="vmfs"
+RW 2048 VMFS "vm-flat.vmdk"
+ddb.adapterType = "lsilogic"
+`
+	snapshotDescriptor = `CID=5c6e1a2b
+parentCID=ab798ecb
+createType="seSparse"
+parentFileNameHint="vm.vmdk"
+RW 2048 SESPARSE "vm-000001-sesparse.vmdk"
+`
+)
+
+func TestExportEsxiDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esxi-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ds := fakeDatastore{
+		"/vmfs/volumes/5c9b/vm/vm.vmdk":                 flatDescriptor,
+		"/vmfs/volumes/5c9b/vm/vm-flat.vmdk":            "flat extent",
+		"/vmfs/volumes/5c9b/vm/vm-000001.vmdk":          snapshotDescriptor,
+		"/vmfs/volumes/5c9b/vm/vm-000001-sesparse.vmdk": "delta",
+	}
+	ctx := context.Background()
+	descPath, err := exportEsxiDisk(ctx, ds, "/vmfs/volumes/5c9b/vm/vm.vmdk", dir)
+	if err != nil {
+		t.Fatalf("export flat disk: %v", err)
+	}
+	if descPath != filepath.Join(dir, "vm.vmdk") {
+		t.Errorf("unexpected descriptor path %s", descPath)
+	}
+	extent, err := ioutil.ReadFile(filepath.Join(dir, "vm-flat.vmdk"))
+	if err != nil || string(extent) != "flat extent" {
+		t.Errorf("extent not exported: %q, %v", extent, err)
+	}
+
+	if _, err := exportEsxiDisk(ctx, ds, "/vmfs/volumes/5c9b/vm/vm-000001.vmdk", dir); err == nil {
+		t.Errorf("disk with snapshots should be refused")
+	}
+	if _, err := exportEsxiDisk(ctx, ds, "/vmfs/volumes/5c9b/vm/missing.vmdk", dir); err == nil {
+		t.Errorf("missing disk should fail")
+	}
+}
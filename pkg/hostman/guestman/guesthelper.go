@@ -17,6 +17,7 @@ package guestman
 import (
 	"yunion.io/x/jsonutils"
 
+	"yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/hostman/storageman"
 )
 
@@ -63,6 +64,13 @@ type SDriverMirror struct {
 	Desc         jsonutils.JSONObject
 }
 
+type SGuestDiskStorageMigrate struct {
+	Sid             string
+	DiskId          string
+	TargetStorageId string
+	Format          string
+}
+
 type SGuestHotplugCpuMem struct {
 	Sid         string
 	AddCpuCount int64
@@ -103,3 +111,8 @@ type SGuestCreateFromLibvirt struct {
 	GuestDesc *jsonutils.JSONDict
 	DisksPath *jsonutils.JSONDict
 }
+
+type SGuestConvertEsxi struct {
+	Sid    string
+	Config *compute.SEsxiConvertConfig
+}
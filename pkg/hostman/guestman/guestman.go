@@ -698,6 +698,23 @@ func (m *SGuestManager) StartDriveMirror(ctx context.Context, params interface{}
 	return nil, nil
 }
 
+func (m *SGuestManager) DiskStorageMigrate(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	migrateParams, ok := params.(*SGuestDiskStorageMigrate)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	guest, ok := guestManger.Servers[migrateParams.Sid]
+	if !ok {
+		return nil, httperrors.NewNotFoundError("guest %s not found", migrateParams.Sid)
+	}
+	if !guest.IsRunning() {
+		return nil, httperrors.NewInvalidStatusError("guest is not running")
+	}
+	NewGuestDiskStorageMigrateTask(ctx, guest, migrateParams.DiskId,
+		migrateParams.TargetStorageId, migrateParams.Format).Start()
+	return nil, nil
+}
+
 func (m *SGuestManager) HotplugCpuMem(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
 	hotplugParams, ok := params.(*SGuestHotplugCpuMem)
 	if !ok {
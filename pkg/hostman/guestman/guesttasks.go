@@ -946,7 +946,7 @@ func (s *SDriveMirrorTask) startMirror(res string) {
 		}
 		target := fmt.Sprintf("%s:exportname=drive_%d", s.nbdUri, s.index)
 		s.Monitor.DriveMirror(s.startMirror, fmt.Sprintf("drive_%d", s.index),
-			target, s.syncMode, true)
+			target, s.syncMode, "", true)
 		s.index += 1
 	} else {
 		if s.onSucc != nil {
@@ -957,6 +957,134 @@ func (s *SDriveMirrorTask) startMirror(res string) {
 	}
 }
 
+/**
+ *  GuestDiskStorageMigrateTask
+**/
+
+type SGuestDiskStorageMigrateTask struct {
+	*SKVMGuestInstance
+
+	ctx        context.Context
+	diskId     string
+	storageId  string
+	format     string
+	drive      string
+	targetDisk storageman.IDisk
+	mirrorDone bool
+}
+
+func NewGuestDiskStorageMigrateTask(
+	ctx context.Context, s *SKVMGuestInstance, diskId, storageId, format string,
+) *SGuestDiskStorageMigrateTask {
+	return &SGuestDiskStorageMigrateTask{
+		SKVMGuestInstance: s,
+		ctx:               ctx,
+		diskId:            diskId,
+		storageId:         storageId,
+		format:            format,
+	}
+}
+
+func (task *SGuestDiskStorageMigrateTask) Start() {
+	disks, _ := task.Desc.GetArray("disks")
+	for _, disk := range disks {
+		if diskId, _ := disk.GetString("disk_id"); diskId == task.diskId {
+			index, _ := disk.Int("index")
+			task.drive = fmt.Sprintf("drive_%d", index)
+			break
+		}
+	}
+	if len(task.drive) == 0 {
+		hostutils.TaskFailed(task.ctx, fmt.Sprintf("disk %s not found on this guest", task.diskId))
+		return
+	}
+	task.targetDisk = storageman.GetManager().GetStorageDisk(task.storageId, task.diskId)
+	if task.targetDisk == nil {
+		hostutils.TaskFailed(task.ctx, fmt.Sprintf("disk %s not found on storage %s", task.diskId, task.storageId))
+		return
+	}
+	log.Infof("Mirror %s of guest %s to %s", task.drive, task.GetName(), task.targetDisk.GetPath())
+	task.Monitor.DriveMirror(task.onMirrorStarted, task.drive, task.targetDisk.GetPath(), "full", task.format, true)
+}
+
+func (task *SGuestDiskStorageMigrateTask) onMirrorStarted(res string) {
+	if len(res) > 0 {
+		hostutils.TaskFailed(task.ctx, fmt.Sprintf("drive mirror: %s", res))
+		return
+	}
+	task.checkJob()
+}
+
+func (task *SGuestDiskStorageMigrateTask) checkJob() {
+	task.Monitor.GetBlockJobs(task.onGetBlockJobs)
+}
+
+func (task *SGuestDiskStorageMigrateTask) onGetBlockJobs(jobs *jsonutils.JSONArray) {
+	var job jsonutils.JSONObject
+	if jobs != nil {
+		for _, val := range jobs.Value() {
+			if device, _ := val.GetString("device"); device == task.drive {
+				job = val
+				break
+			}
+		}
+	}
+	if job == nil {
+		if task.mirrorDone {
+			task.onPivoted()
+		} else {
+			hostutils.TaskFailed(task.ctx, fmt.Sprintf("mirror job of %s aborted", task.drive))
+		}
+		return
+	}
+	if !task.mirrorDone && task.isJobReady(job) {
+		task.Monitor.BlockJobComplete(task.drive, task.onBlockJobComplete)
+		return
+	}
+	time.AfterFunc(time.Second*3, task.checkJob)
+}
+
+func (task *SGuestDiskStorageMigrateTask) isJobReady(job jsonutils.JSONObject) bool {
+	if ready, err := job.Bool("ready"); err == nil {
+		return ready
+	}
+	if status, _ := job.GetString("status"); status == "ready" {
+		return true
+	}
+	offset, _ := job.Int("offset")
+	length, _ := job.Int("len")
+	return length > 0 && offset == length
+}
+
+func (task *SGuestDiskStorageMigrateTask) onBlockJobComplete(res string) {
+	if len(res) > 0 {
+		hostutils.TaskFailed(task.ctx, fmt.Sprintf("block job complete: %s", res))
+		return
+	}
+	task.mirrorDone = true
+	task.checkJob()
+}
+
+func (task *SGuestDiskStorageMigrateTask) onPivoted() {
+	disks, _ := task.Desc.GetArray("disks")
+	for _, disk := range disks {
+		if diskId, _ := disk.GetString("disk_id"); diskId == task.diskId {
+			diskDesc := disk.(*jsonutils.JSONDict)
+			diskDesc.Set("path", jsonutils.NewString(task.targetDisk.GetPath()))
+			diskDesc.Set("storage_id", jsonutils.NewString(task.storageId))
+			if len(task.format) > 0 {
+				diskDesc.Set("format", jsonutils.NewString(task.format))
+			}
+		}
+	}
+	if err := task.SaveDesc(task.Desc); err != nil {
+		log.Errorf("save desc after storage migrate: %s", err)
+	}
+	res := jsonutils.NewDict()
+	res.Set("disk_path", jsonutils.NewString(task.targetDisk.GetPath()))
+	hostutils.TaskComplete(task.ctx, res)
+}
+
 /**
  *  GuestOnlineResizeDiskTask
 **/
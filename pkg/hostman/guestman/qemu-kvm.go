@@ -727,6 +727,12 @@ func (s *SKVMGuestInstance) delTmpDisks(ctx context.Context, migrated bool) erro
 					return err
 				}
 			}
+			if d, ok := d.(*storageman.SCLVMDisk); ok && migrated {
+				// release shared activation for the guest runs on target host
+				if err := d.Deactivate(); err != nil {
+					log.Errorln(err)
+				}
+			}
 		}
 	}
 	return nil
@@ -1173,6 +1179,9 @@ func (s *SKVMGuestInstance) ExecDiskSnapshotTask(
 		if !s.isLiveSnapshotEnabled() {
 			return nil, fmt.Errorf("Guest dosen't support live snapshot")
 		}
+		if disk.GetType() == api.STORAGE_CLVM {
+			return nil, fmt.Errorf("Live snapshot of clvm disk is not supported")
+		}
 		err := disk.CreateSnapshot(snapshotId)
 		if err != nil {
 			return nil, err
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -348,6 +349,7 @@ func (m *HmpMonitor) GetBlockJobs(callback func(*jsonutils.JSONArray)) {
 		} else {
 			res := jsonutils.NewArray()
 			re := regexp.MustCompile(`Type (?P<type>\w+), device (?P<device>\w+)`)
+			progressRe := regexp.MustCompile(`Completed (?P<offset>\d+) of (?P<len>\d+) bytes`)
 			for i := 0; i < len(lines); i++ {
 				m := regutils2.GetParams(re, lines[i])
 				if len(m) > 0 {
@@ -356,6 +358,12 @@ func (m *HmpMonitor) GetBlockJobs(callback func(*jsonutils.JSONArray)) {
 					jobInfo := jsonutils.NewDict()
 					jobInfo.Set("type", jsonutils.NewString(jobType))
 					jobInfo.Set("device", jsonutils.NewString(device))
+					if p := regutils2.GetParams(progressRe, lines[i]); len(p) > 0 {
+						offset, _ := strconv.ParseInt(p["offset"], 10, 64)
+						length, _ := strconv.ParseInt(p["len"], 10, 64)
+						jobInfo.Set("offset", jsonutils.NewInt(offset))
+						jobInfo.Set("len", jsonutils.NewInt(length))
+					}
 					res.Add(jobInfo)
 				}
 			}
@@ -370,15 +378,22 @@ func (m *HmpMonitor) ReloadDiskBlkdev(device, path string, callback StringCallba
 	m.Query(fmt.Sprintf("reload_disk_snapshot_blkdev -n %s %s", device, path), callback)
 }
 
-func (m *HmpMonitor) DriveMirror(callback StringCallback, drive, target, syncMode string, unmap bool) {
+func (m *HmpMonitor) DriveMirror(callback StringCallback, drive, target, syncMode, format string, unmap bool) {
 	cmd := "drive_mirror -n"
 	if syncMode == "full" {
 		cmd += " -f"
 	}
 	cmd += fmt.Sprintf(" %s %s", drive, target)
+	if len(format) > 0 {
+		cmd += " " + format
+	}
 	m.Query(cmd, callback)
 }
 
+func (m *HmpMonitor) BlockJobComplete(drive string, callback StringCallback) {
+	m.Query(fmt.Sprintf("block_job_complete %s", drive), callback)
+}
+
 func (m *HmpMonitor) BlockStream(drive string, callback StringCallback) {
 	var (
 		speed = 30 // MB/s speed limit 31457280 bytes/s
@@ -57,7 +57,8 @@ type Monitor interface {
 	DeviceAdd(dev string, params map[string]interface{}, callback StringCallback)
 
 	BlockStream(drive string, callback StringCallback)
-	DriveMirror(callback StringCallback, drive, target, syncMode string, unmap bool)
+	DriveMirror(callback StringCallback, drive, target, syncMode, format string, unmap bool)
+	BlockJobComplete(drive string, callback StringCallback)
 
 	MigrateSetCapability(capability, state string, callback StringCallback)
 	Migrate(destStr string, copyIncremental, copyFull bool, callback StringCallback)
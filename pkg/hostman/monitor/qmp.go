@@ -670,19 +670,34 @@ func (m *QmpMonitor) ReloadDiskBlkdev(device, path string, callback StringCallba
 	m.Query(cmd, cb)
 }
 
-func (m *QmpMonitor) DriveMirror(callback StringCallback, drive, target, syncMode string, unmap bool) {
+func (m *QmpMonitor) DriveMirror(callback StringCallback, drive, target, syncMode, format string, unmap bool) {
+	var (
+		cb = func(res *Response) {
+			callback(m.actionResult(res))
+		}
+		args = map[string]interface{}{
+			"device": drive,
+			"target": target,
+			"mode":   "existing",
+			"sync":   syncMode,
+			"unmap":  unmap,
+		}
+	)
+	if len(format) > 0 {
+		args["format"] = format
+	}
+	m.Query(&Command{Execute: "drive-mirror", Args: args}, cb)
+}
+
+func (m *QmpMonitor) BlockJobComplete(drive string, callback StringCallback) {
 	var (
 		cb = func(res *Response) {
 			callback(m.actionResult(res))
 		}
 		cmd = &Command{
-			Execute: "drive-mirror",
-			Args: map[string]interface{}{
+			Execute: "block-job-complete",
+			Args: map[string]string{
 				"device": drive,
-				"target": target,
-				"mode":   "existing",
-				"sync":   syncMode,
-				"unmap":  unmap,
 			},
 		}
 	)
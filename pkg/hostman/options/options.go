@@ -101,6 +101,8 @@ type SHostOptions struct {
 	EnableTelegraf          bool `default:"true" help:"enable send monitoring data to telegraf"`
 	WindowsDefaultAdminUser bool `default:"true" help:"Default account for Windows system is Administrator"`
 
+	VirtioWinDriverPath string `help:"Directory of virtio-win drivers in amd64 and x86 subdirectories, injected into windows guests converted to kvm"`
+
 	HostCpuPassthrough bool `default:"true" help:"if it is true, set qemu cpu type as -cpu host, otherwise, qemu64. default is true"`
 }
 
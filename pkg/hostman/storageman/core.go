@@ -44,8 +44,9 @@ type SStorageManager struct {
 	LocalStorageImagecacheManager IImageCacheManger
 	// AgentStorageImagecacheManager IImageCacheManger
 
-	RbdStorageImagecacheManagers map[string]IImageCacheManger
-	NfsStorageImagecacheManagers map[string]IImageCacheManger
+	RbdStorageImagecacheManagers  map[string]IImageCacheManger
+	NfsStorageImagecacheManagers  map[string]IImageCacheManger
+	ClvmStorageImagecacheManagers map[string]IImageCacheManger
 }
 
 func NewStorageManager(host hostutils.IHost) (*SStorageManager, error) {
@@ -91,6 +92,8 @@ func (s *SStorageManager) Remove(storage IStorage) {
 		delete(s.NfsStorageImagecacheManagers, storage.GetStoragecacheId())
 	} else if storage.StorageType() == api.STORAGE_RBD {
 		delete(s.RbdStorageImagecacheManagers, storage.GetStoragecacheId())
+	} else if storage.StorageType() == api.STORAGE_CLVM {
+		delete(s.ClvmStorageImagecacheManagers, storage.GetStoragecacheId())
 	}
 	for index, iS := range s.Storages {
 		if iS.GetId() == storage.GetId() {
@@ -259,6 +262,9 @@ func (s *SStorageManager) GetStoragecacheById(scId string) IImageCacheManger {
 	if sc, ok := s.RbdStorageImagecacheManagers[scId]; ok {
 		return sc
 	}
+	if sc, ok := s.ClvmStorageImagecacheManagers[scId]; ok {
+		return sc
+	}
 	return nil
 }
 
@@ -274,6 +280,8 @@ func (s *SStorageManager) InitSharedStorageImageCache(storageType, storagecacheI
 			// Done
 			s.AddRbdStorageImagecache(imagecachePath, storage, storagecacheId)
 		}
+	} else if storageType == api.STORAGE_CLVM {
+		s.InitClvmStorageImagecache(storagecacheId, imagecachePath)
 	}
 }
 
@@ -289,6 +297,20 @@ func (s *SStorageManager) InitNfsStorageImagecache(storagecacheId, path string)
 	}
 }
 
+// InitClvmStorageImagecache caches images of clvm storage on local disk of
+// host, images are converted into logical volumes when disks are created
+func (s *SStorageManager) InitClvmStorageImagecache(storagecacheId, path string) {
+	if len(path) == 0 {
+		return
+	}
+	if s.ClvmStorageImagecacheManagers == nil {
+		s.ClvmStorageImagecacheManagers = map[string]IImageCacheManger{}
+	}
+	if _, ok := s.ClvmStorageImagecacheManagers[storagecacheId]; !ok {
+		s.ClvmStorageImagecacheManagers[storagecacheId] = NewLocalImageCacheManager(s, path, options.HostOptions.ImageCacheLimit, true, storagecacheId)
+	}
+}
+
 func (s *SStorageManager) AddRbdStorageImagecache(imagecachePath string, storage IStorage, storagecacheId string) {
 	if s.RbdStorageImagecacheManagers == nil {
 		s.RbdStorageImagecacheManagers = map[string]IImageCacheManger{}
@@ -0,0 +1,301 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageman
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/appctx"
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+	"yunion.io/x/onecloud/pkg/util/lvmutils"
+	"yunion.io/x/onecloud/pkg/util/procutils"
+	"yunion.io/x/onecloud/pkg/util/qemuimg"
+	"yunion.io/x/onecloud/pkg/util/qemutils"
+)
+
+type SCLVMDisk struct {
+	SBaseDisk
+}
+
+func NewCLVMDisk(storage IStorage, id string) *SCLVMDisk {
+	var ret = new(SCLVMDisk)
+	ret.SBaseDisk = *NewBaseDisk(storage, id)
+	return ret
+}
+
+func (d *SCLVMDisk) GetType() string {
+	return api.STORAGE_CLVM
+}
+
+func (d *SCLVMDisk) getStorage() *SCLVMStorage {
+	return d.Storage.(*SCLVMStorage)
+}
+
+func (d *SCLVMDisk) getVgName() string {
+	return d.getStorage().getVgName()
+}
+
+func (d *SCLVMDisk) GetPath() string {
+	return lvmutils.LvPath(d.getVgName(), d.Id)
+}
+
+func (d *SCLVMDisk) GetSnapshotDir() string {
+	return ""
+}
+
+// Probe activates logical volume in shared mode if it is not active on this host
+func (d *SCLVMDisk) Probe() error {
+	lv, err := lvmutils.GetLogicalVolume(d.getVgName(), d.Id)
+	if err != nil {
+		return err
+	}
+	if !lv.IsActive() {
+		return lvmutils.ChangeActivation(d.getVgName(), d.Id, lvmutils.ACTIVATE_SHARED)
+	}
+	return nil
+}
+
+// Deactivate releases shared lock of logical volume held by this host,
+// e.g. after guest migrated away
+func (d *SCLVMDisk) Deactivate() error {
+	return lvmutils.ChangeActivation(d.getVgName(), d.Id, lvmutils.DEACTIVATE)
+}
+
+// activateShared converts exclusive activation of newly created logical
+// volume to shared activation
+func (d *SCLVMDisk) activateShared() error {
+	if err := d.Deactivate(); err != nil {
+		return err
+	}
+	return lvmutils.ChangeActivation(d.getVgName(), d.Id, lvmutils.ACTIVATE_SHARED)
+}
+
+func (d *SCLVMDisk) GetDiskDesc() jsonutils.JSONObject {
+	lv, err := lvmutils.GetLogicalVolume(d.getVgName(), d.Id)
+	if err != nil {
+		log.Errorln(err)
+		return nil
+	}
+	desc := map[string]interface{}{
+		"disk_id":     d.Id,
+		"disk_format": "raw",
+		"disk_path":   d.GetPath(),
+		"disk_size":   lv.SizeMb,
+	}
+	return jsonutils.Marshal(desc)
+}
+
+func (d *SCLVMDisk) GetDiskSetupScripts(idx int) string {
+	return fmt.Sprintf("DISK_%d=%s\n", idx, d.GetPath())
+}
+
+func (d *SCLVMDisk) DeleteAllSnapshot() error {
+	return d.getStorage().deleteDiskSnapshots(d.Id)
+}
+
+func (d *SCLVMDisk) Delete(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	log.Infof("Delete guest disk %s", d.GetPath())
+	if err := d.Deactivate(); err != nil {
+		log.Errorln(err)
+	}
+	if err := lvmutils.RemoveLv(d.getVgName(), d.Id); err != nil {
+		return nil, err
+	}
+	d.Storage.RemoveDisk(d)
+	return nil, nil
+}
+
+func (d *SCLVMDisk) Resize(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	diskInfo, ok := params.(*jsonutils.JSONDict)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	sizeMb, _ := diskInfo.Int("size")
+	lv, err := lvmutils.GetLogicalVolume(d.getVgName(), d.Id)
+	if err != nil {
+		return nil, err
+	}
+	if sizeMb > lv.SizeMb {
+		if err := lvmutils.ExtendLv(d.getVgName(), d.Id, sizeMb); err != nil {
+			return nil, err
+		}
+		if err := lvmutils.RefreshLv(d.getVgName(), d.Id); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.ResizeFs(); err != nil {
+		return nil, err
+	}
+	return d.GetDiskDesc(), nil
+}
+
+func (d *SCLVMDisk) ResizeFs() error {
+	disk := NewKVMGuestDisk(d.GetPath())
+	if disk.Connect() {
+		defer disk.Disconnect()
+		if err := disk.ResizePartition(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrepareSaveToGlance copies disk into an image file of host local storage,
+// which is uploaded by SaveToGlance of storage
+func (d *SCLVMDisk) PrepareSaveToGlance(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	if err := d.Probe(); err != nil {
+		return nil, err
+	}
+	localStorage := d.getStorage().getLocalStorage()
+	if localStorage == nil {
+		return nil, fmt.Errorf("no local storage to save image")
+	}
+	destDir := localStorage.GetImgsaveBackupPath()
+	if _, err := procutils.NewCommand("mkdir", "-p", destDir).Run(); err != nil {
+		log.Errorln(err)
+		return nil, err
+	}
+	backupPath := path.Join(destDir, fmt.Sprintf("%s.%s", d.Id, appctx.AppContextTaskId(ctx)))
+	output, err := procutils.NewCommand(qemutils.GetQemuImg(), "convert", "-f", "raw", "-O", "qcow2", d.GetPath(), backupPath).Run()
+	if err != nil {
+		procutils.NewCommand("rm", "-f", backupPath).Run()
+		return nil, fmt.Errorf("convert disk %s: %s", d.Id, output)
+	}
+	res := jsonutils.NewDict()
+	res.Set("backup", jsonutils.NewString(backupPath))
+	return res, nil
+}
+
+func (d *SCLVMDisk) ResetFromSnapshot(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	resetParams, ok := params.(*SDiskReset)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	return nil, d.getStorage().resetFromSnapshot(d.Id, resetParams.SnapshotId)
+}
+
+// CleanupSnapshots only deletes snapshots, snapshots of clvm disk are full
+// copies without backing chain, there is nothing to convert
+func (d *SCLVMDisk) CleanupSnapshots(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	cleanupParams, ok := params.(*SDiskCleanupSnapshots)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	for _, snapshotId := range cleanupParams.DeleteSnapshots {
+		snapId, _ := snapshotId.GetString()
+		if err := d.getStorage().deleteSnapshot(snapId); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (d *SCLVMDisk) PrepareMigrate(liveMigrate bool) (string, error) {
+	return "", nil
+}
+
+func (d *SCLVMDisk) CreateFromUrl(context.Context, string) error {
+	return fmt.Errorf("Not support")
+}
+
+func (d *SCLVMDisk) CreateFromTemplate(ctx context.Context, imageId string, format string, size int64) (jsonutils.JSONObject, error) {
+	imageCacheManager := storageManager.GetStoragecacheById(d.Storage.GetStoragecacheId())
+	if imageCacheManager == nil {
+		imageCacheManager = storageManager.LocalStorageImagecacheManager
+	}
+	imageCache := imageCacheManager.AcquireImage(ctx, imageId, d.GetZone(), "", "")
+	if imageCache == nil {
+		return nil, fmt.Errorf("Fail to fetch image %s", imageId)
+	}
+	defer imageCacheManager.ReleaseImage(imageId)
+
+	img, err := qemuimg.NewQemuImage(imageCache.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	sizeMb := int64(img.GetSizeMB())
+	if size > sizeMb {
+		sizeMb = size
+	}
+	if err := lvmutils.CreateLv(d.getVgName(), d.Id, sizeMb); err != nil {
+		return nil, err
+	}
+	output, err := procutils.NewCommand(qemutils.GetQemuImg(), "convert", "-n", "-O", "raw", imageCache.GetPath(), d.GetPath()).Run()
+	if err != nil {
+		lvmutils.RemoveLv(d.getVgName(), d.Id)
+		return nil, fmt.Errorf("convert image %s to disk %s: %s", imageId, d.Id, output)
+	}
+	if size > int64(img.GetSizeMB()) {
+		if err := d.ResizeFs(); err != nil {
+			log.Errorf("resize fs of disk %s error: %s", d.Id, err)
+		}
+	}
+	if err := d.activateShared(); err != nil {
+		return nil, err
+	}
+	return d.GetDiskDesc(), nil
+}
+
+func (d *SCLVMDisk) CreateFromImageFuse(context.Context, string) error {
+	return fmt.Errorf("Not support")
+}
+
+func (d *SCLVMDisk) CreateRaw(ctx context.Context, sizeMb int, diskFromat string, fsFormat string, encryption bool, diskId string, back string) (jsonutils.JSONObject, error) {
+	if err := lvmutils.CreateLv(d.getVgName(), d.Id, int64(sizeMb)); err != nil {
+		return nil, err
+	}
+	if utils.IsInStringArray(fsFormat, []string{"swap", "ext2", "ext3", "ext4", "xfs"}) {
+		d.FormatFs(fsFormat, diskId)
+	}
+	if err := d.activateShared(); err != nil {
+		return nil, err
+	}
+	return d.GetDiskDesc(), nil
+}
+
+func (d *SCLVMDisk) FormatFs(fsFormat, uuid string) {
+	log.Infof("Make disk %s fs %s", uuid, fsFormat)
+	gd := NewKVMGuestDisk(d.GetPath())
+	if gd.Connect() {
+		defer gd.Disconnect()
+		if err := gd.MakePartition(fsFormat); err == nil {
+			err = gd.FormatPartition(fsFormat, uuid)
+			if err != nil {
+				log.Errorln(err)
+			}
+		} else {
+			log.Errorln(err)
+		}
+	}
+}
+
+func (d *SCLVMDisk) PostCreateFromImageFuse() {
+	log.Errorf("Not support PostCreateFromImageFuse")
+}
+
+func (d *SCLVMDisk) CreateSnapshot(snapshotId string) error {
+	return d.getStorage().createSnapshot(d.Id, snapshotId)
+}
+
+func (d *SCLVMDisk) DeleteSnapshot(snapshotId, convertSnapshot string, pendingDelete bool) error {
+	return d.getStorage().deleteSnapshot(snapshotId)
+}
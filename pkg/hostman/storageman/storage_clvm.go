@@ -0,0 +1,334 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageman
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/util/lvmutils"
+	"yunion.io/x/onecloud/pkg/util/procutils"
+	"yunion.io/x/onecloud/pkg/util/qemutils"
+)
+
+func init() {
+	registerStorageFactory(&SCLVMStorageFactory{})
+}
+
+type SCLVMStorageFactory struct {
+}
+
+func (factory *SCLVMStorageFactory) NewStorage(manager *SStorageManager, mountPoint string) IStorage {
+	return NewCLVMStorage(manager, mountPoint)
+}
+
+func (factory *SCLVMStorageFactory) StorageType() string {
+	return api.STORAGE_CLVM
+}
+
+// SCLVMStorage is a volume group on SAN shared by hosts, each disk is a thick
+// logical volume activated in shared mode, so that both hosts of a live
+// migration are able to open it. Thin volumes can't be active on more than
+// one host under lvmlockd, they are only used to keep snapshots, which take
+// space of the thin pool as much as the disk has written.
+type SCLVMStorage struct {
+	SBaseStorage
+
+	// thin pool is activated exclusively, snapshot operations on this host
+	// are serialized
+	snapshotLock *sync.Mutex
+}
+
+func NewCLVMStorage(manager *SStorageManager, path string) *SCLVMStorage {
+	var ret = new(SCLVMStorage)
+	ret.SBaseStorage = *NewBaseStorage(manager, path)
+	ret.snapshotLock = new(sync.Mutex)
+	return ret
+}
+
+func (s *SCLVMStorage) StorageType() string {
+	return api.STORAGE_CLVM
+}
+
+func (s *SCLVMStorage) getVgName() string {
+	if s.StorageConf != nil {
+		if vg, _ := s.StorageConf.GetString("vg_name"); len(vg) > 0 {
+			return vg
+		}
+	}
+	return path.Base(s.Path)
+}
+
+func (s *SCLVMStorage) getThinPool() string {
+	if s.StorageConf != nil {
+		pool, _ := s.StorageConf.GetString("thin_pool")
+		return pool
+	}
+	return ""
+}
+
+func clvmSnapshotLvName(snapshotId string) string {
+	return "snap_" + snapshotId
+}
+
+func clvmDiskTag(diskId string) string {
+	return "disk_" + diskId
+}
+
+func (s *SCLVMStorage) SetStorageInfo(storageId, storageName string, conf jsonutils.JSONObject) {
+	s.SBaseStorage.SetStorageInfo(storageId, storageName, conf)
+	if err := lvmutils.StartLock(s.getVgName()); err != nil {
+		log.Errorf("Fail to start lockspace of volume group %s: %s", s.getVgName(), err)
+	}
+}
+
+func (s *SCLVMStorage) GetSnapshotDir() string {
+	return ""
+}
+
+func (s *SCLVMStorage) GetSnapshotPathByIds(diskId, snapshotId string) string {
+	return lvmutils.LvPath(s.getVgName(), clvmSnapshotLvName(snapshotId))
+}
+
+func (s *SCLVMStorage) GetFuseTmpPath() string {
+	return ""
+}
+
+func (s *SCLVMStorage) GetFuseMountPath() string {
+	return ""
+}
+
+func (s *SCLVMStorage) GetImgsaveBackupPath() string {
+	return ""
+}
+
+// getCapacityMb excludes thin pool which is reserved for snapshots
+func (s *SCLVMStorage) getCapacityMb() (int64, error) {
+	vg, err := lvmutils.GetVolumeGroup(s.getVgName())
+	if err != nil {
+		return 0, err
+	}
+	capacity := vg.SizeMb
+	if pool := s.getThinPool(); len(pool) > 0 {
+		if lv, err := lvmutils.GetLogicalVolume(vg.Name, pool); err == nil {
+			capacity -= lv.SizeMb
+		}
+	}
+	return capacity, nil
+}
+
+func (s *SCLVMStorage) GetCapacity() int {
+	capacity, err := s.getCapacityMb()
+	if err != nil {
+		log.Errorf("get capacity of clvm storage %s error: %s", s.StorageName, err)
+		return 0
+	}
+	return int(capacity)
+}
+
+func (s *SCLVMStorage) GetFreeSizeMb() int {
+	vg, err := lvmutils.GetVolumeGroup(s.getVgName())
+	if err != nil {
+		log.Errorln(err)
+		return -1
+	}
+	return int(vg.FreeMb)
+}
+
+func (s *SCLVMStorage) SyncStorageInfo() (jsonutils.JSONObject, error) {
+	if len(s.StorageId) == 0 {
+		return nil, fmt.Errorf("Sync clvm storage without storage id")
+	}
+	capacity, err := s.getCapacityMb()
+	if err != nil {
+		log.Errorf("get capacity of clvm storage %s error: %s", s.StorageName, err)
+		return modules.Storages.PerformAction(hostutils.GetComputeSession(context.Background()), s.StorageId, "offline", nil)
+	}
+	content := jsonutils.NewDict()
+	content.Set("capacity", jsonutils.NewInt(capacity))
+	content.Set("storage_type", jsonutils.NewString(s.StorageType()))
+	content.Set("status", jsonutils.NewString(api.STORAGE_ONLINE))
+	content.Set("zone", jsonutils.NewString(s.GetZone()))
+	res, err := modules.Storages.Put(hostutils.GetComputeSession(context.Background()), s.StorageId, content)
+	if err != nil {
+		log.Errorf("SyncStorageInfo Failed: %s: %s", content, err)
+	}
+	return res, err
+}
+
+func (s *SCLVMStorage) GetDiskById(diskId string) IDisk {
+	s.DiskLock.Lock()
+	defer s.DiskLock.Unlock()
+	for i := 0; i < len(s.Disks); i++ {
+		if s.Disks[i].GetId() == diskId {
+			if s.Disks[i].Probe() == nil {
+				return s.Disks[i]
+			}
+			return nil
+		}
+	}
+	var disk = NewCLVMDisk(s, diskId)
+	if disk.Probe() == nil {
+		s.Disks = append(s.Disks, disk)
+		return disk
+	}
+	return nil
+}
+
+func (s *SCLVMStorage) CreateDisk(diskId string) IDisk {
+	s.DiskLock.Lock()
+	defer s.DiskLock.Unlock()
+	disk := NewCLVMDisk(s, diskId)
+	s.Disks = append(s.Disks, disk)
+	return disk
+}
+
+// getLocalStorage returns local storage of host to hold temporary image files
+func (s *SCLVMStorage) getLocalStorage() *SLocalStorage {
+	for _, storage := range s.Manager.Storages {
+		if localStorage, ok := storage.(*SLocalStorage); ok {
+			return localStorage
+		}
+	}
+	return nil
+}
+
+// SaveToGlance uploads image file prepared on local storage by disk
+func (s *SCLVMStorage) SaveToGlance(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	localStorage := s.getLocalStorage()
+	if localStorage == nil {
+		return nil, fmt.Errorf("no local storage to save image")
+	}
+	return localStorage.SaveToGlance(ctx, params)
+}
+
+func (s *SCLVMStorage) CreateSnapshotFormUrl(ctx context.Context, snapshotUrl, diskId, snapshotPath string) error {
+	return fmt.Errorf("Not support")
+}
+
+func (s *SCLVMStorage) DeleteSnapshots(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	diskId, ok := params.(string)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	return nil, s.deleteDiskSnapshots(diskId)
+}
+
+func convertVolume(src, dest string) error {
+	output, err := procutils.NewCommand(qemutils.GetQemuImg(), "convert", "-n", "-f", "raw", "-O", "raw", src, dest).Run()
+	if err != nil {
+		return fmt.Errorf("convert %s to %s: %s", src, dest, output)
+	}
+	return nil
+}
+
+// withThinLv activates thin lv exclusively during doFunc, then deactivates lv
+// and thin pool to release locks for other hosts
+func (s *SCLVMStorage) withThinLv(lvName string, doFunc func() error) error {
+	vg := s.getVgName()
+	lv, err := lvmutils.GetLogicalVolume(vg, lvName)
+	if err != nil {
+		return err
+	}
+	if !lv.IsActive() {
+		if err := lvmutils.ChangeActivation(vg, lvName, lvmutils.ACTIVATE_EXCLUSIVE); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		if err := lvmutils.ChangeActivation(vg, lvName, lvmutils.DEACTIVATE); err != nil {
+			log.Errorln(err)
+		}
+		if err := lvmutils.ChangeActivation(vg, s.getThinPool(), lvmutils.DEACTIVATE); err != nil {
+			log.Warningf("deactivate thin pool: %s", err)
+		}
+	}()
+	return doFunc()
+}
+
+func (s *SCLVMStorage) createSnapshot(diskId, snapshotId string) error {
+	pool := s.getThinPool()
+	if len(pool) == 0 {
+		return fmt.Errorf("storage %s has no thin pool for snapshots", s.StorageName)
+	}
+	s.snapshotLock.Lock()
+	defer s.snapshotLock.Unlock()
+
+	vg := s.getVgName()
+	lv, err := lvmutils.GetLogicalVolume(vg, diskId)
+	if err != nil {
+		return err
+	}
+	snapLv := clvmSnapshotLvName(snapshotId)
+	if err := lvmutils.CreateThinLv(vg, pool, snapLv, lv.SizeMb, clvmDiskTag(diskId)); err != nil {
+		return err
+	}
+	err = s.withThinLv(snapLv, func() error {
+		return convertVolume(lvmutils.LvPath(vg, diskId), lvmutils.LvPath(vg, snapLv))
+	})
+	if err != nil {
+		if e := lvmutils.RemoveLv(vg, snapLv); e != nil {
+			log.Errorln(e)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *SCLVMStorage) resetFromSnapshot(diskId, snapshotId string) error {
+	s.snapshotLock.Lock()
+	defer s.snapshotLock.Unlock()
+
+	vg := s.getVgName()
+	snapLv := clvmSnapshotLvName(snapshotId)
+	return s.withThinLv(snapLv, func() error {
+		return convertVolume(lvmutils.LvPath(vg, snapLv), lvmutils.LvPath(vg, diskId))
+	})
+}
+
+func (s *SCLVMStorage) deleteSnapshot(snapshotId string) error {
+	vg := s.getVgName()
+	snapLv := clvmSnapshotLvName(snapshotId)
+	if _, err := lvmutils.GetLogicalVolume(vg, snapLv); err != nil {
+		log.Warningf("snapshot %s not found: %s", snapshotId, err)
+		return nil
+	}
+	return lvmutils.RemoveLv(vg, snapLv)
+}
+
+func (s *SCLVMStorage) deleteDiskSnapshots(diskId string) error {
+	vg := s.getVgName()
+	lvs, err := lvmutils.ListLogicalVolumes(vg)
+	if err != nil {
+		return err
+	}
+	tag := clvmDiskTag(diskId)
+	for i := range lvs {
+		if lvs[i].HasTag(tag) {
+			if err := lvmutils.RemoveLv(vg, lvs[i].Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -241,6 +241,16 @@ func NewConflictError(msg string, params ...interface{}) *httputils.JSONClientEr
 	return NewJsonClientError(409, "ConflictError", msg, err)
 }
 
+func NewPreconditionFailedError(msg string, params ...interface{}) *httputils.JSONClientError {
+	msg, err := errorMessage(msg, params...)
+	return NewJsonClientError(412, "PreconditionFailedError", msg, err)
+}
+
+func NewTooManyRequestsError(msg string, params ...interface{}) *httputils.JSONClientError {
+	msg, err := errorMessage(msg, params...)
+	return NewJsonClientError(429, "TooManyRequestsError", msg, err)
+}
+
 func NewResourceBusyError(msg string, params ...interface{}) *httputils.JSONClientError {
 	msg, err := errorMessage(msg, params...)
 	return NewJsonClientError(409, "ResourceBusyError", msg, err)
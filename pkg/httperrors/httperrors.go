@@ -101,6 +101,14 @@ func ConflictError(w http.ResponseWriter, msg string, params ...interface{}) {
 	JsonClientError(w, NewConflictError(msg, params...))
 }
 
+func PreconditionFailedError(w http.ResponseWriter, msg string, params ...interface{}) {
+	JsonClientError(w, NewPreconditionFailedError(msg, params...))
+}
+
+func TooManyRequestsError(w http.ResponseWriter, msg string, params ...interface{}) {
+	JsonClientError(w, NewTooManyRequestsError(msg, params...))
+}
+
 func InternalServerError(w http.ResponseWriter, msg string, params ...interface{}) {
 	JsonClientError(w, NewInternalServerError(msg, params...))
 }
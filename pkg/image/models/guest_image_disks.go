@@ -0,0 +1,92 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"database/sql"
+
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type SGuestImageDiskManager struct {
+	db.SResourceBaseManager
+}
+
+var GuestImageDiskManager *SGuestImageDiskManager
+
+func init() {
+	GuestImageDiskManager = &SGuestImageDiskManager{
+		SResourceBaseManager: db.NewResourceBaseManager(
+			SGuestImageDisk{},
+			"guest_image_disks",
+			"guest_image_disk",
+			"guest_image_disks",
+		),
+	}
+
+	GuestImageDiskManager.TableSpec().AddIndex(true, "guest_image_id", "index")
+}
+
+// SGuestImageDisk joins an image to the guest image, Index 0 is the root disk
+type SGuestImageDisk struct {
+	db.SResourceBase
+
+	Id           int    `primary:"true" auto_increment:"true" nullable:"false"`
+	GuestImageId string `width:"36" charset:"ascii" index:"true" nullable:"false"`
+	ImageId      string `width:"36" charset:"ascii" nullable:"false"`
+	Index        int    `nullable:"false" default:"0"`
+}
+
+func (manager *SGuestImageDiskManager) GetDisks(guestImageId string) ([]SGuestImageDisk, error) {
+	q := manager.Query().Equals("guest_image_id", guestImageId).Asc("index")
+	disks := make([]SGuestImageDisk, 0)
+	err := db.FetchModelObjects(manager, q, &disks)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return disks, nil
+}
+
+func (manager *SGuestImageDiskManager) addDisk(guestImageId string, imageId string, index int) error {
+	disk := &SGuestImageDisk{}
+	disk.SetModelManager(manager)
+
+	disk.GuestImageId = guestImageId
+	disk.ImageId = imageId
+	disk.Index = index
+
+	err := manager.TableSpec().Insert(disk)
+	if err != nil {
+		log.Errorf("fail to add disk %s to guest image %s: %s", imageId, guestImageId, err)
+		return err
+	}
+	return nil
+}
+
+func (self *SGuestImageDisk) GetImage() (*SImage, error) {
+	obj, err := ImageManager.FetchById(self.ImageId)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*SImage), nil
+}
+
+func (self *SGuestImageDisk) Delete(ctx context.Context, userCred mcclient.TokenCredential) error {
+	return db.DeleteModel(ctx, userCred, self)
+}
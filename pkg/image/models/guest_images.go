@@ -0,0 +1,394 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/image"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/image/options"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/util/fileutils2"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+	"yunion.io/x/onecloud/pkg/util/ovfutils"
+	"yunion.io/x/onecloud/pkg/util/qemuimg"
+	"yunion.io/x/onecloud/pkg/util/streamutils"
+	"yunion.io/x/onecloud/pkg/util/tarutils"
+)
+
+type SGuestImageManager struct {
+	db.SSharableVirtualResourceBaseManager
+}
+
+var GuestImageManager *SGuestImageManager
+
+func init() {
+	GuestImageManager = &SGuestImageManager{
+		SSharableVirtualResourceBaseManager: db.NewSharableVirtualResourceBaseManager(
+			SGuestImage{},
+			"guestimages",
+			"guestimage",
+			"guestimages",
+		),
+	}
+}
+
+// SGuestImage is a multi-disk image imported from OVA package, disks are
+// stored as images and linked by SGuestImageDisk, hardware of the appliance
+// is kept as hints for creating server
+type SGuestImage struct {
+	db.SSharableVirtualResourceBase
+
+	Size     int64  `nullable:"true" list:"user"`
+	Location string `nullable:"true"`
+
+	OsType     string               `width:"36" charset:"ascii" nullable:"true" list:"user" create:"optional" update:"user"`
+	VcpuCount  int                  `nullable:"false" default:"0" list:"user" create:"optional" update:"user"`
+	VmemSizeMb int                  `nullable:"false" default:"0" list:"user" create:"optional" update:"user"`
+	Nics       jsonutils.JSONObject `nullable:"true" get:"user"`
+}
+
+func (manager *SGuestImageManager) CustomizeHandlerInfo(info *appsrv.SHandlerInfo) {
+	switch info.GetName(nil) {
+	case "create":
+		info.SetProcessTimeout(time.Minute * 30).SetWorkerManager(imgStreamingWorkerMan)
+	}
+}
+
+func (manager *SGuestImageManager) FetchCreateHeaderData(ctx context.Context, header http.Header) (jsonutils.JSONObject, error) {
+	return modules.FetchImageMeta(header), nil
+}
+
+func (manager *SGuestImageManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerProjId string, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	appParams := appsrv.AppContextGetParams(ctx)
+	if appParams.Request.ContentLength <= 0 {
+		return nil, httperrors.NewMissingParameterError("ova")
+	}
+	if !data.Contains("os_type") {
+		if osType, _ := data.GetString("properties", "os_type"); len(osType) > 0 {
+			data.Set("os_type", jsonutils.NewString(osType))
+		}
+	}
+	return manager.SVirtualResourceBaseManager.ValidateCreateData(ctx, userCred, ownerProjId, query, data)
+}
+
+func (self *SGuestImage) CustomizeCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerProjId string, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
+	err := self.SVirtualResourceBase.CustomizeCreate(ctx, userCred, ownerProjId, query, data)
+	if err != nil {
+		return err
+	}
+	self.Status = api.IMAGE_STATUS_QUEUED
+	return nil
+}
+
+func (self *SGuestImage) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerProjId string, query jsonutils.JSONObject, data jsonutils.JSONObject) {
+	self.SVirtualResourceBase.PostCreate(ctx, userCred, ownerProjId, query, data)
+
+	appParams := appsrv.AppContextGetParams(ctx)
+	db.OpsLog.LogEvent(self, db.ACT_SAVING, "create upload", userCred)
+	self.SetStatus(userCred, api.IMAGE_STATUS_SAVING, "create upload")
+
+	localPath := self.GetPath()
+	sp, err := self.saveOvaFromStream(localPath, appParams.Request.Body)
+	if err != nil {
+		self.saveFailed(ctx, userCred, fmt.Sprintf("create upload fail %s", err))
+		return
+	}
+	db.Update(self, func() error {
+		self.Size = sp.Size
+		self.Location = fmt.Sprintf("%s%s", LocalFilePrefix, localPath)
+		return nil
+	})
+	db.OpsLog.LogEvent(self, db.ACT_SAVE, "create upload success", userCred)
+
+	self.StartGuestImageImportTask(ctx, userCred, "")
+}
+
+func (self *SGuestImage) saveOvaFromStream(localPath string, reader io.Reader) (*streamutils.SStreamProperty, error) {
+	fp, err := os.Create(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return streamutils.StreamPipe(reader, fp)
+}
+
+// GetPath returns where the uploaded OVA package is kept until it is imported
+func (self *SGuestImage) GetPath() string {
+	return filepath.Join(options.Options.FilesystemStoreDatadir, fmt.Sprintf("%s.ova", self.Id))
+}
+
+func (self *SGuestImage) saveFailed(ctx context.Context, userCred mcclient.TokenCredential, msg string) {
+	log.Errorf("%s", msg)
+	self.SetStatus(userCred, api.IMAGE_STATUS_KILLED, msg)
+	db.OpsLog.LogEvent(self, db.ACT_SAVE_FAIL, msg, userCred)
+	logclient.AddActionLogWithContext(ctx, self, logclient.ACT_IMAGE_SAVE, msg, userCred, false)
+}
+
+func (self *SGuestImage) StartGuestImageImportTask(ctx context.Context, userCred mcclient.TokenCredential, parentTaskId string) error {
+	task, err := taskman.TaskManager.NewTask(ctx, "GuestImageImportTask", self, userCred, nil, parentTaskId, "", nil)
+	if err != nil {
+		return err
+	}
+	task.ScheduleRun(nil)
+	return nil
+}
+
+var ovaDiskExts = []string{".vmdk", ".qcow2", ".img", ".raw", ".vhd"}
+
+// parseOvaDir reads the OVF descriptor of an unpacked OVA, packages without
+// descriptor are taken as bundles of disks ordered by file name
+func parseOvaDir(dir string) (*ovfutils.SOvfInfo, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	disks := make([]string, 0)
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+		if ext == ".ovf" {
+			fp, err := os.Open(filepath.Join(dir, f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			defer fp.Close()
+			return ovfutils.ParseStream(fp)
+		}
+		for _, diskExt := range ovaDiskExts {
+			if ext == diskExt {
+				disks = append(disks, f.Name())
+			}
+		}
+	}
+	if len(disks) == 0 {
+		return nil, fmt.Errorf("neither ovf descriptor nor disk found in package")
+	}
+	sort.Strings(disks)
+	info := &ovfutils.SOvfInfo{}
+	for _, disk := range disks {
+		info.Disks = append(info.Disks, ovfutils.SOvfDisk{File: disk})
+	}
+	return info, nil
+}
+
+// ImportOva unpacks the uploaded OVA package, converts each disk into an
+// image and records hardware hints of the appliance
+func (self *SGuestImage) ImportOva(ctx context.Context, userCred mcclient.TokenCredential) error {
+	ovaPath := self.GetPath()
+	workDir := fmt.Sprintf("%s.d", ovaPath)
+	defer os.RemoveAll(workDir)
+
+	err := tarutils.UntarFile(ovaPath, workDir)
+	if err != nil {
+		return fmt.Errorf("unpack ova: %s", err)
+	}
+	info, err := parseOvaDir(workDir)
+	if err != nil {
+		return err
+	}
+	if len(info.Disks) == 0 {
+		return fmt.Errorf("no disk found in ovf descriptor")
+	}
+
+	size := int64(0)
+	for i, disk := range info.Disks {
+		diskPath := filepath.Join(workDir, disk.File)
+		if !strings.HasPrefix(diskPath, workDir+string(os.PathSeparator)) {
+			return fmt.Errorf("disk file %s is outside of package", disk.File)
+		}
+		image, err := self.importDisk(ctx, userCred, i, diskPath, info.OsType)
+		if err != nil {
+			return fmt.Errorf("import disk %s: %s", disk.File, err)
+		}
+		size += image.Size
+	}
+
+	_, err = db.Update(self, func() error {
+		self.Size = size
+		self.Location = ""
+		if len(self.OsType) == 0 {
+			self.OsType = info.OsType
+		}
+		if self.VcpuCount == 0 {
+			self.VcpuCount = info.CpuCount
+		}
+		if self.VmemSizeMb == 0 {
+			self.VmemSizeMb = info.MemoryMb
+		}
+		if len(info.Nics) > 0 {
+			self.Nics = jsonutils.Marshal(info.Nics)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(ovaPath); err != nil {
+		log.Warningf("remove imported ova %s: %s", ovaPath, err)
+	}
+	return nil
+}
+
+func (self *SGuestImage) importDisk(ctx context.Context, userCred mcclient.TokenCredential, index int, diskPath string, osType string) (*SImage, error) {
+	img, err := qemuimg.NewQemuImage(diskPath)
+	if err != nil {
+		return nil, err
+	}
+	if !img.IsValid() {
+		return nil, fmt.Errorf("invalid disk image")
+	}
+
+	image := &SImage{}
+	image.SetModelManager(ImageManager)
+	image.Name, err = db.GenerateName(ImageManager, self.ProjectId, fmt.Sprintf("%s-disk%d", self.Name, index))
+	if err != nil {
+		return nil, err
+	}
+	image.ProjectId = self.ProjectId
+	image.Owner = self.ProjectId
+	image.IsPublic = self.IsPublic
+	image.Status = api.IMAGE_STATUS_SAVING
+	err = ImageManager.TableSpec().Insert(image)
+	if err != nil {
+		return nil, err
+	}
+	db.OpsLog.LogEvent(image, db.ACT_CREATE, image.GetShortDesc(ctx), userCred)
+
+	err = GuestImageDiskManager.addDisk(self.Id, image.Id, index)
+	if err != nil {
+		return nil, err
+	}
+	if index == 0 && len(osType) > 0 {
+		_, err = ImagePropertyManager.SaveProperty(ctx, userCred, image.Id, "os_type", osType)
+		if err != nil {
+			log.Warningf("save os_type of image %s: %s", image.Id, err)
+		}
+	}
+
+	err = img.Convert2Qcow2To(image.GetPath(""), true)
+	if err == nil {
+		err = image.SaveImageFromFile()
+	}
+	if err != nil {
+		image.saveFailed(userCred, fmt.Sprintf("convert from ova fail %s", err))
+		return nil, err
+	}
+	image.saveSuccess(userCred, "convert from ova success")
+	return image, nil
+}
+
+func (self *SGuestImage) getMoreDetails(extra *jsonutils.JSONDict) *jsonutils.JSONDict {
+	disks, err := GuestImageDiskManager.GetDisks(self.Id)
+	if err != nil {
+		log.Errorf("fetch disks of guest image %s: %s", self.Id, err)
+		return extra
+	}
+	diskInfos := make([]jsonutils.JSONObject, 0)
+	for i := range disks {
+		image, err := disks[i].GetImage()
+		if err != nil {
+			log.Errorf("fetch image %s: %s", disks[i].ImageId, err)
+			continue
+		}
+		info := jsonutils.NewDict()
+		info.Add(jsonutils.NewString(image.Id), "image_id")
+		info.Add(jsonutils.NewString(image.Name), "name")
+		info.Add(jsonutils.NewInt(int64(disks[i].Index)), "index")
+		info.Add(jsonutils.NewInt(image.Size), "size")
+		info.Add(jsonutils.NewInt(int64(image.MinDiskMB)), "min_disk")
+		info.Add(jsonutils.NewString(image.DiskFormat), "disk_format")
+		info.Add(jsonutils.NewString(image.Status), "status")
+		diskInfos = append(diskInfos, info)
+	}
+	extra.Add(jsonutils.NewArray(diskInfos...), "disks")
+	return extra
+}
+
+func (self *SGuestImage) GetCustomizeColumns(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) *jsonutils.JSONDict {
+	extra := self.SVirtualResourceBase.GetCustomizeColumns(ctx, userCred, query)
+	return self.getMoreDetails(extra)
+}
+
+func (self *SGuestImage) GetExtraDetails(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) (*jsonutils.JSONDict, error) {
+	extra, err := self.SVirtualResourceBase.GetExtraDetails(ctx, userCred, query)
+	if err != nil {
+		return nil, err
+	}
+	return self.getMoreDetails(extra), nil
+}
+
+func (self *SGuestImage) ValidateDeleteCondition(ctx context.Context) error {
+	if self.Status == api.IMAGE_STATUS_SAVING || self.Status == api.IMAGE_STATUS_CONVERTING {
+		return httperrors.NewInvalidStatusError("guest image is %s", self.Status)
+	}
+	return self.SVirtualResourceBase.ValidateDeleteCondition(ctx)
+}
+
+// CustomizeDelete deletes the images of disks along with the guest image
+func (self *SGuestImage) CustomizeDelete(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
+	disks, err := GuestImageDiskManager.GetDisks(self.Id)
+	if err != nil {
+		return httperrors.NewGeneralError(err)
+	}
+	images := make([]*SImage, len(disks))
+	for i := range disks {
+		images[i], err = disks[i].GetImage()
+		if err != nil {
+			log.Warningf("image %s of guest image %s not found", disks[i].ImageId, self.Id)
+			continue
+		}
+		err = images[i].ValidateDeleteCondition(ctx)
+		if err != nil {
+			return fmt.Errorf("image %s: %s", images[i].Name, err)
+		}
+	}
+	for i := range disks {
+		if images[i] != nil {
+			err = images[i].CustomizeDelete(ctx, userCred, query, data)
+			if err != nil {
+				return err
+			}
+		}
+		disks[i].Delete(ctx, userCred)
+	}
+	if ovaPath := self.GetPath(); fileutils2.IsFile(ovaPath) {
+		os.Remove(ovaPath)
+	}
+	return nil
+}
+
+func (self *SGuestImage) Delete(ctx context.Context, userCred mcclient.TokenCredential) error {
+	return db.DeleteModel(ctx, userCred, self)
+}
@@ -392,6 +392,27 @@ func (self *SImage) SaveImageFromStream(reader io.Reader) error {
 		return err
 	}
 
+	return self.saveImageInfo(localPath, sp.Size, sp.CheckSum)
+}
+
+// SaveImageFromFile takes an image file already in place of GetPath(""),
+// e.g. converted from a disk of OVA package
+func (self *SImage) SaveImageFromFile() error {
+	localPath := self.GetPath("")
+
+	fi, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	chksum, err := fileutils2.MD5(localPath)
+	if err != nil {
+		return err
+	}
+
+	return self.saveImageInfo(localPath, fi.Size(), chksum)
+}
+
+func (self *SImage) saveImageInfo(localPath string, size int64, chksum string) error {
 	virtualSizeBytes := int64(0)
 	format := ""
 	img, err := qemuimg.NewQemuImage(localPath)
@@ -407,8 +428,8 @@ func (self *SImage) SaveImageFromStream(reader io.Reader) error {
 	}
 
 	db.Update(self, func() error {
-		self.Size = sp.Size
-		self.Checksum = sp.CheckSum
+		self.Size = size
+		self.Checksum = chksum
 		self.FastHash = fastChksum
 		self.Location = fmt.Sprintf("%s%s", LocalFilePrefix, localPath)
 		if len(format) > 0 {
@@ -42,10 +42,12 @@ func initHandlers(app *appsrv.Application) {
 		// db.UserCacheManager,
 		db.TenantCacheManager,
 		db.Metadata,
+		db.IdempotencyKeyManager,
 		models.ImageTagManager,
 		models.ImageMemberManager,
 		models.ImagePropertyManager,
 		models.ImageSubformatManager,
+		models.GuestImageDiskManager,
 	} {
 		db.RegisterModelManager(manager)
 	}
@@ -53,6 +55,7 @@ func initHandlers(app *appsrv.Application) {
 	for _, manager := range []db.IModelManager{
 		db.OpsLog,
 		models.ImageManager,
+		models.GuestImageManager,
 	} {
 		db.RegisterModelManager(manager)
 		handler := db.NewModelHandler(manager)
@@ -27,6 +27,7 @@ import (
 	app_common "yunion.io/x/onecloud/pkg/cloudcommon/app"
 	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	"yunion.io/x/onecloud/pkg/image/models"
 	"yunion.io/x/onecloud/pkg/image/options"
@@ -97,7 +98,10 @@ func StartService() {
 	go models.CheckImages()
 
 	cron := cronman.GetCronJobManager(true)
+	cron.EnableLeaderElection(SERVICE_TYPE)
 	cron.AddJob1("CleanPendingDeleteImages", time.Duration(options.Options.PendingDeleteCheckSeconds)*time.Second, models.ImageManager.CleanPendingDeleteImages)
+	cron.AddJob1WithStartRun("SweepTasks", time.Duration(opts.TaskSweepIntervalSeconds)*time.Second, taskman.TaskManager.SweepTasks, true)
+	cron.AddJob1("PurgeIdempotencyKeys", time.Hour, db.IdempotencyKeyManager.PurgeExpired)
 
 	cron.Start()
 
@@ -0,0 +1,81 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/image"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/image/models"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+type GuestImageImportTask struct {
+	taskman.STask
+}
+
+func init() {
+	importWorker := appsrv.NewWorkerManager("GuestImageImportTaskWorkerManager", 2, 512, true)
+	taskman.RegisterTaskAndWorker(GuestImageImportTask{}, importWorker)
+}
+
+func (self *GuestImageImportTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	guestImage := obj.(*models.SGuestImage)
+
+	self.SetStage("OnImportComplete", nil)
+	taskman.LocalTaskRun(self, func() (jsonutils.JSONObject, error) {
+		guestImage.SetStatus(self.UserCred, api.IMAGE_STATUS_CONVERTING, "start import")
+		return nil, guestImage.ImportOva(ctx, self.UserCred)
+	})
+}
+
+func (self *GuestImageImportTask) OnImportComplete(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	guestImage := obj.(*models.SGuestImage)
+	disks, err := models.GuestImageDiskManager.GetDisks(guestImage.Id)
+	if err != nil {
+		self.OnImportCompleteFailed(ctx, obj, jsonutils.NewString(err.Error()))
+		return
+	}
+	for i := range disks {
+		image, err := disks[i].GetImage()
+		if err != nil {
+			log.Errorf("fetch image %s of guest image %s: %s", disks[i].ImageId, guestImage.Id, err)
+			continue
+		}
+		err = image.StartImageConvertTask(ctx, self.UserCred, "")
+		if err != nil {
+			log.Errorf("start convert task of image %s: %s", image.Id, err)
+		}
+	}
+	guestImage.SetStatus(self.UserCred, api.IMAGE_STATUS_ACTIVE, "import success")
+	logclient.AddActionLogWithStartable(self, guestImage, logclient.ACT_IMAGE_SAVE, nil, self.UserCred, true)
+	self.SetStageComplete(ctx, nil)
+}
+
+func (self *GuestImageImportTask) OnImportCompleteFailed(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	guestImage := obj.(*models.SGuestImage)
+	msg := fmt.Sprintf("import ova fail %s", data)
+	guestImage.SetStatus(self.UserCred, api.IMAGE_STATUS_KILLED, msg)
+	db.OpsLog.LogEvent(guestImage, db.ACT_SAVE_FAIL, msg, self.UserCred)
+	logclient.AddActionLogWithStartable(self, guestImage, logclient.ACT_IMAGE_SAVE, msg, self.UserCred, false)
+	self.SetStageFailed(ctx, msg)
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbagent
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	agentutils "yunion.io/x/onecloud/pkg/lbagent/utils"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+)
+
+// AccessLogCollector receives access logs from haproxy through a unix
+// datagram socket and ships them in batches to the log service
+type AccessLogCollector struct {
+	opts *Options
+	conn *net.UnixConn
+
+	pending []*agentutils.HaproxyAccessLog
+}
+
+func NewAccessLogCollector(opts *Options) (*AccessLogCollector, error) {
+	sock := opts.accessLogSocketFile()
+	if err := os.Remove(sock); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	// haproxy may drop privileges after start
+	if err := os.Chmod(sock, 0666); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := &AccessLogCollector{
+		opts: opts,
+		conn: conn,
+	}
+	return c, nil
+}
+
+func (c *AccessLogCollector) Run(ctx context.Context) {
+	defer func() {
+		log.Infof("access log collector bye")
+		wg := ctx.Value("wg").(*sync.WaitGroup)
+		wg.Done()
+	}()
+
+	recordChan := make(chan *agentutils.HaproxyAccessLog, c.opts.AccessLogBatchSize)
+	go c.receive(recordChan)
+
+	tick := time.NewTicker(time.Duration(c.opts.AccessLogFlushInterval) * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case r, ok := <-recordChan:
+			if !ok {
+				c.flush(ctx)
+				return
+			}
+			r.AgentId = c.opts.ApiLbagentId
+			c.pending = append(c.pending, r)
+			if len(c.pending) >= c.opts.AccessLogBatchSize {
+				c.flush(ctx)
+			}
+		case <-tick.C:
+			c.flush(ctx)
+		case <-ctx.Done():
+			c.conn.Close()
+			c.flush(ctx)
+			return
+		}
+	}
+}
+
+func (c *AccessLogCollector) receive(recordChan chan<- *agentutils.HaproxyAccessLog) {
+	defer close(recordChan)
+	buf := make([]byte, 65536)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			log.Infof("access log socket read: %s", err)
+			return
+		}
+		r, err := agentutils.ParseHaproxyAccessLog(string(buf[:n]))
+		if err != nil {
+			log.Debugf("ignore access log %q: %s", buf[:n], err)
+			continue
+		}
+		recordChan <- r
+	}
+}
+
+func (c *AccessLogCollector) flush(ctx context.Context) {
+	for len(c.pending) > 0 {
+		n := len(c.pending)
+		if n > c.opts.AccessLogBatchSize {
+			n = c.opts.AccessLogBatchSize
+		}
+		if err := c.upload(ctx, c.pending[:n]); err != nil {
+			log.Errorf("upload %d access logs: %s", n, err)
+			if drop := len(c.pending) - c.opts.AccessLogMaxPending; drop > 0 {
+				log.Warningf("dropping %d pending access logs", drop)
+				c.pending = c.pending[drop:]
+			}
+			return
+		}
+		c.pending = c.pending[n:]
+	}
+	c.pending = nil
+}
+
+func (c *AccessLogCollector) upload(ctx context.Context, records []*agentutils.HaproxyAccessLog) error {
+	s := auth.GetAdminSession(ctx, c.opts.CommonOptions.Region, "v2")
+	params := jsonutils.NewDict()
+	params.Set("access_logs", jsonutils.Marshal(records))
+	_, err := modules.LoadbalancerAccessLogs.PerformClassAction(s, "upload", params)
+	return err
+}
@@ -260,9 +260,52 @@ func (h *ApiHelper) newAgentHbParams(ctx context.Context) (*jsonutils.JSONDict,
 	if err != nil {
 		return nil, err
 	}
+	if state == api.LB_HA_STATE_MASTER {
+		if sessions := h.drainingBackendSessions(ctx); len(sessions) > 0 {
+			params.Set("backend_sessions", jsonutils.Marshal(sessions))
+		}
+	}
 	return params, nil
 }
 
+// drainingBackendSessions reports active sessions of draining backends so
+// that region can remove them when they are drained.  UDP listeners are
+// served by gobetween which keeps no session count in haproxy, backends of
+// groups used by them get weight 0 there, are not reported and are removed
+// by region when the drain timeout expires
+func (h *ApiHelper) drainingBackendSessions(ctx context.Context) map[string]int {
+	if h.corpus == nil {
+		return nil
+	}
+	udpGroups := map[string]bool{}
+	for _, listener := range h.corpus.LoadbalancerListeners {
+		if listener.ListenerType == api.LB_LISTENER_TYPE_UDP {
+			udpGroups[listener.BackendGroupId] = true
+		}
+	}
+	ids := []string{}
+	for id, backend := range h.corpus.LoadbalancerBackends {
+		if backend.Status == api.LB_STATUS_DRAINING && !udpGroups[backend.BackendGroupId] {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	runtime := agentutils.NewHaproxyRuntime(h.opts.haproxyStatsSocketFile())
+	sessions, err := runtime.ServerSessions()
+	if err != nil {
+		log.Errorf("get haproxy server sessions: %s", err)
+		return nil
+	}
+	r := map[string]int{}
+	for _, id := range ids {
+		// servers absent from haproxy have no sessions
+		r[id] = sessions[id]
+	}
+	return r
+}
+
 func (h *ApiHelper) doHb(ctx context.Context) (*models.LoadbalancerAgent, error) {
 	// TODO check if things changed recently
 	s := h.adminClientSession(ctx)
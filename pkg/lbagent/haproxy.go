@@ -132,6 +132,7 @@ func (h *HaproxyHelper) handleUseCorpusCmd(ctx context.Context, cmd *LbagentCmd)
 			opt := fmt.Sprintf("stats socket %s expose-fd listeners", h.haproxyStatsSocketFile())
 			agentParams.SetHaproxyParams("global_stats_socket", opt)
 		}
+		agentParams.SetHaproxyParams("access_log_socket", h.opts.accessLogSocketFile())
 		var genHaproxyConfigsResult *agentmodels.GenHaproxyConfigsResult
 		var err error
 		{
@@ -221,6 +222,7 @@ func (h *HaproxyHelper) useConfigs(ctx context.Context, d string) error {
 		return err
 	}
 	haproxyConfD := h.haproxyConfD()
+	haproxyConfDOld, _ := os.Readlink(haproxyConfD)
 	gobetweenJson := filepath.Join(h.opts.haproxyConfigDir, "gobetween.json")
 	keepalivedConf := filepath.Join(h.opts.haproxyConfigDir, "keepalived.conf")
 	telegrafConf := filepath.Join(h.opts.haproxyConfigDir, "telegraf.conf")
@@ -239,7 +241,7 @@ func (h *HaproxyHelper) useConfigs(ctx context.Context, d string) error {
 	{
 		var errs []error
 		var err error
-		{
+		if !h.applyHaproxyRuntimeChanges(ctx, haproxyConfDOld, d) {
 			// reload haproxy
 			err = h.reloadHaproxy(ctx)
 			if err != nil {
@@ -276,7 +278,111 @@ func (h *HaproxyHelper) haproxyPidFile() string {
 }
 
 func (h *HaproxyHelper) haproxyStatsSocketFile() string {
-	return filepath.Join(h.opts.haproxyRunDir, "haproxy.sock")
+	return h.opts.haproxyStatsSocketFile()
+}
+
+// haproxyRuntimeChanges compares haproxy configs in oldDir and newDir.  It
+// returns server weight changes and true if that's all the difference
+// between them, in which case they can be applied with the runtime api
+// without reloading haproxy
+func (h *HaproxyHelper) haproxyRuntimeChanges(oldDir, newDir string) (map[string]map[string]int, bool) {
+	nonHaproxyFiles := map[string]bool{
+		"gobetween.json":  true,
+		"keepalived.conf": true,
+		"telegraf.conf":   true,
+	}
+	readFiles := func(dir string) (map[string][]byte, error) {
+		files := map[string][]byte{}
+		err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			if nonHaproxyFiles[rel] {
+				return nil
+			}
+			d, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			// configs may refer to files in its own dir, e.g. crt-base
+			files[rel] = bytes.Replace(d, []byte(dir), []byte("."), -1)
+			return nil
+		})
+		return files, err
+	}
+	oldFiles, err := readFiles(oldDir)
+	if err != nil {
+		log.Warningf("read old haproxy configs: %s", err)
+		return nil, false
+	}
+	newFiles, err := readFiles(newDir)
+	if err != nil {
+		log.Warningf("read new haproxy configs: %s", err)
+		return nil, false
+	}
+	if len(oldFiles) != len(newFiles) {
+		return nil, false
+	}
+	changes := map[string]map[string]int{}
+	for rel, newD := range newFiles {
+		oldD, ok := oldFiles[rel]
+		if !ok {
+			return nil, false
+		}
+		if filepath.Ext(rel) != "."+agentutils.HaproxyCfgExt {
+			if !bytes.Equal(oldD, newD) {
+				return nil, false
+			}
+			continue
+		}
+		oldMasked, oldWeights := agentutils.HaproxyConfigServerWeights(oldD)
+		newMasked, newWeights := agentutils.HaproxyConfigServerWeights(newD)
+		if !bytes.Equal(oldMasked, newMasked) {
+			return nil, false
+		}
+		for backend, servers := range newWeights {
+			for server, weight := range servers {
+				if oldWeights[backend][server] == weight {
+					continue
+				}
+				if changes[backend] == nil {
+					changes[backend] = map[string]int{}
+				}
+				changes[backend][server] = weight
+			}
+		}
+	}
+	return changes, true
+}
+
+// applyHaproxyRuntimeChanges tries to make configs in newDir effective
+// through the runtime api.  It returns false if a reload is needed
+func (h *HaproxyHelper) applyHaproxyRuntimeChanges(ctx context.Context, oldDir, newDir string) bool {
+	if oldDir == "" || agentutils.ReadPidFile(h.haproxyPidFile()) == nil {
+		return false
+	}
+	changes, ok := h.haproxyRuntimeChanges(oldDir, newDir)
+	if !ok {
+		return false
+	}
+	runtime := agentutils.NewHaproxyRuntime(h.haproxyStatsSocketFile())
+	for backend, servers := range changes {
+		for server, weight := range servers {
+			log.Infof("haproxy runtime: set %s/%s weight %d", backend, server, weight)
+			if err := runtime.SetServerWeight(backend, server, weight); err != nil {
+				log.Errorf("haproxy runtime: %s", err)
+				return false
+			}
+		}
+	}
+	return true
 }
 
 func (h *HaproxyHelper) reloadHaproxy(ctx context.Context) error {
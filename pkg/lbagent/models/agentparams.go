@@ -151,6 +151,10 @@ func (p *AgentParams) SetHaproxyParams(k string, v interface{}) map[string]inter
 	return p.setXxParams("haproxy", k, v)
 }
 
+func (p *AgentParams) GetHaproxyParams(k string) interface{} {
+	return p.getXxParams("haproxy", k)
+}
+
 func (p *AgentParams) SetTelegrafParams(k string, v interface{}) map[string]interface{} {
 	return p.setXxParams("telegraf", k, v)
 }
@@ -60,6 +60,9 @@ func (b *LoadbalancerCorpus) SyncModelSets(s *mcclient.ClientSession, batchSize
 			return nil, err
 		}
 	}
+	if err := mssNews.LoadbalancerCertificates.fetchPrivateKeys(s); err != nil {
+		return nil, err
+	}
 	r := b.ModelSets.ApplyUpdates(mssNews)
 	b.ModelSetsMaxUpdatedAt = r.ModelSetsMaxUpdatedAt
 	return r, nil
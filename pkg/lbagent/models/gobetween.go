@@ -22,6 +22,7 @@ import (
 
 	"yunion.io/x/log"
 
+	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/lbagent/gobetween"
 	agentutils "yunion.io/x/onecloud/pkg/lbagent/utils"
 )
@@ -70,7 +71,12 @@ func (b *LoadbalancerCorpus) GenGobetweenConfigs(dir string, opts *GenGobetweenC
 			// backends
 			staticList := []string{}
 			for _, backend := range backendGroup.backends {
-				backendS := fmt.Sprintf("%s:%d weight=%d", backend.Address, backend.Port, backend.Weight)
+				weight := backend.Weight
+				if backend.Status == api.LB_STATUS_DRAINING {
+					// keep existing flows, balance no new ones to it
+					weight = 0
+				}
+				backendS := fmt.Sprintf("%s:%d weight=%d", backend.Address, backend.Port, weight)
 				staticList = append(staticList, backendS)
 			}
 
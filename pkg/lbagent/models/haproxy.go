@@ -26,6 +26,7 @@ import (
 
 	"yunion.io/x/log"
 
+	api "yunion.io/x/onecloud/pkg/apis/compute"
 	agentutils "yunion.io/x/onecloud/pkg/lbagent/utils"
 )
 
@@ -195,6 +196,23 @@ func (b *LoadbalancerCorpus) genHaproxyConfigCommon(lb *Loadbalancer, listener *
 			}
 		}
 	}
+	if listener.EnableAccessLog {
+		sock, _ := opts.GetHaproxyParams("access_log_socket").(string)
+		if sock != "" {
+			logLines := []string{}
+			if data["log"] == true {
+				logLines = append(logLines, "log global")
+			}
+			format := agentutils.HaproxyAccessLogFormat(lb.Id, listener.Id, listener.TenantId, listener.ListenerType)
+			logLines = append(logLines,
+				fmt.Sprintf("log %s local0 info", sock),
+				fmt.Sprintf("log-format %s", format),
+			)
+			data["log_lines"] = logLines
+			// log-format supersedes "option tcplog" and "option httplog"
+			delete(data, "log")
+		}
+	}
 	if listener.AclStatus == "on" {
 		lbacl, ok := b.LoadbalancerAcls[listener.AclId]
 		if ok && lbacl.AclEntries != nil && len(*lbacl.AclEntries) > 0 {
@@ -292,7 +310,10 @@ func (b *LoadbalancerCorpus) genHaproxyConfigBackend(data map[string]interface{}
 		serverLines := []string{}
 		for _, backend := range backendGroup.backends {
 			serverLine := fmt.Sprintf("server %s %s:%d", backend.Id, backend.Address, backend.Port)
-			if listener.Scheduler == "rr" {
+			if backend.Status == api.LB_STATUS_DRAINING {
+				// no new connections
+				serverLine += " weight 0"
+			} else if listener.Scheduler == "rr" {
 				serverLine += " weight 1"
 			} else {
 				serverLine += fmt.Sprintf(" weight %d", backend.Weight)
@@ -443,6 +464,18 @@ func (b *LoadbalancerCorpus) genHaproxyConfigHttp(buf *bytes.Buffer, listener *L
 			backends = append(backends, backendData)
 			data["default_backend"] = backendData
 		}
+		// backend group traffic is being shifted to
+		if shiftBackendData, err := b.genHaproxyConfigShiftBackend(lb, listener); err != nil {
+			return err
+		} else if shiftBackendData != nil {
+			if err := b.genHaproxyConfigHttpRate(shiftBackendData, listener.HTTPRequestRate, listener.HTTPRequestRatePerSrc); err != nil {
+				return err
+			}
+			backends = append(backends, shiftBackendData)
+			ruleLines := data["rules"].([]string)
+			ruleLines = append(ruleLines, haproxyConfigShiftRule(listener, shiftBackendData))
+			data["rules"] = ruleLines
+		}
 		if len(backends) == 0 {
 			// no backendgroup specified, nothing to serve
 			return haproxyConfigErrNop
@@ -469,12 +502,49 @@ func (b *LoadbalancerCorpus) genHaproxyConfigTcp(buf *bytes.Buffer, listener *Lo
 			return err
 		}
 		data["backend"] = backendData
+		shiftBackendData, err := b.genHaproxyConfigShiftBackend(lb, listener)
+		if err != nil {
+			return err
+		}
+		if shiftBackendData != nil {
+			data["shift_backend"] = shiftBackendData
+			data["shift_rule"] = haproxyConfigShiftRule(listener, shiftBackendData)
+		}
 		err = haproxyConfigTmpl.ExecuteTemplate(buf, "tcpListen", data)
 		return err
 	}
 	return haproxyConfigErrNop
 }
 
+// genHaproxyConfigShiftBackend returns backend data of the backend group
+// the listener is shifting traffic to, or nil if there is none
+func (b *LoadbalancerCorpus) genHaproxyConfigShiftBackend(lb *Loadbalancer, listener *LoadbalancerListener) (map[string]interface{}, error) {
+	if listener.ShiftBackendGroupId == "" || listener.ShiftWeight <= 0 {
+		return nil, nil
+	}
+	backendGroup, ok := lb.backendGroups[listener.ShiftBackendGroupId]
+	if !ok {
+		log.Warningf("listener %s(%s): shift backend group %s not found",
+			listener.Name, listener.Id, listener.ShiftBackendGroupId)
+		return nil, nil
+	}
+	backendData := map[string]interface{}{
+		"comment": fmt.Sprintf("listener %s(%s) shift backendGroup %s(%s) weight %d",
+			listener.Name, listener.Id,
+			backendGroup.Name, backendGroup.Id,
+			listener.ShiftWeight),
+		"id": fmt.Sprintf("backends_listener_shift-%s", listener.Id),
+	}
+	if err := b.genHaproxyConfigBackend(backendData, lb, listener, backendGroup); err != nil {
+		return nil, err
+	}
+	return backendData, nil
+}
+
+func haproxyConfigShiftRule(listener *LoadbalancerListener, shiftBackendData map[string]interface{}) string {
+	return fmt.Sprintf("use_backend %s if { rand(100) lt %d }", shiftBackendData["id"], listener.ShiftWeight)
+}
+
 var haproxyConfigTmpl = template.Must(template.New("").Parse(`
 {{ define "tcpListen" -}}
 # {{ .listener_type }} listener: {{ .comment }}
@@ -483,10 +553,15 @@ listen {{ .id }}
 	mode tcp
 	{{- println }}
 	{{- if .log }}	{{ println "option tcplog" }} {{- end }}
+	{{- range .log_lines }}	{{ println . }} {{- end }}
 	{{- if .acl }}	{{ println .acl }} {{- end}}
 	{{- if .client_idle_timeout }}	timeout client {{ println .client_idle_timeout }} {{- end}}
+	{{- if .shift_rule }}	{{ println .shift_rule }} {{- end}}
 	default_backend {{ .backend.id }}
 {{ template "backend" .backend }}
+{{- if .shift_backend }}
+{{ template "backend" .shift_backend }}
+{{- end }}
 {{- end }}
 
 {{ define "httpListen" -}}
@@ -500,6 +575,7 @@ frontend {{ .id }}
 	mode http
 	{{- println }}
 	{{- if .log }}	{{ println "option httplog clf" }} {{- end }}
+	{{- range .log_lines }}	{{ println . }} {{- end }}
 	{{- if .acl }}	{{ println .acl }} {{- end}}
 	{{- range .rate_rules }}	{{ println . }} {{- end }}
 	{{- if .client_request_timeout }}	timeout http-request {{ println .client_request_timeout }} {{- end}}
@@ -15,12 +15,16 @@
 package models
 
 import (
+	"fmt"
 	"sort"
 
 	"yunion.io/x/log"
 
+	"yunion.io/x/onecloud/pkg/cloudcommon/keymanager"
+	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/models"
 	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/util/httputils"
 )
 
 type IModelSet interface {
@@ -300,3 +304,26 @@ func (set LoadbalancerCertificates) addModelCallback(i models.IVirtualResource)
 	}
 	return nil
 }
+
+// fetchPrivateKeys replaces encrypted private keys with plain ones.  Region
+// only decrypts private key on get of a single certificate
+func (set LoadbalancerCertificates) fetchPrivateKeys(s *mcclient.ClientSession) error {
+	for id, lbcert := range set {
+		if lbcert.PendingDeleted || !keymanager.IsEncrypted(lbcert.PrivateKey) {
+			continue
+		}
+		obj, err := modules.LoadbalancerCertificates.Get(s, id, nil)
+		if err != nil {
+			if jsonErr, ok := err.(*httputils.JSONClientError); ok && jsonErr.Code == 404 {
+				continue
+			}
+			return fmt.Errorf("get loadbalancercertificate %s: %s", id, err)
+		}
+		privateKey, _ := obj.GetString("private_key")
+		if keymanager.IsEncrypted(privateKey) {
+			return fmt.Errorf("private key of loadbalancercertificate %s not decrypted", id)
+		}
+		lbcert.PrivateKey = privateKey
+	}
+	return nil
+}
@@ -33,6 +33,10 @@ type LbagentOptions struct {
 
 	DataPreserveN int `default:"8" help:"number of recent data to preserve on disk"`
 
+	AccessLogBatchSize     int `default:"512" help:"number of access log records to upload in one batch"`
+	AccessLogFlushInterval int `default:"5" help:"interval in seconds to upload pending access log records"`
+	AccessLogMaxPending    int `default:"65536" help:"max number of access log records kept when upload fails"`
+
 	BaseDataDir      string // `required:"true"`
 	apiDataStoreDir  string
 	haproxyConfigDir string
@@ -57,6 +61,14 @@ func (opts *Options) ValidateThenInit() error {
 		return fmt.Errorf("negative api batch list size: %d",
 			opts.ApiListBatchSize)
 	}
+	if opts.AccessLogBatchSize <= 0 {
+		return fmt.Errorf("non-positive access log batch size: %d",
+			opts.AccessLogBatchSize)
+	}
+	if opts.AccessLogFlushInterval <= 0 {
+		return fmt.Errorf("non-positive access log flush interval: %d",
+			opts.AccessLogFlushInterval)
+	}
 	if err := opts.initDirs(); err != nil {
 		return err
 	}
@@ -85,3 +97,11 @@ func (opts *Options) initDirs() error {
 
 	return nil
 }
+
+func (opts *Options) haproxyStatsSocketFile() string {
+	return filepath.Join(opts.haproxyRunDir, "haproxy.sock")
+}
+
+func (opts *Options) accessLogSocketFile() string {
+	return filepath.Join(opts.haproxyRunDir, "accesslog.sock")
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const haproxyAccessLogSep = "|"
+
+// haproxy access log fields, in the order they appear in log-format
+const (
+	haproxyAccessLogLoadbalancerId = iota
+	haproxyAccessLogListenerId
+	haproxyAccessLogProjectId
+	haproxyAccessLogTs
+	haproxyAccessLogMs
+	haproxyAccessLogClientIp
+	haproxyAccessLogClientPort
+	haproxyAccessLogBackendId
+	haproxyAccessLogStatus
+	haproxyAccessLogBytesRead
+	haproxyAccessLogTotalTime
+	haproxyAccessLogResponseTime
+	haproxyAccessLogTerminationState
+	haproxyAccessLogMethod
+	haproxyAccessLogUri
+
+	haproxyAccessLogFieldCount
+)
+
+// HaproxyAccessLog is one access log record as uploaded to the log service
+type HaproxyAccessLog struct {
+	LoadbalancerId string
+	ListenerId     string
+	ProjectId      string `json:"tenant_id"`
+	AgentId        string
+
+	RequestTime time.Time
+	ClientIp    string
+	ClientPort  int
+
+	BackendId        string
+	Status           int
+	BytesRead        int64
+	TotalTime        int
+	ResponseTime     int
+	TerminationState string
+	Method           string
+	Uri              string
+}
+
+// HaproxyAccessLogFormat returns value of haproxy log-format directive for
+// the listener.  Ids of loadbalancer, listener and project are embedded as
+// literals so that records can be attributed without looking them up
+func HaproxyAccessLogFormat(lbId, listenerId, projectId, listenerType string) string {
+	fields := []string{lbId, listenerId, projectId, "%Ts", "%ms", "%ci", "%cp", "%s"}
+	if listenerType == "tcp" {
+		fields = append(fields, "0", "%B", "%Tt", "-1", "%ts", "-", "-")
+	} else {
+		fields = append(fields, "%ST", "%B", "%Tt", "%Tr", "%tsc", "%HM", "%HU")
+	}
+	return strings.Join(fields, haproxyAccessLogSep)
+}
+
+// ParseHaproxyAccessLog parses a syslog message sent by haproxy with
+// log-format generated by HaproxyAccessLogFormat
+func ParseHaproxyAccessLog(msg string) (*HaproxyAccessLog, error) {
+	// strip syslog header, e.g. "<134>Oct 18 12:00:00 haproxy[42]: "
+	if i := strings.Index(msg, "]: "); i >= 0 {
+		msg = msg[i+3:]
+	} else if i := strings.Index(msg, ": "); i >= 0 {
+		msg = msg[i+2:]
+	}
+	msg = strings.TrimRight(msg, "\r\n")
+	fields := strings.SplitN(msg, haproxyAccessLogSep, haproxyAccessLogFieldCount)
+	if len(fields) != haproxyAccessLogFieldCount {
+		return nil, fmt.Errorf("want %d fields, got %d", haproxyAccessLogFieldCount, len(fields))
+	}
+	ints := map[int]int64{}
+	for _, i := range []int{
+		haproxyAccessLogTs,
+		haproxyAccessLogMs,
+		haproxyAccessLogClientPort,
+		haproxyAccessLogStatus,
+		haproxyAccessLogBytesRead,
+		haproxyAccessLogTotalTime,
+		haproxyAccessLogResponseTime,
+	} {
+		v, err := strconv.ParseInt(fields[i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %s", i, err)
+		}
+		ints[i] = v
+	}
+	dash := func(s string) string {
+		if s == "-" || s == "<NOSRV>" {
+			return ""
+		}
+		return s
+	}
+	r := &HaproxyAccessLog{
+		LoadbalancerId: fields[haproxyAccessLogLoadbalancerId],
+		ListenerId:     fields[haproxyAccessLogListenerId],
+		ProjectId:      fields[haproxyAccessLogProjectId],
+
+		RequestTime: time.Unix(ints[haproxyAccessLogTs], ints[haproxyAccessLogMs]*int64(time.Millisecond)).UTC(),
+		ClientIp:    fields[haproxyAccessLogClientIp],
+		ClientPort:  int(ints[haproxyAccessLogClientPort]),
+
+		BackendId:        dash(fields[haproxyAccessLogBackendId]),
+		Status:           int(ints[haproxyAccessLogStatus]),
+		BytesRead:        ints[haproxyAccessLogBytesRead],
+		TotalTime:        int(ints[haproxyAccessLogTotalTime]),
+		ResponseTime:     int(ints[haproxyAccessLogResponseTime]),
+		TerminationState: dash(fields[haproxyAccessLogTerminationState]),
+		Method:           dash(fields[haproxyAccessLogMethod]),
+		Uri:              dash(fields[haproxyAccessLogUri]),
+	}
+	return r, nil
+}
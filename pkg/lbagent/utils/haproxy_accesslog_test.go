@@ -0,0 +1,67 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHaproxyAccessLog(t *testing.T) {
+	t.Run("http", func(t *testing.T) {
+		msg := "<134>Oct 18 12:00:00 haproxy[42]: lb0|lis0|proj0|1571400000|123|10.0.0.9|51234|b0|502|1024|35|-1|SH--|GET|/a?b=c|d\n"
+		r, err := ParseHaproxyAccessLog(msg)
+		if err != nil {
+			t.Fatalf("parse: %s", err)
+		}
+		want := HaproxyAccessLog{
+			LoadbalancerId:   "lb0",
+			ListenerId:       "lis0",
+			ProjectId:        "proj0",
+			RequestTime:      time.Unix(1571400000, 123*int64(time.Millisecond)).UTC(),
+			ClientIp:         "10.0.0.9",
+			ClientPort:       51234,
+			BackendId:        "b0",
+			Status:           502,
+			BytesRead:        1024,
+			TotalTime:        35,
+			ResponseTime:     -1,
+			TerminationState: "SH--",
+			Method:           "GET",
+			Uri:              "/a?b=c|d",
+		}
+		if *r != want {
+			t.Errorf("want %#v\ngot %#v", want, *r)
+		}
+	})
+	t.Run("tcp no server", func(t *testing.T) {
+		msg := "<134>Oct 18 12:00:00 haproxy[42]: lb0|lis0||1571400000|0|10.0.0.9|51234|<NOSRV>|0|0|3|-1|SC|-|-"
+		r, err := ParseHaproxyAccessLog(msg)
+		if err != nil {
+			t.Fatalf("parse: %s", err)
+		}
+		if r.BackendId != "" || r.Method != "" || r.Uri != "" || r.ProjectId != "" {
+			t.Errorf("unexpected record %#v", *r)
+		}
+		if r.TerminationState != "SC" {
+			t.Errorf("termination state: want SC, got %q", r.TerminationState)
+		}
+	})
+	t.Run("bad", func(t *testing.T) {
+		if _, err := ParseHaproxyAccessLog("<134>Oct 18 12:00:00 haproxy[42]: Proxy lis0 started."); err == nil {
+			t.Errorf("expecting error")
+		}
+	})
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HaproxyRuntime talks to haproxy through the stats socket so that changes
+// can be made without reloading the process
+type HaproxyRuntime struct {
+	socket  string
+	timeout time.Duration
+}
+
+func NewHaproxyRuntime(socket string) *HaproxyRuntime {
+	return &HaproxyRuntime{
+		socket:  socket,
+		timeout: 5 * time.Second,
+	}
+}
+
+func (r *HaproxyRuntime) Command(cmd string) (string, error) {
+	conn, err := net.DialTimeout("unix", r.socket, r.timeout)
+	if err != nil {
+		return "", fmt.Errorf("dial %s: %s", r.socket, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(r.timeout))
+	if _, err := io.WriteString(conn, cmd+"\n"); err != nil {
+		return "", fmt.Errorf("write command %q: %s", cmd, err)
+	}
+	d, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("read response of %q: %s", cmd, err)
+	}
+	return string(d), nil
+}
+
+func (r *HaproxyRuntime) commandNoOutput(cmd string) error {
+	out, err := r.Command(cmd)
+	if err != nil {
+		return err
+	}
+	out = strings.TrimSpace(out)
+	if out != "" {
+		return fmt.Errorf("%s: %s", cmd, out)
+	}
+	return nil
+}
+
+// SetServerWeight sets weight of server in backend.  Zero weight puts the
+// server into drain state so that it stops receiving new connections while
+// existing ones are left alone
+func (r *HaproxyRuntime) SetServerWeight(backend, server string, weight int) error {
+	name := backend + "/" + server
+	if weight == 0 {
+		return r.commandNoOutput(fmt.Sprintf("set server %s state drain", name))
+	}
+	if err := r.commandNoOutput(fmt.Sprintf("set server %s state ready", name)); err != nil {
+		return err
+	}
+	return r.commandNoOutput(fmt.Sprintf("set server %s weight %d", name, weight))
+}
+
+// ServerSessions returns current sessions of each server, summed over all
+// backends the server name appears in
+func (r *HaproxyRuntime) ServerSessions() (map[string]int, error) {
+	out, err := r.Command("show stat")
+	if err != nil {
+		return nil, err
+	}
+	return parseHaproxyStatSessions(out)
+}
+
+func parseHaproxyStatSessions(out string) (map[string]int, error) {
+	out = strings.TrimPrefix(out, "# ")
+	reader := csv.NewReader(strings.NewReader(out))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse stat csv: %s", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty stat output")
+	}
+	svnameIdx, scurIdx := -1, -1
+	for i, col := range records[0] {
+		switch col {
+		case "svname":
+			svnameIdx = i
+		case "scur":
+			scurIdx = i
+		}
+	}
+	if svnameIdx < 0 || scurIdx < 0 {
+		return nil, fmt.Errorf("stat output has no svname or scur column")
+	}
+	r := map[string]int{}
+	for _, record := range records[1:] {
+		if len(record) <= svnameIdx || len(record) <= scurIdx {
+			continue
+		}
+		svname := record[svnameIdx]
+		if svname == "FRONTEND" || svname == "BACKEND" {
+			continue
+		}
+		n, err := strconv.Atoi(record[scurIdx])
+		if err != nil {
+			continue
+		}
+		r[svname] += n
+	}
+	return r, nil
+}
+
+// HaproxyConfigServerWeights returns config content with server weights
+// masked, and the masked weights keyed by backend name and server name
+func HaproxyConfigServerWeights(d []byte) ([]byte, map[string]map[string]int) {
+	weights := map[string]map[string]int{}
+	buf := &bytes.Buffer{}
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(d))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			switch fields[0] {
+			case "backend", "listen", "frontend", "defaults", "global":
+				section = fields[1]
+			case "server":
+				for i := 2; i < len(fields)-1; i++ {
+					if fields[i] != "weight" {
+						continue
+					}
+					weight, err := strconv.Atoi(fields[i+1])
+					if err != nil {
+						break
+					}
+					if weights[section] == nil {
+						weights[section] = map[string]int{}
+					}
+					weights[section][fields[1]] = weight
+					fields[i+1] = "-"
+					line = strings.Join(fields, " ")
+					break
+				}
+			}
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), weights
+}
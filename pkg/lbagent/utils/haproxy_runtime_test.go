@@ -0,0 +1,75 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHaproxyConfigServerWeights(t *testing.T) {
+	cfgOld := []byte(`backend backends_listener-l0
+	mode tcp
+	balance roundrobin
+	server b0 10.0.0.1:80 weight 10 check rise 2 fall 3 inter 5s
+	server b1 10.0.0.2:80 weight 10 check rise 2 fall 3 inter 5s
+`)
+	cfgNew := []byte(`backend backends_listener-l0
+	mode tcp
+	balance roundrobin
+	server b0 10.0.0.1:80 weight 0 check rise 2 fall 3 inter 5s
+	server b1 10.0.0.2:80 weight 10 check rise 2 fall 3 inter 5s
+`)
+	maskedOld, weightsOld := HaproxyConfigServerWeights(cfgOld)
+	maskedNew, weightsNew := HaproxyConfigServerWeights(cfgNew)
+	if !bytes.Equal(maskedOld, maskedNew) {
+		t.Fatalf("masked configs differ:\n%s\n%s", maskedOld, maskedNew)
+	}
+	if w := weightsOld["backends_listener-l0"]["b0"]; w != 10 {
+		t.Errorf("old b0 weight: want 10, got %d", w)
+	}
+	if w := weightsNew["backends_listener-l0"]["b0"]; w != 0 {
+		t.Errorf("new b0 weight: want 0, got %d", w)
+	}
+	if w := weightsNew["backends_listener-l0"]["b1"]; w != 10 {
+		t.Errorf("new b1 weight: want 10, got %d", w)
+	}
+}
+
+func TestParseHaproxyStatSessions(t *testing.T) {
+	out := `# pxname,svname,qcur,qmax,scur,smax,slim,
+listen0,FRONTEND,,,3,5,2000,
+backends_listener-l0,b0,0,0,2,4,,
+backends_listener-l0,b1,0,0,1,3,,
+backends_rule-r0,b0,0,0,1,1,,
+backends_listener-l0,BACKEND,0,0,3,5,200,
+`
+	sessions, err := parseHaproxyStatSessions(out)
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	want := map[string]int{
+		"b0": 3,
+		"b1": 1,
+	}
+	if len(sessions) != len(want) {
+		t.Fatalf("want %v, got %v", want, sessions)
+	}
+	for k, v := range want {
+		if sessions[k] != v {
+			t.Errorf("%s: want %d, got %d", k, v, sessions[k])
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/logger/options"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type SLoadbalancerAccessLogManager struct {
+	db.SModelBaseManager
+}
+
+// SLoadbalancerAccessLog is one request/connection record shipped by
+// lbagent for listeners with access log enabled.  Status, Method and Uri are
+// only meaningful for http/https listeners
+type SLoadbalancerAccessLog struct {
+	db.SModelBase
+
+	Id int64 `primary:"true" auto_increment:"true" list:"user"`
+
+	LoadbalancerId string `width:"36" charset:"ascii" nullable:"false" index:"true" list:"user" create:"required"`
+	ListenerId     string `width:"36" charset:"ascii" nullable:"false" index:"true" list:"user" create:"required"`
+	BackendId      string `width:"36" charset:"ascii" nullable:"false" list:"user" create:"optional"`
+	ProjectId      string `name:"tenant_id" width:"128" charset:"ascii" nullable:"false" index:"true" list:"user" create:"required"`
+	AgentId        string `width:"36" charset:"ascii" nullable:"false" list:"admin" create:"optional"`
+
+	RequestTime time.Time `nullable:"false" index:"true" list:"user" create:"required"`
+	ClientIp    string    `width:"64" charset:"ascii" nullable:"false" list:"user" create:"required"`
+	ClientPort  int       `nullable:"false" list:"user" create:"optional"`
+
+	Method           string `width:"16" charset:"ascii" nullable:"false" list:"user" create:"optional"`
+	Uri              string `width:"1024" charset:"utf8" nullable:"false" list:"user" create:"optional"`
+	Status           int    `nullable:"false" index:"true" list:"user" create:"optional"`
+	BytesRead        int64  `nullable:"false" list:"user" create:"optional"`
+	TotalTime        int    `nullable:"false" list:"user" create:"optional"` // milliseconds
+	ResponseTime     int    `nullable:"false" list:"user" create:"optional"` // milliseconds, -1 if no response
+	TerminationState string `width:"8" charset:"ascii" nullable:"false" list:"user" create:"optional"`
+}
+
+var LoadbalancerAccessLogManager *SLoadbalancerAccessLogManager
+
+func init() {
+	LoadbalancerAccessLogManager = &SLoadbalancerAccessLogManager{
+		SModelBaseManager: db.NewModelBaseManager(
+			SLoadbalancerAccessLog{},
+			"loadbalancer_access_log_tbl",
+			"loadbalanceraccesslog",
+			"loadbalanceraccesslogs",
+		),
+	}
+}
+
+func (lblog *SLoadbalancerAccessLog) GetId() string {
+	return fmt.Sprintf("%d", lblog.Id)
+}
+
+func (lblog *SLoadbalancerAccessLog) GetName() string {
+	return fmt.Sprintf("%s-%d", lblog.ListenerId, lblog.Id)
+}
+
+func (lblog *SLoadbalancerAccessLog) GetModelManager() db.IModelManager {
+	return LoadbalancerAccessLogManager
+}
+
+func (man *SLoadbalancerAccessLogManager) FilterById(q *sqlchemy.SQuery, idStr string) *sqlchemy.SQuery {
+	id, _ := strconv.ParseInt(idStr, 10, 64)
+	return q.Equals("id", id)
+}
+
+func (man *SLoadbalancerAccessLogManager) FilterByNotId(q *sqlchemy.SQuery, idStr string) *sqlchemy.SQuery {
+	id, _ := strconv.ParseInt(idStr, 10, 64)
+	return q.NotEquals("id", id)
+}
+
+func (man *SLoadbalancerAccessLogManager) FilterByName(q *sqlchemy.SQuery, name string) *sqlchemy.SQuery {
+	return q
+}
+
+func (man *SLoadbalancerAccessLogManager) FilterByOwner(q *sqlchemy.SQuery, owner string) *sqlchemy.SQuery {
+	if len(owner) > 0 {
+		q = q.Equals("tenant_id", owner)
+	}
+	return q
+}
+
+func (man *SLoadbalancerAccessLogManager) GetOwnerId(userCred mcclient.IIdentityProvider) string {
+	return userCred.GetProjectId()
+}
+
+func (man *SLoadbalancerAccessLogManager) AllowListItems(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return true
+}
+
+func (man *SLoadbalancerAccessLogManager) AllowCreateItem(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return false
+}
+
+func (lblog *SLoadbalancerAccessLog) AllowGetDetails(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return db.IsAdminAllowGet(userCred, lblog) || userCred.GetProjectId() == lblog.ProjectId
+}
+
+func (lblog *SLoadbalancerAccessLog) AllowUpdateItem(ctx context.Context, userCred mcclient.TokenCredential) bool {
+	return false
+}
+
+func (lblog *SLoadbalancerAccessLog) AllowDeleteItem(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return false
+}
+
+func (lblog *SLoadbalancerAccessLog) ValidateDeleteCondition(ctx context.Context) error {
+	return httperrors.NewForbiddenError("not allow to delete log")
+}
+
+// ListItemFilter supports filtering by loadbalancer, listener, backend,
+// client ip, http method, time range (since, until) and status.  Status
+// can be given as exact code like 404 or as class like 5xx
+func (man *SLoadbalancerAccessLogManager) ListItemFilter(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, query jsonutils.JSONObject) (*sqlchemy.SQuery, error) {
+	for key, field := range map[string]string{
+		"loadbalancer": "loadbalancer_id",
+		"listener":     "listener_id",
+		"backend":      "backend_id",
+		"client_ip":    "client_ip",
+		"method":       "method",
+	} {
+		vals := jsonutils.GetQueryStringArray(query, key)
+		if len(vals) > 0 {
+			q = q.In(field, vals)
+		}
+	}
+	if statuses := jsonutils.GetQueryStringArray(query, "status"); len(statuses) > 0 {
+		conds := []sqlchemy.ICondition{}
+		for _, status := range statuses {
+			lo, hi, err := parseAccessLogStatus(status)
+			if err != nil {
+				return nil, httperrors.NewInputParameterError("invalid status %q: %s", status, err)
+			}
+			if lo == hi {
+				conds = append(conds, sqlchemy.Equals(q.Field("status"), lo))
+			} else {
+				conds = append(conds, sqlchemy.Between(q.Field("status"), lo, hi))
+			}
+		}
+		q = q.Filter(sqlchemy.OR(conds...))
+	}
+	since, _ := query.GetTime("since")
+	if !since.IsZero() {
+		q = q.GE("request_time", since)
+	}
+	until, _ := query.GetTime("until")
+	if !until.IsZero() {
+		q = q.LE("request_time", until)
+	}
+	return q, nil
+}
+
+// parseAccessLogStatus returns the inclusive status code range matched by s,
+// which is either an exact code like 502 or a class like 5xx
+func parseAccessLogStatus(s string) (int, int, error) {
+	if len(s) == 3 && (s[1:] == "xx" || s[1:] == "XX") {
+		c := int(s[0] - '0')
+		if c < 1 || c > 5 {
+			return 0, 0, fmt.Errorf("unknown status class")
+		}
+		return c * 100, c*100 + 99, nil
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return code, code, nil
+}
+
+func (man *SLoadbalancerAccessLogManager) AllowPerformUpload(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowClassPerform(userCred, man, "upload")
+}
+
+// PerformUpload stores a batch of access log records sent by lbagent.  The
+// batch is inserted in one transaction so that a failed upload, which lbagent
+// will retry as a whole, leaves no partial rows behind
+func (man *SLoadbalancerAccessLogManager) PerformUpload(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	logs, err := data.GetArray("access_logs")
+	if err != nil {
+		return nil, httperrors.NewMissingParameterError("access_logs")
+	}
+	lblogs := make([]*SLoadbalancerAccessLog, 0, len(logs))
+	for _, logObj := range logs {
+		lblog := &SLoadbalancerAccessLog{}
+		if err := logObj.Unmarshal(lblog); err != nil {
+			log.Warningf("invalid loadbalancer access log %s: %s", logObj, err)
+			continue
+		}
+		if lblog.ListenerId == "" || lblog.RequestTime.IsZero() {
+			log.Warningf("incomplete loadbalancer access log %s", logObj)
+			continue
+		}
+		lblogs = append(lblogs, lblog)
+	}
+	if err := man.insertBatch(lblogs); err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	ret := jsonutils.NewDict()
+	ret.Set("count", jsonutils.NewInt(int64(len(lblogs))))
+	return ret, nil
+}
+
+const lbAccessLogInsertChunk = 500
+
+var lbAccessLogInsertColumns = []string{
+	"loadbalancer_id", "listener_id", "backend_id", "tenant_id", "agent_id",
+	"request_time", "client_ip", "client_port",
+	"method", "uri", "status", "bytes_read", "total_time", "response_time", "termination_state",
+}
+
+func (man *SLoadbalancerAccessLogManager) insertBatch(lblogs []*SLoadbalancerAccessLog) error {
+	if len(lblogs) == 0 {
+		return nil
+	}
+	tx, err := sqlchemy.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(lbAccessLogInsertColumns)), ", ") + ")"
+	for start := 0; start < len(lblogs); start += lbAccessLogInsertChunk {
+		end := start + lbAccessLogInsertChunk
+		if end > len(lblogs) {
+			end = len(lblogs)
+		}
+		rows := make([]string, 0, end-start)
+		values := make([]interface{}, 0, (end-start)*len(lbAccessLogInsertColumns))
+		for _, lblog := range lblogs[start:end] {
+			rows = append(rows, placeholder)
+			values = append(values,
+				lblog.LoadbalancerId, lblog.ListenerId, lblog.BackendId, lblog.ProjectId, lblog.AgentId,
+				lblog.RequestTime, lblog.ClientIp, lblog.ClientPort,
+				lblog.Method, lblog.Uri, lblog.Status, lblog.BytesRead, lblog.TotalTime, lblog.ResponseTime, lblog.TerminationState,
+			)
+		}
+		sql := fmt.Sprintf("INSERT INTO `%s` (`%s`) VALUES %s",
+			man.TableSpec().Name(),
+			strings.Join(lbAccessLogInsertColumns, "`, `"),
+			strings.Join(rows, ", "))
+		if _, err := tx.Exec(sql, values...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PurgeExpiredLogs removes access logs older than the configured retention
+func (man *SLoadbalancerAccessLogManager) PurgeExpiredLogs(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	days := options.Options.LoadbalancerAccessLogRetentionDays
+	if days <= 0 {
+		return
+	}
+	cutoff := time.Now().UTC().AddDate(0, 0, -days)
+	sql := fmt.Sprintf("DELETE FROM `%s` WHERE `request_time` < ?", man.TableSpec().Name())
+	if _, err := sqlchemy.GetDB().Exec(sql, cutoff); err != nil {
+		log.Errorf("purge loadbalancer access logs before %s: %s", cutoff, err)
+	}
+}
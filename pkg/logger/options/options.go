@@ -22,6 +22,8 @@ type SLoggerOptions struct {
 	common_options.CommonOptions
 
 	common_options.DBOptions
+
+	LoadbalancerAccessLogRetentionDays int `default:"30" help:"Days to keep loadbalancer access logs, 0 to keep forever"`
 }
 
 var (
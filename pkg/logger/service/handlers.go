@@ -33,6 +33,7 @@ func initHandlers(app *appsrv.Application) {
 
 	for _, manager := range []db.IModelManager{
 		models.ActonLog,
+		models.LoadbalancerAccessLogManager,
 	} {
 		db.RegisterModelManager(manager)
 		handler := db.NewModelHandler(manager)
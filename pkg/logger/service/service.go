@@ -16,6 +16,7 @@ package service
 
 import (
 	"os"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 
@@ -24,6 +25,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon"
 	app_common "yunion.io/x/onecloud/pkg/cloudcommon/app"
 	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	"yunion.io/x/onecloud/pkg/logger/models"
@@ -60,5 +62,11 @@ func StartService() {
 		log.Fatalf("database schema not in sync!")
 	}
 
+	cron := cronman.GetCronJobManager(true)
+	cron.EnableLeaderElection(SERVICE_TYPE)
+	cron.AddJob1("PurgeExpiredLoadbalancerAccessLogs", time.Hour, models.LoadbalancerAccessLogManager.PurgeExpiredLogs)
+	cron.Start()
+	defer cron.Stop()
+
 	app_common.ServeForever(app, commonOpts)
 }
@@ -75,6 +75,22 @@ func AuthenticateWithDelayDecision(f appsrv.FilterHandler, delayDecision bool) a
 	}
 }
 
+// RateLimitIdentity identifies the caller of a request for appsrv rate
+// limiting. Only tokens already in the verify cache are looked up, so no
+// keystone request is issued before the request reaches a worker; unknown
+// tokens are left to Authenticate.
+func RateLimitIdentity(r *http.Request) (string, string, bool) {
+	tokenStr := r.Header.Get(mcclient.AUTH_TOKEN)
+	if len(tokenStr) == 0 || manager == nil {
+		return "", "", false
+	}
+	token, found := manager.tokenCacheVerify.GetToken(tokenStr)
+	if !found || !token.IsValid() {
+		return "", "", false
+	}
+	return token.GetUserId(), token.GetProjectId(), token.HasSystemAdminPrivilege()
+}
+
 func FetchUserCredential(ctx context.Context, filter func(mcclient.TokenCredential) mcclient.TokenCredential) mcclient.TokenCredential {
 	tokenValue := ctx.Value(AUTH_TOKEN)
 	if tokenValue != nil {
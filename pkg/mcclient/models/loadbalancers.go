@@ -85,6 +85,9 @@ type LoadbalancerListener struct {
 	AclType   string
 	AclId     string
 
+	EnableAccessLog bool
+	TenantId        string
+
 	HealthCheck     string
 	HealthCheckType string
 
@@ -106,6 +109,9 @@ type LoadbalancerListener struct {
 	LoadbalancerHTTPSListener
 
 	LoadbalancerHTTPRateLimiter
+
+	ShiftBackendGroupId string
+	ShiftWeight         int
 }
 
 type LoadbalancerListenerRule struct {
@@ -143,6 +149,9 @@ type LoadbalancerBackend struct {
 	Weight         int
 	Address        string
 	Port           int
+
+	DrainDeadline time.Time
+	DrainSessions int
 }
 
 type LoadbalancerAclEntry struct {
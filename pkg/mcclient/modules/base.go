@@ -102,6 +102,9 @@ type ListResult struct {
 	Total  int
 	Limit  int
 	Offset int
+
+	// marker of the next page when paging by marker, empty on the last page
+	NextMarker string
 }
 
 func ListResult2JSONWithKey(result *ListResult, key string) jsonutils.JSONObject {
@@ -115,6 +118,9 @@ func ListResult2JSONWithKey(result *ListResult, key string) jsonutils.JSONObject
 	if result.Offset > 0 {
 		obj.Add(jsonutils.NewInt(int64(result.Offset)), "offset")
 	}
+	if len(result.NextMarker) > 0 {
+		obj.Add(jsonutils.NewString(result.NextMarker), "next_marker")
+	}
 	arr := jsonutils.NewArray(result.Data...)
 	obj.Add(arr, key)
 	return obj
@@ -128,8 +134,9 @@ func JSON2ListResult(result jsonutils.JSONObject) *ListResult {
 	total, _ := result.Int("total")
 	limit, _ := result.Int("limit")
 	offset, _ := result.Int("offset")
+	nextMarker, _ := result.GetString("next_marker")
 	data, _ := result.GetArray("data")
-	return &ListResult{Data: data, Total: int(total), Limit: int(limit), Offset: int(offset)}
+	return &ListResult{Data: data, Total: int(total), Limit: int(limit), Offset: int(offset), NextMarker: nextMarker}
 }
 
 func (this *BaseManager) _list(session *mcclient.ClientSession, path, responseKey string) (*ListResult, error) {
@@ -160,13 +167,21 @@ func (this *BaseManager) _list(session *mcclient.ClientSession, path, responseKe
 	if err != nil {
 		offset = 0
 	}
-	return &ListResult{rets, int(total), int(limit), int(offset)}, nil
+	nextMarker, _ := body.GetString("next_marker")
+	return &ListResult{Data: rets, Total: int(total), Limit: int(limit), Offset: int(offset), NextMarker: nextMarker}, nil
 }
 
 func (this *BaseManager) _submit(session *mcclient.ClientSession, method httputils.THttpMethod, path string, body jsonutils.JSONObject, respKey string) (jsonutils.JSONObject, error) {
-	hdr, resp, e := this.jsonRequest(session, method, path, nil, body)
+	_, ret, e := this._submitWithHeader(session, method, path, nil, body, respKey)
+	return ret, e
+}
+
+// _submitWithHeader is like _submit, but also sends the given request
+// headers and returns the response headers
+func (this *BaseManager) _submitWithHeader(session *mcclient.ClientSession, method httputils.THttpMethod, path string, header http.Header, body jsonutils.JSONObject, respKey string) (http.Header, jsonutils.JSONObject, error) {
+	hdr, resp, e := this.jsonRequest(session, method, path, header, body)
 	if e != nil {
-		return nil, e
+		return nil, nil, e
 	}
 	if method == "HEAD" {
 		ret := jsonutils.NewDict()
@@ -181,19 +196,19 @@ func (this *BaseManager) _submit(session *mcclient.ClientSession, method httputi
 				}
 			}
 		}
-		return ret, nil
+		return hdr, ret, nil
 	}
 	if resp == nil { // no reslt
-		return jsonutils.NewDict(), nil
+		return hdr, jsonutils.NewDict(), nil
 	}
 	if len(respKey) == 0 {
-		return resp, nil
+		return hdr, resp, nil
 	}
 	ret, e := resp.Get(respKey)
 	if e != nil {
-		return nil, e
+		return nil, nil, e
 	}
-	return ret, nil
+	return hdr, ret, nil
 }
 
 type SubmitResult struct {
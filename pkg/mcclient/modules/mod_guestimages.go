@@ -0,0 +1,59 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modules
+
+import (
+	"fmt"
+	"io"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+type GuestImageManager struct {
+	ResourceManager
+}
+
+// Upload creates a guest image from OVA package, params are passed as image
+// meta headers like image upload
+func (this *GuestImageManager) Upload(s *mcclient.ClientSession, params jsonutils.JSONObject, body io.Reader, size int64) (jsonutils.JSONObject, error) {
+	headers, err := setImageMeta(params)
+	if err != nil {
+		return nil, err
+	}
+	headers.Add("Content-Type", "application/octet-stream")
+	headers.Add("Content-Length", fmt.Sprintf("%d", size))
+	path := fmt.Sprintf("/%s", this.URLPath())
+	resp, err := this.rawRequest(s, httputils.POST, path, headers, body)
+	_, json, err := s.ParseJSONResponse(resp, err)
+	if err != nil {
+		return nil, err
+	}
+	return json.Get(this.Keyword)
+}
+
+var (
+	GuestImages GuestImageManager
+)
+
+func init() {
+	GuestImages = GuestImageManager{NewImageManager("guestimage", "guestimages",
+		[]string{"ID", "Name", "Status", "Size", "OS_Type",
+			"Vcpu_count", "Vmem_size_mb", "Is_public"},
+		[]string{"Tenant"})}
+	register(&GuestImages)
+}
@@ -0,0 +1,33 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modules
+
+var (
+	Webhooks          ResourceManager
+	WebhookDeliveries ResourceManager
+)
+
+func init() {
+	Webhooks = NewComputeManager("webhook", "webhooks",
+		[]string{"ID", "Name", "Url", "Enabled", "Resource_types", "Actions", "Result", "Tenant_id"},
+		[]string{"All_projects", "Last_seq"})
+
+	WebhookDeliveries = NewComputeManager("webhook_delivery", "webhook_deliveries",
+		[]string{"ID", "Name", "Webhook_id", "Event_seq", "Obj_type", "Obj_name", "Action", "Status", "Attempts", "Next_attempt_at", "Response_code", "Last_error", "Delivered_at"},
+		[]string{})
+
+	registerCompute(&Webhooks)
+	registerCompute(&WebhookDeliveries)
+}
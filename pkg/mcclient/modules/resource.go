@@ -16,6 +16,7 @@ package modules
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
 
@@ -204,6 +205,28 @@ func (this *ResourceManager) GetInContexts(session *mcclient.ClientSession, id s
 	}
 }
 
+// GetWithETag fetches a resource by id together with its ETag. The ETag can
+// be passed to UpdateIfMatch or PerformActionIfMatch so that the change is
+// rejected if somebody else modified the resource in between.
+func (this *ResourceManager) GetWithETag(session *mcclient.ClientSession, id string, params jsonutils.JSONObject) (jsonutils.JSONObject, string, error) {
+	path := fmt.Sprintf("/%s/%s", this.ContextPath(nil), url.PathEscape(id))
+	if params != nil {
+		qs := params.QueryString()
+		if len(qs) > 0 {
+			path = fmt.Sprintf("%s?%s", path, qs)
+		}
+	}
+	hdr, obj, err := this._submitWithHeader(session, "GET", path, nil, nil, this.Keyword)
+	if err != nil {
+		return nil, "", err
+	}
+	obj, err = this.filterSingleResult(session, obj, params)
+	if err != nil {
+		return nil, "", err
+	}
+	return obj, hdr.Get("ETag"), nil
+}
+
 func (this *ResourceManager) GetId(session *mcclient.ClientSession, id string, params jsonutils.JSONObject) (string, error) {
 	return this.GetIdInContexts(session, id, params, nil)
 }
@@ -402,6 +425,28 @@ func (this *ResourceManager) PutInContexts(session *mcclient.ClientSession, id s
 	return this.filterSingleResult(session, result, nil)
 }
 
+func ifMatchHeader(etag string) http.Header {
+	header := http.Header{}
+	header.Set("If-Match", etag)
+	return header
+}
+
+// UpdateIfMatch updates a resource only if its current ETag is etag, a
+// precondition failed error (412) is returned otherwise. The ETag of the
+// updated resource is returned for chaining further changes.
+func (this *ResourceManager) UpdateIfMatch(session *mcclient.ClientSession, id string, etag string, params jsonutils.JSONObject) (jsonutils.JSONObject, string, error) {
+	path := fmt.Sprintf("/%s/%s", this.ContextPath(nil), url.PathEscape(id))
+	hdr, result, err := this._submitWithHeader(session, "PUT", path, ifMatchHeader(etag), this.params2Body(session, params), this.Keyword)
+	if err != nil {
+		return nil, "", err
+	}
+	result, err = this.filterSingleResult(session, result, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return result, hdr.Get("ETag"), nil
+}
+
 func (this *ResourceManager) BatchUpdate(session *mcclient.ClientSession, idlist []string, params jsonutils.JSONObject) []SubmitResult {
 	return this.BatchPutInContexts(session, idlist, params, nil)
 }
@@ -468,6 +513,21 @@ func (this *ResourceManager) PerformActionInContexts(session *mcclient.ClientSes
 	return this.filterSingleResult(session, result, nil)
 }
 
+// PerformActionIfMatch is like PerformAction, but the action is only
+// performed if the current ETag of the resource is etag
+func (this *ResourceManager) PerformActionIfMatch(session *mcclient.ClientSession, id string, action string, etag string, params jsonutils.JSONObject) (jsonutils.JSONObject, string, error) {
+	path := fmt.Sprintf("/%s/%s/%s", this.ContextPath(nil), url.PathEscape(id), url.PathEscape(action))
+	hdr, result, err := this._submitWithHeader(session, "POST", path, ifMatchHeader(etag), this.params2Body(session, params), this.Keyword)
+	if err != nil {
+		return nil, "", err
+	}
+	result, err = this.filterSingleResult(session, result, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return result, hdr.Get("ETag"), nil
+}
+
 func (this *ResourceManager) PerformClassAction(session *mcclient.ClientSession, action string, params jsonutils.JSONObject) (jsonutils.JSONObject, error) {
 	return this.PerformClassActionInContexts(session, action, params, nil)
 }
@@ -0,0 +1,75 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modules
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+// Watch streams the change events of the resources after sequence number
+// since, or the new events only if since is negative. It returns the seq of
+// the last event passed to callback when the server ends the stream, so that
+// the caller can resume from there.
+func (this *ResourceManager) Watch(session *mcclient.ClientSession, params jsonutils.JSONObject, since int64, callback func(ev jsonutils.JSONObject) error) (int64, error) {
+	path := fmt.Sprintf("/watch/%s", this.URLPath())
+	query := jsonutils.NewDict()
+	if params != nil {
+		query.Update(params)
+	}
+	if since >= 0 {
+		query.Set("since_seq", jsonutils.NewInt(since))
+	}
+	if qs := query.QueryString(); len(qs) > 0 {
+		path = fmt.Sprintf("%s?%s", path, qs)
+	}
+	resp, err := this.rawRequest(session, "GET", path, nil, nil)
+	if err != nil || resp.StatusCode >= 300 {
+		_, _, err = session.ParseJSONResponse(resp, err)
+		return since, err
+	}
+	defer resp.Body.Close()
+
+	data := make([]string, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimSpace(line[len("data:"):]))
+			continue
+		}
+		if len(line) > 0 || len(data) == 0 {
+			// id, event and comment lines, the event itself carries them
+			continue
+		}
+		ev, err := jsonutils.ParseString(strings.Join(data, "\n"))
+		data = data[:0]
+		if err != nil {
+			return since, err
+		}
+		err = callback(ev)
+		if err != nil {
+			return since, err
+		}
+		since, _ = ev.Int("seq")
+	}
+	return since, scanner.Err()
+}
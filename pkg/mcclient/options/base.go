@@ -198,6 +198,10 @@ type BaseListOptions struct {
 	PendingDelete    *bool    `help:"Show only pending deleted resource"`
 	PendingDeleteAll *bool    `help:"Show all resources including pending deleted" json:"-"`
 	Field            []string `help:"Show only specified fields"`
+	Fields           []string `help:"Return only the specified fields of each item, details included"`
+	PagingMarker     string   `help:"Marker of the page to list by marker, given by the previous page"`
+	Paging           bool     `help:"List the first page by marker" json:"-"`
+	WithTotal        *bool    `help:"Count the total number of items"`
 	ShowEmulated     *bool    `help:"Show all resources including the emulated resources"`
 	ExportFile       string   `help:"Export to file" metavar:"<EXPORT_FILE_PATH>" json:"-"`
 	ExportKeys       string   `help:"Export field keys"`
@@ -222,6 +226,9 @@ func (opts *BaseListOptions) Params() (*jsonutils.JSONDict, error) {
 	if len(opts.Filter) == 0 {
 		params.Remove("filter_any")
 	}
+	if opts.Paging && len(opts.PagingMarker) == 0 {
+		params.Set("paging_marker", jsonutils.NewString(""))
+	}
 	if BoolV(opts.PendingDeleteAll) {
 		params.Set("pending_delete", jsonutils.NewString("all"))
 		params.Set("details", jsonutils.JSONTrue) // required to get pending_deleted field
@@ -0,0 +1,29 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+type LoadbalancerAccessLogListOptions struct {
+	BaseListOptions
+
+	Loadbalancer string
+	Listener     string
+	Backend      string
+	ClientIp     string
+	Method       string
+	Status       []string `help:"Status code like 404 or status class like 5xx"`
+
+	Since string `help:"Show logs since specific date" metavar:"DATETIME"`
+	Until string `help:"Show logs until specific date" metavar:"DATETIME"`
+}
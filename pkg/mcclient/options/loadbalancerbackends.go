@@ -47,3 +47,8 @@ type LoadbalancerBackendGetOptions struct {
 type LoadbalancerBackendDeleteOptions struct {
 	ID string `json:"-"`
 }
+
+type LoadbalancerBackendActionDrainOptions struct {
+	ID      string `json:"-"`
+	Timeout *int   `help:"seconds to wait for active sessions to finish before removing the backend"`
+}
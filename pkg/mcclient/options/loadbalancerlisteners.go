@@ -63,6 +63,8 @@ type LoadbalancerListenerCreateOptions struct {
 	TLSCipherPolicy string
 	EnableHttp2     string `choices:"true|false"`
 
+	EnableAccessLog string `choices:"true|false"`
+
 	HTTPRequestRate       *int
 	HTTPRequestRatePerSrc *int
 }
@@ -118,8 +120,9 @@ type LoadbalancerListenerListOptions struct {
 }
 
 type LoadbalancerListenerUpdateOptions struct {
-	ID   string `json:"-"`
-	Name string
+	ID      string `json:"-"`
+	Name    string
+	IfMatch string `help:"Only update if the listener still has this ETag, see etag-show" json:"-"`
 
 	BackendGroup string
 
@@ -161,6 +164,8 @@ type LoadbalancerListenerUpdateOptions struct {
 	TLSCipherPolicy string
 	EnableHttp2     string `choices:"true|false"`
 
+	EnableAccessLog string `choices:"true|false"`
+
 	HTTPRequestRate       *int
 	HTTPRequestRatePerSrc *int
 }
@@ -181,3 +186,11 @@ type LoadbalancerListenerActionStatusOptions struct {
 type LoadbalancerListenerActionSyncStatusOptions struct {
 	ID string `json:"-"`
 }
+
+type LoadbalancerListenerActionShiftWeightOptions struct {
+	ID           string `json:"-"`
+	BackendGroup string `help:"backend group to shift traffic to"`
+	Weight       *int   `help:"target percentage of traffic sent to the backend group, 0 to roll back"`
+	Step         *int   `help:"percentage to shift each step"`
+	Interval     *int   `help:"seconds between steps"`
+}
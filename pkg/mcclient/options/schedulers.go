@@ -15,6 +15,8 @@
 package options
 
 import (
+	"fmt"
+
 	"yunion.io/x/onecloud/pkg/apis/scheduler"
 	"yunion.io/x/onecloud/pkg/mcclient"
 )
@@ -93,3 +95,30 @@ func (o SchedulerForecastOptions) Params(s *mcclient.ClientSession) (*scheduler.
 	input.ScheduleBaseConfig = *opts
 	return input, nil
 }
+
+type SchedulerCapacityOptions struct {
+	ServerConfigs
+
+	Mem  int    `help:"Memory size (MB) of server spec" metavar:"MEMORY"`
+	Ncpu int    `help:"#CPU cores of server spec" metavar:"<SERVER_CPU_COUNT>"`
+	Sku  string `help:"Server SKU instance type, overrides --mem and --ncpu"`
+}
+
+func (o SchedulerCapacityOptions) Params(s *mcclient.ClientSession) (*scheduler.ScheduleInput, error) {
+	if o.Mem <= 0 && len(o.Sku) == 0 {
+		return nil, fmt.Errorf("Either --mem or --sku must be specified")
+	}
+	config, err := o.ServerConfigs.Data()
+	if err != nil {
+		return nil, err
+	}
+	input := new(scheduler.ScheduleInput)
+	input.ServerConfigs = config
+	input.Memory = o.Mem
+	input.Ncpu = o.Ncpu
+	if input.Ncpu <= 0 {
+		input.Ncpu = 1
+	}
+	input.InstanceType = o.Sku
+	return input, nil
+}
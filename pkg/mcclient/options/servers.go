@@ -140,6 +140,8 @@ type ServerConfigs struct {
 	Project        string   `help:"'Owner project ID or Name" json:"tenant"`
 	User           string   `help:"Owner user ID or Name"`
 	Count          int      `help:"Create multiple simultaneously" default:"1"`
+
+	TopologySpread []string `help:"Spread servers of group over topology domains, e.g. '<group>:<rack|zone|schedtag:<key>>:<max_skew>[:require|prefer]'"`
 }
 
 func (o ServerConfigs) Data() (*computeapi.ServerConfigs, error) {
@@ -217,6 +219,13 @@ func (o ServerConfigs) Data() (*computeapi.ServerConfigs, error) {
 		}
 		data.Schedtags = append(data.Schedtags, schedtag)
 	}
+	for _, spread := range o.TopologySpread {
+		conf, err := cmdline.ParseTopologySpreadConfig(spread)
+		if err != nil {
+			return nil, err
+		}
+		data.TopologySpreads = append(data.TopologySpreads, conf)
+	}
 	return data, nil
 }
 
@@ -266,6 +275,11 @@ type ServerCreateOptions struct {
 	EipBw         int    `help:"allocate EIP with bandwidth in MB when server is created" json:"eip_bw,omitzero"`
 	EipChargeType string `help:"newly allocated EIP charge type, either traffic or bandwidth" choices:"traffic|bandwidth" json:"eip_charge_type,omitempty"`
 	Eip           string `help:"associate with an existing EIP when server is created" json:"eip,omitempty"`
+
+	GuestImage string `help:"Guest image imported from OVA, provides disks and hardware hints of server" json:"guest_image_id"`
+
+	PriorityClass    string `help:"Priority class of server" choices:"low|normal|high"`
+	PreemptionPolicy string `help:"Whether to preempt lower priority servers when no host fits, admin only" choices:"never|plan|execute"`
 }
 
 func (o *ServerCreateOptions) ToScheduleInput() (*schedapi.ScheduleInput, error) {
@@ -319,6 +333,8 @@ func (opts *ServerCreateOptions) Params() (*computeapi.ServerCreateInput, error)
 	if err != nil {
 		return nil, err
 	}
+	config.PriorityClass = opts.PriorityClass
+	config.PreemptionPolicy = opts.PreemptionPolicy
 
 	params := &computeapi.ServerCreateInput{
 		ServerConfigs:      config,
@@ -339,6 +355,7 @@ func (opts *ServerCreateOptions) Params() (*computeapi.ServerCreateInput, error)
 		EipChargeType:      opts.EipChargeType,
 		Eip:                opts.Eip,
 		EnableCloudInit:    opts.EnableCloudInit,
+		GuestImageId:       opts.GuestImage,
 	}
 
 	if opts.GenerateName {
@@ -361,6 +378,7 @@ func (opts *ServerCreateOptions) Params() (*computeapi.ServerCreateInput, error)
 		return nil, err
 	}
 	params.DeployConfigs = deployInfos
+	params.Groups = opts.Group
 
 	if len(opts.Boot) > 0 {
 		if opts.Boot == "disk" {
@@ -414,6 +432,8 @@ type ServerUpdateOptions struct {
 	Boot             string   `help:"Boot device" choices:"disk|cdrom"`
 	Delete           string   `help:"Lock server to prevent from deleting" choices:"enable|disable" json:"-"`
 	ShutdownBehavior string   `help:"Behavior after VM server shutdown, stop or terminate server" choices:"stop|terminate"`
+	PriorityClass    string   `help:"Priority class of server" choices:"low|normal|high"`
+	IfMatch          string   `help:"Only update if the server still has this ETag, see etag-show" json:"-"`
 }
 
 func (opts *ServerUpdateOptions) Params() (*jsonutils.JSONDict, error) {
@@ -546,6 +566,13 @@ type ServerMigrateOptions struct {
 					  default false" json:"rescue_mode"`
 }
 
+type ServerConvertToKvmOptions struct {
+	ID           string `help:"ID of esxi server" json:"-"`
+	PreferHost   string `help:"KVM host id or name to convert server onto" json:"prefer_host"`
+	Storage      string `help:"Storage of the host to put disks, default the one with most free capacity" json:"storage"`
+	DeleteSource *bool  `help:"Delete source vm from vCenter after converted" json:"delete_source"`
+}
+
 type ServerLiveMigrateOptions struct {
 	ID         string `help:"ID of server" json:"-"`
 	PreferHost string `help:"Server migration prefer host id or name" json:"prefer_host"`
@@ -0,0 +1,317 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/scheduler/algorithm"
+	"yunion.io/x/onecloud/pkg/scheduler/algorithm/plugin"
+	"yunion.io/x/onecloud/pkg/scheduler/algorithm/predicates"
+	"yunion.io/x/onecloud/pkg/scheduler/cache/candidate"
+	"yunion.io/x/onecloud/pkg/scheduler/core"
+	"yunion.io/x/onecloud/pkg/scheduler/core/score"
+)
+
+// TopologySpreadPredicate spreads guests of a group over racks, zones or
+// schedtag domains, so that one failure domain never holds all replicas.
+// Required spreads exclude hosts whose domain would exceed max skew,
+// preferred spreads only rank hosts of less loaded domains higher.
+type TopologySpreadPredicate struct {
+	predicates.BasePredicate
+	plugin.BasePlugin
+
+	spreads []*topologySpread
+}
+
+type topologySpread struct {
+	*computeapi.TopologySpreadConfig
+
+	// guest count of group in each domain
+	domainCounts map[string]int64
+	minCount     int64
+}
+
+// pendingGroupGuestsTTL is how long a placement is remembered, long enough
+// for the region to create the guest and the host cache to be reloaded
+const pendingGroupGuestsTTL = 5 * time.Minute
+
+type sPendingGroupGuests struct {
+	total  int64
+	expire time.Time
+}
+
+// sPendingGroupGuestsTable remembers how many guests of a group each host is
+// expected to hold after recent schedules, as the host cache only counts
+// guests once the region has created them and the cache is reloaded
+type sPendingGroupGuestsTable struct {
+	lock sync.Mutex
+	data map[string]*sPendingGroupGuests
+}
+
+var pendingGroupGuests = &sPendingGroupGuestsTable{
+	data: make(map[string]*sPendingGroupGuests),
+}
+
+// count returns the guests of group on host, cached is the count of the
+// host cache which already includes the pending guests once reloaded
+func (t *sPendingGroupGuestsTable) count(groupId, hostId string, cached int64, now time.Time) int64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.getCount(groupId+"/"+hostId, cached, now)
+}
+
+func (t *sPendingGroupGuestsTable) getCount(key string, cached int64, now time.Time) int64 {
+	pending, ok := t.data[key]
+	if !ok {
+		return cached
+	}
+	if now.After(pending.expire) {
+		delete(t.data, key)
+		return cached
+	}
+	if pending.total > cached {
+		return pending.total
+	}
+	return cached
+}
+
+func (t *sPendingGroupGuestsTable) add(groupId, hostId string, cached, count int64, now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := groupId + "/" + hostId
+	total := t.getCount(key, cached, now) + count
+	t.data[key] = &sPendingGroupGuests{total: total, expire: now.Add(pendingGroupGuestsTTL)}
+	for k, pending := range t.data {
+		if now.After(pending.expire) {
+			delete(t.data, k)
+		}
+	}
+}
+
+func (p *TopologySpreadPredicate) Name() string {
+	return "host_topology_spread"
+}
+
+func (p *TopologySpreadPredicate) Clone() core.FitPredicate {
+	return &TopologySpreadPredicate{}
+}
+
+// topologyDomain returns domain of host in topology, empty if host is not in
+// any domain of the topology
+func topologyDomain(topologyKey string, host *candidate.HostDesc) string {
+	switch {
+	case topologyKey == computeapi.TOPOLOGY_KEY_RACK:
+		return host.Rack
+	case topologyKey == computeapi.TOPOLOGY_KEY_ZONE:
+		if host.Zone != nil {
+			return host.Zone.Id
+		}
+	case strings.HasPrefix(topologyKey, computeapi.TOPOLOGY_KEY_SCHEDTAG_PREFIX):
+		prefix := topologyKey[len(computeapi.TOPOLOGY_KEY_SCHEDTAG_PREFIX):] + computeapi.TOPOLOGY_SCHEDTAG_SEP
+		for _, tag := range host.HostSchedtags {
+			if strings.HasPrefix(tag.Name, prefix) && len(tag.Name) > len(prefix) {
+				return tag.Name[len(prefix):]
+			}
+		}
+	}
+	return ""
+}
+
+func groupGuestCount(groupId string, host *candidate.HostDesc) int64 {
+	if host.Groups == nil {
+		return 0
+	}
+	if count, ok := host.Groups.Data[groupId]; ok {
+		return count.Count
+	}
+	return 0
+}
+
+func newTopologySpread(conf *computeapi.TopologySpreadConfig, hosts []*candidate.HostDesc) *topologySpread {
+	spread := &topologySpread{
+		TopologySpreadConfig: conf,
+		domainCounts:         make(map[string]int64),
+	}
+	now := time.Now()
+	for _, host := range hosts {
+		domain := topologyDomain(conf.TopologyKey, host)
+		if len(domain) == 0 {
+			continue
+		}
+		spread.domainCounts[domain] += pendingGroupGuests.count(conf.GroupId, host.Id, groupGuestCount(conf.GroupId, host), now)
+	}
+	spread.updateMinCount()
+	return spread
+}
+
+func (s *topologySpread) updateMinCount() {
+	first := true
+	for _, count := range s.domainCounts {
+		if first || count < s.minCount {
+			s.minCount = count
+			first = false
+		}
+	}
+}
+
+// place counts one more guest of group in the domain, the budget of the
+// domain is shared by all its hosts
+func (s *topologySpread) place(domain string) {
+	s.domainCounts[domain] += 1
+	s.updateMinCount()
+}
+
+// allowed returns how many more guests could be placed in the domain
+// without exceeding max skew
+func (s *topologySpread) allowed(domain string) int64 {
+	maxSkew := int64(s.MaxSkew)
+	if maxSkew < 1 {
+		maxSkew = 1
+	}
+	return s.minCount + maxSkew - s.domainCounts[domain]
+}
+
+func (s *topologySpread) isRequired() bool {
+	return s.Strategy != computeapi.STRATEGY_PREFER
+}
+
+func (p *TopologySpreadPredicate) PreExecute(u *core.Unit, cs []core.Candidater) (bool, error) {
+	d := u.SchedData()
+	if len(d.TopologySpreads) == 0 {
+		return false, nil
+	}
+
+	hosts := make([]*candidate.HostDesc, 0, len(cs))
+	for _, c := range cs {
+		host, err := algorithm.ToHostCandidate(c)
+		if err != nil {
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	for _, conf := range d.TopologySpreads {
+		p.spreads = append(p.spreads, newTopologySpread(conf, hosts))
+	}
+	u.AppendSelectPlugin(p)
+	return true, nil
+}
+
+func (p *TopologySpreadPredicate) Execute(u *core.Unit, c core.Candidater) (bool, []core.PredicateFailureReason, error) {
+	h := predicates.NewPredicateHelper(p, u, c)
+
+	host, err := h.HostCandidate()
+	if err != nil {
+		return false, nil, err
+	}
+
+	capacity := int64(-1)
+	for _, spread := range p.spreads {
+		if !spread.isRequired() {
+			continue
+		}
+		domain := topologyDomain(spread.TopologyKey, host)
+		if len(domain) == 0 {
+			h.Exclude(fmt.Sprintf("not in any domain of topology %s", spread.TopologyKey))
+			break
+		}
+		allowed := spread.allowed(domain)
+		if allowed <= 0 {
+			h.Exclude(fmt.Sprintf("%s %s of group %s exceeds max skew %d", spread.TopologyKey, domain, spread.GroupId, spread.MaxSkew))
+			break
+		}
+		if capacity < 0 || allowed < capacity {
+			capacity = allowed
+		}
+	}
+	if capacity > 0 {
+		h.SetCapacity(capacity)
+	}
+
+	return h.GetResult()
+}
+
+// CanSelect keeps the domains of required spreads within max skew while
+// guests of a batch are placed
+func (p *TopologySpreadPredicate) CanSelect(u *core.Unit, c core.Candidater) bool {
+	host, err := algorithm.ToHostCandidate(c)
+	if err != nil {
+		return false
+	}
+	for _, spread := range p.spreads {
+		if !spread.isRequired() {
+			continue
+		}
+		if spread.allowed(topologyDomain(spread.TopologyKey, host)) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *TopologySpreadPredicate) OnSelect(u *core.Unit, c core.Candidater) {
+	host, err := algorithm.ToHostCandidate(c)
+	if err != nil {
+		return
+	}
+	for _, spread := range p.spreads {
+		if domain := topologyDomain(spread.TopologyKey, host); len(domain) > 0 {
+			spread.place(domain)
+		}
+	}
+}
+
+// OnSelectEnd remembers the guests placed, so that schedules following
+// before the host cache is reloaded count them
+func (p *TopologySpreadPredicate) OnSelectEnd(u *core.Unit, c core.Candidater, count int64) {
+	if u.SchedInfo.IsSuggestion {
+		return
+	}
+	host, err := algorithm.ToHostCandidate(c)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, spread := range p.spreads {
+		pendingGroupGuests.add(spread.GroupId, host.Id, groupGuestCount(spread.GroupId, host), count, now)
+	}
+}
+
+func (p *TopologySpreadPredicate) OnPriorityEnd(u *core.Unit, c core.Candidater) {
+	host, err := algorithm.ToHostCandidate(c)
+	if err != nil {
+		return
+	}
+	for _, spread := range p.spreads {
+		domain := topologyDomain(spread.TopologyKey, host)
+		if len(domain) == 0 {
+			continue
+		}
+		// the more guests of group in domain, the lower the score
+		skew := spread.domainCounts[domain] - spread.minCount
+		if skew > 0 {
+			u.SetFrontScore(
+				c.IndexKey(),
+				score.NewScore(
+					score.TScore(-core.PriorityStep*int(skew)),
+					p.Name()+":"+spread.TopologyKey,
+				))
+		}
+	}
+}
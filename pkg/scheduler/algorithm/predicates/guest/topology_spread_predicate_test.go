@@ -0,0 +1,154 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guest
+
+import (
+	"testing"
+	"time"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	computemodels "yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/scheduler/cache/candidate"
+)
+
+func newTopologyHost(rack, zoneId string, tags []string, groupId string, count int64) *candidate.HostDesc {
+	host := &candidate.HostDesc{
+		BaseHostDesc: &candidate.BaseHostDesc{
+			SHost: &computemodels.SHost{},
+			Zone:  &computemodels.SZone{},
+		},
+		Groups: candidate.NewGroupCounts(),
+	}
+	host.Rack = rack
+	host.Zone.Id = zoneId
+	for _, tag := range tags {
+		schedtag := computemodels.SSchedtag{}
+		schedtag.Name = tag
+		host.HostSchedtags = append(host.HostSchedtags, schedtag)
+	}
+	if count > 0 {
+		host.Groups.Data[groupId] = &candidate.GroupCount{ID: groupId, Count: count}
+	}
+	return host
+}
+
+func TestTopologyDomain(t *testing.T) {
+	host := newTopologyHost("rack1", "zone1", []string{"ssd", "power.a"}, "", 0)
+	cases := []struct {
+		key  string
+		want string
+	}{
+		{computeapi.TOPOLOGY_KEY_RACK, "rack1"},
+		{computeapi.TOPOLOGY_KEY_ZONE, "zone1"},
+		{"schedtag:power", "a"},
+		{"schedtag:ssd", ""},
+		{"schedtag:row", ""},
+		{"unknown", ""},
+	}
+	for _, c := range cases {
+		if got := topologyDomain(c.key, host); got != c.want {
+			t.Errorf("topologyDomain(%q) = %q, want %q", c.key, got, c.want)
+		}
+	}
+}
+
+func TestTopologySpreadAllowed(t *testing.T) {
+	hosts := []*candidate.HostDesc{
+		newTopologyHost("rack1", "", nil, "g1", 2),
+		newTopologyHost("rack1", "", nil, "g1", 1),
+		newTopologyHost("rack2", "", nil, "g1", 1),
+		newTopologyHost("rack3", "", nil, "g1", 0),
+		newTopologyHost("", "", nil, "g1", 5),
+	}
+	spread := newTopologySpread(&computeapi.TopologySpreadConfig{
+		GroupId:     "g1",
+		TopologyKey: computeapi.TOPOLOGY_KEY_RACK,
+		MaxSkew:     1,
+	}, hosts)
+	if spread.minCount != 0 {
+		t.Errorf("minCount = %d, want 0", spread.minCount)
+	}
+	cases := map[string]int64{
+		"rack1": -2,
+		"rack2": 0,
+		"rack3": 1,
+	}
+	for domain, want := range cases {
+		if got := spread.allowed(domain); got != want {
+			t.Errorf("allowed(%q) = %d, want %d", domain, got, want)
+		}
+	}
+	if !spread.isRequired() {
+		t.Errorf("spread without strategy should be required")
+	}
+}
+
+func TestTopologySpreadSharedBudget(t *testing.T) {
+	// two hosts in rack1 must not each take the whole budget of the rack
+	hosts := []*candidate.HostDesc{
+		newTopologyHost("rack1", "", nil, "g1", 0),
+		newTopologyHost("rack1", "", nil, "g1", 0),
+		newTopologyHost("rack2", "", nil, "g1", 0),
+	}
+	spread := newTopologySpread(&computeapi.TopologySpreadConfig{
+		GroupId:     "g1",
+		TopologyKey: computeapi.TOPOLOGY_KEY_RACK,
+		MaxSkew:     1,
+	}, hosts)
+	placed := map[string]int64{}
+	for i := 0; i < 4; i++ {
+		placedOne := false
+		for _, domain := range []string{"rack1", "rack1", "rack2"} {
+			if spread.allowed(domain) > 0 {
+				spread.place(domain)
+				placed[domain] += 1
+				placedOne = true
+				break
+			}
+		}
+		if !placedOne {
+			t.Fatalf("guest %d not placed", i)
+		}
+	}
+	if placed["rack1"] != 2 || placed["rack2"] != 2 {
+		t.Errorf("placed %v, want 2 in each rack", placed)
+	}
+}
+
+func TestPendingGroupGuests(t *testing.T) {
+	table := &sPendingGroupGuestsTable{data: make(map[string]*sPendingGroupGuests)}
+	now := time.Now()
+	if got := table.count("g1", "h1", 1, now); got != 1 {
+		t.Errorf("count without pending = %d, want 1", got)
+	}
+	table.add("g1", "h1", 1, 2, now)
+	if got := table.count("g1", "h1", 1, now); got != 3 {
+		t.Errorf("count with 2 pending = %d, want 3", got)
+	}
+	table.add("g1", "h1", 1, 1, now)
+	if got := table.count("g1", "h1", 1, now); got != 4 {
+		t.Errorf("count with 3 pending = %d, want 4", got)
+	}
+	// the reloaded cache counts the created guests, they are not counted twice
+	if got := table.count("g1", "h1", 4, now); got != 4 {
+		t.Errorf("count after reload = %d, want 4", got)
+	}
+	if got := table.count("g1", "h1", 1, now.Add(pendingGroupGuestsTTL+time.Second)); got != 1 {
+		t.Errorf("count after expire = %d, want 1", got)
+	}
+	if len(table.data) != 0 {
+		t.Errorf("expired pending guests not removed")
+	}
+}
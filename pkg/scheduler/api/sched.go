@@ -207,3 +207,26 @@ type SchedForecastResult struct {
 	Filters   []*ForecastFilter        `json:"filters"`
 	Results   []*api.CandidateResource `json:"results"`
 }
+
+type CapacityHost struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	ZoneId string `json:"zone_id"`
+	// Capacity is -1 when no filter limits host
+	Capacity int64 `json:"capacity"`
+	// Bottleneck is the filter limiting capacity of host
+	Bottleneck string `json:"bottleneck"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+type CapacityBottleneck struct {
+	Filter    string `json:"filter"`
+	HostCount int64  `json:"host_count"`
+	Capacity  int64  `json:"capacity"`
+}
+
+type SchedCapacityResult struct {
+	Total       int64                 `json:"total"`
+	Hosts       []*CapacityHost       `json:"hosts"`
+	Bottlenecks []*CapacityBottleneck `json:"bottlenecks"`
+}
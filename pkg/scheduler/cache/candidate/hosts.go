@@ -30,6 +30,7 @@ import (
 	"yunion.io/x/sqlchemy"
 
 	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	schedapi "yunion.io/x/onecloud/pkg/apis/scheduler"
 	computedb "yunion.io/x/onecloud/pkg/cloudcommon/db"
 	computemodels "yunion.io/x/onecloud/pkg/compute/models"
 	"yunion.io/x/onecloud/pkg/scheduler/core"
@@ -78,6 +79,9 @@ type HostDesc struct {
 	IsMaintenance             bool                  `json:"is_maintenance"`
 	GuestReservedResource     *ReservedResource     `json:"guest_reserved_resource"`
 	GuestReservedResourceUsed *ReservedResource     `json:"guest_reserved_used"`
+
+	// running guests could be preempted by guests with higher priority
+	PreemptibleGuests []*schedapi.PreemptionVictim `json:"preemptible_guests"`
 }
 
 type ReservedResource struct {
@@ -277,6 +281,9 @@ func (h *HostDesc) Get(key string) interface{} {
 	case "Groups":
 		return h.Groups
 
+	case "PreemptibleGuests":
+		return h.PreemptibleGuests
+
 	case "IsolatedDevices":
 		return h.IsolatedDevices
 
@@ -1051,6 +1058,16 @@ func (b *HostBuilder) fillGuestsResourceInfo(desc *HostDesc, host *computemodels
 			runningCount++
 			memSize += int64(guest.VmemSize)
 			cpuCount += int64(guest.VcpuCount)
+			if guest.Status == computeapi.VM_RUNNING && guest.HostId == host.Id {
+				desc.PreemptibleGuests = append(desc.PreemptibleGuests, &schedapi.PreemptionVictim{
+					GuestId:       guest.Id,
+					Name:          guest.Name,
+					ProjectId:     guest.ProjectId,
+					PriorityClass: guest.PriorityClass,
+					VcpuCount:     int(guest.VcpuCount),
+					VmemSize:      guest.VmemSize,
+				})
+			}
 		} else if IsGuestCreating(guest) {
 			creatingGuestCount++
 			creatingMemSize += int64(guest.VmemSize)
@@ -139,6 +139,10 @@ func (g *GenericScheduler) Schedule(unit *Unit, candidates []Candidater) (*Sched
 
 	// if there is no candidate and not from scheduler/test api will return
 	if len(filteredCandidates) == 0 && !isSuggestion {
+		if plan := findPreemptionPlan(unit); plan != nil {
+			log.Infof("SessionID: %s, no candidate fits, preempt %d guests on host %s", schedInfo.SessionId, len(plan.Victims), plan.HostName)
+			return &SchedResultItemList{Unit: unit, Data: []*SchedResultItem{}, Preemption: plan}, nil
+		}
 		return nil, &FitError{
 			Unit:               unit,
 			FailedCandidateMap: unit.FailedCandidateMap,
@@ -279,6 +283,9 @@ func GetCapacities(u *Unit, id string) (res map[string]int64) {
 type SchedResultItemList struct {
 	Unit *Unit
 	Data []*SchedResultItem
+
+	// Preemption is set when no candidate fits but preempting guests helps
+	Preemption *schedapi.PreemptionPlan
 }
 
 func (its SchedResultItemList) Len() int {
@@ -347,6 +354,20 @@ func SelectHosts(unit *Unit, priorityList HostPriorityList) ([]*SelectedCandidat
 	selectedCandidates := []*SelectedCandidate{}
 
 	plugins := unit.AllSelectPlugins()
+	limitPlugins := make([]SelectLimitPlugin, 0)
+	for _, plugin := range plugins {
+		if limitPlugin, ok := plugin.(SelectLimitPlugin); ok {
+			limitPlugins = append(limitPlugins, limitPlugin)
+		}
+	}
+	canSelect := func(c Candidater) bool {
+		for _, plugin := range limitPlugins {
+			if !plugin.CanSelect(unit, c) {
+				return false
+			}
+		}
+		return true
+	}
 
 	sort.Sort(sort.Reverse(priorityList))
 
@@ -354,11 +375,19 @@ completed:
 	for len(priorityList) > 0 {
 		log.V(10).Debugf("PriorityList: %#v", priorityList)
 		priorityList0 := HostPriorityList{}
+		selectedOne := false
 		for _, it := range priorityList {
 			if count <= 0 {
 				break completed
 			}
 			hostID := it.Host
+			// a limit may be lifted by guests placed on other candidates,
+			// keep the candidate for the next round
+			if !canSelect(it.Candidate) {
+				priorityList0 = append(priorityList0, it)
+				continue
+			}
+			selectedOne = true
 			var (
 				selectedItem *SelectedCandidate
 				ok           bool
@@ -372,11 +401,17 @@ completed:
 			}
 			selectedItem.Count++
 			count--
+			for _, plugin := range limitPlugins {
+				plugin.OnSelect(unit, it.Candidate)
+			}
 			// if capacity of the host large than selected count, this host can be added to priorityList.
 			if unit.GetCapacity(hostID) > selectedItem.Count {
 				priorityList0 = append(priorityList0, it)
 			}
 		}
+		if !selectedOne {
+			break
+		}
 		// sort by score
 		priorityList = priorityList0
 		sort.Sort(sort.Reverse(priorityList))
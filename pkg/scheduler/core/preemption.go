@@ -0,0 +1,154 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"sort"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	schedapi "yunion.io/x/onecloud/pkg/apis/scheduler"
+	o "yunion.io/x/onecloud/pkg/scheduler/options"
+)
+
+// preemptibleStages are predicates whose failure could be resolved by
+// stopping running guests on the candidate
+var preemptibleStages = map[string]bool{
+	"host_cpu":    true,
+	"host_memory": true,
+}
+
+// findPreemptionPlan looks for the candidate where stopping the minimal set
+// of lower priority guests makes room for the unit. Only candidates failed
+// by cpu or memory predicates are considered, the region will schedule again
+// after preemption, so other predicates are still checked then.
+//
+// Stopped guests keep holding their cpu and memory unless
+// IgnoreNonrunningGuests is set, no plan is made then as stopping victims
+// would free nothing.
+func findPreemptionPlan(u *Unit) *schedapi.PreemptionPlan {
+	if !o.GetOptions().IgnoreNonrunningGuests {
+		return nil
+	}
+	d := u.SchedData()
+	if d.PreemptionPolicy != computeapi.PREEMPTION_POLICY_PLAN && d.PreemptionPolicy != computeapi.PREEMPTION_POLICY_EXECUTE {
+		return nil
+	}
+	if d.Backup || u.IsPublicCloudProvider() {
+		return nil
+	}
+	count := d.Count
+	if count < 1 {
+		count = 1
+	}
+	needCPU := int64(d.Ncpu * count)
+	needMem := int64(d.Memory * count)
+	priority := computeapi.GuestPriority(d.PriorityClass)
+
+	candidates := make(map[string]Candidater)
+	unresolvable := make(map[string]bool)
+	for stage, fcs := range u.FailedCandidateMap {
+		for _, fc := range fcs.Candidates {
+			id := fc.Candidate.IndexKey()
+			candidates[id] = fc.Candidate
+			if !preemptibleStages[stage] {
+				unresolvable[id] = true
+			}
+		}
+	}
+	ids := make([]string, 0, len(candidates))
+	for id := range candidates {
+		if !unresolvable[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var plan *schedapi.PreemptionPlan
+	for _, id := range ids {
+		c := candidates[id]
+		guests, ok := c.Get("PreemptibleGuests").([]*schedapi.PreemptionVictim)
+		if !ok || len(guests) == 0 {
+			continue
+		}
+		freeCPU, _ := c.Get("FreeCPUCount").(int64)
+		freeMem, _ := c.Get("FreeMemSize").(int64)
+		victims := selectPreemptionVictims(guests, priority, needCPU-freeCPU, needMem-freeMem)
+		if len(victims) == 0 {
+			continue
+		}
+		if plan == nil || len(victims) < len(plan.Victims) {
+			name, _ := c.Get("Name").(string)
+			plan = &schedapi.PreemptionPlan{
+				HostId:   id,
+				HostName: name,
+				Victims:  victims,
+			}
+		}
+	}
+	return plan
+}
+
+// selectPreemptionVictims picks guests with priority lower than given one,
+// lowest priority and largest guests first, until lacked cpu and memory are
+// freed. Guests not needed at last are dropped to keep the set minimal.
+// Returns nil if stopping all lower priority guests is still not enough.
+func selectPreemptionVictims(guests []*schedapi.PreemptionVictim, priority int, lackCPU, lackMem int64) []*schedapi.PreemptionVictim {
+	if lackCPU <= 0 && lackMem <= 0 {
+		return nil
+	}
+	preemptible := make([]*schedapi.PreemptionVictim, 0, len(guests))
+	for _, g := range guests {
+		if computeapi.GuestPriority(g.PriorityClass) < priority {
+			preemptible = append(preemptible, g)
+		}
+	}
+	sort.SliceStable(preemptible, func(i, j int) bool {
+		pi, pj := computeapi.GuestPriority(preemptible[i].PriorityClass), computeapi.GuestPriority(preemptible[j].PriorityClass)
+		if pi != pj {
+			return pi < pj
+		}
+		if preemptible[i].VmemSize != preemptible[j].VmemSize {
+			return preemptible[i].VmemSize > preemptible[j].VmemSize
+		}
+		return preemptible[i].VcpuCount > preemptible[j].VcpuCount
+	})
+
+	victims := make([]*schedapi.PreemptionVictim, 0)
+	var cpu, mem int64
+	for _, g := range preemptible {
+		if cpu >= lackCPU && mem >= lackMem {
+			break
+		}
+		if (cpu < lackCPU && g.VcpuCount > 0) || (mem < lackMem && g.VmemSize > 0) {
+			victims = append(victims, g)
+			cpu += int64(g.VcpuCount)
+			mem += int64(g.VmemSize)
+		}
+	}
+	if cpu < lackCPU || mem < lackMem {
+		return nil
+	}
+
+	// drop victims not needed, higher priority and smaller ones first
+	for i := len(victims) - 1; i >= 0; i-- {
+		g := victims[i]
+		if cpu-int64(g.VcpuCount) >= lackCPU && mem-int64(g.VmemSize) >= lackMem {
+			cpu -= int64(g.VcpuCount)
+			mem -= int64(g.VmemSize)
+			victims = append(victims[:i], victims[i+1:]...)
+		}
+	}
+	return victims
+}
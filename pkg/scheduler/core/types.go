@@ -43,6 +43,16 @@ type SelectPlugin interface {
 	OnSelectEnd(u *Unit, c Candidater, count int64)
 }
 
+// SelectLimitPlugin is a SelectPlugin limiting the guests placed across
+// candidates, e.g. a budget shared by the hosts of a failure domain, which
+// the capacity of each single candidate cannot express
+type SelectLimitPlugin interface {
+	// CanSelect returns whether one more guest can be placed on candidate
+	CanSelect(u *Unit, c Candidater) bool
+	// OnSelect is called after one more guest is placed on candidate
+	OnSelect(u *Unit, c Candidater)
+}
+
 type Kind int
 
 const (
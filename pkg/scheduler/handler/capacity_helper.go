@@ -0,0 +1,158 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	computemodels "yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/scheduler/api"
+	"yunion.io/x/onecloud/pkg/scheduler/core"
+	schedman "yunion.io/x/onecloud/pkg/scheduler/manager"
+)
+
+// doSchedulerCapacity reports how many servers of given spec still fit,
+// per host and by the filter limiting each host
+func doSchedulerCapacity(c *gin.Context) {
+	if !schedman.IsReady() {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("Global scheduler not init"))
+		return
+	}
+
+	schedInfo, err := api.FetchSchedInfo(c.Request)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if len(schedInfo.InstanceType) > 0 {
+		hypervisor := schedInfo.Hypervisor
+		if hypervisor == api.HostHypervisorForKvm {
+			hypervisor = computeapi.HYPERVISOR_KVM
+		}
+		sku, err := computemodels.ServerSkuManager.FetchSkuByNameAndHypervisor(schedInfo.InstanceType, hypervisor, true)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		schedInfo.Memory = sku.MemorySizeMB
+		schedInfo.Ncpu = sku.CpuCoreCount
+	}
+
+	schedInfo.Count = 1
+	schedInfo.IsSuggestion = true
+	schedInfo.ShowSuggestionDetails = true
+	schedInfo.SuggestionAll = true
+	schedInfo.SuggestionLimit = core.MaxCapacity
+	result, err := schedman.Schedule(schedInfo)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, transToSchedCapacityResult(result))
+}
+
+// capacityBottleneck returns the filter with minimal capacity, filters are
+// compared by name when capacities are equal to keep result stable
+func capacityBottleneck(details map[string]int64) (string, int64) {
+	names := make([]string, 0, len(details))
+	for name := range details {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bottleneck := ""
+	capacity := core.EmptyCapacity
+	for _, name := range names {
+		cnt := details[name]
+		if cnt == core.EmptyCapacity {
+			continue
+		}
+		if capacity == core.EmptyCapacity || cnt < capacity {
+			bottleneck = name
+			capacity = cnt
+		}
+	}
+	return bottleneck, capacity
+}
+
+func transToSchedCapacityResult(result *core.SchedResultItemList) *api.SchedCapacityResult {
+	schedData := result.Unit.SchedData()
+	failedLogs := result.Unit.LogManager.FailedLogs()
+
+	ret := &api.SchedCapacityResult{
+		Hosts:       make([]*api.CapacityHost, 0),
+		Bottlenecks: make([]*api.CapacityBottleneck, 0),
+	}
+	bottlenecks := make(map[string]*api.CapacityBottleneck)
+	for _, item := range result.Data {
+		getter := item.Candidater.Getter()
+		if getter.HostType() != schedData.Hypervisor {
+			continue
+		}
+		filter, capacity := capacityBottleneck(item.CapacityDetails)
+		if capacity == core.EmptyCapacity {
+			// no filter limits this host
+			capacity = item.Capacity
+		}
+		if capacity < 0 {
+			capacity = 0
+		}
+		host := &api.CapacityHost{
+			Id:         item.ID,
+			Name:       item.Name,
+			Capacity:   capacity,
+			Bottleneck: filter,
+		}
+		if zone := getter.Zone(); zone != nil {
+			host.ZoneId = zone.Id
+		}
+		if capacity == 0 {
+			logIndex := fmt.Sprintf("%s:%s", item.Candidater.Get("Name"), item.Candidater.Get("ID"))
+			if failedLog := failedLogs.Get(logIndex); failedLog != nil {
+				host.Reason = failedLog.String()
+			}
+		} else if capacity == core.MaxCapacity {
+			host.Capacity = -1
+		} else {
+			ret.Total += capacity
+		}
+		ret.Hosts = append(ret.Hosts, host)
+
+		if len(filter) == 0 {
+			continue
+		}
+		b, ok := bottlenecks[filter]
+		if !ok {
+			b = &api.CapacityBottleneck{Filter: filter}
+			bottlenecks[filter] = b
+			ret.Bottlenecks = append(ret.Bottlenecks, b)
+		}
+		b.HostCount++
+		if host.Capacity > 0 {
+			b.Capacity += host.Capacity
+		}
+	}
+
+	sort.Slice(ret.Hosts, func(i, j int) bool {
+		return ret.Hosts[i].Capacity > ret.Hosts[j].Capacity
+	})
+	return ret
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	schedapi "yunion.io/x/onecloud/pkg/apis/scheduler"
+	computemodels "yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/scheduler/api"
+	"yunion.io/x/onecloud/pkg/scheduler/core"
+)
+
+type sCapacityTestGetter struct {
+	core.CandidatePropertyGetter
+
+	hostType string
+	zone     *computemodels.SZone
+}
+
+func (g *sCapacityTestGetter) HostType() string {
+	return g.hostType
+}
+
+func (g *sCapacityTestGetter) Zone() *computemodels.SZone {
+	return g.zone
+}
+
+type sCapacityTestCandidate struct {
+	core.Candidater
+
+	id     string
+	name   string
+	getter *sCapacityTestGetter
+}
+
+func (c *sCapacityTestCandidate) Getter() core.CandidatePropertyGetter {
+	return c.getter
+}
+
+func (c *sCapacityTestCandidate) Get(key string) interface{} {
+	switch key {
+	case "ID":
+		return c.id
+	case "Name":
+		return c.name
+	}
+	return nil
+}
+
+func TestCapacityBottleneck(t *testing.T) {
+	cases := []struct {
+		name         string
+		details      map[string]int64
+		wantFilter   string
+		wantCapacity int64
+	}{
+		{"no filter", nil, "", core.EmptyCapacity},
+		{"all empty", map[string]int64{"host_cpu": core.EmptyCapacity}, "", core.EmptyCapacity},
+		{"minimal", map[string]int64{"host_cpu": 4, "host_memory": 2, "host_storage": 9}, "host_memory", 2},
+		{"tie by name", map[string]int64{"host_storage": 3, "host_memory": 3}, "host_memory", 3},
+		{"zero", map[string]int64{"host_cpu": 0, "host_memory": 5}, "host_cpu", 0},
+		{"skip empty", map[string]int64{"host_cpu": core.EmptyCapacity, "host_memory": 7}, "host_memory", 7},
+	}
+	for _, c := range cases {
+		filter, capacity := capacityBottleneck(c.details)
+		if filter != c.wantFilter || capacity != c.wantCapacity {
+			t.Errorf("%s: want (%q, %d), got (%q, %d)", c.name, c.wantFilter, c.wantCapacity, filter, capacity)
+		}
+	}
+}
+
+func TestTransToSchedCapacityResult(t *testing.T) {
+	zone := &computemodels.SZone{}
+	zone.Id = "zone1"
+	newItem := func(id string, hostType string, capacity int64, details map[string]int64) *core.SchedResultItem {
+		getter := &sCapacityTestGetter{hostType: hostType}
+		if id == "host1" {
+			getter.zone = zone
+		}
+		return &core.SchedResultItem{
+			ID:              id,
+			Name:            id + "-name",
+			Capacity:        capacity,
+			CapacityDetails: details,
+			Candidater:      &sCapacityTestCandidate{id: id, name: id + "-name", getter: getter},
+		}
+	}
+
+	info := &api.SchedInfo{
+		ScheduleInput: &schedapi.ScheduleInput{
+			ServerConfig: schedapi.ServerConfig{
+				ServerConfigs: &computeapi.ServerConfigs{Hypervisor: api.HostHypervisorForKvm},
+			},
+		},
+	}
+	unit := &core.Unit{SchedInfo: info, LogManager: core.NewSchedLogManager()}
+	unit.LogManager.Append("host2-name:host2", "host_cpu", "no enough cpu", true)
+	unit.LogManager.Append("host1-name:host1", "host_memory", "not a failure", false)
+
+	result := &core.SchedResultItemList{
+		Unit: unit,
+		Data: []*core.SchedResultItem{
+			newItem("host1", api.HostHypervisorForKvm, 2, map[string]int64{"host_cpu": 4, "host_memory": 2}),
+			newItem("host2", api.HostHypervisorForKvm, 0, map[string]int64{"host_cpu": 0, "host_memory": 8}),
+			newItem("host3", api.HostHypervisorForKvm, core.MaxCapacity, nil),
+			newItem("host4", computeapi.HOST_TYPE_ESXI, 10, map[string]int64{"host_cpu": 10}),
+			newItem("host5", api.HostHypervisorForKvm, 3, map[string]int64{"host_memory": 3, "host_cpu": core.EmptyCapacity}),
+			newItem("host6", api.HostHypervisorForKvm, core.EmptyCapacity, map[string]int64{"host_memory": -2}),
+		},
+	}
+
+	want := &api.SchedCapacityResult{
+		Total: 5,
+		Hosts: []*api.CapacityHost{
+			{Id: "host5", Name: "host5-name", Capacity: 3, Bottleneck: "host_memory"},
+			{Id: "host1", Name: "host1-name", ZoneId: "zone1", Capacity: 2, Bottleneck: "host_memory"},
+			{Id: "host2", Name: "host2-name", Capacity: 0, Bottleneck: "host_cpu", Reason: "host2-name:host2 [host_cpu] no enough cpu"},
+			{Id: "host6", Name: "host6-name", Capacity: 0, Bottleneck: "host_memory"},
+			{Id: "host3", Name: "host3-name", Capacity: -1},
+		},
+		Bottlenecks: []*api.CapacityBottleneck{
+			{Filter: "host_memory", HostCount: 3, Capacity: 5},
+			{Filter: "host_cpu", HostCount: 1, Capacity: 0},
+		},
+	}
+	got := transToSchedCapacityResult(result)
+	if got.Total != want.Total {
+		t.Errorf("want total %d, got %d", want.Total, got.Total)
+	}
+	if len(got.Hosts) != len(want.Hosts) {
+		t.Fatalf("want %d hosts, got %d", len(want.Hosts), len(got.Hosts))
+	}
+	// hosts of equal capacity are in no particular order
+	gotHosts := make(map[string]*api.CapacityHost)
+	for i, host := range got.Hosts {
+		gotHosts[host.Id] = host
+		if i > 0 && got.Hosts[i-1].Capacity < host.Capacity {
+			t.Errorf("hosts not sorted by capacity: %s(%d) before %s(%d)", got.Hosts[i-1].Id, got.Hosts[i-1].Capacity, host.Id, host.Capacity)
+		}
+	}
+	for _, host := range want.Hosts {
+		if !reflect.DeepEqual(gotHosts[host.Id], host) {
+			t.Errorf("want host %#v, got %#v", host, gotHosts[host.Id])
+		}
+	}
+	if !reflect.DeepEqual(got.Bottlenecks, want.Bottlenecks) {
+		for _, b := range got.Bottlenecks {
+			t.Logf("got bottleneck %#v", b)
+		}
+		t.Errorf("unexpected bottlenecks")
+	}
+}
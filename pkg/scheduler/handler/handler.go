@@ -71,6 +71,8 @@ func schedulerActionHandler(c *gin.Context) {
 		doSchedulerTest(c)
 	case "forecast":
 		doSchedulerForecast(c)
+	case "capacity":
+		doSchedulerCapacity(c)
 	case "candidate-list":
 		doCandidateList(c)
 	case "cleanup":
@@ -293,7 +295,9 @@ func doSyncSchedule(c *gin.Context) {
 	if schedInfo.Backup {
 		resp = transToBackupSchedResult(result, schedInfo.PreferHost, schedInfo.PreferBackupHost, count, true)
 	} else {
-		resp = transToRegionSchedResult(result.Data, count)
+		output := transToRegionSchedResult(result.Data, count)
+		output.Preemption = result.Preemption
+		resp = output
 	}
 
 	c.JSON(http.StatusOK, resp)
@@ -26,7 +26,8 @@ import (
 )
 
 const (
-	PXECLIENT = "PXEClient"
+	PXECLIENT  = "PXEClient"
+	HTTPCLIENT = "HTTPClient"
 
 	OptClasslessRouteLin OptionCode = OptionClasslessRouteFormat //Classless Static Route Option
 	OptClasslessRouteWin OptionCode = 249
@@ -50,6 +51,10 @@ type ResponseConfig struct {
 	BootServer string
 	BootFile   string
 	BootBlock  uint16
+
+	// VendorClassId is echoed back in option 60, UEFI HTTP boot clients
+	// require it to be HTTPClient
+	VendorClassId string
 }
 
 func (conf ResponseConfig) GetHostname() string {
@@ -143,9 +148,14 @@ func makeDHCPReplyPacket(req Packet, conf *ResponseConfig, msgType MessageType)
 	}
 	if conf.BootFile != "" {
 		resp.AddOption(OptionBootFileName, []byte(fmt.Sprintf("%s\x00", conf.BootFile)))
-		sz := make([]byte, 2)
-		binary.BigEndian.PutUint16(sz, conf.BootBlock)
-		resp.AddOption(OptionBootFileSize, sz)
+		if conf.BootBlock > 0 {
+			sz := make([]byte, 2)
+			binary.BigEndian.PutUint16(sz, conf.BootBlock)
+			resp.AddOption(OptionBootFileSize, sz)
+		}
+	}
+	if conf.VendorClassId != "" {
+		resp.AddOption(OptionVendorClassIdentifier, []byte(conf.VendorClassId))
 	}
 	//if bs, _ := req.ParseOptions().Bytes(OptionClientMachineIdentifier); bs != nil {
 	//resp.AddOption(OptionClientMachineIdentifier, bs)
@@ -270,6 +270,27 @@ func (cli *SESXiClient) FindHostByMoId(moId string) (cloudprovider.ICloudHost, e
 	return nil, cloudprovider.ErrNotFound
 }
 
+// FindDatastoreByGlobalId finds datastore by its volume id, which is
+// external id of the storage in region
+func (cli *SESXiClient) FindDatastoreByGlobalId(globalId string) (*SDatastore, error) {
+	dcs, err := cli.GetDatacenters()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(dcs); i += 1 {
+		istorages, err := dcs[i].GetIStorages()
+		if err != nil {
+			return nil, err
+		}
+		for j := 0; j < len(istorages); j += 1 {
+			if istorages[j].GetGlobalId() == globalId {
+				return istorages[j].(*SDatastore), nil
+			}
+		}
+	}
+	return nil, cloudprovider.ErrNotFound
+}
+
 func (cli *SESXiClient) getPrivateId(idStr string) string {
 	if len(cli.providerId) > 0 && strings.HasPrefix(idStr, cli.providerId) {
 		idStr = idStr[len(cli.providerId)+1:]
@@ -68,6 +68,7 @@ var WhiteList = []string{
 	ACT_IMAGE_SAVE, ACT_RECYCLE_PREPAID, ACT_UNDO_RECYCLE_PREPAID,
 	ACT_FETCH, ACT_VM_CHANGE_NIC, ACT_HOST_IMPORT_LIBVIRT_SERVERS,
 	ACT_GUEST_CREATE_FROM_IMPORT, ACT_DISK_CREATE_SNAPSHOT,
+	ACT_VM_PREEMPT, ACT_MIGRATE_STORAGE, ACT_DECOMMISSION,
 }
 
 const (
@@ -135,6 +136,8 @@ const (
 	ACT_LB_REMOVE_BACKEND            = "移除后端服务器"
 	ACT_LB_ADD_LISTENER_RULE         = "添加负载均衡转发规则"
 	ACT_LB_REMOVE_LISTENER_RULE      = "移除负载均衡转发规则"
+	ACT_LB_DRAIN_BACKEND             = "排空后端服务器"
+	ACT_LB_SHIFT_BACKEND_GROUP       = "切换后端服务器组流量"
 	ACT_DELETE_BACKUP                = "删除备份机"
 
 	ACT_IMAGE_SAVE = "上传镜像"
@@ -148,6 +151,15 @@ const (
 
 	ACT_HOST_IMPORT_LIBVIRT_SERVERS = "libvirt托管虚拟机导入"
 	ACT_GUEST_CREATE_FROM_IMPORT    = "导入虚拟机创建"
+
+	ACT_BM_DISK_WIPE = "擦除磁盘"
+
+	ACT_VM_CONVERT_TO_KVM = "转换为KVM虚拟机"
+
+	ACT_VM_PREEMPT = "抢占"
+
+	ACT_MIGRATE_STORAGE = "迁移存储"
+	ACT_DECOMMISSION    = "下线存储"
 )
 
 // golang 不支持 const 的string array, http://t.cn/EzAvbw8
@@ -0,0 +1,244 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lvmutils
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/onecloud/pkg/util/procutils"
+)
+
+const (
+	// ACTIVATE_EXCLUSIVE activates LV on one host only, required by thin
+	// volumes of a shared VG
+	ACTIVATE_EXCLUSIVE = "ey"
+	// ACTIVATE_SHARED activates LV on several hosts at the same time, for a
+	// local VG it is the same as plain activation
+	ACTIVATE_SHARED = "sy"
+	DEACTIVATE      = "n"
+
+	LOCK_TYPE_NONE = "none"
+
+	reportSeparator = "|"
+)
+
+type SVolumeGroup struct {
+	Name     string
+	SizeMb   int64
+	FreeMb   int64
+	LockType string
+}
+
+type SLogicalVolume struct {
+	Name   string
+	VgName string
+	Attr   string
+	SizeMb int64
+	PoolLv string
+	Tags   []string
+}
+
+// IsActive checks state bit of lv_attr, e.g. -wi-a-----
+func (lv *SLogicalVolume) IsActive() bool {
+	return len(lv.Attr) > 4 && lv.Attr[4] == 'a'
+}
+
+func (lv *SLogicalVolume) HasTag(tag string) bool {
+	for _, t := range lv.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func LvPath(vg, lv string) string {
+	return path.Join("/dev", vg, lv)
+}
+
+func run(name string, args ...string) ([]byte, error) {
+	output, err := procutils.NewCommand(name, args...).Run()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %s", name, strings.Join(args, " "), strings.TrimSpace(string(output)))
+	}
+	return output, nil
+}
+
+func reportArgs(fields string) []string {
+	return []string{"--noheadings", "--nosuffix", "--units", "m",
+		"--separator", reportSeparator, "-o", fields}
+}
+
+func parseSizeMb(s string) (int64, error) {
+	size, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(size), nil
+}
+
+func splitReport(output []byte) [][]string {
+	ret := [][]string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.Split(line, reportSeparator)
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		ret = append(ret, fields)
+	}
+	return ret
+}
+
+func parseVgs(output []byte) ([]SVolumeGroup, error) {
+	ret := []SVolumeGroup{}
+	for _, fields := range splitReport(output) {
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("invalid vgs output %q", strings.Join(fields, reportSeparator))
+		}
+		vg := SVolumeGroup{Name: fields[0], LockType: fields[3]}
+		var err error
+		if vg.SizeMb, err = parseSizeMb(fields[1]); err != nil {
+			return nil, err
+		}
+		if vg.FreeMb, err = parseSizeMb(fields[2]); err != nil {
+			return nil, err
+		}
+		if len(vg.LockType) == 0 {
+			vg.LockType = LOCK_TYPE_NONE
+		}
+		ret = append(ret, vg)
+	}
+	return ret, nil
+}
+
+func parseLvs(output []byte) ([]SLogicalVolume, error) {
+	ret := []SLogicalVolume{}
+	for _, fields := range splitReport(output) {
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("invalid lvs output %q", strings.Join(fields, reportSeparator))
+		}
+		lv := SLogicalVolume{Name: fields[0], VgName: fields[1], Attr: fields[2], PoolLv: fields[4]}
+		var err error
+		if lv.SizeMb, err = parseSizeMb(fields[3]); err != nil {
+			return nil, err
+		}
+		if len(fields[5]) > 0 {
+			lv.Tags = strings.Split(fields[5], ",")
+		}
+		ret = append(ret, lv)
+	}
+	return ret, nil
+}
+
+func GetVolumeGroup(vg string) (*SVolumeGroup, error) {
+	args := append(reportArgs("vg_name,vg_size,vg_free,vg_lock_type"), vg)
+	output, err := run("vgs", args...)
+	if err != nil {
+		return nil, err
+	}
+	vgs, err := parseVgs(output)
+	if err != nil {
+		return nil, err
+	}
+	if len(vgs) == 0 {
+		return nil, fmt.Errorf("volume group %s not found", vg)
+	}
+	return &vgs[0], nil
+}
+
+// StartLock joins lockspace of a shared VG, it must be done before any LV of
+// the VG is activated on this host
+func StartLock(vg string) error {
+	_, err := run("vgchange", "--lock-start", vg)
+	return err
+}
+
+func ListLogicalVolumes(vg string) ([]SLogicalVolume, error) {
+	args := append(reportArgs("lv_name,vg_name,lv_attr,lv_size,pool_lv,lv_tags"), vg)
+	output, err := run("lvs", args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseLvs(output)
+}
+
+func GetLogicalVolume(vg, lv string) (*SLogicalVolume, error) {
+	args := append(reportArgs("lv_name,vg_name,lv_attr,lv_size,pool_lv,lv_tags"), vg+"/"+lv)
+	output, err := run("lvs", args...)
+	if err != nil {
+		return nil, err
+	}
+	lvs, err := parseLvs(output)
+	if err != nil {
+		return nil, err
+	}
+	if len(lvs) == 0 {
+		return nil, fmt.Errorf("logical volume %s/%s not found", vg, lv)
+	}
+	return &lvs[0], nil
+}
+
+func tagArgs(tags []string) []string {
+	args := []string{}
+	for _, tag := range tags {
+		args = append(args, "--addtag", tag)
+	}
+	return args
+}
+
+// CreateLv creates a thick LV, new LV of a shared VG is activated exclusively
+func CreateLv(vg, lv string, sizeMb int64, tags ...string) error {
+	args := []string{"-y", "-W", "y", "-Z", "y", "-n", lv, "-L", fmt.Sprintf("%dm", sizeMb)}
+	args = append(args, tagArgs(tags)...)
+	_, err := run("lvcreate", append(args, vg)...)
+	return err
+}
+
+// CreateThinLv creates a thin LV of virtual size sizeMb in thin pool, only
+// written blocks take space of the pool
+func CreateThinLv(vg, pool, lv string, sizeMb int64, tags ...string) error {
+	args := []string{"-y", "-n", lv, "-V", fmt.Sprintf("%dm", sizeMb), "--thinpool", pool}
+	args = append(args, tagArgs(tags)...)
+	_, err := run("lvcreate", append(args, vg)...)
+	return err
+}
+
+func ExtendLv(vg, lv string, sizeMb int64) error {
+	_, err := run("lvextend", "-L", fmt.Sprintf("%dm", sizeMb), vg+"/"+lv)
+	return err
+}
+
+// RefreshLv reloads LV metadata, e.g. new size, on a host it is active on
+func RefreshLv(vg, lv string) error {
+	_, err := run("lvchange", "--refresh", vg+"/"+lv)
+	return err
+}
+
+func ChangeActivation(vg, lv, mode string) error {
+	_, err := run("lvchange", "-a"+mode, vg+"/"+lv)
+	return err
+}
+
+func RemoveLv(vg, lv string) error {
+	_, err := run("lvremove", "-f", vg+"/"+lv)
+	return err
+}
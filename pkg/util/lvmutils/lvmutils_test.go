@@ -0,0 +1,156 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lvmutils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/util/procutils"
+)
+
+func TestParseVgs(t *testing.T) {
+	output := "  shared_vg|10236.00|1020.50|sanlock\n  local_vg|512.00|512.00|\n"
+	vgs, err := parseVgs([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SVolumeGroup{
+		{Name: "shared_vg", SizeMb: 10236, FreeMb: 1020, LockType: "sanlock"},
+		{Name: "local_vg", SizeMb: 512, FreeMb: 512, LockType: LOCK_TYPE_NONE},
+	}
+	if len(vgs) != len(want) {
+		t.Fatalf("got %d vgs, want %d", len(vgs), len(want))
+	}
+	for i := range want {
+		if vgs[i] != want[i] {
+			t.Errorf("vg %d: got %#v, want %#v", i, vgs[i], want[i])
+		}
+	}
+	if _, err := parseVgs([]byte("  vg|10.00\n")); err == nil {
+		t.Errorf("short line should fail")
+	}
+}
+
+func TestParseLvs(t *testing.T) {
+	output := strings.Join([]string{
+		"  disk1|vg0|-wi-a-----|1024.00||",
+		"  snap_1|vg0|Vwi---tz--|1024.00|pool|disk_disk1,snapshot",
+		"  pool|vg0|twi---tz--|4096.00||",
+	}, "\n")
+	lvs, err := parseLvs([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lvs) != 3 {
+		t.Fatalf("got %d lvs, want 3", len(lvs))
+	}
+	if !lvs[0].IsActive() || lvs[1].IsActive() {
+		t.Errorf("wrong activation state %s %s", lvs[0].Attr, lvs[1].Attr)
+	}
+	if lvs[1].PoolLv != "pool" || !lvs[1].HasTag("disk_disk1") || lvs[0].HasTag("disk_disk1") {
+		t.Errorf("wrong thin lv %#v", lvs[1])
+	}
+	if lvs[2].SizeMb != 4096 {
+		t.Errorf("wrong size %d", lvs[2].SizeMb)
+	}
+}
+
+// TestLocalVgOnLoopDevice runs the lv helpers against a plain local VG on
+// a loop device, lvmlockd and shared VGs are not covered. It creates loop
+// devices and a VG on the running machine, set LVMUTILS_LOOP_TEST=1 to
+// enable it, it needs root, losetup and the lvm2 tools
+func TestLocalVgOnLoopDevice(t *testing.T) {
+	if len(os.Getenv("LVMUTILS_LOOP_TEST")) == 0 {
+		t.Skip("LVMUTILS_LOOP_TEST not set")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("loop device test needs root")
+	}
+	for _, tool := range []string{"losetup", "vgcreate", "lvcreate"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found", tool)
+		}
+	}
+	f, err := ioutil.TempFile("", "lvmutils")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if err := f.Truncate(256 * 1024 * 1024); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	output, err := procutils.NewCommand("losetup", "-f", "--show", f.Name()).Run()
+	if err != nil {
+		t.Fatalf("losetup: %s", output)
+	}
+	dev := strings.TrimSpace(string(output))
+	defer procutils.NewCommand("losetup", "-d", dev).Run()
+
+	vg := fmt.Sprintf("lvmutils_test_%d", os.Getpid())
+	if _, err := run("vgcreate", vg, dev); err != nil {
+		t.Fatal(err)
+	}
+	defer run("vgremove", "-f", vg)
+
+	if err := StartLock(vg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run("lvcreate", "-y", "-L", "64m", "-T", vg+"/pool"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateLv(vg, "disk", 32); err != nil {
+		t.Fatal(err)
+	}
+	if err := ChangeActivation(vg, "disk", ACTIVATE_SHARED); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExtendLv(vg, "disk", 48); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateThinLv(vg, "pool", "snap", 48, "disk_disk"); err != nil {
+		t.Fatal(err)
+	}
+	lvs, err := ListLogicalVolumes(vg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, lv := range lvs {
+		if lv.Name == "snap" && lv.PoolLv == "pool" && lv.HasTag("disk_disk") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("thin lv not found in %#v", lvs)
+	}
+	lv, err := GetLogicalVolume(vg, "disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lv.SizeMb != 48 || !lv.IsActive() {
+		t.Errorf("wrong lv %#v", lv)
+	}
+	for _, name := range []string{"snap", "disk"} {
+		if err := RemoveLv(vg, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovfutils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	RESOURCE_TYPE_CPU      = 3
+	RESOURCE_TYPE_MEMORY   = 4
+	RESOURCE_TYPE_ETHERNET = 10
+	RESOURCE_TYPE_DISK     = 17
+)
+
+type SOvfDisk struct {
+	DiskId string
+	// File is the path of disk file inside the OVA package
+	File string
+	// CapacityBytes is the virtual size of the disk
+	CapacityBytes int64
+}
+
+type SOvfNic struct {
+	Name    string
+	Network string
+	// Driver is the nic model translated to the one of KVM, e.g. virtio, e1000 or vmxnet3
+	Driver string
+}
+
+type SOvfInfo struct {
+	Name     string
+	OsType   string
+	OsDesc   string
+	CpuCount int
+	MemoryMb int
+	Disks    []SOvfDisk
+	Nics     []SOvfNic
+}
+
+type ovfFile struct {
+	Id   string `xml:"id,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type ovfDisk struct {
+	DiskId        string `xml:"diskId,attr"`
+	FileRef       string `xml:"fileRef,attr"`
+	Capacity      string `xml:"capacity,attr"`
+	CapacityUnits string `xml:"capacityAllocationUnits,attr"`
+}
+
+type ovfItem struct {
+	ElementName     string `xml:"ElementName"`
+	ResourceType    int    `xml:"ResourceType"`
+	ResourceSubType string `xml:"ResourceSubType"`
+	VirtualQuantity int64  `xml:"VirtualQuantity"`
+	AllocationUnits string `xml:"AllocationUnits"`
+	HostResource    string `xml:"HostResource"`
+	Connection      string `xml:"Connection"`
+}
+
+type ovfEnvelope struct {
+	XMLName xml.Name  `xml:"Envelope"`
+	Files   []ovfFile `xml:"References>File"`
+	Disks   []ovfDisk `xml:"DiskSection>Disk"`
+	System  struct {
+		Id   string `xml:"id,attr"`
+		Name string `xml:"Name"`
+		Os   struct {
+			Id          string `xml:"id,attr"`
+			OsType      string `xml:"osType,attr"`
+			Description string `xml:"Description"`
+		} `xml:"OperatingSystemSection"`
+		Items []ovfItem `xml:"VirtualHardwareSection>Item"`
+	} `xml:"VirtualSystem"`
+}
+
+var (
+	unitsRegexp = regexp.MustCompile(`^byte\s*\*\s*2\^\s*(\d+)$`)
+)
+
+// parseAllocationUnits returns the bytes of one unit, units are in form of
+// "byte * 2^20" as DMTF recommends, or legacy forms like "MegaBytes"
+func parseAllocationUnits(units string) (int64, error) {
+	units = strings.ToLower(strings.TrimSpace(units))
+	switch units {
+	case "", "byte", "bytes":
+		return 1, nil
+	case "kb", "kilobytes":
+		return 1 << 10, nil
+	case "mb", "megabytes":
+		return 1 << 20, nil
+	case "gb", "gigabytes":
+		return 1 << 30, nil
+	}
+	matches := unitsRegexp.FindStringSubmatch(units)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("unsupported allocation units %q", units)
+	}
+	exp, _ := strconv.Atoi(matches[1])
+	if exp >= 63 {
+		return 0, fmt.Errorf("allocation units %q overflow", units)
+	}
+	return int64(1) << uint(exp), nil
+}
+
+func nicDriver(subType string) string {
+	subType = strings.ToLower(subType)
+	switch {
+	case strings.Contains(subType, "vmxnet3"):
+		return "vmxnet3"
+	case strings.Contains(subType, "e1000"):
+		return "e1000"
+	case strings.Contains(subType, "virtio"):
+		return "virtio"
+	}
+	return ""
+}
+
+func normalizeOsType(osType, desc string) string {
+	for _, s := range []string{osType, desc} {
+		s = strings.ToLower(s)
+		if strings.Contains(s, "windows") || strings.HasPrefix(s, "win") {
+			return "Windows"
+		}
+	}
+	if len(osType) > 0 || len(desc) > 0 {
+		return "Linux"
+	}
+	return ""
+}
+
+func Parse(content string) (*SOvfInfo, error) {
+	return ParseStream(strings.NewReader(content))
+}
+
+func ParseStream(stream io.Reader) (*SOvfInfo, error) {
+	env := ovfEnvelope{}
+	err := xml.NewDecoder(stream).Decode(&env)
+	if err != nil {
+		return nil, fmt.Errorf("decode ovf descriptor: %s", err)
+	}
+
+	info := SOvfInfo{
+		Name:   env.System.Name,
+		OsDesc: env.System.Os.Description,
+		OsType: normalizeOsType(env.System.Os.OsType, env.System.Os.Description),
+	}
+	if len(info.Name) == 0 {
+		info.Name = env.System.Id
+	}
+
+	files := make(map[string]string)
+	for _, f := range env.Files {
+		files[f.Id] = f.Href
+	}
+	disks := make(map[string]SOvfDisk)
+	for _, d := range env.Disks {
+		href, ok := files[d.FileRef]
+		if !ok {
+			return nil, fmt.Errorf("disk %s refers to unknown file %s", d.DiskId, d.FileRef)
+		}
+		disk := SOvfDisk{DiskId: d.DiskId, File: href}
+		if len(d.Capacity) > 0 {
+			capacity, err := strconv.ParseInt(d.Capacity, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid capacity %q of disk %s", d.Capacity, d.DiskId)
+			}
+			unit, err := parseAllocationUnits(d.CapacityUnits)
+			if err != nil {
+				return nil, err
+			}
+			disk.CapacityBytes = capacity * unit
+		}
+		disks[d.DiskId] = disk
+	}
+
+	// disks are ordered as they are attached in the hardware section, disks
+	// not attached are appended in order of the disk section
+	attached := make(map[string]bool)
+	for _, item := range env.System.Items {
+		switch item.ResourceType {
+		case RESOURCE_TYPE_CPU:
+			info.CpuCount = int(item.VirtualQuantity)
+		case RESOURCE_TYPE_MEMORY:
+			unit, err := parseAllocationUnits(item.AllocationUnits)
+			if err != nil {
+				return nil, err
+			}
+			info.MemoryMb = int(math.Ceil(float64(item.VirtualQuantity*unit) / 1024 / 1024))
+		case RESOURCE_TYPE_ETHERNET:
+			info.Nics = append(info.Nics, SOvfNic{
+				Name:    item.ElementName,
+				Network: item.Connection,
+				Driver:  nicDriver(item.ResourceSubType),
+			})
+		case RESOURCE_TYPE_DISK:
+			// HostResource is like ovf:/disk/vmdisk1
+			diskId := item.HostResource[strings.LastIndex(item.HostResource, "/")+1:]
+			if disk, ok := disks[diskId]; ok && !attached[diskId] {
+				info.Disks = append(info.Disks, disk)
+				attached[diskId] = true
+			}
+		}
+	}
+	for _, d := range env.Disks {
+		if !attached[d.DiskId] {
+			info.Disks = append(info.Disks, disks[d.DiskId])
+		}
+	}
+	return &info, nil
+}
@@ -89,6 +89,9 @@ func PrintJSONList(list *modules.ListResult, columns []string) {
 		title = fmt.Sprintf("%s Pages: %d Limit: %d Offset: %d Page: %d",
 			title, pages, list.Limit, list.Offset, page)
 	}
+	if len(list.NextMarker) > 0 {
+		title = fmt.Sprintf("%s NextMarker: %s", title, list.NextMarker)
+	}
 	fmt.Println("*** ", title, " ***")
 }
 
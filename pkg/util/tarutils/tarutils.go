@@ -15,8 +15,12 @@
 package tarutils
 
 import (
+	"archive/tar"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"yunion.io/x/log"
 
@@ -38,3 +42,59 @@ func TarSparseFile(origin, tar string) error {
 	}
 	return nil
 }
+
+// UntarFile extracts regular files and directories of tar archive into
+// destDir, entries escaping destDir are refused
+func UntarFile(tarPath, destDir string) error {
+	fp, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	return Untar(fp, destDir)
+}
+
+func Untar(reader io.Reader, destDir string) error {
+	destDir, err := filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		target := filepath.Join(destDir, hdr.Name)
+		if target != destDir && !strings.HasPrefix(target, destDir+string(os.PathSeparator)) {
+			return fmt.Errorf("tar entry %s is outside of %s", hdr.Name, destDir)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := untarFile(tr, target, hdr.Mode); err != nil {
+				return err
+			}
+		default:
+			log.Warningf("skip tar entry %s of type %c", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+func untarFile(reader io.Reader, target string, mode int64) error {
+	fp, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(mode)&os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	_, err = io.Copy(fp, reader)
+	return err
+}
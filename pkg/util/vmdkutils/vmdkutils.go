@@ -35,6 +35,17 @@ type SVMDKInfo struct {
 	UUID             string
 	AdapterType      string
 	VirtualHWVersion string
+
+	// ExtentType is VMFS for flat extent, SESPARSE for delta of snapshot
+	ExtentType string
+	// ParentFileNameHint is set when the disk is delta of a snapshot
+	ParentFileNameHint string
+}
+
+// IsFlat tells whether data of the disk is fully in its flat extent, i.e. the
+// disk has no snapshot
+func (info SVMDKInfo) IsFlat() bool {
+	return info.ExtentType == "VMFS" && len(info.ParentFileNameHint) == 0
 }
 
 func (info SVMDKInfo) Size() int64 {
@@ -64,6 +75,7 @@ func ParseStream(stream io.Reader) (*SVMDKInfo, error) {
 		matches := extentPatternRegexp.FindStringSubmatch(line)
 		if len(matches) > 0 {
 			// log.Debugf("%#v", matches)
+			info.ExtentType = matches[1]
 			info.ExtentFile = matches[2]
 			findExtent = true
 		} else {
@@ -88,6 +100,8 @@ func ParseStream(stream io.Reader) (*SVMDKInfo, error) {
 					info.AdapterType = value
 				case "ddb.virtualHWVersion":
 					info.VirtualHWVersion = value
+				case "parentFileNameHint":
+					info.ParentFileNameHint = value
 				}
 			}
 		}
@@ -40,6 +40,19 @@ const (
     ddb.uuid = "60 00 C2 97 32 7d ac 2b-ac 97 a4 95 5b a6 0b c0"
     ddb.virtualHWVersion = "13"
 `
+
+	SnapshotVMDKContent = `version=3
+    encoding="UTF-8"
+    CID=5c6e1a2b
+    parentCID=ab798ecb
+    createType="seSparse"
+    parentFileNameHint="c8336ec2-3885-4205-a2f1-095e48228a56.vmdk"
+
+    # Extent description
+    RW 62914560 SESPARSE "c8336ec2-3885-4205-a2f1-095e48228a56-000001-sesparse.vmdk"
+
+    ddb.longContentID = "8f0d1e4c3a5b6c7d8e9f0a1b5c6e1a2b"
+`
 )
 
 func TestParseStream(t *testing.T) {
@@ -48,6 +61,17 @@ func TestParseStream(t *testing.T) {
 		t.Errorf("parse error %s", err)
 	}
 	t.Logf("%#v", info)
+	if !info.IsFlat() || info.ExtentFile != "c8336ec2-3885-4205-a2f1-095e48228a56-flat.vmdk" {
+		t.Errorf("flat disk parsed as %#v", info)
+	}
+
+	info, err = Parse(SnapshotVMDKContent)
+	if err != nil {
+		t.Fatalf("parse snapshot error %s", err)
+	}
+	if info.IsFlat() || info.ExtentType != "SESPARSE" || info.ParentFileNameHint != "c8336ec2-3885-4205-a2f1-095e48228a56.vmdk" {
+		t.Errorf("snapshot disk parsed as %#v", info)
+	}
 
 	_, err = Parse("")
 	if err != nil {
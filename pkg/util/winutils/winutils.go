@@ -714,6 +714,54 @@ func (w *SWinRegTool) installGpeditStartScript(script, scriptPath string) {
 	}
 }
 
+// InstallVirtioStorDriver registers viostor as a boot start driver of virtio
+// block devices, as windows does not probe a new storage controller of the
+// root disk on boot.  viostor.sys must be put in system32\drivers
+func (w *SWinRegTool) InstallVirtioStorDriver() bool {
+	ccs := w.GetCcsKeyPath()
+	svc := ccs + `\Services\viostor`
+	kvts := [][3]string{
+		{svc + `\Type`, "1", "REG_DWORD"},
+		{svc + `\Start`, "0", "REG_DWORD"},
+		{svc + `\ErrorControl`, "1", "REG_DWORD"},
+		{svc + `\Group`, "SCSI miniport", "REG_SZ"},
+		{svc + `\ImagePath`, `system32\drivers\viostor.sys`, "REG_EXPAND_SZ"},
+	}
+	for _, devId := range []string{
+		`pci#ven_1af4&dev_1001&subsys_00021af4&rev_00`,
+		`pci#ven_1af4&dev_1042&subsys_11001af4&rev_01`,
+	} {
+		cdd := ccs + `\Control\CriticalDeviceDatabase\` + devId
+		kvts = append(kvts,
+			[3]string{cdd + `\Service`, "viostor", "REG_SZ"},
+			[3]string{cdd + `\ClassGUID`, "{4D36E97B-E325-11CE-BFC1-08002BE10318}", "REG_SZ"},
+		)
+	}
+	for _, kvt := range kvts {
+		if !w.SetRegistry(kvt[0], kvt[1], kvt[2]) {
+			log.Errorf("set registry %s failed", kvt[0])
+			return false
+		}
+	}
+	return true
+}
+
+// AddDevicePath appends dir to the paths searched for drivers by plug and
+// play, so drivers of other virtio devices are installed on first boot
+func (w *SWinRegTool) AddDevicePath(dir string) bool {
+	key := `HKLM\SOFTWARE\Microsoft\Windows\CurrentVersion\DevicePath`
+	paths := w.GetRegistry(key)
+	for _, p := range strings.Split(paths, ";") {
+		if strings.EqualFold(p, dir) {
+			return true
+		}
+	}
+	if len(paths) > 0 {
+		paths += ";"
+	}
+	return w.SetRegistry(key, paths+dir, "REG_EXPAND_SZ")
+}
+
 func (w *SWinRegTool) EnableRdp() {
 	key := w.GetCcsKeyPath() + `\Control\Terminal Server\fDenyTSConnections`
 	w.SetRegistry(key, "0", `REG_DWORD`)